@@ -0,0 +1,220 @@
+package jdb
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type (
+	// Faker returns the fake a value is replaced with by Anonymize, drawing
+	// from rng only so equal values get equal fakes. Returning false drops
+	// the field
+	Faker func(value interface{}, rng *mathrand.Rand) (interface{}, bool)
+
+	// AnonymizeOptions configures Anonymize
+	AnonymizeOptions struct {
+		// Fields maps the dotted fields to anonymize to their Faker
+		Fields map[string]Faker
+
+		// IDs fakes the IDs of the records too when set. Fields referring
+		// to them should use the same Faker so they keep matching
+		IDs Faker
+
+		// Key seeds the fakes: runs sharing a key map equal values to equal
+		// fakes, keeping references between collections intact. A random
+		// key is used when it's empty
+		Key []byte
+	}
+)
+
+var (
+	fakeFirstNames = []string{"Alex", "Blake", "Casey", "Dana", "Eli", "Frances", "Gale", "Harper", "Indra", "Jules", "Kai", "Logan", "Morgan", "Noa", "Oakley", "Parker", "Quinn", "Riley", "Sam", "Taylor"}
+	fakeLastNames  = []string{"Adler", "Brooks", "Castillo", "Dubois", "Eriksen", "Fischer", "Garcia", "Hughes", "Ito", "Jensen", "Kowalski", "Larsen", "Moreau", "Novak", "Okafor", "Petrov", "Rossi", "Silva", "Tanaka", "Weber"}
+)
+
+// Anonymize copies the records of src to dst with the fields of the options
+// replaced by fakes, so developers get data shaped like production without
+// its sensitive values. Fakes are derived from the values and the key, so
+// a value appearing in several records or collections gets the same fake
+// everywhere. It returns how many records were copied
+func (d *Driver) Anonymize(src, dst string, opts AnonymizeOptions) (int, error) {
+	if src == dst {
+		return 0, fmt.Errorf("anonymizing %s into itself", src)
+	}
+
+	if err := ValidateCollection(dst); err != nil {
+		return 0, err
+	}
+
+	key := opts.Key
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return 0, err
+		}
+	}
+
+	fields := make([]string, 0, len(opts.Fields))
+	for field := range opts.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	faked := make(map[string]string)
+	count := 0
+
+	err := d.ForEach(src, func(ID string, raw []byte) error {
+		v, err := decodeJSON(raw)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", src, ID, err)
+		}
+
+		doc, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s/%s is not an object", src, ID)
+		}
+
+		for _, field := range fields {
+			value, ok := getField(doc, field)
+			if !ok {
+				continue
+			}
+
+			if value, ok = fake(opts.Fields[field], value, key); ok {
+				err = setField(doc, field, value)
+			} else {
+				err = dropField(doc, field)
+			}
+
+			if err != nil {
+				return fmt.Errorf("%s/%s: anonymizing %s: %w", src, ID, field, err)
+			}
+		}
+
+		newID := ID
+		if opts.IDs != nil {
+			if newID, err = fakeID(opts.IDs, ID, key); err != nil {
+				return fmt.Errorf("%s/%s: %w", src, ID, err)
+			}
+
+			if other, ok := faked[newID]; ok {
+				return fmt.Errorf("%s/%s: fake ID %s is already the one of %s", src, ID, newID, other)
+			}
+
+			faked[newID] = ID
+		}
+
+		if _, err := d.Write(dst, newID, doc); err != nil {
+			return err
+		}
+
+		count++
+		return nil
+	})
+
+	return count, err
+}
+
+// fake runs the Faker with a generator seeded by the value and the key
+func fake(f Faker, value interface{}, key []byte) (interface{}, bool) {
+	canonical, _ := valueKey(value)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(canonical))
+	seed := binary.BigEndian.Uint64(mac.Sum(nil))
+
+	return f(value, mathrand.New(mathrand.NewSource(int64(seed))))
+}
+
+func fakeID(f Faker, ID string, key []byte) (string, error) {
+	v, ok := fake(f, ID, key)
+	if !ok {
+		return "", fmt.Errorf("no fake for the ID")
+	}
+
+	newID, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("fake ID is %s, not a string", typeOf(v))
+	}
+
+	return newID, ValidateID(newID)
+}
+
+// FakeString is a Faker keeping the format of strings and numbers: digits
+// are replaced by digits, hexadecimal letters by hexadecimal letters, other
+// letters by letters of the same case and the rest is kept, so UUIDs, card
+// numbers or postcodes stay valid looking. Numbers keep their sign, decimal
+// point and number of digits. Other values are dropped
+func FakeString(value interface{}, rng *mathrand.Rand) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		return fakeChars(v, rng), true
+	case json.Number:
+		s := fakeChars(string(v), rng)
+		if i := strings.IndexFunc(s, unicode.IsDigit); i >= 0 && s[i] == '0' && i+1 < len(s) && unicode.IsDigit(rune(s[i+1])) {
+			s = s[:i] + "1" + s[i+1:]
+		}
+
+		return json.Number(s), true
+	}
+
+	return nil, false
+}
+
+func fakeChars(s string, rng *mathrand.Rand) string {
+	runes := []rune(s)
+
+	for i, r := range runes {
+		switch {
+		case r >= '0' && r <= '9':
+			runes[i] = '0' + rune(rng.Intn(10))
+		case r >= 'a' && r <= 'f':
+			runes[i] = 'a' + rune(rng.Intn(6))
+		case r >= 'A' && r <= 'F':
+			runes[i] = 'A' + rune(rng.Intn(6))
+		case unicode.IsLower(r):
+			runes[i] = 'a' + rune(rng.Intn(26))
+		case unicode.IsUpper(r):
+			runes[i] = 'A' + rune(rng.Intn(26))
+		}
+	}
+
+	return string(runes)
+}
+
+// FakeName is a Faker replacing strings with a first name, followed by a
+// last name when the value had several words. Other values are dropped
+func FakeName(value interface{}, rng *mathrand.Rand) (interface{}, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+
+	name := fakeFirstNames[rng.Intn(len(fakeFirstNames))]
+	if len(strings.Fields(s)) > 1 {
+		name += " " + fakeLastNames[rng.Intn(len(fakeLastNames))]
+	}
+
+	return name, true
+}
+
+// FakeEmail is a Faker replacing email addresses with ones made of a fake
+// name and a number at example.com. Other values are dropped
+func FakeEmail(value interface{}, rng *mathrand.Rand) (interface{}, bool) {
+	if _, ok := value.(string); !ok {
+		return nil, false
+	}
+
+	first := fakeFirstNames[rng.Intn(len(fakeFirstNames))]
+	last := fakeLastNames[rng.Intn(len(fakeLastNames))]
+
+	return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), rng.Intn(1000)), true
+}
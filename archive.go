@@ -0,0 +1,194 @@
+package jdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveDir is the reserved directory holding compressed cold records
+const archiveDir = "_archive"
+
+func (d *Driver) archivePath(collection, ID string) string {
+	return filepath.Join(d.dir, archiveDir, collection, ID+".json.gz")
+}
+
+// SetArchivePolicy makes ApplyArchivePolicies move the records of the
+// collection not written for maxAge to the archive, zero removes the policy
+func (d *Driver) SetArchivePolicy(collection string, maxAge time.Duration) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.archiveAfter = maxAge
+	})
+}
+
+// ApplyArchivePolicies archives the records of every collection with a
+// policy, returning how many were moved
+func (d *Driver) ApplyArchivePolicies() (int, error) {
+	collections := d.configured()
+	sort.Strings(collections)
+
+	total := 0
+
+	for _, collection := range collections {
+		maxAge := d.config(collection).archiveAfter
+		if maxAge <= 0 {
+			continue
+		}
+
+		n, err := d.Archive(collection, maxAge)
+		total += n
+		if err != nil && !os.IsNotExist(err) {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Archive moves the records of the collection not written for olderThan to a
+// compressed archive area, keeping the collection directory small. Archived
+// records are still returned by Read and ReadInto, but not by ReadAll, List
+// or the indexes
+func (d *Driver) Archive(collection string, olderThan time.Duration) (int, error) {
+	if collection == "" {
+		return 0, fmt.Errorf("missing collection, nothing to archive")
+	}
+
+	done, err := d.admitLocal(collection)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.listRecords(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := d.clock.Now().Add(-olderThan)
+	hot := filepath.Join(d.dir, collection) + string(filepath.Separator)
+	count := 0
+
+	for _, file := range files {
+		// records of read-only directories can't be moved
+		if !strings.HasPrefix(file.path, hot) || !file.info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		if err := d.archiveRecord(collection, file); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	if count > 0 {
+		d.log.Info("archived %d records of %s", count, collection)
+	}
+
+	return count, nil
+}
+
+// archiveRecord compresses the record into the archive and removes it from
+// the collection, callers must hold the collection lock. It's sealed again
+// so records written before the encryption key was set are encrypted there
+func (d *Driver) archiveRecord(collection string, file record) error {
+	var b []byte
+
+	err := d.readFile(collection, file.path, func(doc []byte) error {
+		var err error
+		b, err = d.seal(collection, doc)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	path := d.archivePath(collection, file.ID)
+
+	if err := d.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if err := d.fs.WriteFile(path+".tmp", buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	if err := d.fs.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	d.expectChange(file.path)
+	if err := d.fs.Remove(file.path); err != nil {
+		d.expectedChange(file.path)
+		return err
+	}
+
+	d.handles.evict(file.path)
+
+	d.unindexRecord(collection, file.ID)
+
+	return nil
+}
+
+// readArchived hands an archived record to fn
+func (d *Driver) readArchived(collection, ID string, fn func([]byte) error) error {
+	b, err := d.fs.ReadFile(d.archivePath(collection, ID))
+	if err != nil {
+		return err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	if b, err = ioutil.ReadAll(zr); err != nil {
+		return err
+	}
+
+	return d.decrypted(collection, fn)(b)
+}
+
+func (d *Driver) archived(collection, ID string) bool {
+	_, err := d.fs.Stat(d.archivePath(collection, ID))
+	return err == nil
+}
+
+// archivedIDs returns the IDs archived for the collection
+func (d *Driver) archivedIDs(collection string) ([]string, error) {
+	files, err := d.fs.ReadDir(filepath.Join(d.dir, archiveDir, collection))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var IDs []string
+	for _, file := range files {
+		if name := file.Name(); strings.HasSuffix(name, ".json.gz") {
+			IDs = append(IDs, strings.TrimSuffix(name, ".json.gz"))
+		}
+	}
+
+	return IDs, nil
+}
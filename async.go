@@ -0,0 +1,116 @@
+package jdb
+
+import (
+	"fmt"
+	"sync"
+)
+
+// defaultAsyncWorkers is the size of the WriteAsync pool when
+// Options.AsyncWorkers isn't set
+const defaultAsyncWorkers = 4
+
+// asyncQueuePerWorker is how many writes WriteAsync queues per worker
+const asyncQueuePerWorker = 64
+
+type (
+	// asyncPool runs the writes of WriteAsync
+	asyncPool struct {
+		mutex   sync.RWMutex
+		workers int
+		queue   chan asyncWrite
+		closed  bool
+		started sync.Once
+		wg      sync.WaitGroup
+	}
+
+	asyncWrite struct {
+		collection, ID string
+		v              interface{}
+		done           chan error
+	}
+)
+
+// WriteAsync queues the write of v under the ID and returns at once, the
+// channel receiving the outcome of the write once it's on disk. v must not
+// be changed until then. Writes are run by a pool of Options.AsyncWorkers
+// goroutines, queued writes are finished by Close. The queue holds 64 writes
+// per worker, writes beyond it fail at once with ErrOverloaded. Writes to
+// collections coalescing writes are held rather than queued, see SetCoalesce
+func (d *Driver) WriteAsync(collection, identifier string, v interface{}) <-chan error {
+	done := make(chan error, 1)
+
+	switch {
+	case collection == "":
+		done <- fmt.Errorf("missing collection, no place to save data")
+	case identifier == "":
+		done <- fmt.Errorf("missing identifier")
+	default:
+		if err := validateRecord(collection, identifier); err != nil {
+			done <- err
+			break
+		}
+
+		if interval := d.config(collection).coalesce; interval > 0 {
+			return d.coalesce(collection, identifier, v, interval)
+		}
+
+		d.async.submit(d, asyncWrite{collection: collection, ID: identifier, v: v, done: done})
+	}
+
+	return done
+}
+
+func (p *asyncPool) submit(d *Driver, w asyncWrite) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.closed {
+		w.done <- ErrClosed
+		return
+	}
+
+	p.started.Do(func() {
+		for i := 0; i < p.workers; i++ {
+			p.wg.Add(1)
+			go p.work(d)
+		}
+	})
+
+	select {
+	case p.queue <- w:
+	default:
+		w.done <- fmt.Errorf("%d async writes queued: %w", cap(p.queue), ErrOverloaded)
+	}
+}
+
+func (p *asyncPool) work(d *Driver) {
+	defer p.wg.Done()
+
+	for w := range p.queue {
+		_, err := d.Write(w.collection, w.ID, w.v)
+		w.done <- err
+	}
+}
+
+// close refuses new writes and waits for the queued ones
+func (p *asyncPool) close() {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+
+	p.closed = true
+	close(p.queue)
+	p.mutex.Unlock()
+
+	p.wg.Wait()
+}
+
+func newAsyncPool(workers int) *asyncPool {
+	if workers <= 0 {
+		workers = defaultAsyncWorkers
+	}
+
+	return &asyncPool{workers: workers, queue: make(chan asyncWrite, workers*asyncQueuePerWorker)}
+}
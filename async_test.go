@@ -0,0 +1,48 @@
+package jdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestWriteAsyncRefusesWritesOverTheQueue(t *testing.T) {
+	d := jdbtest.New(t, jdb.WithAsyncWorkers(1), jdb.WithFreezeTimeout(-1))
+
+	// frozen writes hold the worker, the queue fills up behind it
+	d.Freeze()
+
+	var results []<-chan error
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		results = append(results, d.WriteAsync("users", fmt.Sprintf("u-%d", i), i))
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WriteAsync blocked for %s", elapsed)
+	}
+
+	d.Thaw()
+
+	written, overloaded := 0, 0
+
+	for _, done := range results {
+		switch err := <-done; {
+		case err == nil:
+			written++
+		case errors.Is(err, jdb.ErrOverloaded):
+			overloaded++
+		default:
+			t.Errorf("async write failed: %s", err)
+		}
+	}
+
+	if overloaded == 0 || written+overloaded != 100 {
+		t.Errorf("%d writes written and %d overloaded, want some refused", written, overloaded)
+	}
+}
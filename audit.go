@@ -0,0 +1,178 @@
+package jdb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// auditDir is the reserved directory holding the hash chains of append-only
+// collections
+const auditDir = "_audit"
+
+func (d *Driver) chainPath(collection string) string {
+	return filepath.Join(d.dir, auditDir, collection+".chain")
+}
+
+// SetAppendOnly makes the collection refuse to overwrite, update or delete
+// records. With hashChain every record written is chained to the previous
+// one by its SHA-256 so VerifyChain can tell when files were tampered with
+func (d *Driver) SetAppendOnly(collection string, hashChain bool) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.appendOnly = true
+		c.hashChain = hashChain
+	})
+}
+
+// checkAppendOnly fails when the record exists in an append-only collection,
+// callers must hold the collection lock
+func (d *Driver) checkAppendOnly(collection, ID string) error {
+	if !d.config(collection).appendOnly {
+		return nil
+	}
+
+	if _, err := d.locate(collection, ID); err == nil || d.archived(collection, ID) {
+		return fmt.Errorf("%s/%s: %w", collection, ID, ErrAppendOnly)
+	}
+
+	return nil
+}
+
+// chain appends the record to the hash chain of the collection, callers must
+// hold the collection lock
+func (d *Driver) chain(collection, ID string, doc []byte) error {
+	if !d.config(collection).hashChain {
+		return nil
+	}
+
+	d.mutex.Lock()
+	prev, ok := d.chainHeads[collection]
+	d.mutex.Unlock()
+
+	if !ok {
+		links, err := d.readChain(collection)
+		if err != nil {
+			return err
+		}
+
+		if len(links) > 0 {
+			prev = links[len(links)-1].hash
+		}
+	}
+
+	path := d.chainPath(collection)
+	if err := d.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	hash := chainHash(prev, ID, doc)
+
+	if err := d.fs.AppendFile(path, []byte(ID+" "+hash+"\n"), 0644); err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	d.chainHeads[collection] = hash
+	d.mutex.Unlock()
+
+	return nil
+}
+
+type chainLink struct {
+	ID   string
+	hash string
+}
+
+func (d *Driver) readChain(collection string) ([]chainLink, error) {
+	b, err := d.fs.ReadFile(d.chainPath(collection))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var links []chainLink
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		sep := strings.LastIndexByte(line, ' ')
+		if sep < 0 {
+			return nil, fmt.Errorf("malformed chain entry %q: %w", line, ErrChainBroken)
+		}
+
+		links = append(links, chainLink{ID: line[:sep], hash: line[sep+1:]})
+	}
+
+	return links, scanner.Err()
+}
+
+func chainHash(prev, ID string, doc []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write([]byte(ID + "\n"))
+	h.Write(doc)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyChain recomputes the hash chain of an append-only collection,
+// returning an error wrapping ErrChainBroken naming the first record that was
+// changed, removed or added outside of the chain
+func (d *Driver) VerifyChain(collection string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, nothing to verify")
+	}
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	links, err := d.readChain(collection)
+	if err != nil {
+		return err
+	}
+
+	chained := make(map[string]bool, len(links))
+	prev := ""
+
+	for _, link := range links {
+		var hash string
+
+		err := d.load(collection, link.ID, func(b []byte) error {
+			hash = chainHash(prev, link.ID, b)
+			return nil
+		})
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s/%s was removed: %w", collection, link.ID, ErrChainBroken)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if hash != link.hash {
+			return fmt.Errorf("%s/%s was modified: %w", collection, link.ID, ErrChainBroken)
+		}
+
+		chained[link.ID] = true
+		prev = link.hash
+	}
+
+	files, err := d.listRecords(collection)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, file := range files {
+		if !chained[file.ID] {
+			return fmt.Errorf("%s/%s is not in the chain: %w", collection, file.ID, ErrChainBroken)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package jdb_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestVerifyChainWithBloomFilter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	d := jdbtest.Open(t, dir, jdb.WithBloomFilter(16))
+	d.SetAppendOnly("ledger", true)
+
+	for _, ID := range []string{"a", "b", "c"} {
+		if _, err := d.Write("ledger", ID, map[string]string{"ID": ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- d.VerifyChain("ledger") }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("verifying an intact chain: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("VerifyChain deadlocked with the bloom filter enabled")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "ledger", "b.json"), []byte(`{"ID":"x"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.VerifyChain("ledger"); !errors.Is(err, jdb.ErrChainBroken) {
+		t.Errorf("verifying a tampered chain returned %v, want ErrChainBroken", err)
+	}
+}
@@ -0,0 +1,118 @@
+package jdb
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupEntry is a line of a backup, backups are newline delimited JSON with
+// one record per line
+type BackupEntry struct {
+	Collection string          `json:"collection"`
+	ID         string          `json:"id"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Backup writes every record of the database to w, see BackupSince
+func (d *Driver) Backup(w io.Writer) (uint64, error) {
+	return d.BackupSince(0, w)
+}
+
+// backupSkew is how long before its start a backup takes its seq, file
+// modification times coming from a coarser clock than Options.Clock. Records
+// written meanwhile are backed up twice, which Restore doesn't mind
+const backupSkew = time.Second
+
+// BackupSince writes the records changed after seq to w and returns the seq
+// to pass to the next call, so each backup only holds what changed since the
+// previous one. A seq is taken when the backup starts, records written while
+// it runs being in the next one, zero backs everything up. The KV, the queues
+// and archived records are backed up too, archived records being restored
+// as live ones. Deletions aren't recorded, a full backup is needed to drop
+// deleted records
+func (d *Driver) BackupSince(seq uint64, w io.Writer) (uint64, error) {
+	next := uint64(d.clock.Now().Add(-backupSkew).UnixNano())
+
+	collections, err := d.collections()
+	if err != nil {
+		return seq, err
+	}
+
+	stores, err := d.stores()
+	if err != nil {
+		return seq, err
+	}
+
+	collections = append(collections, stores...)
+
+	total, err := d.countRecords(collections)
+	if err != nil {
+		return seq, err
+	}
+
+	enc := json.NewEncoder(w)
+	done := 0
+
+	for _, c := range collections {
+		if err := d.backupCollection(c, seq, enc, &done, total); err != nil {
+			return seq, err
+		}
+	}
+
+	return next, nil
+}
+
+func (d *Driver) backupCollection(collection string, seq uint64, enc *json.Encoder, done *int, total int) error {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// archived first so live records win when both are restored
+	archived, err := d.fs.ReadDir(filepath.Join(d.dir, archiveDir, collection))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, file := range archived {
+		name := file.Name()
+		if !strings.HasSuffix(name, ".json.gz") || uint64(file.ModTime().UnixNano()) < seq {
+			continue
+		}
+
+		ID := strings.TrimSuffix(name, ".json.gz")
+
+		err := d.readArchived(collection, ID, func(b []byte) error {
+			return enc.Encode(BackupEntry{Collection: collection, ID: ID, Data: b})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	records, err := d.listRecords(collection)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		*done++
+		d.progress(Progress{Op: "backup", Collection: collection, Done: *done, Total: total})
+
+		if uint64(r.info.ModTime().UnixNano()) < seq {
+			continue
+		}
+
+		err := d.readFile(collection, r.path, func(b []byte) error {
+			return enc.Encode(BackupEntry{Collection: collection, ID: r.ID, Data: b})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
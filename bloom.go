@@ -0,0 +1,161 @@
+package jdb
+
+import (
+	"hash/fnv"
+	"math"
+	"os"
+	"sync/atomic"
+)
+
+// bloomFalsePositive is the false positive rate the filters are sized for
+const bloomFalsePositive = 0.01
+
+// bloom is a fixed size bloom filter over record IDs, it grows by being
+// rebuilt once more IDs than its capacity have been added. Its bits are set
+// and read atomically so lookups take no lock and writes to different
+// stripes of a collection can add IDs at once
+type bloom struct {
+	// count is first to be 64 bit aligned for atomic ops on 32 bit platforms
+	count    int64
+	bits     []uint64
+	k        uint64
+	capacity int64
+}
+
+func newBloom(capacity int) *bloom {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	m := math.Ceil(-float64(capacity) * math.Log(bloomFalsePositive) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(capacity)*math.Ln2))
+
+	return &bloom{
+		bits:     make([]uint64, (uint64(m)+63)/64),
+		k:        uint64(k),
+		capacity: int64(capacity),
+	}
+}
+
+// hashes uses double hashing over a single 64 bit fnv sum
+func (b *bloom) hashes(id string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	sum := h.Sum64()
+
+	return sum & 0xffffffff, sum>>32 | 1
+}
+
+func (b *bloom) add(id string) {
+	h1, h2 := b.hashes(id)
+	m := uint64(len(b.bits) * 64)
+
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		word, mask := &b.bits[bit/64], uint64(1)<<(bit%64)
+
+		for {
+			old := atomic.LoadUint64(word)
+			if old&mask != 0 || atomic.CompareAndSwapUint64(word, old, old|mask) {
+				break
+			}
+		}
+	}
+
+	atomic.AddInt64(&b.count, 1)
+}
+
+func (b *bloom) has(id string) bool {
+	h1, h2 := b.hashes(id)
+	m := uint64(len(b.bits) * 64)
+
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		if atomic.LoadUint64(&b.bits[bit/64])&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (b *bloom) full() bool {
+	return atomic.LoadInt64(&b.count) > b.capacity
+}
+
+// mayExist reports whether the record could be on disk, false is definitive
+// while true still needs to be confirmed against the filesystem
+func (d *Driver) mayExist(collection, ID string) bool {
+	if d.bloomSize <= 0 {
+		return true
+	}
+
+	b := d.bloom(collection)
+
+	if b == nil || b.full() {
+		var err error
+		if b, err = d.buildBloom(collection); err != nil {
+			d.log.Debug("unable to build bloom filter for %s: %s", collection, err)
+			return true
+		}
+	}
+
+	return b.has(ID)
+}
+
+// buildBloom loads every ID of the collection into a fresh filter, it holds
+// the collection lock so no write can slip in between listing and install
+func (d *Driver) buildBloom(collection string) (*bloom, error) {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.listRecords(collection)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	capacity := d.bloomSize
+	for capacity < len(files)*2 {
+		capacity *= 2
+	}
+
+	archived, err := d.archivedIDs(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	b := newBloom(capacity)
+	for _, file := range files {
+		b.add(file.ID)
+	}
+
+	for _, ID := range archived {
+		b.add(ID)
+	}
+
+	d.blooms.Store(collection, b)
+
+	return b, nil
+}
+
+// bloom returns the filter of the collection, nil until it's built
+func (d *Driver) bloom(collection string) *bloom {
+	if b, ok := d.blooms.Load(collection); ok {
+		return b.(*bloom)
+	}
+
+	return nil
+}
+
+// addBloom records a written ID, callers must hold the collection lock or
+// its shared mutex
+func (d *Driver) addBloom(collection, ID string) {
+	if d.bloomSize <= 0 {
+		return
+	}
+
+	if b := d.bloom(collection); b != nil {
+		b.add(ID)
+	}
+}
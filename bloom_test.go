@@ -0,0 +1,48 @@
+package jdb_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestBloomFilterUnderConcurrentReadsAndWrites(t *testing.T) {
+	d := jdbtest.New(t, jdb.WithBloomFilter(16), jdb.WithLockStripes(8))
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				ID := fmt.Sprintf("w%d-%d", w, i)
+
+				if _, err := d.Write("users", ID, i); err != nil {
+					t.Error(err)
+					return
+				}
+
+				if _, err := d.Read("users", ID); err != nil {
+					t.Errorf("reading %s just written: %s", ID, err)
+				}
+
+				if ok, _ := d.Exists("users", "missing-"+ID); ok {
+					t.Errorf("missing-%s exists", ID)
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	IDs, err := d.IDs("users")
+	if err != nil || len(IDs) != 200 {
+		t.Errorf("users holds %d records, %v, want 200", len(IDs), err)
+	}
+}
@@ -0,0 +1,316 @@
+// Package client is the Go client of package server, its Client implements
+// jdb.Store so code can switch between an embedded Driver and a remote one.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/server"
+)
+
+type (
+	// Client talks to a jdb server
+	Client struct {
+		baseURL string
+		opts    Options
+	}
+
+	// Options configures a Client
+	Options struct {
+		// HTTPClient sends the requests, http.DefaultClient when nil
+		HTTPClient *http.Client
+
+		// Token is sent as a bearer token
+		Token string
+
+		// APIKey is sent in the X-API-Key header
+		APIKey string
+
+		// Username and Password are sent with basic authentication
+		Username, Password string
+	}
+)
+
+var _ jdb.Store = (*Client)(nil)
+
+// New returns a Client of the server at baseURL, opts may be nil
+func New(baseURL string, opts *Options) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/")}
+	if opts != nil {
+		c.opts = *opts
+	}
+
+	if c.opts.HTTPClient == nil {
+		c.opts.HTTPClient = http.DefaultClient
+	}
+
+	return c
+}
+
+func (c *Client) Write(collection, identifier string, v interface{}) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if identifier == "" {
+		return "", fmt.Errorf("missing identifier")
+	}
+
+	return identifier, c.do(http.MethodPut, recordPath(collection, identifier), nil, v, nil)
+}
+
+// Insert writes v as a new record under an ID made by the server
+func (c *Client) Insert(collection string, v interface{}) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection, no place to save data")
+	}
+
+	var res struct {
+		ID string `json:"id"`
+	}
+
+	err := c.do(http.MethodPost, "/records/"+escape(collection), nil, v, &res)
+	return res.ID, err
+}
+
+// Update writes v over an existing record, it fails with an error wrapping
+// os.ErrNotExist when there's no such record
+func (c *Client) Update(collection, ID string, v interface{}) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if ID == "" {
+		return "", fmt.Errorf("missing identifier")
+	}
+
+	header := http.Header{"If-Match": {"*"}}
+	return ID, c.do(http.MethodPut, recordPath(collection, ID), header, v, nil)
+}
+
+func (c *Client) Read(collection, identifier string) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if identifier == "" {
+		return "", fmt.Errorf("missing ID, no identifier to get data")
+	}
+
+	var record json.RawMessage
+	if err := c.do(http.MethodGet, recordPath(collection, identifier), nil, nil, &record); err != nil {
+		return "", err
+	}
+
+	return string(record), nil
+}
+
+// ReadInto decodes the record into v
+func (c *Client) ReadInto(collection, identifier string, v interface{}) error {
+	record, err := c.Read(collection, identifier)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(record), v)
+}
+
+// ReadAll returns every record of the collection, compacted
+func (c *Client) ReadAll(collection string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	var raw []json.RawMessage
+	if err := c.do(http.MethodGet, "/records/"+escape(collection), nil, nil, &raw); err != nil {
+		return nil, err
+	}
+
+	records := make([]string, len(raw))
+	for i, r := range raw {
+		records[i] = string(r)
+	}
+
+	return records, nil
+}
+
+// IDs returns the identifiers of every record of the collection
+func (c *Client) IDs(collection string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	var IDs []string
+	err := c.do(http.MethodGet, "/ids/"+escape(collection), nil, nil, &IDs)
+	return IDs, err
+}
+
+// Exists reports whether a record with the identifier is in the collection
+func (c *Client) Exists(collection, identifier string) (bool, error) {
+	if collection == "" {
+		return false, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if identifier == "" {
+		return false, fmt.Errorf("missing ID, no identifier to get data")
+	}
+
+	req, err := c.newRequest(http.MethodHead, recordPath(collection, identifier), nil)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("checking %s/%s: %s", collection, identifier, res.Status)
+	}
+}
+
+func (c *Client) Delete(collection, ID string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to delete data")
+	}
+
+	if ID == "" {
+		return fmt.Errorf("missing identifier")
+	}
+
+	return c.do(http.MethodDelete, recordPath(collection, ID), nil, nil, nil)
+}
+
+// do sends in encoded as JSON and decodes the response into out, error
+// responses are turned back into the errors the Driver would have returned
+func (c *Client) do(method, path string, header http.Header, in, out interface{}) error {
+	var body io.Reader
+
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(b)
+	}
+
+	req, err := c.newRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return decodeError(res)
+	}
+
+	if out == nil {
+		io.Copy(ioutil.Discard, res.Body)
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// newRequest returns a request carrying the credentials of the Client
+func (c *Client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.opts.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	case c.opts.APIKey != "":
+		req.Header.Set("X-API-Key", c.opts.APIKey)
+	case c.opts.Username != "":
+		req.SetBasicAuth(c.opts.Username, c.opts.Password)
+	}
+
+	return req, nil
+}
+
+// decodeError maps an error response to the matching jdb error
+func decodeError(res *http.Response) error {
+	var e server.Error
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Message == "" {
+		return fmt.Errorf("jdb server: %s", res.Status)
+	}
+
+	switch e.Code {
+	case server.CodeInvalid:
+		return &jdb.ValidationError{Collection: e.Collection, ID: e.ID, Violations: e.Violations}
+	case server.CodeNotFound:
+		return &remoteError{msg: e.Message, err: os.ErrNotExist}
+	case server.CodeAppendOnly:
+		return &remoteError{msg: e.Message, err: jdb.ErrAppendOnly}
+	case server.CodeReadOnly:
+		return &remoteError{msg: e.Message, err: jdb.ErrReadOnly}
+	case server.CodeConditionFailed:
+		return &remoteError{msg: e.Message, err: jdb.ErrConditionFailed}
+	case server.CodeConflict:
+		return &remoteError{msg: e.Message, err: jdb.ErrConflict}
+	case server.CodeUnauthorized, server.CodeForbidden:
+		return &remoteError{msg: e.Message, err: os.ErrPermission}
+	case server.CodeOverloaded:
+		return &remoteError{msg: e.Message, err: jdb.ErrOverloaded}
+	case server.CodeFrozen:
+		return &remoteError{msg: e.Message, err: jdb.ErrFrozen}
+	case server.CodeStorageFull:
+		return &remoteError{msg: e.Message, err: syscall.ENOSPC}
+	}
+
+	return fmt.Errorf("jdb server: %s", e.Message)
+}
+
+// remoteError is an error of the server, unwrapping to the matching jdb
+// error so errors.Is works as with an embedded Driver
+type remoteError struct {
+	msg string
+	err error
+}
+
+func (e *remoteError) Error() string { return e.msg }
+func (e *remoteError) Unwrap() error { return e.err }
+
+// recordPath returns the path of a record, escaping slashes of nested
+// collections
+func recordPath(collection, ID string) string {
+	return "/records/" + escape(collection) + "/" + escape(ID)
+}
+
+func escape(s string) string {
+	return url.PathEscape(s)
+}
@@ -0,0 +1,256 @@
+package jdb
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CloneOptions filters what CloneTo copies, the zero value copies everything
+type CloneOptions struct {
+	// Collections limits the clone to these collections, nested collections
+	// below them included
+	Collections []string
+
+	// ModifiedSince only copies records written at or after this time
+	ModifiedSince time.Time
+}
+
+// CloneTo copies the records of the database into destDir, which can then be
+// opened with New. Each collection is copied while holding its lock so the
+// clone of a collection is consistent, records are merged from the read-only
+// directories. Archived records and expiry times are copied along with their
+// collection, and the KV and the queues when every collection is cloned.
+// History, trash and indexes are left out. It returns how many records were
+// copied
+func (d *Driver) CloneTo(destDir string, opts *CloneOptions) (int, error) {
+	if opts == nil {
+		opts = &CloneOptions{}
+	}
+
+	destDir = filepath.Clean(destDir)
+	if destDir == d.dir || strings.HasPrefix(destDir, d.dir+string(filepath.Separator)) {
+		return 0, fmt.Errorf("can't clone %s into itself", d.dir)
+	}
+
+	collections, err := d.collections()
+	if err != nil {
+		return 0, err
+	}
+
+	var selected []string
+	for _, c := range collections {
+		if within(c, opts.Collections) {
+			selected = append(selected, c)
+		}
+	}
+
+	if len(opts.Collections) == 0 {
+		stores, err := d.stores()
+		if err != nil {
+			return 0, err
+		}
+
+		selected = append(selected, stores...)
+	}
+
+	total, err := d.countRecords(selected)
+	if err != nil {
+		return 0, err
+	}
+
+	count, done := 0, 0
+
+	for _, c := range selected {
+		n, err := d.cloneCollection(c, destDir, opts.ModifiedSince, &done, total)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+func (d *Driver) cloneCollection(collection, destDir string, since time.Time, done *int, total int) (int, error) {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	records, err := d.listRecords(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	dir := filepath.Join(destDir, collection)
+	if err := d.fs.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, r := range records {
+		*done++
+		d.progress(Progress{Op: "clone", Collection: collection, Done: *done, Total: total})
+
+		if r.info.ModTime().Before(since) {
+			continue
+		}
+
+		if err := d.cloneFile(r.path, filepath.Join(dir, r.ID+".json")); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	archived, err := d.cloneFiles(filepath.Join(archiveDir, collection), destDir, ".json.gz", since)
+	count += archived
+	if err != nil {
+		return count, err
+	}
+
+	_, err = d.cloneFiles(filepath.Join(ttlDir, collection), destDir, ".ttl", since)
+	return count, err
+}
+
+// cloneFiles copies the files of a reserved directory of the data directory
+// ending with suffix into the same directory below destDir, returning how
+// many were copied
+func (d *Driver) cloneFiles(rel, destDir, suffix string, since time.Time) (int, error) {
+	files, err := d.fs.ReadDir(filepath.Join(d.dir, rel))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, file := range files {
+		if !file.Mode().IsRegular() || !strings.HasSuffix(file.Name(), suffix) || file.ModTime().Before(since) {
+			continue
+		}
+
+		if count == 0 {
+			if err := d.fs.MkdirAll(filepath.Join(destDir, rel), 0755); err != nil {
+				return count, err
+			}
+		}
+
+		if err := d.cloneFile(filepath.Join(d.dir, rel, file.Name()), filepath.Join(destDir, rel, file.Name())); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// cloneFile copies a file of the database to path outside of it, atomically
+func (d *Driver) cloneFile(src, path string) error {
+	b, err := d.fs.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	if err := d.fs.WriteFile(path+".tmp", b, 0644); err != nil {
+		return err
+	}
+
+	return d.fs.Rename(path+".tmp", path)
+}
+
+// collections returns every collection of the data directory and the
+// read-only ones sorted by name, nested collections are returned as their
+// path. Reserved and hidden directories are left out
+func (d *Driver) collections() ([]string, error) {
+	seen := make(map[string]bool)
+
+	for _, root := range d.roots() {
+		err := d.walkDirs(root, func(dir string, info os.FileInfo) (bool, error) {
+			if strings.HasPrefix(info.Name(), "_") || strings.HasPrefix(info.Name(), ".") {
+				return false, nil
+			}
+
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return false, err
+			}
+
+			seen[filepath.ToSlash(rel)] = true
+			return true, nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	collections := make([]string, 0, len(seen))
+	for c := range seen {
+		collections = append(collections, c)
+	}
+
+	sort.Strings(collections)
+
+	return collections, nil
+}
+
+// stores returns the reserved collections the Driver keeps records of its
+// users in, the KV and the queues, see validateStored
+func (d *Driver) stores() ([]string, error) {
+	seen := make(map[string]bool)
+
+	for _, root := range d.roots() {
+		if _, err := d.fs.Stat(filepath.Join(root, kvCollection)); err == nil {
+			seen[kvCollection] = true
+		}
+
+		queues := filepath.Join(root, queueDir)
+
+		err := d.walkDirs(queues, func(dir string, info os.FileInfo) (bool, error) {
+			rel, err := filepath.Rel(queues, dir)
+			if err != nil {
+				return false, err
+			}
+
+			seen[path.Join(queueDir, filepath.ToSlash(rel))] = true
+			return true, nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	stores := make([]string, 0, len(seen))
+	for s := range seen {
+		stores = append(stores, s)
+	}
+
+	sort.Strings(stores)
+
+	return stores, nil
+}
+
+// within reports whether the collection is one of filter or nested below
+// one of them, an empty filter holds every collection
+func within(collection string, filter []string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+
+	for _, f := range filter {
+		if collection == f || strings.HasPrefix(collection, f+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,104 @@
+package jdb_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestCloneToCopiesReservedStores(t *testing.T) {
+	src := jdbtest.New(t)
+	jdbtest.Seed(t, src, "users", 3, jdbtest.Sequence("u", map[string]int{"n": 1}))
+
+	if err := src.KV().Set("flag", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := src.Enqueue("jobs/email", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.Expire("users", "u-1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if n, err := src.Archive("users", time.Millisecond); err != nil || n != 3 {
+		t.Fatalf("archived %d records: %v", n, err)
+	}
+
+	if _, err := src.Write("users", "u-0", map[string]int{"n": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "clone")
+
+	count, err := src.CloneTo(dest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// u-0 live and archived, u-1 and u-2 archived, the KV and the message
+	if count != 6 {
+		t.Errorf("cloned %d records, want 6", count)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "_ttl", "users", "u-1.ttl")); err != nil {
+		t.Errorf("the expiry of u-1 wasn't cloned: %s", err)
+	}
+
+	clone := jdbtest.Open(t, dest)
+
+	for _, ID := range []string{"u-0", "u-1", "u-2"} {
+		if _, err := clone.Read("users", ID); err != nil {
+			t.Errorf("reading %s from the clone: %s", ID, err)
+		}
+	}
+
+	var flag bool
+	if err := clone.KV().Get("flag", &flag); err != nil || !flag {
+		t.Errorf("KV flag in the clone = %v, %v", flag, err)
+	}
+
+	msg, err := clone.Dequeue("jobs/email", time.Minute)
+	if err != nil {
+		t.Fatalf("dequeuing from the clone: %s", err)
+	}
+
+	var body string
+	if err := msg.Decode(&body); err != nil || body != "hello" {
+		t.Errorf("message = %q, %v", body, err)
+	}
+}
+
+func TestCloneToFiltersCollections(t *testing.T) {
+	src := jdbtest.New(t)
+	jdbtest.Seed(t, src, "users", 2, jdbtest.Sequence("u", 1))
+	jdbtest.Seed(t, src, "orders", 2, jdbtest.Sequence("o", 1))
+
+	if err := src.KV().Set("flag", true); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "clone")
+
+	count, err := src.CloneTo(dest, &jdb.CloneOptions{Collections: []string{"users"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("cloned %d records, want 2", count)
+	}
+
+	for _, dir := range []string{"orders", "_kv"} {
+		if _, err := os.Stat(filepath.Join(dest, dir)); !os.IsNotExist(err) {
+			t.Errorf("%s was cloned though it wasn't selected", dir)
+		}
+	}
+}
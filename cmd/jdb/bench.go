@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arham09/jdb"
+)
+
+const benchUsage = "bench [-workload read|write|mixed] [-duration d] [-concurrency n] [-records n] [-size bytes] [options]"
+
+// benchResult holds the latencies of one kind of operation
+type benchResult struct {
+	mutex     sync.Mutex
+	latencies []time.Duration
+	errors    int
+}
+
+func (r *benchResult) add(elapsed time.Duration, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err != nil {
+		r.errors++
+		return
+	}
+
+	r.latencies = append(r.latencies, elapsed)
+}
+
+// runBench runs a workload against a scratch collection of the data
+// directory, dropped afterwards, and reports throughput and latency
+// percentiles
+func runBench(dir string, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	workload := fs.String("workload", "mixed", "read, write or mixed")
+	duration := fs.Duration("duration", 10*time.Second, "how long to run")
+	concurrency := fs.Int("concurrency", 4, "concurrent workers")
+	records := fs.Int("records", 1000, "records written before the run and read or overwritten during it")
+	size := fs.Int("size", 256, "approximate size of records in bytes")
+	readRatio := fs.Float64("read-ratio", 0.8, "share of reads of the mixed workload")
+	collection := fs.String("collection", "jdb-bench", "scratch collection, dropped after the run")
+	mmap := fs.Int64("mmap", 0, "Options.MmapThreshold")
+	bloom := fs.Int("bloom", 0, "Options.BloomFilterSize")
+	encrypt := fs.Bool("encrypt", false, "encrypt records with a random key")
+	history := fs.Bool("history", false, "keep the history of the records")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 || *concurrency < 1 || *records < 1 {
+		return fmt.Errorf("usage: %s", benchUsage)
+	}
+
+	reads := map[string]float64{"read": 1, "write": 0, "mixed": *readRatio}
+	ratio, ok := reads[*workload]
+	if !ok {
+		return fmt.Errorf("unknown workload %q", *workload)
+	}
+
+	opts := jdb.Options{MmapThreshold: *mmap, BloomFilterSize: *bloom}
+	if *encrypt {
+		opts.EncryptionKey = make([]byte, 32)
+		rand.Read(opts.EncryptionKey)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	d, err := open(dir, opts)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if exists, _ := collectionExists(d, *collection); exists {
+		return fmt.Errorf("collection %s already exists, pick another with -collection", *collection)
+	}
+	defer d.DropCollection(*collection, true)
+
+	d.SetHistory(*collection, *history)
+
+	doc := benchRecord(*size)
+
+	fmt.Printf("writing %d records of %d bytes\n", *records, *size)
+	for i := 0; i < *records; i++ {
+		if _, err := d.Write(*collection, benchID(i), doc); err != nil {
+			return err
+		}
+	}
+
+	results := map[string]*benchResult{"read": {}, "write": {}}
+	deadline := time.Now().Add(*duration)
+
+	fmt.Printf("running %s workload for %s with %d workers\n", *workload, *duration, *concurrency)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+
+		go func(seed int64) {
+			defer wg.Done()
+
+			rnd := mathrand.New(mathrand.NewSource(seed))
+
+			for time.Now().Before(deadline) {
+				ID := benchID(rnd.Intn(*records))
+				began := time.Now()
+
+				if rnd.Float64() < ratio {
+					_, err := d.Read(*collection, ID)
+					results["read"].add(time.Since(began), err)
+				} else {
+					_, err := d.Write(*collection, ID, doc)
+					results["write"].add(time.Since(began), err)
+				}
+			}
+		}(time.Now().UnixNano() + int64(w))
+	}
+
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	fmt.Printf("\n%-6s %10s %10s %10s %10s %10s %10s %7s\n", "op", "ops", "ops/s", "p50", "p90", "p99", "max", "errors")
+	for _, op := range []string{"read", "write"} {
+		r := results[op]
+		if len(r.latencies) == 0 && r.errors == 0 {
+			continue
+		}
+
+		sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+
+		fmt.Printf("%-6s %10d %10.0f %10s %10s %10s %10s %7d\n", op, len(r.latencies),
+			float64(len(r.latencies))/elapsed.Seconds(),
+			percentile(r.latencies, 50), percentile(r.latencies, 90), percentile(r.latencies, 99),
+			percentile(r.latencies, 100), r.errors)
+	}
+
+	return nil
+}
+
+func collectionExists(d *jdb.Driver, collection string) (bool, error) {
+	IDs, err := d.IDs(collection)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return len(IDs) > 0, err
+}
+
+// benchRecord returns a record of about size bytes once encoded
+func benchRecord(size int) map[string]interface{} {
+	pad := make([]byte, size/2)
+	rand.Read(pad)
+
+	payload := hex.EncodeToString(pad)
+	if len(payload) > size {
+		payload = payload[:size]
+	}
+
+	return map[string]interface{}{"payload": payload, "tags": strings.Fields("bench jdb")}
+}
+
+func benchID(i int) string {
+	return fmt.Sprintf("r%08d", i)
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := (len(sorted)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+
+	return sorted[i].Round(time.Microsecond)
+}
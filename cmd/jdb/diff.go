@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/arham09/jdb"
+)
+
+const diffUsage = "diff [-v] [-collection c] <dirA> <dirB>"
+
+// runDiff reports the records added, removed and changed from dirA to dirB
+// per collection, exiting with 1 when they differ like diff(1)
+func runDiff(_ string, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	verbose := fs.Bool("v", false, "show the changes of changed records as JSON Patch operations")
+	only := fs.String("collection", "", "only compare this collection")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: %s", diffUsage)
+	}
+
+	a, err := open(fs.Arg(0), jdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	b, err := open(fs.Arg(1), jdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	collections := []string{*only}
+	if *only == "" {
+		if collections, err = unionCollections(fs.Arg(0), fs.Arg(1)); err != nil {
+			return err
+		}
+	}
+
+	different := false
+
+	for _, c := range collections {
+		changed, err := diffCollection(a, b, c, *verbose)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c, err)
+		}
+
+		different = different || changed
+	}
+
+	if different {
+		return exitError(1)
+	}
+
+	return nil
+}
+
+func unionCollections(a, b string) ([]string, error) {
+	ca, err := collections(a)
+	if err != nil {
+		return nil, err
+	}
+
+	cb, err := collections(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return union(ca, cb), nil
+}
+
+// diffCollection prints the differences of the collection and reports
+// whether there were any
+func diffCollection(a, b *jdb.Driver, collection string, verbose bool) (bool, error) {
+	idsA, err := ids(a, collection)
+	if err != nil {
+		return false, err
+	}
+
+	idsB, err := ids(b, collection)
+	if err != nil {
+		return false, err
+	}
+
+	inA := make(map[string]bool, len(idsA))
+	for _, ID := range idsA {
+		inA[ID] = true
+	}
+
+	inB := make(map[string]bool, len(idsB))
+	for _, ID := range idsB {
+		inB[ID] = true
+	}
+
+	var lines []string
+	added, removed, changed := 0, 0, 0
+
+	for _, ID := range union(idsA, idsB) {
+		switch {
+		case !inA[ID]:
+			added++
+			lines = append(lines, fmt.Sprintf("  + %s/%s", collection, ID))
+		case !inB[ID]:
+			removed++
+			lines = append(lines, fmt.Sprintf("  - %s/%s", collection, ID))
+		default:
+			changes, err := diffRecord(a, b, collection, ID)
+			if err != nil {
+				return false, err
+			}
+
+			if len(changes) == 0 {
+				continue
+			}
+
+			changed++
+			lines = append(lines, fmt.Sprintf("  ~ %s/%s", collection, ID))
+
+			if verbose {
+				for _, c := range changes {
+					value, _ := json.Marshal(c.Value)
+					if c.Op == "remove" {
+						value = nil
+					}
+
+					lines = append(lines, fmt.Sprintf("      %s %s %s", c.Op, c.Path, value))
+				}
+			}
+		}
+	}
+
+	if len(lines) == 0 {
+		return false, nil
+	}
+
+	fmt.Printf("%s: %d added, %d removed, %d changed\n", collection, added, removed, changed)
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+
+	return true, nil
+}
+
+func diffRecord(a, b *jdb.Driver, collection, ID string) ([]jdb.Change, error) {
+	ra, err := a.Read(collection, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rb, err := b.Read(collection, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ra == rb {
+		return nil, nil
+	}
+
+	return jdb.DiffJSON([]byte(ra), []byte(rb))
+}
+
+// ids returns the IDs of the collection, none when it doesn't exist
+func ids(d *jdb.Driver, collection string) ([]string, error) {
+	IDs, err := d.IDs(collection)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return IDs, err
+}
+
+// union returns the sorted union of a and b
+func union(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+
+	var all []string
+	for _, s := range append(append([]string(nil), a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			all = append(all, s)
+		}
+	}
+
+	sort.Strings(all)
+	return all
+}
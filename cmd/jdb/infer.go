@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/arham09/jdb"
+)
+
+const inferUsage = "infer [-sample n] [-struct Name] <collection>"
+
+// runInfer prints the schema inferred from the records of a collection, or
+// a Go struct matching it
+func runInfer(dir string, args []string) error {
+	fs := flag.NewFlagSet("infer", flag.ContinueOnError)
+	sample := fs.Int("sample", 1000, "number of records sampled, zero for all")
+	name := fs.String("struct", "", "print a Go struct of this name instead of the schema")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: %s", inferUsage)
+	}
+
+	db, err := open(dir, jdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	schema, err := db.InferSchema(fs.Arg(0), *sample)
+	if err != nil {
+		return err
+	}
+
+	if *name != "" {
+		src, err := jdb.GenerateStruct(*name, schema)
+		if err != nil {
+			return err
+		}
+
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(schema)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// lineEditor reads lines from a raw terminal with history and completion of
+// the word before the cursor
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  []string
+	complete func(words []string) []string
+}
+
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyTab       = 9
+	keyEnter     = 13
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// readLine returns the next line, io.EOF once the user hits ^D on an empty
+// line
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	var line []rune
+	cursor := 0
+	hist := len(e.history)
+	draft := ""
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r\x1b[K%s%s", prompt, string(line))
+		if back := len(line) - cursor; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+
+	setLine := func(s string) {
+		line = []rune(s)
+		cursor = len(line)
+		redraw()
+	}
+
+	fmt.Fprint(e.out, prompt)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, '\n':
+			fmt.Fprint(e.out, "\n")
+
+			s := string(line)
+			if strings.TrimSpace(s) != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != s) {
+				e.history = append(e.history, s)
+			}
+
+			return s, nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\n")
+			line, cursor = nil, 0
+			fmt.Fprint(e.out, prompt)
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+		case keyCtrlA:
+			cursor = 0
+			redraw()
+		case keyCtrlE:
+			cursor = len(line)
+			redraw()
+		case keyCtrlU:
+			line, cursor = line[cursor:], 0
+			redraw()
+		case keyBackspace, '\b':
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+				redraw()
+			}
+		case keyTab:
+			e.completeWord(prompt, &line, &cursor)
+			redraw()
+		case keyEscape:
+			seq := e.escape()
+			switch seq {
+			case "[A":
+				if hist > 0 {
+					if hist == len(e.history) {
+						draft = string(line)
+					}
+
+					hist--
+					setLine(e.history[hist])
+				}
+			case "[B":
+				if hist < len(e.history) {
+					hist++
+					if hist == len(e.history) {
+						setLine(draft)
+					} else {
+						setLine(e.history[hist])
+					}
+				}
+			case "[C":
+				if cursor < len(line) {
+					cursor++
+					redraw()
+				}
+			case "[D":
+				if cursor > 0 {
+					cursor--
+					redraw()
+				}
+			case "[3~":
+				if cursor < len(line) {
+					line = append(line[:cursor], line[cursor+1:]...)
+					redraw()
+				}
+			}
+		default:
+			if r < ' ' {
+				continue
+			}
+
+			line = append(line[:cursor], append([]rune{r}, line[cursor:]...)...)
+			cursor++
+			redraw()
+		}
+	}
+}
+
+// escape reads the rest of an escape sequence
+func (e *lineEditor) escape() string {
+	var seq []rune
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+
+		seq = append(seq, r)
+		if len(seq) > 1 && (r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r == '~') {
+			return string(seq)
+		}
+
+		if len(seq) == 1 && r != '[' && r != 'O' {
+			return string(seq)
+		}
+	}
+}
+
+// completeWord completes the word before the cursor with the longest prefix
+// shared by the candidates, listing them when there are several
+func (e *lineEditor) completeWord(prompt string, line *[]rune, cursor *int) {
+	if e.complete == nil {
+		return
+	}
+
+	before := string((*line)[:*cursor])
+	words := strings.Fields(before)
+	if before == "" || strings.HasSuffix(before, " ") {
+		words = append(words, "")
+	}
+
+	word := words[len(words)-1]
+	candidates := e.complete(words)
+	if len(candidates) == 0 {
+		return
+	}
+
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	if len(candidates) == 1 {
+		prefix += " "
+	}
+
+	if len(prefix) > len(word) {
+		insert := []rune(prefix[len(word):])
+		*line = append((*line)[:*cursor], append(insert, (*line)[*cursor:]...)...)
+		*cursor += len(insert)
+		return
+	}
+
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/arham09/jdb"
+)
+
+const lintUsage = "lint -rules file [-json] <collection>"
+
+// runLint checks the records of a collection against the lint rules of a
+// JSON file, exiting with 1 when some break them
+func runLint(dir string, args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	rulesFile := fs.String("rules", "", "JSON file holding a list of lint rules")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *rulesFile == "" {
+		return fmt.Errorf("usage: %s", lintUsage)
+	}
+
+	b, err := ioutil.ReadFile(*rulesFile)
+	if err != nil {
+		return err
+	}
+
+	var rules []jdb.LintRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return fmt.Errorf("%s: %w", *rulesFile, err)
+	}
+
+	db, err := open(dir, jdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.SetLintRules(fs.Arg(0), rules); err != nil {
+		return err
+	}
+
+	report, err := db.Lint(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, v := range report.Violations {
+			fmt.Printf("%s: %s: %s\n", v.ID, v.Rule, v.Message)
+		}
+	}
+
+	if len(report.Violations) > 0 {
+		return exitError(1)
+	}
+
+	return nil
+}
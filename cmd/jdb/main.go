@@ -0,0 +1,141 @@
+// Command jdb inspects and queries jdb data directories.
+//
+// Usage:
+//
+//	jdb [-dir dir] <command> [arguments]
+//
+// The data directory defaults to $JDB_DIR, or the working directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arham09/jdb"
+	"github.com/jcelliott/lumber"
+)
+
+// command is a subcommand of the CLI, run with the arguments following its
+// name
+type command struct {
+	usage string
+	run   func(dir string, args []string) error
+}
+
+// exitError makes the CLI exit quietly with its status code
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+var commands = map[string]command{
+	"bench":   {benchUsage, runBench},
+	"diff":    {diffUsage, runDiff},
+	"infer":   {inferUsage, runInfer},
+	"lint":    {lintUsage, runLint},
+	"parquet": {parquetUsage, runParquet},
+	"query":   {queryUsage, runQuery},
+	"redis":   {redisUsage, runRedis},
+	"shell":   {shellUsage, runShell},
+	"tail":    {tailUsage, runTail},
+}
+
+func main() {
+	flag.Usage = usage
+
+	dir := flag.String("dir", defaultDir(), "data directory")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "jdb: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(*dir, flag.Args()[1:]); err != nil {
+		if code, ok := err.(exitError); ok {
+			os.Exit(int(code))
+		}
+
+		fmt.Fprintf(os.Stderr, "jdb %s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: jdb [-dir dir] <command> [arguments]\n\ncommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func defaultDir() string {
+	if dir := os.Getenv("JDB_DIR"); dir != "" {
+		return dir
+	}
+
+	return "."
+}
+
+// open opens the data directory, quietly
+func open(dir string, opts jdb.Options) (*jdb.Driver, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	opts.Logger = lumber.NewConsoleLogger(lumber.WARN)
+	return jdb.New(dir, &opts)
+}
+
+// collections lists the collections of the data directory sorted by name,
+// nested collections as their slash separated path. Reserved and hidden
+// directories are left out
+func collections(dir string) ([]string, error) {
+	var found []string
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() || path == dir {
+			return nil
+		}
+
+		if name := info.Name(); strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		found = append(found, filepath.ToSlash(rel))
+		return nil
+	})
+
+	sort.Strings(found)
+	return found, err
+}
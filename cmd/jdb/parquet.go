@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/arham09/jdb"
+)
+
+const parquetUsage = "parquet <collection> <file>"
+
+// runParquet exports a collection to a Parquet file, its columns following
+// the schema of the collection or one inferred from its records
+func runParquet(dir string, args []string) error {
+	fs := flag.NewFlagSet("parquet", flag.ContinueOnError)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: %s", parquetUsage)
+	}
+
+	db, err := open(dir, jdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExportParquet(fs.Arg(0), f, nil); err != nil {
+		f.Close()
+		os.Remove(fs.Arg(1))
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/arham09/jdb"
+)
+
+const queryUsage = "query [-format table|json|ndjson|csv] [-fields a,b] [-limit n] <collection> [filter]"
+
+// row is a record along with its ID
+type row struct {
+	ID     string
+	Record string
+}
+
+func runQuery(dir string, args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	format := fs.String("format", "table", "output format: table, json, ndjson or csv")
+	fields := fs.String("fields", "", "comma separated fields to show in tables and CSV, all top-level fields by default")
+	limit := fs.Int("limit", 0, "maximum number of records, zero for all")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("usage: %s", queryUsage)
+	}
+
+	filter, err := jdb.ParseFilter(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	d, err := open(dir, jdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	rows, err := query(d, fs.Arg(0), filter, *limit)
+	if err != nil {
+		return err
+	}
+
+	var columns []string
+	if *fields != "" {
+		columns = strings.Split(*fields, ",")
+	}
+
+	return printRows(os.Stdout, *format, rows, columns)
+}
+
+// query returns the records of the collection matching the filter
+func query(d *jdb.Driver, collection string, filter *jdb.Filter, limit int) ([]row, error) {
+	IDs, err := d.IDs(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []row
+
+	for _, ID := range IDs {
+		if limit > 0 && len(rows) == limit {
+			break
+		}
+
+		record, err := d.Read(collection, ID)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		ok, err := filter.Match([]byte(record))
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", collection, ID, err)
+		}
+
+		if ok {
+			rows = append(rows, row{ID: ID, Record: record})
+		}
+	}
+
+	return rows, nil
+}
+
+func printRows(w io.Writer, format string, rows []row, columns []string) error {
+	switch format {
+	case "json":
+		raw := make([]json.RawMessage, len(rows))
+		for i, r := range rows {
+			raw[i] = json.RawMessage(r.Record)
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(raw)
+	case "ndjson":
+		for _, r := range rows {
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, []byte(r.Record)); err != nil {
+				return err
+			}
+
+			buf.WriteByte('\n')
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case "csv", "table":
+		table, err := tabulate(rows, columns)
+		if err != nil {
+			return err
+		}
+
+		if format == "csv" {
+			cw := csv.NewWriter(w)
+			cw.WriteAll(table)
+			return cw.Error()
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, line := range table {
+			fmt.Fprintln(tw, strings.Join(line, "\t"))
+		}
+
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// tabulate returns the rows as cells under a header of the ID and columns,
+// nested values are shown as JSON
+func tabulate(rows []row, columns []string) ([][]string, error) {
+	docs := make([]map[string]interface{}, len(rows))
+
+	for i, r := range rows {
+		dec := json.NewDecoder(strings.NewReader(r.Record))
+		dec.UseNumber()
+
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("%s: %w", r.ID, err)
+		}
+
+		doc, ok := v.(map[string]interface{})
+		if !ok {
+			doc = map[string]interface{}{"value": v}
+		}
+
+		docs[i] = doc
+	}
+
+	if columns == nil {
+		seen := map[string]bool{}
+		for _, doc := range docs {
+			for k := range doc {
+				if !seen[k] {
+					seen[k] = true
+					columns = append(columns, k)
+				}
+			}
+		}
+		sort.Strings(columns)
+	}
+
+	table := [][]string{append([]string{"ID"}, columns...)}
+
+	for i, doc := range docs {
+		line := []string{rows[i].ID}
+		for _, c := range columns {
+			line = append(line, cell(doc, c))
+		}
+
+		table = append(table, line)
+	}
+
+	return table, nil
+}
+
+// cell formats the value at the dotted field of doc
+func cell(doc map[string]interface{}, field string) string {
+	var v interface{} = doc
+
+	for _, key := range strings.Split(field, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+
+		if v, ok = m[key]; !ok {
+			return ""
+		}
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/arham09/jdb"
+)
+
+const redisUsage = "redis [-prefix prefix] [-ttl duration] <collection>"
+
+// runRedis prints the records of a collection as Redis commands, to be piped
+// into redis-cli --pipe
+func runRedis(dir string, args []string) error {
+	fs := flag.NewFlagSet("redis", flag.ContinueOnError)
+	prefix := fs.String("prefix", "", "key prefix, the collection and a colon by default")
+	ttl := fs.Duration("ttl", 0, "expiry of the keys, none by default")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: %s", redisUsage)
+	}
+
+	db, err := open(dir, jdb.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExportRedis(fs.Arg(0), os.Stdout, jdb.RedisOptions{Prefix: *prefix, TTL: *ttl})
+	return err
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arham09/jdb"
+)
+
+const shellUsage = "shell [-readonly]"
+
+// historySize is how many lines of history the shell keeps between sessions
+const historySize = 500
+
+// shellCommands are the commands of the shell, with their arguments
+var shellCommands = []struct{ name, args string }{
+	{"collections", ""},
+	{"count", "<collection> [filter]"},
+	{"delete", "<collection> <id>"},
+	{"exit", ""},
+	{"find", "<collection> [filter]"},
+	{"get", "<collection> <id>"},
+	{"help", ""},
+	{"ids", "<collection>"},
+	{"put", "<collection> <id> <json>"},
+}
+
+type shell struct {
+	d   *jdb.Driver
+	dir string
+	out io.Writer
+}
+
+func runShell(dir string, args []string) error {
+	fs := flag.NewFlagSet("shell", flag.ContinueOnError)
+	readOnly := fs.Bool("readonly", false, "open the data directory read-only")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	d, err := open(dir, jdb.Options{ReadOnly: *readOnly})
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	sh := &shell{d: d, dir: dir, out: os.Stdout}
+	historyFile := historyPath()
+
+	editor := &lineEditor{
+		in:       bufio.NewReader(os.Stdin),
+		out:      os.Stdout,
+		history:  loadHistory(historyFile),
+		complete: sh.complete,
+	}
+
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		// not a terminal, read plain lines
+		return sh.runLines(bufio.NewScanner(os.Stdin))
+	}
+	defer restore()
+
+	fmt.Fprintf(sh.out, "jdb shell on %s, type help for the commands\n", dir)
+
+	for {
+		line, err := editor.readLine("jdb> ")
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if done := sh.exec(line); done {
+			break
+		}
+	}
+
+	saveHistory(historyFile, editor.history)
+	return nil
+}
+
+// runLines runs the commands read from a pipe
+func (sh *shell) runLines(scanner *bufio.Scanner) error {
+	for scanner.Scan() {
+		if done := sh.exec(scanner.Text()); done {
+			break
+		}
+	}
+
+	return scanner.Err()
+}
+
+// exec runs a command line and reports whether the shell should exit
+func (sh *shell) exec(line string) bool {
+	cmd, rest := cut(strings.TrimSpace(line))
+
+	var err error
+
+	switch cmd {
+	case "":
+	case "exit", "quit":
+		return true
+	case "help":
+		for _, c := range shellCommands {
+			fmt.Fprintf(sh.out, "  %s\n", strings.TrimSpace(c.name+" "+c.args))
+		}
+
+		fmt.Fprintln(sh.out, "\nfilters are the filter language of jdb query, e.g. age >= 18 and country = \"FR\"")
+	case "collections":
+		var names []string
+		if names, err = collections(sh.dir); err == nil {
+			sh.printLines(names)
+		}
+	case "ids":
+		var IDs []string
+		if IDs, err = sh.d.IDs(rest); err == nil {
+			sh.printLines(IDs)
+		}
+	case "get":
+		collection, ID := cut(rest)
+
+		var record string
+		if record, err = sh.d.Read(collection, ID); err == nil {
+			sh.printJSON(record)
+		}
+	case "find", "count":
+		collection, expr := cut(rest)
+
+		var filter *jdb.Filter
+		if filter, err = jdb.ParseFilter(expr); err != nil {
+			break
+		}
+
+		var records []string
+		if records, err = sh.d.Find(collection, filter); err != nil {
+			break
+		}
+
+		if cmd == "count" {
+			fmt.Fprintln(sh.out, len(records))
+			break
+		}
+
+		for _, r := range records {
+			sh.printJSON(r)
+		}
+
+		fmt.Fprintf(sh.out, "(%d records)\n", len(records))
+	case "put":
+		collection, rest := cut(rest)
+		ID, doc := cut(rest)
+
+		if !json.Valid([]byte(doc)) {
+			err = errors.New("record is not valid JSON")
+			break
+		}
+
+		_, err = sh.d.Write(collection, ID, json.RawMessage(doc))
+	case "delete":
+		collection, ID := cut(rest)
+		err = sh.d.Delete(collection, ID)
+	default:
+		err = fmt.Errorf("unknown command %q, type help for the commands", cmd)
+	}
+
+	if err != nil {
+		fmt.Fprintf(sh.out, "error: %v\n", err)
+	}
+
+	return false
+}
+
+// complete returns the completions of the last of the words: a command, a
+// collection, or an ID for the commands taking one
+func (sh *shell) complete(words []string) []string {
+	word := words[len(words)-1]
+
+	var options []string
+
+	switch len(words) {
+	case 1:
+		for _, c := range shellCommands {
+			options = append(options, c.name)
+		}
+	case 2:
+		if words[0] != "help" && words[0] != "exit" && words[0] != "collections" {
+			options, _ = collections(sh.dir)
+		}
+	case 3:
+		if words[0] == "get" || words[0] == "delete" || words[0] == "put" {
+			options, _ = sh.d.IDs(words[1])
+		}
+	}
+
+	var candidates []string
+	for _, o := range options {
+		if strings.HasPrefix(o, word) {
+			candidates = append(candidates, o)
+		}
+	}
+
+	sort.Strings(candidates)
+	return candidates
+}
+
+func (sh *shell) printLines(lines []string) {
+	for _, l := range lines {
+		fmt.Fprintln(sh.out, l)
+	}
+}
+
+func (sh *shell) printJSON(record string) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(record), "", "  "); err != nil {
+		fmt.Fprintln(sh.out, record)
+		return
+	}
+
+	fmt.Fprintln(sh.out, strings.TrimSpace(buf.String()))
+}
+
+// cut splits the first word off s
+func cut(s string) (string, string) {
+	s = strings.TrimSpace(s)
+
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, ""
+	}
+
+	return s[:i], strings.TrimSpace(s[i+1:])
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".jdb_history")
+}
+
+func loadHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func saveHistory(path string, history []string) {
+	if path == "" || len(history) == 0 {
+		return
+	}
+
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+
+	ioutil.WriteFile(path, []byte(strings.Join(history, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/arham09/jdb"
+)
+
+const tailUsage = "tail [-data] [-json] [collection]"
+
+// tailEvent is a line of jdb tail -json
+type tailEvent struct {
+	Time       time.Time       `json:"time"`
+	Op         string          `json:"op"`
+	Collection string          `json:"collection"`
+	ID         string          `json:"id"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// runTail follows the changes made to the data directory by every process
+// until interrupted
+func runTail(dir string, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	data := fs.Bool("data", false, "print the content of written records")
+	asJSON := fs.Bool("json", false, "print the changes as newline delimited JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: %s", tailUsage)
+	}
+
+	collection := fs.Arg(0)
+
+	d, err := open(dir, jdb.Options{ReadOnly: true, WatchFiles: true})
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	events, cancel := d.Watch(collection)
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	enc := json.NewEncoder(os.Stdout)
+
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			// changes to history, archives and the other reserved
+			// directories aren't changes to records
+			if strings.HasPrefix(e.Collection, "_") || strings.Contains(e.Collection, "/_") {
+				continue
+			}
+
+			var record json.RawMessage
+			if *data && e.Op == jdb.OpWrite {
+				if r, err := d.Read(e.Collection, e.ID); err == nil {
+					record = json.RawMessage(r)
+				}
+			}
+
+			if *asJSON {
+				enc.Encode(tailEvent{Time: e.Time, Op: e.Op.String(), Collection: e.Collection, ID: e.ID, Data: record})
+				continue
+			}
+
+			line := fmt.Sprintf("%s %-6s %s/%s", e.Time.Format(time.RFC3339Nano), e.Op, e.Collection, e.ID)
+			if record != nil {
+				line += " " + compact(record)
+			}
+
+			fmt.Println(line)
+		}
+	}
+}
+
+func compact(b []byte) string {
+	var buf strings.Builder
+
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return string(b)
+	}
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+
+	return strings.TrimSpace(buf.String())
+}
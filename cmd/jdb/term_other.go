@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+// makeRaw isn't available here, the shell falls back to reading whole lines
+// without completion
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal unsupported")
+}
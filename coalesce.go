@@ -0,0 +1,177 @@
+package jdb
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// coalescer holds the writes of coalesced collections until they're
+	// flushed, see SetCoalesce
+	coalescer struct {
+		collections sync.Map // collection to *coalescedCollection
+	}
+
+	// coalescedCollection holds the writes of a collection. Every write to
+	// the collection flushes it, held tells them without locking whether
+	// writes are held or being flushed
+	coalescedCollection struct {
+		held int32
+
+		mutex  sync.Mutex
+		writes map[string]*coalescedWrite
+
+		// flushing keeps flushes in order so an older version never lands
+		// after a newer one
+		flushing sync.Mutex
+	}
+
+	// coalescedWrite is the latest version of a record and the writers
+	// waiting for it
+	coalescedWrite struct {
+		v       interface{}
+		waiters []chan error
+	}
+)
+
+// SetCoalesce makes Write and WriteAsync hold the writes to the collection
+// for up to interval, only the latest version of a record written meanwhile
+// hitting disk. It saves most of the IO of records updated many times a
+// second, like live cursor positions. Write still returns once its version
+// or a later one is on disk, use WriteAsync not to wait. Reads see the
+// previous version until then, while other writes and deletes to the
+// collection flush the held writes first. Close and Freeze flush them too. A
+// zero interval turns it off
+func (d *Driver) SetCoalesce(collection string, interval time.Duration) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.coalesce = interval
+	})
+
+	if interval <= 0 {
+		d.coalesced.flush(d, collection)
+	}
+}
+
+// coalesce holds the write until the collection is flushed, the channel
+// receiving the outcome of the version written
+func (d *Driver) coalesce(collection, ID string, v interface{}, interval time.Duration) <-chan error {
+	done := make(chan error, 1)
+
+	c := d.coalesced.collection(collection)
+	c.mutex.Lock()
+
+	start := c.writes == nil
+	if start {
+		c.writes = make(map[string]*coalescedWrite)
+		atomic.StoreInt32(&c.held, 1)
+	}
+
+	w := c.writes[ID]
+	if w == nil {
+		w = &coalescedWrite{}
+		c.writes[ID] = w
+	}
+
+	w.v = v
+	w.waiters = append(w.waiters, done)
+
+	c.mutex.Unlock()
+
+	if start {
+		go func() {
+			select {
+			case <-d.after(interval):
+			case <-d.done:
+			}
+
+			c.flush(d, collection)
+		}()
+	}
+
+	return done
+}
+
+// collection returns the held writes of the collection
+func (c *coalescer) collection(name string) *coalescedCollection {
+	if cc, ok := c.collections.Load(name); ok {
+		return cc.(*coalescedCollection)
+	}
+
+	cc, _ := c.collections.LoadOrStore(name, &coalescedCollection{})
+	return cc.(*coalescedCollection)
+}
+
+// flush writes the held writes of the collection, all of them when the
+// collection is empty
+func (c *coalescer) flush(d *Driver, collection string) {
+	if collection != "" {
+		if cc, ok := c.collections.Load(collection); ok {
+			cc.(*coalescedCollection).flush(d, collection)
+		}
+
+		return
+	}
+
+	var names []string
+	c.collections.Range(func(name, _ interface{}) bool {
+		names = append(names, name.(string))
+		return true
+	})
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		c.collection(name).flush(d, name)
+	}
+}
+
+func (c *coalescedCollection) flush(d *Driver, collection string) {
+	if atomic.LoadInt32(&c.held) == 0 {
+		return
+	}
+
+	c.flushing.Lock()
+	defer c.flushing.Unlock()
+
+	c.mutex.Lock()
+	writes := c.writes
+	c.writes = nil
+	c.mutex.Unlock()
+
+	for _, ID := range sortedWriteKeys(writes) {
+		w := writes[ID]
+		err := d.flushWrite(collection, ID, w.v)
+
+		for _, done := range w.waiters {
+			done <- err
+		}
+	}
+
+	// only cleared once written, so writes checking held wait for them
+	c.mutex.Lock()
+	if c.writes == nil {
+		atomic.StoreInt32(&c.held, 0)
+	}
+	c.mutex.Unlock()
+
+	if len(writes) > 0 {
+		d.log.Debug("flushed %d coalesced writes to %s", len(writes), collection)
+	}
+}
+
+// flushWrite writes a held record, admitted without flushing again
+func (d *Driver) flushWrite(collection, ID string, v interface{}) error {
+	done, err := d.reserve(collection)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return d.applyWrite(collection, ID, v)
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{}
+}
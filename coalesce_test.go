@@ -0,0 +1,35 @@
+package jdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestCoalescedWritesLandBeforeOtherWrites(t *testing.T) {
+	d := jdbtest.New(t)
+	d.SetCoalesce("cursors", time.Hour)
+
+	var results []<-chan error
+	for i := 0; i < 10; i++ {
+		results = append(results, d.WriteAsync("cursors", "c", i))
+	}
+
+	// the delete flushes the held write first, which it then removes
+	if err := d.Delete("cursors", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, done := range results {
+		if err := <-done; err != nil {
+			t.Errorf("coalesced write failed: %s", err)
+		}
+	}
+
+	if _, err := d.Read("cursors", "c"); !errors.Is(err, jdb.ErrNotFound) {
+		t.Errorf("read after the delete = %v, want ErrNotFound", err)
+	}
+}
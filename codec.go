@@ -0,0 +1,286 @@
+package jdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSONOptions tunes how records are encoded on write and decoded by ReadInto
+type JSONOptions struct {
+	// Prefix and Indent are passed to json.Encoder.SetIndent, Indent defaults
+	// to a tab unless Compact writes records on a single line
+	Prefix  string
+	Indent  string
+	Compact bool
+
+	// DisableHTMLEscape keeps <, > and & as they are instead of escaping
+	// them, which mangles URLs stored in strings
+	DisableHTMLEscape bool
+
+	// UseNumber decodes numbers into interface{} values as json.Number
+	UseNumber bool
+
+	// DisallowUnknownFields fails decoding into structs when a record holds
+	// fields the struct doesn't have with an *UnknownFieldError, see also
+	// SetStrictDecode
+	DisallowUnknownFields bool
+
+	// CompactAbove writes records whose indented encoding is larger than
+	// this many bytes on a single line, so small records stay easy to read
+	// while large ones don't pay for the indentation. Zero never does
+	CompactAbove int
+
+	// CompressAbove gzips records larger than this many bytes once encoded,
+	// they're read back transparently but no longer by plain text tools.
+	// Zero never does
+	CompressAbove int
+}
+
+// UnknownFieldError is returned when decoding a record into a struct lacking
+// one of its fields while unknown fields are disallowed, which usually means
+// the struct drifted from what's stored
+type UnknownFieldError struct {
+	Collection string
+	ID         string
+	Field      string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("%s/%s has field %q unknown to the struct decoded into", e.Collection, e.ID, e.Field)
+}
+
+// SetStrictDecode disallows unknown fields when decoding records of the
+// collection like JSONOptions.DisallowUnknownFields does for every collection
+func (d *Driver) SetStrictDecode(collection string, strict bool) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.strictDecode = strict
+	})
+}
+
+func (o JSONOptions) withDefaults() JSONOptions {
+	if o.Indent == "" && o.Prefix == "" {
+		o.Indent = "\t"
+	}
+
+	return o
+}
+
+// rawJSON is encoded like any other value, formatted following JSONOptions,
+// while a json.RawMessage is written byte for byte
+type rawJSON []byte
+
+func (r rawJSON) MarshalJSON() ([]byte, error) {
+	return r, nil
+}
+
+// bufferPool recycles the buffers records are encoded into on the write path
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer keeps the buffers of unusually large records out of the
+// pool so they don't stay pinned in memory
+const maxPooledBuffer = 1 << 20
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// encode marshals v followed by a newline, json.RawMessage values are stored
+// as they are so they round trip byte-identically
+func (d *Driver) encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := d.encodeTo(&buf, v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeTo encodes v like encode into buf, so the write path can encode into
+// pooled buffers
+func (d *Driver) encodeTo(buf *bytes.Buffer, v interface{}) error {
+	switch raw := v.(type) {
+	case json.RawMessage:
+		return passthrough(buf, raw)
+	case *json.RawMessage:
+		if raw != nil {
+			return passthrough(buf, *raw)
+		}
+	}
+
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(!d.json.DisableHTMLEscape)
+
+	if !d.json.Compact {
+		enc.SetIndent(d.json.Prefix, d.json.Indent)
+	}
+
+	start := buf.Len()
+
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+
+	if d.json.Compact || d.json.CompactAbove <= 0 || buf.Len()-start <= d.json.CompactAbove {
+		return nil
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, buf.Bytes()[start:]); err != nil {
+		return err
+	}
+
+	buf.Truncate(start)
+	buf.Write(compact.Bytes())
+	buf.WriteByte('\n')
+
+	return nil
+}
+
+// compress gzips the record when it's larger than JSONOptions.CompressAbove
+// and encodes it with Options.Codec
+func (d *Driver) compress(doc []byte) ([]byte, error) {
+	if d.json.CompressAbove > 0 && len(doc) > d.json.CompressAbove {
+		var err error
+		if doc, err = GzipCodec.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.codec == nil {
+		return doc, nil
+	}
+
+	return d.codec.Encode(doc)
+}
+
+// decompress returns the record as it was before compress, records that
+// weren't compressed as they are
+func (d *Driver) decompress(b []byte) ([]byte, error) {
+	if d.codec != nil {
+		var err error
+		if b, err = d.codec.Decode(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return GzipCodec.Decode(b)
+}
+
+func passthrough(buf *bytes.Buffer, raw json.RawMessage) error {
+	if !json.Valid(raw) {
+		return fmt.Errorf("json.RawMessage holds invalid JSON")
+	}
+
+	buf.Write(raw)
+	return nil
+}
+
+// decode unmarshals a record into v, a *json.RawMessage gets the record
+// exactly as it's stored
+func (d *Driver) decode(collection, ID string, b []byte, v interface{}) error {
+	if raw, ok := v.(*json.RawMessage); ok && raw != nil {
+		*raw = append((*raw)[:0], b...)
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+
+	if d.json.UseNumber {
+		dec.UseNumber()
+	}
+
+	if d.json.DisallowUnknownFields || d.config(collection).strictDecode {
+		dec.DisallowUnknownFields()
+	}
+
+	err := dec.Decode(v)
+
+	// encoding/json has no typed error for unknown fields
+	if msg := fmt.Sprint(err); strings.HasPrefix(msg, "json: unknown field ") {
+		field, uerr := strconv.Unquote(strings.TrimPrefix(msg, "json: unknown field "))
+		if uerr == nil {
+			return &UnknownFieldError{Collection: collection, ID: ID, Field: field}
+		}
+	}
+
+	return err
+}
+
+// ReadInto decodes a record into v following Options.JSON
+func (d *Driver) ReadInto(collection, identifier string, v interface{}) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if identifier == "" {
+		return fmt.Errorf("missing ID, no identifier to get data")
+	}
+
+	return d.readRecord(collection, identifier, func(b []byte) error {
+		return d.decode(collection, identifier, b, v)
+	})
+}
+
+// ReadAllInto decodes every record of the collection into the slice v points
+// to, one element at a time straight from the files so no intermediate copy
+// of the collection is built
+func (d *Driver) ReadAllInto(collection string, v interface{}) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to get data")
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("ReadAllInto needs a non-nil pointer to a slice, got %T: %w", v, ErrUnsupportedValue)
+	}
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.listRecords(collection)
+	if err != nil {
+		return err
+	}
+
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+
+	if slice.Cap() < len(files) {
+		slice.Set(reflect.MakeSlice(slice.Type(), 0, len(files)))
+	} else {
+		slice.SetLen(0)
+	}
+
+	for _, file := range files {
+		elem := reflect.New(elemType)
+
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			return d.decode(collection, file.ID, b, elem.Interface())
+		})
+		if err != nil {
+			return fmt.Errorf("decoding %s/%s: %w", collection, file.ID, err)
+		}
+
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package jdb
+
+import "time"
+
+// Collection is a handle on a collection of a Driver, saving callers from
+// passing its name to every call. Handles are cheap and hold no state of
+// their own, the settings they make apply to every handle of the collection
+type Collection struct {
+	d    *Driver
+	name string
+}
+
+// Collection returns a handle on the collection, which doesn't have to
+// exist yet
+func (d *Driver) Collection(name string) *Collection {
+	return &Collection{d: d, name: name}
+}
+
+// Name returns the name of the collection
+func (c *Collection) Name() string {
+	return c.name
+}
+
+// Driver returns the Driver of the collection
+func (c *Collection) Driver() *Driver {
+	return c.d
+}
+
+// Write writes v under the ID, see Driver.Write
+func (c *Collection) Write(ID string, v interface{}) (string, error) {
+	return c.d.Write(c.name, ID, v)
+}
+
+// WriteAsync queues the write of v under the ID, see Driver.WriteAsync
+func (c *Collection) WriteAsync(ID string, v interface{}) <-chan error {
+	return c.d.WriteAsync(c.name, ID, v)
+}
+
+// Insert writes v under a new ID, see Driver.Insert
+func (c *Collection) Insert(v interface{}) (string, error) {
+	return c.d.Insert(c.name, v)
+}
+
+// WriteIdempotent inserts v once per idempotency key, see
+// Driver.WriteIdempotent
+func (c *Collection) WriteIdempotent(idempotencyKey string, v interface{}) (string, error) {
+	return c.d.WriteIdempotent(c.name, idempotencyKey, v)
+}
+
+// Update overwrites an existing record, see Driver.Update
+func (c *Collection) Update(ID string, v interface{}) (string, error) {
+	return c.d.Update(c.name, ID, v)
+}
+
+// WriteIf writes v when the condition holds, see Driver.WriteIf
+func (c *Collection) WriteIf(ID string, v interface{}, cond Condition) (string, error) {
+	return c.d.WriteIf(c.name, ID, v, cond)
+}
+
+// Read returns the record, see Driver.Read
+func (c *Collection) Read(ID string) (string, error) {
+	return c.d.Read(c.name, ID)
+}
+
+// ReadInto decodes the record into v, see Driver.ReadInto
+func (c *Collection) ReadInto(ID string, v interface{}) error {
+	return c.d.ReadInto(c.name, ID, v)
+}
+
+// ReadAll returns every record, see Driver.ReadAll
+func (c *Collection) ReadAll() ([]string, error) {
+	return c.d.ReadAll(c.name)
+}
+
+// ReadAllInto decodes every record into the slice v points to, see
+// Driver.ReadAllInto
+func (c *Collection) ReadAllInto(v interface{}) error {
+	return c.d.ReadAllInto(c.name, v)
+}
+
+// ForEach calls fn with every record, see Driver.ForEach
+func (c *Collection) ForEach(fn func(ID string, raw []byte) error, opts ...ForEachOption) error {
+	return c.d.ForEach(c.name, fn, opts...)
+}
+
+// IDs returns the identifiers of every record, see Driver.IDs
+func (c *Collection) IDs() ([]string, error) {
+	return c.d.IDs(c.name)
+}
+
+// List returns a page of records, see Driver.List
+func (c *Collection) List(token string, limit int) ([]string, string, error) {
+	return c.d.List(c.name, token, limit)
+}
+
+// Exists reports whether the record exists, see Driver.Exists
+func (c *Collection) Exists(ID string) (bool, error) {
+	return c.d.Exists(c.name, ID)
+}
+
+// Delete removes the record, see Driver.Delete
+func (c *Collection) Delete(ID string) error {
+	return c.d.Delete(c.name, ID)
+}
+
+// Find returns the records matching the filter, see Driver.Find
+func (c *Collection) Find(filter *Filter) ([]string, error) {
+	return c.d.Find(c.name, filter)
+}
+
+// FindBy returns the records whose indexed field has the value, see
+// Driver.FindBy
+func (c *Collection) FindBy(field string, value interface{}) ([]string, error) {
+	return c.d.FindBy(c.name, field, value)
+}
+
+// Watch streams the changes of the collection, see Driver.Watch
+func (c *Collection) Watch() (<-chan Event, func()) {
+	return c.d.Watch(c.name)
+}
+
+// Expire deletes the record once ttl elapsed, see Driver.Expire
+func (c *Collection) Expire(ID string, ttl time.Duration) error {
+	return c.d.Expire(c.name, ID, ttl)
+}
+
+// SetDefaultTTL makes records written without a TTL expire, see
+// Driver.SetDefaultTTL
+func (c *Collection) SetDefaultTTL(ttl time.Duration) {
+	c.d.SetDefaultTTL(c.name, ttl)
+}
+
+// Drop removes the collection and every record of it, see
+// Driver.DropCollection
+func (c *Collection) Drop(confirm bool) error {
+	return c.d.DropCollection(c.name, confirm)
+}
+
+// Schema returns the schema of the collection, see Driver.Schema
+func (c *Collection) Schema() *Schema {
+	return c.d.Schema(c.name)
+}
+
+// SetSchema validates the records written against the schema, see
+// Driver.SetSchema
+func (c *Collection) SetSchema(schema *Schema) error {
+	return c.d.SetSchema(c.name, schema)
+}
+
+// SetHistory keeps the previous versions of records, see Driver.SetHistory
+func (c *Collection) SetHistory(keep bool) {
+	c.d.SetHistory(c.name, keep)
+}
+
+// SetAppendOnly refuses updates and deletes, see Driver.SetAppendOnly
+func (c *Collection) SetAppendOnly(hashChain bool) {
+	c.d.SetAppendOnly(c.name, hashChain)
+}
+
+// SetCoalesce holds the writes to the collection, see Driver.SetCoalesce
+func (c *Collection) SetCoalesce(interval time.Duration) {
+	c.d.SetCoalesce(c.name, interval)
+}
+
+// SetConflictDetector checks the updates of the collection, see
+// Driver.SetConflictDetector
+func (c *Collection) SetConflictDetector(fn ConflictFunc) {
+	c.d.SetConflictDetector(c.name, fn)
+}
+
+// SetWriteRate limits the writes to the collection, see Driver.SetWriteRate
+func (c *Collection) SetWriteRate(rate float64, burst int) {
+	c.d.SetWriteRate(c.name, rate, burst)
+}
+
+// SetEngine stores the records with the engine and codec, see
+// Driver.SetEngine
+func (c *Collection) SetEngine(engine Engine, codec Codec) error {
+	return c.d.SetEngine(c.name, engine, codec)
+}
+
+// EnsureIndex indexes the field, see Driver.EnsureIndex
+func (c *Collection) EnsureIndex(field string) error {
+	return c.d.EnsureIndex(c.name, field)
+}
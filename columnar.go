@@ -0,0 +1,219 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type (
+	// Column holds the values of a field for the rows of a RecordBatch: a
+	// slice of its type, nulls having their Valid bit unset and a zero
+	// value. It's no Arrow array, package jdbarrow turns batches into Arrow
+	// records and IPC streams
+	Column struct {
+		Name string
+
+		// Type is "string", "integer", "number" or "boolean", or "json"
+		// for values of other types kept as JSON text in Strings
+		Type string
+
+		Valid   []bool
+		Strings []string
+		Ints    []int64
+		Floats  []float64
+		Bools   []bool
+	}
+
+	// RecordBatch is a slice of the records of a collection, column by
+	// column, see ScanBatches
+	RecordBatch struct {
+		IDs     []string
+		Columns []*Column
+	}
+)
+
+// Len returns how many rows the batch holds
+func (b *RecordBatch) Len() int {
+	return len(b.IDs)
+}
+
+// ScanBatches calls fn with the records of the collection in batches of up
+// to size rows, a column per property of the object schema, so they can be
+// handed to column builders or a DuckDB appender without decoding each
+// record twice. A nil schema means the one of the collection, see
+// TableSchema. Missing fields and nulls are nulls, values of other types
+// than their column fail the scan. The batch is only valid until fn returns,
+// which can stop the scan with ErrStop
+func (d *Driver) ScanBatches(collection string, schema *Schema, size int, fn func(*RecordBatch) error) error {
+	if size <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", size)
+	}
+
+	schema, err := d.TableSchema(collection, schema)
+	if err != nil {
+		return err
+	}
+
+	batch := newRecordBatch(schema)
+
+	err = d.ForEach(collection, func(ID string, raw []byte) error {
+		if err := batch.add(collection, ID, raw); err != nil {
+			return err
+		}
+
+		if batch.Len() < size {
+			return nil
+		}
+
+		err := fn(batch)
+		batch.reset()
+		return err
+	})
+	if err != nil || batch.Len() == 0 {
+		return err
+	}
+
+	return stopped(fn(batch))
+}
+
+// TableSchema returns the object schema ScanBatches lays the records of the
+// collection out by: schema when it isn't nil, else the one of the
+// collection or one inferred from its records when it has none
+func (d *Driver) TableSchema(collection string, schema *Schema) (*Schema, error) {
+	if schema == nil {
+		schema = d.Schema(collection)
+	}
+
+	if schema == nil {
+		inferred, err := d.InferSchema(collection, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		schema = inferred
+	}
+
+	if schema.Type != "object" || len(schema.Properties) == 0 {
+		return nil, fmt.Errorf("columns need an object schema with properties")
+	}
+
+	return schema, nil
+}
+
+func newRecordBatch(schema *Schema) *RecordBatch {
+	return &RecordBatch{Columns: Columns(schema)}
+}
+
+// Columns returns the empty columns of the batches records of the object
+// schema are laid out in, in name order
+func Columns(schema *Schema) []*Column {
+	var columns []*Column
+
+	for _, name := range sortedSchemaKeys(schema.Properties) {
+		typ := schema.Properties[name].Type
+		switch typ {
+		case "string", "integer", "number", "boolean":
+		default:
+			typ = "json"
+		}
+
+		columns = append(columns, &Column{Name: name, Type: typ})
+	}
+
+	return columns
+}
+
+// add appends the record as a row
+func (b *RecordBatch) add(collection, ID string, raw []byte) error {
+	v, err := decodeJSON(raw)
+	if err != nil {
+		return fmt.Errorf("%s/%s: %w", collection, ID, err)
+	}
+
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s/%s is not an object", collection, ID)
+	}
+
+	for _, c := range b.Columns {
+		if err := c.append(doc[c.Name]); err != nil {
+			return fmt.Errorf("%s/%s: %s: %w", collection, ID, c.Name, err)
+		}
+	}
+
+	b.IDs = append(b.IDs, ID)
+	return nil
+}
+
+func (b *RecordBatch) reset() {
+	b.IDs = b.IDs[:0]
+
+	for _, c := range b.Columns {
+		c.Valid = c.Valid[:0]
+		c.Strings = c.Strings[:0]
+		c.Ints = c.Ints[:0]
+		c.Floats = c.Floats[:0]
+		c.Bools = c.Bools[:0]
+	}
+}
+
+// append adds a value to the column, nil being null
+func (c *Column) append(v interface{}) error {
+	valid := v != nil
+
+	switch c.Type {
+	case "string":
+		s, ok := v.(string)
+		if valid && !ok {
+			return fmt.Errorf("%s in a string column", typeOf(v))
+		}
+
+		c.Strings = append(c.Strings, s)
+	case "integer":
+		var i int64
+
+		if valid {
+			n, ok := v.(json.Number)
+			if !ok {
+				return fmt.Errorf("%s in an integer column", typeOf(v))
+			}
+
+			var err error
+			if i, err = n.Int64(); err != nil {
+				return fmt.Errorf("%s doesn't fit an integer column", n)
+			}
+		}
+
+		c.Ints = append(c.Ints, i)
+	case "number":
+		f, ok := toFloat(v)
+		if valid && !ok {
+			return fmt.Errorf("%s in a number column", typeOf(v))
+		}
+
+		c.Floats = append(c.Floats, f)
+	case "boolean":
+		b, ok := v.(bool)
+		if valid && !ok {
+			return fmt.Errorf("%s in a boolean column", typeOf(v))
+		}
+
+		c.Bools = append(c.Bools, b)
+	default:
+		var s string
+
+		if valid {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+
+			s = string(b)
+		}
+
+		c.Strings = append(c.Strings, s)
+	}
+
+	c.Valid = append(c.Valid, valid)
+	return nil
+}
@@ -0,0 +1,282 @@
+package jdb
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Incompatibility is a difference between the records of a collection and a
+// Go type found by CheckCompatibility
+type Incompatibility struct {
+	// Pointer is the JSON pointer of the field, array elements having
+	// their index replaced by *
+	Pointer string `json:"pointer"`
+
+	// Problem is "unknown" for fields of records the type doesn't have,
+	// "mistyped" for values the type can't hold and "missing" for fields
+	// of the type records lack
+	Problem string `json:"problem"`
+
+	Message string `json:"message"`
+
+	// Records counts the records having the problem, Example is one of them
+	Records int    `json:"records"`
+	Example string `json:"example"`
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// CheckCompatibility decodes every record of the collection against the type
+// of v, a struct or a pointer to one, and reports the fields records have
+// that the type doesn't, the values it can't hold and the fields it expects
+// that records lack. Fields that are omitempty, pointers, slices, maps or
+// interfaces aren't expected. Types decoding themselves aren't looked into
+func (d *Driver) CheckCompatibility(collection string, v interface{}) ([]Incompatibility, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("CheckCompatibility needs a struct, got %T: %w", v, ErrUnsupportedValue)
+	}
+
+	found := make(map[string]*Incompatibility)
+
+	err := d.ForEach(collection, func(ID string, raw []byte) error {
+		doc, err := decodeJSON(raw)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", collection, ID, err)
+		}
+
+		c := compatCheck{ID: ID, seen: make(map[string]bool), found: found}
+		c.value("", doc, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]Incompatibility, 0, len(found))
+	for _, i := range found {
+		report = append(report, *i)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Pointer != report[j].Pointer {
+			return report[i].Pointer < report[j].Pointer
+		}
+
+		return report[i].Problem < report[j].Problem
+	})
+
+	return report, nil
+}
+
+// compatCheck compares a record to a type
+type compatCheck struct {
+	ID    string
+	seen  map[string]bool
+	found map[string]*Incompatibility
+}
+
+func (c *compatCheck) report(pointer, problem, format string, args ...interface{}) {
+	key := pointer + " " + problem
+	if c.seen[key] {
+		return
+	}
+
+	c.seen[key] = true
+
+	i := c.found[key]
+	if i == nil {
+		i = &Incompatibility{Pointer: pointer, Problem: problem, Message: fmt.Sprintf(format, args...), Example: c.ID}
+		c.found[key] = i
+	}
+
+	i.Records++
+}
+
+func (c *compatCheck) value(pointer string, v interface{}, t reflect.Type) {
+	if v == nil {
+		return
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if reflect.PtrTo(t).Implements(jsonUnmarshaler) || reflect.PtrTo(t).Implements(textUnmarshaler) {
+		return
+	}
+
+	if !holds(t, v) {
+		c.report(pointerOrRoot(pointer), "mistyped", "%s can't hold %s", t, typeOf(v))
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		c.object(pointer, v.(map[string]interface{}), t)
+	case reflect.Map:
+		for k, e := range v.(map[string]interface{}) {
+			c.value(pointer+"/"+escapePointer(k), e, t.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		if arr, ok := v.([]interface{}); ok {
+			for _, e := range arr {
+				c.value(pointer+"/*", e, t.Elem())
+			}
+		}
+	}
+}
+
+func (c *compatCheck) object(pointer string, doc map[string]interface{}, t reflect.Type) {
+	fields := jsonFields(t)
+
+	for k, e := range doc {
+		f, ok := fields[k]
+		if !ok {
+			// encoding/json falls back to a case-insensitive match
+			for name, candidate := range fields {
+				if strings.EqualFold(name, k) {
+					f, ok = candidate, true
+					break
+				}
+			}
+		}
+
+		if !ok {
+			c.report(pointer+"/"+escapePointer(k), "unknown", "%s has no field for it", t)
+			continue
+		}
+
+		c.value(pointer+"/"+escapePointer(k), e, f.Type)
+	}
+
+	for name, f := range fields {
+		if _, ok := doc[name]; ok || !f.expected {
+			continue
+		}
+
+		c.report(pointer+"/"+escapePointer(name), "missing", "%s.%s is not in the record", t, f.Name)
+	}
+}
+
+// holds reports whether a value of type t can be decoded from the JSON value
+func holds(t reflect.Type, v interface{}) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.String:
+		_, ok := v.(string)
+		return ok
+	case reflect.Bool:
+		_, ok := v.(bool)
+		return ok
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := toFloat(v)
+		limit := math.Ldexp(1, t.Bits()-1)
+		return ok && hasType(v, "integer") && f >= -limit && f < limit
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f, ok := toFloat(v)
+		return ok && hasType(v, "integer") && f >= 0 && f < math.Ldexp(1, t.Bits())
+	case reflect.Float32, reflect.Float64:
+		_, ok := toFloat(v)
+		return ok
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			_, ok := v.(string)
+			return ok
+		}
+
+		_, ok := v.([]interface{})
+		return ok
+	case reflect.Array:
+		_, ok := v.([]interface{})
+		return ok
+	case reflect.Map:
+		_, ok := v.(map[string]interface{})
+		return ok && t.Key().Kind() == reflect.String
+	case reflect.Struct:
+		_, ok := v.(map[string]interface{})
+		return ok
+	default:
+		return false
+	}
+}
+
+// jsonField is a field of a struct as encoding/json sees it
+type jsonField struct {
+	reflect.StructField
+
+	// expected is set for fields records should have: not omitempty and of
+	// a type that can't be null
+	expected bool
+}
+
+// jsonFields returns the fields of the struct by JSON name, following the
+// tags and embedded structs like encoding/json does
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				for k, e := range jsonFields(ft) {
+					if _, ok := fields[k]; !ok {
+						fields[k] = e
+					}
+				}
+
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		if opts == "string" || strings.Contains(opts, ",string") {
+			// quoted values, only the presence of the field is checked
+			f.Type = reflect.TypeOf("")
+		}
+
+		omitempty := strings.Contains(","+opts+",", ",omitempty,")
+
+		switch f.Type.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			omitempty = true
+		}
+
+		fields[name] = jsonField{StructField: f, expected: !omitempty}
+	}
+
+	return fields
+}
@@ -0,0 +1,149 @@
+package jdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Condition decides whether WriteIf may write, it gets the current content of
+// the record and whether it exists and returns an error to refuse the write
+type Condition func(current []byte, exists bool) error
+
+// NotExists only lets the write through when the record doesn't exist
+func NotExists() Condition {
+	return func(current []byte, exists bool) error {
+		if exists {
+			return fmt.Errorf("record exists: %w", ErrConditionFailed)
+		}
+		return nil
+	}
+}
+
+// FieldEquals only lets the write through when the (dotted) field of the
+// existing record equals value
+func FieldEquals(field string, value interface{}) Condition {
+	return func(current []byte, exists bool) error {
+		if !exists {
+			return fmt.Errorf("record doesn't exist: %w", ErrConditionFailed)
+		}
+
+		want, ok := valueKey(value)
+		if !ok {
+			return fmt.Errorf("unable to encode value %v", value)
+		}
+
+		if got, ok := fieldKey(current, field); !ok || got != want {
+			return fmt.Errorf("%s is not %s: %w", field, want, ErrConditionFailed)
+		}
+
+		return nil
+	}
+}
+
+// RevisionIs only lets the write through when the record is still at the
+// revision returned by Revision, making compare-and-swap loops possible
+func RevisionIs(revision string) Condition {
+	return func(current []byte, exists bool) error {
+		if !exists {
+			return fmt.Errorf("record doesn't exist: %w", ErrConditionFailed)
+		}
+
+		if got := revisionOf(current); got != revision {
+			return fmt.Errorf("revision is %s, not %s: %w", got, revision, ErrConditionFailed)
+		}
+
+		return nil
+	}
+}
+
+func revisionOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Revision returns an opaque token changing whenever the content of the
+// record does, for use with RevisionIs
+func (d *Driver) Revision(collection, identifier string) (string, error) {
+	var revision string
+
+	err := d.readRecord(collection, identifier, func(b []byte) error {
+		revision = revisionOf(b)
+		return nil
+	})
+
+	return revision, err
+}
+
+// lockShared takes the collection lock and an advisory lock on the collection
+// directory, so the critical section is exclusive across processes too
+func (d *Driver) lockShared(collection string) (func(), error) {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+
+	dir := filepath.Join(d.dir, collection)
+
+	// collections stored by other engines are only seen by this Driver
+	if d.engines.base != OSStorage || d.engines.routed(dir) {
+		return mutex.Unlock, nil
+	}
+	if err := d.fs.MkdirAll(dir, 0755); err != nil {
+		mutex.Unlock()
+		return nil, err
+	}
+
+	unlock, err := lockFile(filepath.Join(dir, ".lock"))
+	if err != nil {
+		mutex.Unlock()
+		return nil, err
+	}
+
+	return func() {
+		unlock()
+		mutex.Unlock()
+	}, nil
+}
+
+// WriteIf writes the record only when the condition holds, returning an error
+// wrapping ErrConditionFailed otherwise. The check and the write happen under
+// the collection lock and an advisory file lock, so they are atomic even for
+// several processes sharing the data directory
+func (d *Driver) WriteIf(collection, identifier string, v interface{}, cond Condition) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if identifier == "" {
+		return "", fmt.Errorf("missing identifier")
+	}
+
+	done, err := d.admitLocal(collection, identifier)
+	if err != nil {
+		return identifier, err
+	}
+	defer done()
+
+	unlock, err := d.lockShared(collection)
+	if err != nil {
+		return identifier, err
+	}
+	defer unlock()
+
+	var current []byte
+
+	err = d.load(collection, identifier, func(b []byte) error {
+		current = append([]byte(nil), b...)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return identifier, err
+	}
+
+	if err := cond(current, err == nil); err != nil {
+		return identifier, fmt.Errorf("%s/%s: %w", collection, identifier, err)
+	}
+
+	return d.writeLocked(collection, identifier, v)
+}
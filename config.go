@@ -0,0 +1,79 @@
+package jdb
+
+import "time"
+
+// collectionConfig holds the settings of a single collection
+type collectionConfig struct {
+	archiveAfter  time.Duration
+	appendOnly    bool
+	hashChain     bool
+	dedup         bool
+	defaultTTL    time.Duration
+	history       bool
+	coalesce      time.Duration
+	conflict      ConflictFunc
+	lintRules     []lintRule
+	onExpire      []ExpireFunc
+	encryptFields []string
+	redactions    []redaction
+	schema        *Schema
+	strictDecode  bool
+}
+
+// config returns a copy of the settings of the collection. It takes no lock
+// as it's read by every write, see configure
+func (d *Driver) config(collection string) collectionConfig {
+	if c, ok := d.configs.Load(collection); ok {
+		return *c.(*collectionConfig)
+	}
+
+	return collectionConfig{}
+}
+
+// configure changes the settings of the collection. They're copied, changed
+// and stored anew so readers never see them half changed, the slices of the
+// copy being clipped so appending to them never writes to the stored ones
+func (d *Driver) configure(collection string, fn func(*collectionConfig)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var c collectionConfig
+	if old, ok := d.configs.Load(collection); ok {
+		c = *old.(*collectionConfig)
+	}
+
+	c.lintRules = clip(c.lintRules)
+	c.onExpire = clip(c.onExpire)
+	c.encryptFields = clip(c.encryptFields)
+	c.redactions = clip(c.redactions)
+
+	fn(&c)
+
+	c.lintRules = clip(c.lintRules)
+	c.onExpire = clip(c.onExpire)
+	c.encryptFields = clip(c.encryptFields)
+	c.redactions = clip(c.redactions)
+
+	d.configs.Store(collection, &c)
+}
+
+// clip copies the slice into one without spare capacity
+func clip[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+
+	return append(make([]T, 0, len(s)), s...)
+}
+
+// configured returns the collections having settings
+func (d *Driver) configured() []string {
+	var collections []string
+
+	d.configs.Range(func(collection, _ interface{}) bool {
+		collections = append(collections, collection.(string))
+		return true
+	})
+
+	return collections
+}
@@ -0,0 +1,66 @@
+package jdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ConflictFunc is called by Update with the stored and the incoming version
+// of a record, returning what to write instead of incoming: incoming itself,
+// a merge of both or incoming annotated, e.g. with a conflict marker. An
+// error rejects the update, wrapping ErrConflict by convention
+type ConflictFunc func(ID string, stored, incoming []byte) (interface{}, error)
+
+// SetConflictDetector makes Update of the collection hand the stored and
+// the incoming records to fn, which decides what's written, so domain rules
+// like refusing stale versions or merging counters live in one place. The
+// check and the write are atomic like WriteIf. A nil fn removes it
+func (d *Driver) SetConflictDetector(collection string, fn ConflictFunc) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.conflict = fn
+	})
+}
+
+// updateChecked is Update of a collection with a conflict detector
+func (d *Driver) updateChecked(collection, ID string, v interface{}, fn ConflictFunc) (string, error) {
+	done, err := d.admitLocal(collection, ID)
+	if err != nil {
+		return ID, err
+	}
+	defer done()
+
+	unlock, err := d.lockShared(collection)
+	if err != nil {
+		return ID, err
+	}
+	defer unlock()
+
+	var stored []byte
+
+	err = d.load(collection, ID, func(b []byte) error {
+		stored = append([]byte(nil), b...)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return ID, fmt.Errorf("unable to find record %q: %w", filepath.Join(collection, ID), ErrNotFound)
+	}
+
+	if err != nil {
+		return ID, err
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := d.encodeTo(buf, v); err != nil {
+		return ID, err
+	}
+
+	resolved, err := fn(ID, stored, buf.Bytes())
+	if err != nil {
+		return ID, fmt.Errorf("%s/%s: %w", collection, ID, err)
+	}
+
+	return d.writeLocked(collection, ID, resolved)
+}
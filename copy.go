@@ -0,0 +1,171 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// CopyRecord copies a record to another collection under the same ID
+func (d *Driver) CopyRecord(srcCollection, ID, dstCollection string) error {
+	return d.CopyRecords(srcCollection, []string{ID}, dstCollection)
+}
+
+// MoveRecord moves a record to another collection under the same ID
+func (d *Driver) MoveRecord(srcCollection, ID, dstCollection string) error {
+	return d.MoveRecords(srcCollection, []string{ID}, dstCollection)
+}
+
+// CopyRecords copies the records to another collection while holding the
+// locks of both, so other writers of either wait for the whole batch, though
+// readers of single records may see part of it. Every record is read before
+// anything is written, a missing one fails the batch untouched, and a batch
+// failing while it's written is rolled back as far as it can be
+func (d *Driver) CopyRecords(srcCollection string, IDs []string, dstCollection string) error {
+	return d.transfer(srcCollection, IDs, dstCollection, false)
+}
+
+// MoveRecords moves the records to another collection like CopyRecords,
+// deleting them from the source once they're written to the destination.
+// Rolling back writes the records deleted so far back to the source
+func (d *Driver) MoveRecords(srcCollection string, IDs []string, dstCollection string) error {
+	return d.transfer(srcCollection, IDs, dstCollection, true)
+}
+
+// transferred is the state of a record a transfer changes, to roll it back
+type transferred struct {
+	doc     json.RawMessage
+	prior   json.RawMessage
+	existed bool
+	written bool
+	deleted bool
+}
+
+func (d *Driver) transfer(src string, IDs []string, dst string, move bool) error {
+	if src == "" || dst == "" {
+		return fmt.Errorf("missing collection, no place to copy data")
+	}
+
+	if src == dst {
+		return fmt.Errorf("source and destination are both %s", src)
+	}
+
+	if err := ValidateCollection(src); err != nil {
+		return err
+	}
+
+	d.coalesced.flush(d, src)
+
+	// the batch is one write, reserved once even when it moves records
+	done, err := d.admitLocal(dst, IDs...)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	unlock := d.lockCollections(src, dst)
+	defer unlock()
+
+	if move && d.config(src).appendOnly {
+		return fmt.Errorf("%s: %w", src, ErrAppendOnly)
+	}
+
+	records := make([]transferred, len(IDs))
+
+	for i, ID := range IDs {
+		if ID == "" {
+			return fmt.Errorf("missing ID, no identifier to copy data")
+		}
+
+		err := d.load(src, ID, func(b []byte) error {
+			records[i].doc = append(json.RawMessage(nil), b...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = d.load(dst, ID, func(b []byte) error {
+			records[i].prior = append(json.RawMessage(nil), b...)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		records[i].existed = err == nil
+	}
+
+	for i, ID := range IDs {
+		if _, err := d.writeLocked(dst, ID, records[i].doc); err != nil {
+			d.rollbackTransfer(src, IDs, dst, records)
+			return err
+		}
+
+		records[i].written = true
+
+		if move {
+			if err := d.deleteRecord(src, ID); err != nil {
+				d.rollbackTransfer(src, IDs, dst, records)
+				return err
+			}
+
+			records[i].deleted = true
+		}
+	}
+
+	return nil
+}
+
+// rollbackTransfer undoes what a failed transfer changed, logging what it
+// can't undo, callers must hold the locks of both collections
+func (d *Driver) rollbackTransfer(src string, IDs []string, dst string, records []transferred) {
+	for i := len(IDs) - 1; i >= 0; i-- {
+		ID, r := IDs[i], records[i]
+
+		if r.deleted {
+			if _, err := d.writeLocked(src, ID, r.doc); err != nil {
+				d.log.Error("rolling back the move of %s/%s: %s", src, ID, err)
+			}
+		}
+
+		if !r.written {
+			continue
+		}
+
+		var err error
+		if r.existed {
+			_, err = d.writeLocked(dst, ID, r.prior)
+		} else {
+			err = d.removeRecord(dst, ID)
+		}
+
+		if err != nil {
+			d.log.Error("rolling back the copy of %s/%s: %s", dst, ID, err)
+		}
+	}
+}
+
+// lockCollections locks every collection in name order, so two callers
+// locking an overlapping set can't deadlock, and returns the unlock
+func (d *Driver) lockCollections(collections ...string) func() {
+	sorted := append([]string(nil), collections...)
+	sort.Strings(sorted)
+
+	locked := make([]string, 0, len(sorted))
+	for i, c := range sorted {
+		if i > 0 && c == sorted[i-1] {
+			continue
+		}
+
+		d.getMutex(c).Lock()
+		locked = append(locked, c)
+	}
+
+	return func() {
+		for i := len(locked) - 1; i >= 0; i-- {
+			d.getMutex(locked[i]).Unlock()
+		}
+	}
+}
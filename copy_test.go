@@ -0,0 +1,59 @@
+package jdb_test
+
+import (
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestMoveRecordWithOnePendingWrite(t *testing.T) {
+	d := jdbtest.New(t, jdb.WithMaxPendingWrites(1))
+	jdbtest.Seed(t, d, "inbox", 1, jdbtest.Sequence("m", map[string]string{"subject": "hi"}))
+
+	if err := d.MoveRecord("inbox", "m-0", "done"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := d.Exists("inbox", "m-0"); ok {
+		t.Error("m-0 is still in inbox")
+	}
+
+	if got, err := d.Read("done", "m-0"); err != nil {
+		t.Errorf("reading the moved record: %v, %s", err, got)
+	}
+}
+
+func TestFailedMoveIsRolledBack(t *testing.T) {
+	d := jdbtest.New(t)
+
+	for ID, v := range map[string]map[string]string{"a": {"name": "a"}, "b": {"name": "b"}, "c": {}} {
+		if _, err := d.Write("inbox", ID, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := d.Write("done", "a", map[string]string{"name": "older a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	inbox := jdbtest.Snapshot(t, d, "inbox")
+	done := jdbtest.Snapshot(t, d, "done")
+
+	// c has no name so the batch fails once a and b are moved
+	if err := d.SetSchema("done", &jdb.Schema{Type: "object", Required: []string{"name"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.MoveRecords("inbox", []string{"a", "b", "c"}, "done"); err == nil {
+		t.Fatal("moving a record the destination refuses succeeded")
+	}
+
+	if got := jdbtest.Snapshot(t, d, "inbox"); string(got) != string(inbox) {
+		t.Errorf("inbox holds\n%s\nwant\n%s", got, inbox)
+	}
+
+	if got := jdbtest.Snapshot(t, d, "done"); string(got) != string(done) {
+		t.Errorf("done holds\n%s\nwant\n%s", got, done)
+	}
+}
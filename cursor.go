@@ -0,0 +1,114 @@
+package jdb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// cursor is the position after the last record of a page, it's handed out
+// base64 encoded so callers treat it as opaque
+type cursor struct {
+	Order   Order  `json:"o"`
+	Created int64  `json:"c,omitempty"`
+	ID      string `json:"i"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor %q", token)
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor %q", token)
+	}
+
+	return c, nil
+}
+
+// after reports whether the record sorts after the cursor
+func (c cursor) after(r record) bool {
+	if r.created != c.Created {
+		return r.created > c.Created
+	}
+
+	return r.ID > c.ID
+}
+
+// List returns up to limit records of the collection in the Driver's Order,
+// starting after the cursor returned by the previous call or at the beginning
+// when it's empty. The next cursor is empty once the collection is exhausted;
+// records written or deleted between calls never make a page skip or repeat
+// the records around them
+func (d *Driver) List(collection, token string, limit int) ([]string, string, error) {
+	if collection == "" {
+		return nil, "", fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("limit must be positive, got %d", limit)
+	}
+
+	var from cursor
+
+	if token != "" {
+		var err error
+		if from, err = decodeCursor(token); err != nil {
+			return nil, "", err
+		}
+
+		if from.Order != d.order {
+			return nil, "", fmt.Errorf("cursor was made for another order")
+		}
+	}
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.listRecords(collection)
+	if err != nil {
+		return nil, "", err
+	}
+
+	start := 0
+	if token != "" {
+		start = sort.Search(len(files), func(i int) bool {
+			return from.after(files[i])
+		})
+	}
+
+	end := start + limit
+	if end > len(files) {
+		end = len(files)
+	}
+
+	records := make([]string, 0, end-start)
+
+	for _, file := range files[start:end] {
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			records = append(records, string(b))
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	next := ""
+	if end < len(files) {
+		last := files[end-1]
+		next = cursor{Order: d.order, Created: last.created, ID: last.ID}.encode()
+	}
+
+	return records, next, nil
+}
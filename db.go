@@ -1,13 +1,14 @@
 package jdb
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/jcelliott/lumber"
 )
 
@@ -25,44 +26,399 @@ type (
 
 	Driver struct {
 		mutex   sync.Mutex
-		mutexes map[string]*sync.Mutex
+		mutexes sync.Map // collection to *collectionLock
+		stripes []sync.Mutex
 		dir     string
 		log     Logger
+		mmap    int64
+		layers  []string
+		fs      Storage
+		engines *engineRouter
+		onDisk  bool
+		clock   Clock
+		newID   IDGenerator
+		json    JSONOptions
+		codec   Codec
+		sync    SyncMode
+		order   Order
+
+		trashRetention time.Duration
+
+		keys       keyring
+		fieldsOnly bool
+
+		onProgress func(Progress)
+
+		// writeLimiter is Options.WriteRate, limiters the rates of the
+		// collections
+		writeLimiter *limiter
+		limiters     map[string]*limiter
+
+		pending    int
+		maxPending int
+
+		// frozen counts the Freezes not thawed yet, thawed is closed by the
+		// last Thaw and idle once the pending writes are done
+		frozen        int
+		thawed        chan struct{}
+		idle          chan struct{}
+		freezeTimeout time.Duration
+
+		statsSample   int
+		slowThreshold time.Duration
+
+		replicator Replicator
+
+		handles   *handleCache
+		async     *asyncPool
+		coalesced *coalescer
+
+		leaseTerm time.Duration
+		holder    string
+		leader    bool
+
+		blooms    sync.Map // collection to *bloom
+		bloomSize int
+
+		hidden sync.Map // queue collection to *hiddenMessages
+
+		// blobs is read locked by the writers of deduplicated records while
+		// they link a blob and locked by PruneBlobs
+		blobs sync.RWMutex
+
+		indexes map[string]map[string]*index
+
+		creations map[string]int
+		configs   sync.Map // collection to *collectionConfig, never changed once stored
+		swaps     map[string]chan struct{}
+
+		chainHeads map[string]string
+
+		subscribers map[*subscriber]struct{}
+		watcher     *fsnotify.Watcher
+		expected    map[string]int
+
+		done      chan struct{}
+		closeOnce sync.Once
+
+		// loops are the goroutines of the Driver, sweepLoop, leaseLoop and
+		// watchLoop, Close waits for them once done is closed
+		loops sync.WaitGroup
 	}
 
 	Options struct {
 		Logger
+
+		// MmapThreshold makes records of at least this many bytes to be read
+		// through a memory mapping instead of read syscalls, zero disables it
+		MmapThreshold int64
+
+		// BloomFilterSize enables a bloom filter per collection sized for this
+		// many records, so lookups of missing IDs skip the stat syscall. Files
+		// added behind the Driver's back are not seen until it's rebuilt
+		BloomFilterSize int
+
+		// WatchFiles watches the data directory for records edited outside
+		// of the Driver, refreshing caches and indexes and emitting events
+		WatchFiles bool
+
+		// ReadOnlyDirs are data directories merged under dir at read time,
+		// e.g. seed data shipped with a binary. Records in dir win over them
+		// and earlier directories win over later ones, writes only go to dir
+		ReadOnlyDirs []string
+
+		// Storage is where records are kept, defaults to OSStorage. Memory
+		// mapped reads and WatchFiles only work with OSStorage
+		Storage Storage
+
+		// Clock is where the Driver reads the time from, defaulting to the
+		// system clock. Records stored by OSStorage keep the modification
+		// times of the system though, so BackupSince only backs up what
+		// changed when the clock follows it
+		Clock Clock
+
+		// IDGenerator makes the IDs of inserted records, defaults to UUIDs
+		IDGenerator IDGenerator
+
+		// JSON tunes how records are encoded and decoded
+		JSON JSONOptions
+
+		// Codec encodes every record once it's JSON encoded and compressed,
+		// e.g. into a binary format, and decodes it as it's read. Decode
+		// must take records written without it, as GzipCodec does. The
+		// codecs of collection engines encode its output, see SetEngine
+		Codec Codec
+
+		// Sync is how durable writes are once they return, defaults to
+		// SyncNever
+		Sync SyncMode
+
+		// Order is the order ReadAll, ReadAllInto and IDs return records in,
+		// defaults to OrderByID. It doesn't depend on the OS directory order
+		Order Order
+
+		// TTLSweepInterval runs SweepExpired and PurgeTrash in the
+		// background at this interval until the Driver is closed, zero
+		// disables it
+		TTLSweepInterval time.Duration
+
+		// TrashRetention makes Delete move records to a trash they can be
+		// restored from with Undelete, until PurgeTrash removes them once
+		// they've been deleted for longer than this. Zero deletes for good
+		TrashRetention time.Duration
+
+		// EncryptionKey encrypts records at rest with AES-GCM, it's an
+		// AES-128, AES-192 or AES-256 key. Plain records are still read and
+		// get encrypted when written, see RotateKey. Their copies in the
+		// trash, the archive and the history are encrypted too, while
+		// indexes keep the values of the fields they index in plain
+		EncryptionKey []byte
+
+		// DecryptionKeys are previous encryption keys records may still be
+		// encrypted with, e.g. while a RotateKey is resumed
+		DecryptionKeys [][]byte
+
+		// EncryptFieldsOnly only uses EncryptionKey for the fields set with
+		// SetEncryptedFields, records themselves are kept plain
+		EncryptFieldsOnly bool
+
+		// OnProgress is called as long operations like backups, imports or
+		// index rebuilds go, e.g. to show a progress bar. It's called while
+		// holding collection locks so it must not use the Driver
+		OnProgress func(Progress)
+
+		// WriteRate limits writes to this many per second with bursts of up
+		// to WriteBurst writes, writers over it wait for their turn. Zero
+		// disables it, see also SetWriteRate
+		WriteRate  float64
+		WriteBurst int
+
+		// MaxPendingWrites makes writes fail with ErrOverloaded rather than
+		// queue up when this many are already waiting, zero doesn't bound them
+		MaxPendingWrites int
+
+		// FreezeTimeout is how long writes made while writes are frozen
+		// wait for Thaw before failing with ErrFrozen. Zero fails them at
+		// once, a negative timeout waits however long it takes. See Freeze
+		FreezeTimeout time.Duration
+
+		// ReadOnly opens an existing database for reads only, writes fail
+		// with ErrReadOnly. See OpenReplica
+		ReadOnly bool
+
+		// LeaderLease elects a leader among the processes sharing the data
+		// directory through a lease renewed by heartbeats, only the leader
+		// runs background maintenance like TTL sweeps. A leader that stops
+		// is replaced once its lease expires. Zero makes every Driver lead
+		LeaderLease time.Duration
+
+		// Replicator sends Write, Insert, Update and Delete through a
+		// replicated log instead of applying them, see Replicator
+		Replicator Replicator
+
+		// HandleCacheSize keeps the files of up to this many recently read
+		// records open, saving the open and close syscalls of hot reads.
+		// Records changed by other processes may be read stale unless
+		// WatchFiles is set. Only used with OSStorage, zero disables it
+		HandleCacheSize int
+
+		// LockStripes lets writes to different records of a collection run
+		// in parallel, serializing them on this many locks picked by record
+		// instead of on the collection. Collections with hash chains or
+		// deduplication, and OrderByCreation, keep serializing writes. Zero
+		// serializes every write to a collection
+		LockStripes int
+
+		// SlowThreshold logs the reads, writes, deletes and queries taking
+		// longer, with their collection, duration and the bytes they read
+		// or wrote. Zero logs none
+		SlowThreshold time.Duration
+
+		// StatsSample is how many records of each collection Stats reads to
+		// describe their fields, defaults to 1000. A negative sample skips
+		// the fields
+		StatsSample int
+
+		// AsyncWorkers is how many goroutines run the writes of WriteAsync,
+		// defaults to 4
+		AsyncWorkers int
+
+		// Collections declares collections up front, their settings are
+		// applied and their on-disk state checked against them by New, see
+		// CollectionSpec
+		Collections []CollectionSpec
+
+		// DefaultTTL is the TTL of records of the declared Collections that
+		// don't set one of their own
+		DefaultTTL time.Duration
+
+		// VerifyOnOpen checks every record when New opens the Driver, before
+		// indexes are built, reporting or quarantining the bad ones. Nil
+		// skips the check, see Verify
+		VerifyOnOpen *VerifyOptions
 	}
 )
 
-// New create a new instance of Driver
-func New(dir string, opt *Options) (*Driver, error) {
+// New create a new instance of Driver, configured by the options applied in
+// order, see Option
+func New(dir string, options ...Option) (*Driver, error) {
 	dir = filepath.Clean(dir)
 
-	opts := Options{}
-
-	if opt != nil {
-		opts = *opt
-	}
+	opts := buildOptions(options)
 
 	if opts.Logger == nil {
 		opts.Logger = lumber.NewConsoleLogger((lumber.INFO))
 	}
 
+	if opts.Storage == nil {
+		opts.Storage = OSStorage
+	}
+
+	onDisk := opts.Storage == OSStorage
+
+	if opts.ReadOnly {
+		opts.Storage = readOnlyStorage{opts.Storage}
+	}
+
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+
+	if opts.IDGenerator == nil {
+		opts.IDGenerator = newUUID
+	}
+
+	engines := newEngineRouter(opts.Storage)
+
+	var layers []string
+	for _, layer := range opts.ReadOnlyDirs {
+		layers = append(layers, filepath.Clean(layer))
+	}
+
 	driver := Driver{
 		dir:     dir,
-		mutexes: make(map[string]*sync.Mutex),
 		log:     opts.Logger,
+		engines: engines,
+		mmap:    opts.MmapThreshold,
+		layers:  layers,
+		fs:      engines,
+		onDisk:  onDisk,
+		clock:   opts.Clock,
+		newID:   opts.IDGenerator,
+		json:    opts.JSON.withDefaults(),
+		codec:   opts.Codec,
+		sync:    opts.Sync,
+		order:   opts.Order,
+
+		trashRetention: opts.TrashRetention,
+		fieldsOnly:     opts.EncryptFieldsOnly,
+		onProgress:     opts.OnProgress,
+		maxPending:     opts.MaxPendingWrites,
+		freezeTimeout:  opts.FreezeTimeout,
+		statsSample:    opts.StatsSample,
+		slowThreshold:  opts.SlowThreshold,
+		leaseTerm:      opts.LeaderLease,
+		replicator:     opts.Replicator,
+
+		limiters: make(map[string]*limiter),
+
+		bloomSize: opts.BloomFilterSize,
+
+		indexes: make(map[string]map[string]*index),
+
+		creations: make(map[string]int),
+		swaps:     make(map[string]chan struct{}),
+
+		chainHeads: make(map[string]string),
+
+		subscribers: make(map[*subscriber]struct{}),
+		expected:    make(map[string]int),
+
+		done: make(chan struct{}),
+	}
+
+	driver.async = newAsyncPool(opts.AsyncWorkers)
+	driver.coalesced = newCoalescer()
+
+	if driver.statsSample == 0 {
+		driver.statsSample = defaultStatsSample
+	}
+
+	if opts.LockStripes > 0 {
+		driver.stripes = make([]sync.Mutex, opts.LockStripes)
+	}
+
+	if opts.HandleCacheSize > 0 && onDisk {
+		driver.handles = newHandleCache(opts.HandleCacheSize)
+	}
+
+	if opts.WriteRate > 0 {
+		driver.writeLimiter = newLimiter(opts.WriteRate, opts.WriteBurst, opts.Clock.Now())
 	}
 
-	if _, err := os.Stat(dir); err == nil {
+	for _, key := range opts.DecryptionKeys {
+		if _, err := driver.keys.add(key); err != nil {
+			return nil, driver.abort(err)
+		}
+	}
+
+	rk, err := driver.keys.add(opts.EncryptionKey)
+	if err != nil {
+		return nil, driver.abort(err)
+	}
+
+	driver.keys.use(rk)
+
+	if _, err := opts.Storage.Stat(dir); err == nil {
 		opts.Logger.Debug("%s already exists", dir)
-		return &driver, nil
+	} else {
+		opts.Logger.Debug("creating %s database", dir)
+
+		if err := opts.Storage.MkdirAll(dir, 0755); err != nil {
+			return nil, driver.abort(err)
+		}
+	}
+
+	if err := driver.declare(opts.Collections, opts.DefaultTTL); err != nil {
+		return nil, driver.abort(err)
+	}
+
+	if opts.VerifyOnOpen != nil {
+		if err := driver.verifyOnOpen(*opts.VerifyOnOpen); err != nil {
+			return nil, driver.abort(err)
+		}
 	}
 
-	opts.Logger.Debug("creating %s database", dir)
+	if err := driver.repair(opts.Collections, opts.DefaultTTL, opts.ReadOnly); err != nil {
+		return nil, driver.abort(err)
+	}
+
+	if opts.WatchFiles && onDisk {
+		if err := driver.watchFiles(); err != nil {
+			return nil, driver.abort(err)
+		}
+	}
+
+	if opts.LeaderLease > 0 {
+		driver.holder = newUUID()
 
-	return &driver, os.MkdirAll(dir, 0755)
+		if err := driver.campaign(); err != nil {
+			return nil, driver.abort(err)
+		}
+
+		driver.loops.Add(1)
+		go driver.leaseLoop()
+	}
+
+	switch {
+	case opts.TTLSweepInterval > 0 && opts.Replicator != nil:
+		opts.Logger.Warn("not sweeping expired records, sweeps aren't replicated")
+	case opts.TTLSweepInterval > 0:
+		driver.loops.Add(1)
+		go driver.sweepLoop(opts.TTLSweepInterval)
+	}
+
+	return &driver, nil
 }
 
 func (d *Driver) Write(collection, identifier string, v interface{}) (string, error) {
@@ -74,35 +430,162 @@ func (d *Driver) Write(collection, identifier string, v interface{}) (string, er
 		return "", fmt.Errorf("missing identifier")
 	}
 
+	if err := validateRecord(collection, identifier); err != nil {
+		return identifier, err
+	}
+
+	if interval := d.config(collection).coalesce; interval > 0 {
+		return identifier, <-d.coalesce(collection, identifier, v, interval)
+	}
+
 	return d.doWrite(collection, identifier, v)
 }
 
+// Insert writes v as a new record under an ID made by the IDGenerator. When
+// v points to a struct with a string field tagged `jdb:"id"` the field is set
+// to the ID before v is written
+func (d *Driver) Insert(collection string, v interface{}) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection, no place to save data")
+	}
+
+	ID := d.newID()
+	if err := validateRecord(collection, ID); err != nil {
+		return ID, err
+	}
+
+	if err := assignID(v, ID); err != nil {
+		return "", err
+	}
+
+	return d.doWrite(collection, ID, v)
+}
+
 func (d *Driver) doWrite(collection, ID string, v interface{}) (string, error) {
-	mutex := d.getMutex(collection)
-	mutex.Lock()
-	defer mutex.Unlock()
+	defer d.logSlowWrite(collection, ID, d.clock.Now())
+
+	done, err := d.admitStore(collection, ID)
+	if err != nil {
+		return ID, err
+	}
+	defer done()
+
+	return ID, d.applyWrite(collection, ID, v)
+}
+
+// applyWrite writes the admitted record, through the Replicator if any
+func (d *Driver) applyWrite(collection, ID string, v interface{}) error {
+	if d.replicator != nil {
+		return d.replicate(OpWrite, collection, ID, v)
+	}
+
+	_, err := d.writeLocal(collection, ID, v)
+	return err
+}
+
+func (d *Driver) writeLocal(collection, ID string, v interface{}) (string, error) {
+	unlock := d.lockRecord(collection, ID)
+	defer unlock()
+
+	return d.writeLocked(collection, ID, v)
+}
+
+// writeLocked writes the record, callers must hold the collection lock or
+// the record lock, see lockRecord
+func (d *Driver) writeLocked(collection, ID string, v interface{}) (string, error) {
+	if err := validateStored(collection, ID); err != nil {
+		return ID, err
+	}
 
 	dir := filepath.Join(d.dir, collection)
 	fnlPath := filepath.Join(dir, ID+".json")
 	tmpPath := fnlPath + ".tmp"
 
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := d.fs.MkdirAll(dir, 0755); err != nil {
+		return ID, err
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := d.encodeTo(buf, v); err != nil {
+		return ID, err
+	}
+
+	b := buf.Bytes()
+
+	if err := d.validate(collection, ID, b); err != nil {
+		return ID, err
+	}
+
+	if err := d.checkAppendOnly(collection, ID); err != nil {
 		return ID, err
 	}
 
-	b, err := json.MarshalIndent(v, "", "\t")
+	if err := d.recordHistory(collection, ID, b); err != nil {
+		return ID, err
+	}
+
+	created := false
+	if d.order == OrderByCreation {
+		_, err := d.locate(collection, ID)
+		created = os.IsNotExist(err)
+	}
+
+	stored, err := d.encryptFields(collection, b)
 	if err != nil {
 		return ID, err
 	}
 
-	b = append(b, byte('\n'))
+	if err := d.writeTemp(collection, tmpPath, stored); err != nil {
+		return ID, err
+	}
+
+	if err := d.syncFile(tmpPath); err != nil {
+		return ID, err
+	}
+
+	if err := d.clearWhiteout(collection, ID); err != nil {
+		return ID, err
+	}
+
+	d.expectChange(fnlPath)
+
+	if err := d.fs.Rename(tmpPath, fnlPath); err != nil {
+		d.expectedChange(fnlPath)
+		return ID, err
+	}
+
+	if err := d.syncFile(dir); err != nil {
+		return ID, err
+	}
+
+	d.handles.evict(fnlPath)
+
+	if created {
+		if err := d.recordCreation(collection, ID); err != nil {
+			return ID, err
+		}
+	}
 
-	if err := ioutil.WriteFile(tmpPath, b, 0644); err != nil {
+	if err := d.chain(collection, ID, b); err != nil {
 		return ID, err
 	}
 
+	if err := d.applyDefaultTTL(collection, ID); err != nil {
+		return ID, err
+	}
+
+	lock := d.getMutex(collection)
+	lock.shared.Lock()
+	d.addBloom(collection, ID)
+	d.indexRecord(collection, ID, b)
+	lock.shared.Unlock()
+
+	d.emit(Event{Collection: collection, ID: ID, Op: OpWrite})
+
 	d.log.Info("done creating: %s", ID)
-	return ID, os.Rename(tmpPath, fnlPath)
+	return ID, nil
 }
 
 func (d *Driver) Read(collection, identifier string) (string, error) {
@@ -114,100 +597,411 @@ func (d *Driver) Read(collection, identifier string) (string, error) {
 		return "", fmt.Errorf("missing ID, no identifier to get data")
 	}
 
-	record := filepath.Join(d.dir, collection, identifier)
-
-	if _, err := stat(record); err != nil {
+	if err := validateRecord(collection, identifier); err != nil {
 		return "", err
 	}
 
-	b, err := ioutil.ReadFile(record + ".json")
+	start := d.clock.Now()
+
+	var data string
+
+	err := d.readRecord(collection, identifier, func(b []byte) error {
+		data = string(b)
+		return nil
+	})
 	if err != nil {
 		return "", err
 	}
 
-	return string(b), nil
+	d.logSlow("read", collection, start, int64(len(data)))
+	return data, nil
 }
 
-func (d *Driver) ReadAll(collection string) ([]string, error) {
+// Exists reports whether a record with the identifier is in the collection
+func (d *Driver) Exists(collection, identifier string) (bool, error) {
 	if collection == "" {
-		return nil, fmt.Errorf("missing collection, no place to get data")
+		return false, fmt.Errorf("missing collection, no place to get data")
 	}
 
-	var records []string
+	if identifier == "" {
+		return false, fmt.Errorf("missing ID, no identifier to get data")
+	}
 
-	dir := filepath.Join(d.dir, collection)
+	if err := validateRecord(collection, identifier); err != nil {
+		return false, err
+	}
+
+	if !d.mayExist(collection, identifier) {
+		return false, nil
+	}
+
+	switch _, err := d.locate(collection, identifier); {
+	case err == nil:
+		return true, nil
+	case os.IsNotExist(err):
+		return d.archived(collection, identifier), nil
+	default:
+		return false, err
+	}
+}
 
-	if _, err := stat(dir); err != nil {
+// ReadAll returns every record of the collection in the Driver's Order as a
+// consistent snapshot, writes and deletes wait until the scan is done
+func (d *Driver) ReadAll(collection string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if err := ValidateCollection(collection); err != nil {
 		return nil, err
 	}
 
-	files, err := ioutil.ReadDir(dir)
+	start := d.clock.Now()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var records []string
+
+	files, err := d.listRecords(collection)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, file := range files {
-		b, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			records = append(records, string(b))
+			return nil
+		})
 		if err != nil {
 			return nil, err
 		}
-
-		records = append(records, string(b))
 	}
 
+	d.logSlow("read-all", collection, start, recordsSize(records))
 	return records, nil
 }
 
+// IDs returns the identifiers of every record in the Driver's Order
+func (d *Driver) IDs(collection string) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if err := ValidateCollection(collection); err != nil {
+		return nil, err
+	}
+
+	files, err := d.listRecords(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	IDs := make([]string, 0, len(files))
+	for _, file := range files {
+		IDs = append(IDs, file.ID)
+	}
+
+	return IDs, nil
+}
+
+// Update overwrites an existing record, keeping its history when the
+// collection has one. The conflict detector of the collection, if any, decides
+// what's written, see SetConflictDetector
 func (d *Driver) Update(collection, ID string, v interface{}) (string, error) {
-	if err := d.doDelete(collection, ID); err != nil {
+	if fn := d.config(collection).conflict; fn != nil {
+		return d.updateChecked(collection, ID, v, fn)
+	}
+
+	exists, err := d.Exists(collection, ID)
+	if err != nil {
 		return ID, err
 	}
 
+	if !exists {
+		return ID, fmt.Errorf("unable to find record %q: %w", filepath.Join(collection, ID), ErrNotFound)
+	}
+
 	return d.doWrite(collection, ID, v)
 }
 
+// Delete removes a single record, see DeleteRecord
 func (d *Driver) Delete(collection, ID string) error {
+	return d.DeleteRecord(collection, ID)
+}
+
+// DeleteRecord removes a single record, it never removes a directory so a
+// malformed ID can't wipe a collection, use DropCollection for that
+func (d *Driver) DeleteRecord(collection, ID string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to delete data")
+	}
+
+	if ID == "" {
+		return fmt.Errorf("missing ID, no identifier to delete data")
+	}
+
+	if err := validateRecord(collection, ID); err != nil {
+		return err
+	}
+
 	return d.doDelete(collection, ID)
 }
 
 func (d *Driver) doDelete(collection, ID string) error {
-	path := filepath.Join(collection, ID)
+	defer d.logSlow("delete", collection, d.clock.Now(), 0)
+
+	done, err := d.admitStore(collection, ID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if d.replicator != nil {
+		return d.replicate(OpDelete, collection, ID, nil)
+	}
+
+	return d.deleteLocal(collection, ID)
+}
+
+func (d *Driver) deleteLocal(collection, ID string) error {
 	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return d.deleteLocked(collection, ID)
+}
+
+// deleteLocked deletes the record, callers must hold the collection lock
+func (d *Driver) deleteLocked(collection, ID string) error {
+	if d.config(collection).appendOnly {
+		return fmt.Errorf("%s/%s: %w", collection, ID, ErrAppendOnly)
+	}
+
+	switch _, err := d.locate(collection, ID); {
+	case err == nil, os.IsNotExist(err) && d.archived(collection, ID):
+		return d.deleteRecord(collection, ID)
+	case os.IsNotExist(err):
+		return fmt.Errorf("unable to find record %q: %w", filepath.Join(collection, ID), ErrNotFound)
+	default:
+		return err
+	}
+}
+
+// DropCollection removes a collection with all of its records, indexes,
+// history and archive. It only does so when confirm is true, as a guard
+// against dropping a collection by accident
+func (d *Driver) DropCollection(collection string, confirm bool) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, nothing to drop")
+	}
+
+	if err := ValidateCollection(collection); err != nil {
+		return err
+	}
+
+	if !confirm {
+		return fmt.Errorf("dropping %s: %w", collection, ErrNotConfirmed)
+	}
+
+	done, err := d.admitLocal(collection)
+	if err != nil {
+		return err
+	}
+	defer done()
 
+	mutex := d.getMutex(collection)
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	dir := filepath.Join(d.dir, path)
+	if d.config(collection).appendOnly {
+		return fmt.Errorf("%s: %w", collection, ErrAppendOnly)
+	}
+
+	dir := filepath.Join(d.dir, collection)
+
+	info, err := d.fs.Stat(dir)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a collection", collection)
+	}
+
+	d.unindexRecord(collection, "")
+
+	d.blooms.Delete(collection)
+
+	for _, reserved := range []string{archiveDir, historyDir, ttlDir, idempotencyDir} {
+		if err := d.fs.RemoveAll(filepath.Join(d.dir, reserved, collection)); err != nil {
+			return err
+		}
+	}
+
+	defer d.handles.evictDir(dir)
+
+	if d.trashRetention > 0 {
+		return d.trashTree(collection, "", dir)
+	}
+
+	return d.fs.RemoveAll(dir)
+}
+
+// deleteRecord moves the record to the trash when it's enabled and removes
+// it, callers must hold the collection lock
+func (d *Driver) deleteRecord(collection, ID string) error {
+	if err := d.trashRecord(collection, ID); err != nil {
+		return err
+	}
+
+	return d.removeRecord(collection, ID)
+}
+
+// removeRecord deletes the record file, hiding it behind a whiteout when a
+// read-only directory has it too. Callers must hold the collection lock
+func (d *Driver) removeRecord(collection, ID string) error {
+	if err := validateStored(collection, ID); err != nil {
+		return err
+	}
+
+	path := filepath.Join(d.dir, collection, ID+".json")
+
+	d.expectChange(path)
+	if err := d.fs.RemoveAll(path); err != nil {
+		d.expectedChange(path)
+		return err
+	}
+
+	d.handles.evict(path)
+
+	if err := d.syncFile(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	if err := d.fs.RemoveAll(d.archivePath(collection, ID)); err != nil {
+		return err
+	}
+
+	if err := d.dropHistory(collection, ID); err != nil {
+		return err
+	}
+
+	if err := d.dropTTL(collection, ID); err != nil {
+		return err
+	}
+
+	if d.inLayers(collection, ID) {
+		if err := d.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
 
-	switch file, err := stat(dir); {
-	case file == nil, err != nil:
-		return fmt.Errorf("unable to find directory %q", path)
-	case file.Mode().IsDir():
-		return os.RemoveAll(dir)
-	case file.Mode().IsRegular():
-		os.RemoveAll(dir + ".json")
+		if err := d.fs.WriteFile(d.whiteout(collection, ID), nil, 0644); err != nil {
+			return err
+		}
 	}
 
+	d.unindexRecord(collection, ID)
+	d.emit(Event{Collection: collection, ID: ID, Op: OpDelete})
+
 	return nil
 }
 
-func (d *Driver) getMutex(collection string) *sync.Mutex {
-	d.mutex.Lock()
-	defer d.mutex.Unlock()
+// readRecord hands the content of a record to fn, reading it from the data
+// directory, the read-only ones or the archive
+func (d *Driver) readRecord(collection, ID string, fn func([]byte) error) error {
+	if !d.mayExist(collection, ID) {
+		return notExist(filepath.Join(d.dir, collection, ID+".json"))
+	}
+
+	return d.load(collection, ID, fn)
+}
+
+// load is readRecord without the bloom filter, which takes the collection
+// lock, so it can be used while holding it
+func (d *Driver) load(collection, ID string, fn func([]byte) error) error {
+	if err := validateStored(collection, ID); err != nil {
+		return err
+	}
+
+	err := d.loadFile(collection, ID, fn)
+	if os.IsNotExist(err) && d.awaitSwap(collection) {
+		err = d.loadFile(collection, ID, fn)
+	}
+
+	if os.IsNotExist(err) && d.archived(collection, ID) {
+		return d.readArchived(collection, ID, fn)
+	}
+
+	return err
+}
+
+// readFile hands the content of path, a record of the collection, to fn. The
+// slice is only valid until fn returns because it may be backed by a memory
+// mapping
+func (d *Driver) readFile(collection, path string, fn func([]byte) error) error {
+	fn = d.decrypted(collection, fn)
+
+	if !d.onDisk || d.engines.routed(path) {
+		b, err := d.fs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return fn(b)
+	}
+
+	if d.handles != nil {
+		h, err := d.handles.acquire(path)
+		if err != nil {
+			return err
+		}
+		defer d.handles.release(h)
+
+		return d.readOpen(h.f, h.size, fn)
+	}
 
-	m, ok := d.mutexes[collection]
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	if !ok {
-		m = &sync.Mutex{}
-		d.mutexes[collection] = m
+	info, err := f.Stat()
+	if err != nil {
+		return err
 	}
 
-	return m
+	return d.readOpen(f, info.Size(), fn)
 }
 
-func stat(path string) (file os.FileInfo, err error) {
-	if file, err = os.Stat(path); os.IsNotExist(err) {
-		file, err = os.Stat(path + ".json")
+// readOpen hands the size bytes of the open file to fn, reading at offsets
+// so cached handles can be shared by concurrent readers
+func (d *Driver) readOpen(f *os.File, size int64, fn func([]byte) error) error {
+	if d.mmap > 0 && size >= d.mmap {
+		b, err := mmap(f, size)
+		if err == nil {
+			defer munmap(b)
+			return fn(b)
+		}
+
+		d.log.Debug("mmap %s failed, falling back to read: %s", f.Name(), err)
 	}
-	return
+
+	b := make([]byte, size)
+	if n, err := f.ReadAt(b, 0); n < len(b) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	return fn(b)
+}
+
+// notExist is the error of missing records, it wraps ErrNotFound
+func notExist(path string) error {
+	return &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
 }
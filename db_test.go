@@ -0,0 +1,71 @@
+package jdb_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestCloseStopsEveryGoroutine(t *testing.T) {
+	clock := jdbtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	before := runtime.NumGoroutine()
+
+	d, err := jdb.New(filepath.Join(t.TempDir(), "db"),
+		jdb.WithLogger(jdbtest.Logger(t)),
+		jdb.WithClock(clock),
+		jdb.WithWatchFiles(),
+		jdb.WithLeaderLease(time.Minute),
+		jdb.WithTTLSweep(time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both loops wait for the clock
+	for clock.Waiters() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("closing: %s", err)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after Close, %d before New", after, before)
+	}
+}
+
+func TestFailedNewStopsEveryGoroutine(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+
+	// the lease can't be read, so campaigning fails once files are watched
+	if err := os.MkdirAll(filepath.Join(dir, ".leader"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	before := runtime.NumGoroutine()
+
+	d, err := jdb.New(dir,
+		jdb.WithLogger(jdbtest.Logger(t)),
+		jdb.WithWatchFiles(),
+		jdb.WithLeaderLease(time.Minute),
+		jdb.WithTTLSweep(time.Minute),
+	)
+	if err == nil {
+		d.Close()
+		t.Fatal("New succeeded without a readable lease")
+	}
+
+	if d != nil {
+		t.Errorf("New returned a Driver along with %s", err)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after a failed New, %d before", after, before)
+	}
+}
@@ -0,0 +1,161 @@
+package jdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CollectionSpec declares a collection in Options.Collections
+type CollectionSpec struct {
+	Name string
+
+	// Indexes are the dotted fields indexed, see EnsureIndex
+	Indexes []string
+
+	// TTL is the default TTL of the records, see SetDefaultTTL. Zero uses
+	// Options.DefaultTTL and a negative TTL turns it off
+	TTL time.Duration
+
+	// Schema validates the records written, see SetSchema
+	Schema *Schema
+
+	// History keeps the previous versions of records, see SetHistory
+	History bool
+
+	// AppendOnly refuses updates and deletes, see SetAppendOnly
+	AppendOnly bool
+
+	// HashChain chains the records of an AppendOnly collection
+	HashChain bool
+
+	// Lint are the rules Lint checks the records against, see
+	// SetLintRules
+	Lint []LintRule
+
+	// Conflict checks the updates, see SetConflictDetector
+	Conflict ConflictFunc
+
+	// Coalesce holds the writes for up to its interval, see SetCoalesce
+	Coalesce time.Duration
+
+	// Engine stores the records, EngineFiles by default, their files
+	// going through Codec when it's set. See SetEngine
+	Engine Engine
+	Codec  Codec
+}
+
+// declare applies the settings of the declared collections
+func (d *Driver) declare(specs []CollectionSpec, defaultTTL time.Duration) error {
+	seen := make(map[string]bool, len(specs))
+
+	for _, spec := range specs {
+		if err := ValidateCollection(spec.Name); err != nil {
+			return err
+		}
+
+		if seen[spec.Name] {
+			return fmt.Errorf("collection %s is declared twice", spec.Name)
+		}
+
+		seen[spec.Name] = true
+
+		if spec.Engine != "" || spec.Codec != nil {
+			if err := d.setEngine(spec.Name, spec.Engine, spec.Codec); err != nil {
+				return fmt.Errorf("collection %s: %w", spec.Name, err)
+			}
+		}
+
+		if err := d.SetSchema(spec.Name, spec.Schema); err != nil {
+			return fmt.Errorf("collection %s: %w", spec.Name, err)
+		}
+
+		if err := d.SetLintRules(spec.Name, spec.Lint); err != nil {
+			return fmt.Errorf("collection %s: %w", spec.Name, err)
+		}
+
+		d.SetDefaultTTL(spec.Name, spec.ttl(defaultTTL))
+		d.SetHistory(spec.Name, spec.History)
+		d.SetCoalesce(spec.Name, spec.Coalesce)
+		d.SetConflictDetector(spec.Name, spec.Conflict)
+
+		if spec.AppendOnly {
+			d.SetAppendOnly(spec.Name, spec.HashChain)
+		}
+	}
+
+	return nil
+}
+
+// repair checks the records of the declared collections against them:
+// indexes are built, records missing the default TTL get it and TTLs of
+// missing records are dropped. Read-only Drivers only build the indexes
+func (d *Driver) repair(specs []CollectionSpec, defaultTTL time.Duration, readOnly bool) error {
+	for _, spec := range specs {
+		for _, field := range spec.Indexes {
+			if err := d.EnsureIndex(spec.Name, field); err != nil {
+				return fmt.Errorf("indexing %s.%s: %w", spec.Name, field, err)
+			}
+		}
+
+		if !readOnly {
+			if err := d.repairTTLs(spec.Name, spec.ttl(defaultTTL)); err != nil {
+				return fmt.Errorf("repairing the TTLs of %s: %w", spec.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (spec CollectionSpec) ttl(defaultTTL time.Duration) time.Duration {
+	if spec.TTL == 0 {
+		return defaultTTL
+	}
+
+	return spec.TTL
+}
+
+// repairTTLs drops the TTLs of records that are gone and gives the default
+// TTL to the records without one
+func (d *Driver) repairTTLs(collection string, ttl time.Duration) error {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.listRecords(collection)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	records := make(map[string]bool, len(files))
+	for _, file := range files {
+		records[file.ID] = true
+
+		if ttl > 0 {
+			if err := d.applyDefaultTTL(collection, file.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	found, err := d.expiries(filepath.Join(d.dir, ttlDir, collection), collection)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range found {
+		if e.collection != collection || records[e.ID] || d.archived(collection, e.ID) {
+			continue
+		}
+
+		d.log.Warn("dropping the TTL of missing record %s/%s", collection, e.ID)
+
+		if err := d.dropTTL(collection, e.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package jdb
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blobsDir is the reserved directory holding the content addressed bodies of
+// deduplicated collections
+const blobsDir = "_blobs"
+
+// Linker is implemented by Storages able to hard link files, which
+// deduplication needs
+type Linker interface {
+	Link(oldname, newname string) error
+}
+
+func (osStorage) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+// SetDeduplicate makes the collection store identical records once: bodies
+// are kept by SHA-256 under the blobs directory and records are hard links to
+// them. Records must then only be changed through the Driver, editing a file
+// in place changes every record sharing its body
+func (d *Driver) SetDeduplicate(collection string, dedup bool) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.dedup = dedup
+	})
+}
+
+func (d *Driver) blobPath(doc []byte) string {
+	name := hex.EncodeToString(d.blobName(doc))
+
+	return filepath.Join(d.dir, blobsDir, name[:2], name+".json")
+}
+
+// writeTemp writes the record to its temporary path, as a link to a shared
+// blob when the collection is deduplicated
+func (d *Driver) writeTemp(collection, path string, doc []byte) error {
+	stored, err := d.compress(doc)
+	if err != nil {
+		return err
+	}
+
+	if stored, err = d.encrypt(stored); err != nil {
+		return err
+	}
+
+	linker := d.engines.linker(path)
+	if linker == nil || !d.config(collection).dedup {
+		return d.fs.WriteFile(path, stored, 0644)
+	}
+
+	blob := d.blobPath(doc)
+
+	d.blobs.RLock()
+	defer d.blobs.RUnlock()
+
+	if _, err := d.fs.Stat(blob); os.IsNotExist(err) {
+		if err := d.fs.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+			return err
+		}
+
+		if err := d.fs.WriteFile(blob+".tmp", stored, 0444); err != nil {
+			return err
+		}
+
+		if err := d.fs.Rename(blob+".tmp", blob); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if err := d.fs.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return linker.Link(blob, path)
+}
+
+// PruneBlobs removes the blobs no record links to anymore, returning how many
+// were removed. It does nothing on platforms not reporting link counts.
+// Writes to deduplicated collections wait for it
+func (d *Driver) PruneBlobs() (int, error) {
+	done, err := d.admitMaintenance("pruning blobs")
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	d.blobs.Lock()
+	defer d.blobs.Unlock()
+
+	root := filepath.Join(d.dir, blobsDir)
+
+	dirs, err := d.fs.ReadDir(root)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		files, err := d.fs.ReadDir(filepath.Join(root, dir.Name()))
+		if err != nil {
+			return count, err
+		}
+
+		for _, file := range files {
+			if !strings.HasSuffix(file.Name(), ".json") {
+				continue
+			}
+
+			if links, ok := linkCount(file); !ok || links > 1 {
+				continue
+			}
+
+			if err := d.fs.Remove(filepath.Join(root, dir.Name(), file.Name())); err != nil {
+				return count, err
+			}
+
+			count++
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,61 @@
+package jdb_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestPruneBlobsWhileWriting(t *testing.T) {
+	d := jdbtest.New(t)
+	d.SetDeduplicate("users", true)
+
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if _, err := d.PruneBlobs(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		ID := fmt.Sprintf("u-%d", i%4)
+
+		if _, err := d.Write("users", ID, map[string]int{"n": i % 3}); err != nil {
+			t.Fatalf("write %d: %s", i, err)
+		}
+
+		if _, err := d.Read("users", ID); err != nil {
+			t.Fatalf("read %d: %s", i, err)
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	if _, err := d.PruneBlobs(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := d.Read("users", fmt.Sprintf("u-%d", i)); err != nil {
+			t.Errorf("u-%d lost its blob: %s", i, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package jdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change is a single difference between two JSON documents, a JSON Patch
+// operation whose Op is add, remove or replace and whose Path is a JSON pointer
+type Change struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// decodeJSON decodes a document keeping numbers exact
+func decodeJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// DiffJSON returns the changes turning the JSON document a into b, objects
+// are compared key by key and arrays of the same length element by element
+func DiffJSON(a, b []byte) ([]Change, error) {
+	av, err := decodeJSON(a)
+	if err != nil {
+		return nil, err
+	}
+
+	bv, err := decodeJSON(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffValues("", av, bv), nil
+}
+
+// Diff returns the changes turning the stored record into v, e.g. to audit an
+// update before writing it. A missing record diffs as null
+func (d *Driver) Diff(collection, identifier string, v interface{}) ([]Change, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if identifier == "" {
+		return nil, fmt.Errorf("missing ID, no identifier to get data")
+	}
+
+	next, err := d.encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := []byte("null")
+
+	err = d.readRecord(collection, identifier, func(b []byte) error {
+		stored = append([]byte(nil), b...)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return DiffJSON(stored, next)
+}
+
+// diffValues returns the changes turning a into b, objects are compared key by
+// key and arrays of the same length element by element
+func diffValues(path string, a, b interface{}) []Change {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		var changes []Change
+
+		for _, key := range sortedKeys(a) {
+			p := path + "/" + escapePointer(key)
+
+			if bv, ok := b[key]; ok {
+				changes = append(changes, diffValues(p, a[key], bv)...)
+			} else {
+				changes = append(changes, Change{Op: "remove", Path: p})
+			}
+		}
+
+		for _, key := range sortedKeys(b) {
+			if _, ok := a[key]; !ok {
+				changes = append(changes, Change{Op: "add", Path: path + "/" + escapePointer(key), Value: b[key]})
+			}
+		}
+
+		return changes
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			break
+		}
+
+		var changes []Change
+		for i := range a {
+			changes = append(changes, diffValues(path+"/"+strconv.Itoa(i), a[i], b[i])...)
+		}
+
+		return changes
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+
+	return []Change{{Op: "replace", Path: path, Value: b}}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func unescapePointer(s string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+}
+
+// applyChanges applies the changes to the document returning the new one
+func applyChanges(doc interface{}, changes []Change) (interface{}, error) {
+	for _, c := range changes {
+		var err error
+		if doc, err = applyChange(doc, strings.Split(c.Path, "/")[1:], c); err != nil {
+			return nil, fmt.Errorf("applying %s %s: %w", c.Op, c.Path, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func applyChange(doc interface{}, tokens []string, c Change) (interface{}, error) {
+	if len(tokens) == 0 {
+		if c.Op == "remove" {
+			return nil, nil
+		}
+		return c.Value, nil
+	}
+
+	token := unescapePointer(tokens[0])
+	last := len(tokens) == 1
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if last {
+			if c.Op == "remove" {
+				delete(node, token)
+			} else {
+				node[token] = c.Value
+			}
+			return node, nil
+		}
+
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("missing key %q", token)
+		}
+
+		child, err := applyChange(child, tokens[1:], c)
+		if err != nil {
+			return nil, err
+		}
+
+		node[token] = child
+		return node, nil
+	case []interface{}:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(node) {
+			return nil, fmt.Errorf("invalid index %q", token)
+		}
+
+		if last && c.Op != "remove" {
+			node[i] = c.Value
+			return node, nil
+		}
+
+		if last {
+			return append(node[:i], node[i+1:]...), nil
+		}
+
+		if node[i], err = applyChange(node[i], tokens[1:], c); err != nil {
+			return nil, err
+		}
+
+		return node, nil
+	default:
+		return nil, fmt.Errorf("can't descend into %T", doc)
+	}
+}
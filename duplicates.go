@@ -0,0 +1,152 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type (
+	// DuplicateSet is a group of records sharing the values of the fields
+	// given to FindDuplicates
+	DuplicateSet struct {
+		// Values are the shared values, one per field
+		Values []json.RawMessage `json:"values"`
+
+		IDs []string `json:"ids"`
+	}
+
+	// DuplicateResolver decides what becomes of a set of duplicates given
+	// their records: it returns the ID of the one to keep, the others being
+	// deleted, and optionally a value to write to it, e.g. a merge of the
+	// set. An empty ID leaves the set alone
+	DuplicateResolver func(IDs []string, records [][]byte) (keep string, merged interface{}, err error)
+)
+
+// FindDuplicates groups the records of the collection by the values of the
+// dotted fields and returns the groups of more than one record, in the order
+// of their first record. Records missing one of the fields aren't grouped.
+// Values compare like in indexes, so 1 and 1.0 are the same
+func (d *Driver) FindDuplicates(collection string, fields ...string) ([]DuplicateSet, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("missing fields, nothing to compare records on")
+	}
+
+	groups := make(map[string]*DuplicateSet)
+	var order []string
+
+	err := d.ForEach(collection, func(ID string, raw []byte) error {
+		doc, err := decodeJSON(raw)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", collection, ID, err)
+		}
+
+		values := make([]json.RawMessage, len(fields))
+		for i, field := range fields {
+			v, ok := lookupPath(doc, strings.Split(field, "."))
+			if !ok {
+				return nil
+			}
+
+			key, ok := valueKey(v)
+			if !ok {
+				return nil
+			}
+
+			values[i] = json.RawMessage(key)
+		}
+
+		key, _ := json.Marshal(values)
+
+		set := groups[string(key)]
+		if set == nil {
+			set = &DuplicateSet{Values: values}
+			groups[string(key)] = set
+			order = append(order, string(key))
+		}
+
+		set.IDs = append(set.IDs, ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []DuplicateSet
+	for _, key := range order {
+		if set := groups[key]; len(set.IDs) > 1 {
+			sets = append(sets, *set)
+		}
+	}
+
+	return sets, nil
+}
+
+// ResolveDuplicates hands every set found by FindDuplicates to resolve and
+// applies its decision, returning how many records were deleted. Records
+// gone since the sets were found are left out of them
+func (d *Driver) ResolveDuplicates(collection string, sets []DuplicateSet, resolve DuplicateResolver) (int, error) {
+	deleted := 0
+
+	for _, set := range sets {
+		var IDs []string
+		var records [][]byte
+
+		for _, ID := range set.IDs {
+			record, err := d.Read(collection, ID)
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			if err != nil {
+				return deleted, err
+			}
+
+			IDs = append(IDs, ID)
+			records = append(records, []byte(record))
+		}
+
+		if len(IDs) < 2 {
+			continue
+		}
+
+		keep, merged, err := resolve(IDs, records)
+		if err != nil {
+			return deleted, fmt.Errorf("resolving duplicates %v: %w", IDs, err)
+		}
+
+		if keep == "" {
+			continue
+		}
+
+		found := false
+		for _, ID := range IDs {
+			found = found || ID == keep
+		}
+
+		if !found {
+			return deleted, fmt.Errorf("resolving duplicates %v: kept %s is not one of them", IDs, keep)
+		}
+
+		if merged != nil {
+			if _, err := d.Write(collection, keep, merged); err != nil {
+				return deleted, err
+			}
+		}
+
+		for _, ID := range IDs {
+			if ID == keep {
+				continue
+			}
+
+			if err := d.Delete(collection, ID); err != nil {
+				return deleted, err
+			}
+
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,226 @@
+package jdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ImportDynamoDB imports the lines of a DynamoDB JSON export, like the files
+// of an export to S3, into the collection. Each line is an item of typed
+// attributes, {"Item": {"id": {"S": "a"}, "n": {"N": "1"}}}, or the item
+// alone. Attributes are unwrapped into plain JSON, sets becoming arrays and
+// binary values staying base64 strings, and the item is imported like by
+// ImportNDJSON, its ID being the ImportOptions.IDField attribute
+func (d *Driver) ImportDynamoDB(collection string, r io.Reader, opts ImportOptions) (int, error) {
+	return d.importNDJSON(collection, r, opts, dynamoLine)
+}
+
+// ExportDynamoDB writes the records of the collection to w in the format read
+// by ImportDynamoDB and DynamoDB's import from S3, one item per line. When
+// idField is set, records lacking it get their ID under it so it can be the
+// partition key. It returns how many records were written
+func (d *Driver) ExportDynamoDB(collection, idField string, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	count := 0
+
+	err := d.ForEach(collection, func(ID string, raw []byte) error {
+		doc, err := decodeJSON(raw)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", collection, ID, err)
+		}
+
+		fields, ok := doc.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s/%s is not an object, DynamoDB items are", collection, ID)
+		}
+
+		if _, ok := fields[idField]; idField != "" && !ok {
+			fields[idField] = ID
+		}
+
+		item := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			item[k] = dynamoAttribute(v)
+		}
+
+		b, err := json.Marshal(map[string]interface{}{"Item": item})
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", collection, ID, err)
+		}
+
+		if _, err := bw.Write(append(b, '\n')); err != nil {
+			return err
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, bw.Flush()
+}
+
+// dynamoLine turns a line of a DynamoDB JSON export into a plain JSON record
+func dynamoLine(line []byte) ([]byte, error) {
+	v, err := decodeJSON(line)
+	if err != nil {
+		return nil, fmt.Errorf("not JSON: %w", err)
+	}
+
+	item, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a DynamoDB item")
+	}
+
+	if wrapped, ok := item["Item"].(map[string]interface{}); ok && len(item) == 1 {
+		item = wrapped
+	}
+
+	fields := make(map[string]interface{}, len(item))
+	for k, attr := range item {
+		v, err := dynamoValue(attr)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", k, err)
+		}
+
+		fields[k] = v
+	}
+
+	return json.Marshal(fields)
+}
+
+// dynamoValue unwraps a typed attribute value into plain JSON
+func dynamoValue(attr interface{}) (interface{}, error) {
+	wrapper, ok := attr.(map[string]interface{})
+	if !ok || len(wrapper) != 1 {
+		return nil, fmt.Errorf("not a typed attribute value")
+	}
+
+	for typ, v := range wrapper {
+		switch typ {
+		case "S", "B":
+			if _, ok := v.(string); ok {
+				return v, nil
+			}
+		case "N":
+			if n, ok := dynamoNumber(v); ok {
+				return n, nil
+			}
+		case "BOOL":
+			if _, ok := v.(bool); ok {
+				return v, nil
+			}
+		case "NULL":
+			return nil, nil
+		case "SS", "BS", "NS":
+			list, ok := v.([]interface{})
+			if !ok {
+				break
+			}
+
+			values := make([]interface{}, len(list))
+			for i, e := range list {
+				values[i] = e
+
+				if typ == "NS" {
+					if values[i], ok = dynamoNumber(e); !ok {
+						return nil, fmt.Errorf("%v in NS is not a number", e)
+					}
+				} else if _, ok := e.(string); !ok {
+					return nil, fmt.Errorf("%v in %s is not a string", e, typ)
+				}
+			}
+
+			return values, nil
+		case "L":
+			list, ok := v.([]interface{})
+			if !ok {
+				break
+			}
+
+			values := make([]interface{}, len(list))
+			for i, e := range list {
+				value, err := dynamoValue(e)
+				if err != nil {
+					return nil, fmt.Errorf("element %d: %w", i, err)
+				}
+
+				values[i] = value
+			}
+
+			return values, nil
+		case "M":
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				break
+			}
+
+			values := make(map[string]interface{}, len(m))
+			for k, e := range m {
+				value, err := dynamoValue(e)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", k, err)
+				}
+
+				values[k] = value
+			}
+
+			return values, nil
+		default:
+			return nil, fmt.Errorf("unknown attribute type %s", typ)
+		}
+
+		return nil, fmt.Errorf("%s attribute holds %s", typ, typeOf(v))
+	}
+
+	return nil, nil
+}
+
+// dynamoNumber parses the string DynamoDB holds a number in
+func dynamoNumber(v interface{}) (json.Number, bool) {
+	s, ok := v.(string)
+	if !ok || !json.Valid([]byte(s)) {
+		return "", false
+	}
+
+	n := json.Number(s)
+	if _, err := n.Float64(); err != nil {
+		return "", false
+	}
+
+	return n, true
+}
+
+// dynamoAttribute wraps a plain JSON value into a typed attribute value
+func dynamoAttribute(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case nil:
+		return map[string]interface{}{"NULL": true}
+	case bool:
+		return map[string]interface{}{"BOOL": v}
+	case json.Number:
+		return map[string]interface{}{"N": v.String()}
+	case string:
+		return map[string]interface{}{"S": v}
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = dynamoAttribute(e)
+		}
+
+		return map[string]interface{}{"L": list}
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = dynamoAttribute(e)
+		}
+
+		return map[string]interface{}{"M": m}
+	}
+
+	return map[string]interface{}{"S": fmt.Sprint(v)}
+}
@@ -0,0 +1,361 @@
+package jdb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// encryptedMagic starts the files of records encrypted at rest, followed by
+// the ID of the key, the nonce and the sealed record. JSON can't start with
+// it so plain records are told apart and still read
+const encryptedMagic = "\x00jdb"
+
+const keyIDSize = 8
+
+type (
+	recordKey struct {
+		id   string
+		raw  []byte
+		aead cipher.AEAD
+	}
+
+	// keyring holds the keys records can be decrypted with, records are
+	// encrypted with the current one or kept plain when there's none
+	keyring struct {
+		mutex   sync.RWMutex
+		current *recordKey
+		keys    map[string]*recordKey
+	}
+)
+
+func newRecordKey(key []byte) (*recordKey, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(key)
+
+	return &recordKey{id: string(sum[:keyIDSize]), raw: key, aead: aead}, nil
+}
+
+// add makes records encrypted with key readable, a nil key does nothing
+func (k *keyring) add(key []byte) (*recordKey, error) {
+	if key == nil {
+		return nil, nil
+	}
+
+	rk, err := newRecordKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.keys == nil {
+		k.keys = make(map[string]*recordKey)
+	}
+
+	k.keys[rk.id] = rk
+
+	return rk, nil
+}
+
+func (k *keyring) use(rk *recordKey) {
+	k.mutex.Lock()
+	k.current = rk
+	k.mutex.Unlock()
+}
+
+func (k *keyring) get() *recordKey {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return k.current
+}
+
+func (k *keyring) lookup(id string) *recordKey {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return k.keys[id]
+}
+
+// recordKey returns the key records are encrypted with, nil when they're
+// kept plain
+func (d *Driver) recordKey() *recordKey {
+	if d.fieldsOnly {
+		return nil
+	}
+
+	return d.keys.get()
+}
+
+// encrypt seals a record with the current key
+func (d *Driver) encrypt(doc []byte) ([]byte, error) {
+	return sealRecord(d.recordKey(), doc)
+}
+
+func sealRecord(rk *recordKey, doc []byte) ([]byte, error) {
+	if rk == nil {
+		return doc, nil
+	}
+
+	nonce := make([]byte, rk.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(encryptedMagic)+keyIDSize+len(nonce)+len(doc)+rk.aead.Overhead())
+	out = append(out, encryptedMagic...)
+	out = append(out, rk.id...)
+	out = append(out, nonce...)
+
+	return rk.aead.Seal(out, nonce, doc, []byte(rk.id)), nil
+}
+
+// seal returns a record of the collection, as it's read, in the form records
+// are stored in, so the copies kept in the trash or the archive are encrypted
+// like the records themselves
+func (d *Driver) seal(collection string, doc []byte) ([]byte, error) {
+	stored, err := d.encryptFields(collection, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored, err = d.compress(stored); err != nil {
+		return nil, err
+	}
+
+	return d.encrypt(stored)
+}
+
+// decrypt opens a record of the collection encrypted at rest, decompresses
+// it and opens its encrypted fields, plain records are returned as is
+func (d *Driver) decrypt(collection string, b []byte) ([]byte, error) {
+	b, err := d.openRecord(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, err = d.decompress(b); err != nil {
+		return nil, err
+	}
+
+	return d.decryptFields(collection, b)
+}
+
+func (d *Driver) openRecord(b []byte) ([]byte, error) {
+	id, ok := encryptedWith(b)
+	if !ok {
+		return b, nil
+	}
+
+	rk := d.keys.lookup(id)
+	if rk == nil {
+		return nil, fmt.Errorf("record is encrypted with an unknown key")
+	}
+
+	b = b[len(encryptedMagic)+keyIDSize:]
+
+	size := rk.aead.NonceSize()
+	if len(b) < size {
+		return nil, fmt.Errorf("encrypted record is truncated")
+	}
+
+	return rk.aead.Open(nil, b[:size], b[size:], []byte(id))
+}
+
+// decrypted wraps fn so it's handed records of the collection decrypted
+func (d *Driver) decrypted(collection string, fn func([]byte) error) func([]byte) error {
+	return func(b []byte) error {
+		doc, err := d.decrypt(collection, b)
+		if err != nil {
+			return err
+		}
+
+		return fn(doc)
+	}
+}
+
+// encryptedWith returns the ID of the key the record is encrypted with
+func encryptedWith(b []byte) (string, bool) {
+	if len(b) < len(encryptedMagic)+keyIDSize || !bytes.HasPrefix(b, []byte(encryptedMagic)) {
+		return "", false
+	}
+
+	return string(b[len(encryptedMagic) : len(encryptedMagic)+keyIDSize]), true
+}
+
+// blobName names the blob of a deduplicated record, keyed with the current
+// key when records are encrypted so it doesn't give away their content
+func (d *Driver) blobName(doc []byte) []byte {
+	rk := d.recordKey()
+	if rk == nil {
+		sum := sha256.Sum256(doc)
+		return sum[:]
+	}
+
+	mac := hmac.New(sha256.New, rk.raw)
+	mac.Write(doc)
+
+	return mac.Sum(nil)
+}
+
+// RotateKey re-encrypts the records of the data directory with newKey, new
+// writes use it as soon as it's called. A nil oldKey encrypts plain records
+// and a nil newKey decrypts them. Records are rewritten one at a time and
+// those already encrypted with newKey are skipped, so after a crash the
+// database is opened with newKey as Options.EncryptionKey and oldKey in
+// Options.DecryptionKeys, and RotateKey is called again to resume. Records of
+// read-only directories and the archive are left as they are. progress, when
+// not nil, is called after each record with how many are done so far
+func (d *Driver) RotateKey(oldKey, newKey []byte, progress func(done, total int)) error {
+	admitted, err := d.admitMaintenance("rotating the encryption key")
+	if err != nil {
+		return err
+	}
+	defer admitted()
+
+	if _, err := d.keys.add(oldKey); err != nil {
+		return err
+	}
+
+	rk, err := d.keys.add(newKey)
+	if err != nil {
+		return err
+	}
+
+	d.keys.use(rk)
+
+	if d.fieldsOnly {
+		rk = nil
+	}
+
+	collections, err := d.collections()
+	if err != nil {
+		return err
+	}
+
+	total, err := d.countRecords(collections)
+	if err != nil {
+		return err
+	}
+
+	done := 0
+
+	for _, c := range collections {
+		c := c
+		n, err := d.rotateCollection(c, rk, func() {
+			done++
+			if progress != nil {
+				progress(done, total)
+			}
+
+			d.progress(Progress{Op: "rotate-key", Collection: c, Done: done, Total: total})
+		})
+		if err != nil {
+			return fmt.Errorf("rotating %s after %d records: %w", c, n, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) rotateCollection(collection string, rk *recordKey, step func()) (int, error) {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	records, err := d.listRecords(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, r := range records {
+		if filepath.Dir(r.path) != filepath.Join(d.dir, collection) {
+			continue
+		}
+
+		b, err := d.fs.ReadFile(r.path)
+		if err != nil {
+			return count, err
+		}
+
+		id, encrypted := encryptedWith(b)
+		rotated := encrypted && rk != nil && id == rk.id || !encrypted && rk == nil
+
+		doc, err := d.openRecord(b)
+		if err == nil {
+			doc, err = d.decompress(doc)
+		}
+
+		if err != nil {
+			return count, fmt.Errorf("%s: %w", r.ID, err)
+		}
+
+		// encrypted fields don't tell their key apart cheaply, records
+		// having some are rewritten even when rotated already
+		if rotated && !hasEncryptedFields(doc) {
+			count++
+			step()
+			continue
+		}
+
+		if doc, err = d.decryptFields(collection, doc); err != nil {
+			return count, fmt.Errorf("%s: %w", r.ID, err)
+		}
+
+		if doc, err = d.encryptFields(collection, doc); err != nil {
+			return count, fmt.Errorf("%s: %w", r.ID, err)
+		}
+
+		if doc, err = d.compress(doc); err != nil {
+			return count, err
+		}
+
+		if b, err = sealRecord(rk, doc); err != nil {
+			return count, err
+		}
+
+		d.expectChange(r.path)
+
+		if err := d.fs.WriteFile(r.path+".tmp", b, 0644); err != nil {
+			d.expectedChange(r.path)
+			return count, err
+		}
+
+		if err := d.fs.Rename(r.path+".tmp", r.path); err != nil {
+			d.expectedChange(r.path)
+			return count, err
+		}
+
+		d.handles.evict(r.path)
+
+		count++
+		step()
+	}
+
+	return count, nil
+}
@@ -0,0 +1,87 @@
+package jdb_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestEncryptionCoversCopiesNextToRecords(t *testing.T) {
+	for name, option := range map[string]jdb.Option{
+		"records": jdb.WithEncryption(fieldKey),
+		"fields":  jdb.WithFieldEncryption(fieldKey),
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "db")
+			d := jdbtest.Open(t, dir, option, jdb.WithTrash(time.Hour))
+
+			for _, collection := range []string{"users", "cold"} {
+				d.SetEncryptedFields(collection, "ssn")
+			}
+
+			d.SetHistory("users", true)
+
+			for _, w := range []struct{ collection, ID, ssn string }{
+				{"users", "a", "111-11-1111"},
+				{"users", "a", "222-22-2222"},
+				{"users", "b", "333-33-3333"},
+				{"cold", "c", "444-44-4444"},
+			} {
+				if _, err := d.Write(w.collection, w.ID, map[string]string{"ssn": w.ssn}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := d.Delete("users", "b"); err != nil {
+				t.Fatal(err)
+			}
+
+			if n, err := d.Archive("cold", -time.Hour); n != 1 || err != nil {
+				t.Fatalf("archived %d records, %v, want 1", n, err)
+			}
+
+			err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+
+				b, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+
+				if bytes.Contains(b, []byte("-11-")) || bytes.Contains(b, []byte("-33-")) || bytes.Contains(b, []byte("-44-")) {
+					t.Errorf("%s holds a plain SSN", strings.TrimPrefix(path, dir))
+				}
+
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := d.ReadVersion("users", "a", 1); err != nil || !strings.Contains(got, "111-11-1111") {
+				t.Errorf("reading version 1 returned %s, %v", got, err)
+			}
+
+			if got, err := d.Read("cold", "c"); err != nil || !strings.Contains(got, "444-44-4444") {
+				t.Errorf("reading the archived record returned %s, %v", got, err)
+			}
+
+			if err := d.Undelete("users", "b"); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := d.Read("users", "b"); err != nil || !strings.Contains(got, "333-33-3333") {
+				t.Errorf("reading the undeleted record returned %s, %v", got, err)
+			}
+		})
+	}
+}
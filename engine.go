@@ -0,0 +1,539 @@
+package jdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// Engine is how the records of a collection are stored, see SetEngine
+	Engine string
+
+	// Codec transforms the files of a collection on their way to and from
+	// its Engine, e.g. to compress them. Files are encoded as the Driver
+	// writes them, so after encryption when it encrypts records
+	Codec interface {
+		Encode(b []byte) ([]byte, error)
+		Decode(b []byte) ([]byte, error)
+	}
+
+	gzipCodec struct{}
+)
+
+const (
+	// EngineFiles stores every record in a file of its own, the default
+	EngineFiles Engine = "files"
+
+	// EngineSegment appends the records of the collection to a single
+	// segment file compacted as it fills with replaced records, which
+	// suits large collections of small records. The records it holds are
+	// indexed in memory when the collection is set to it
+	EngineSegment Engine = "segment"
+
+	// EngineMemory keeps the records in memory only, they're lost when
+	// the Driver goes away
+	EngineMemory Engine = "memory"
+)
+
+// GzipCodec compresses files with gzip, decoding files that aren't gzipped
+// as they are
+var GzipCodec Codec = gzipCodec{}
+
+func (gzipCodec) Encode(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(b []byte) ([]byte, error) {
+	if len(b) < 2 || b[0] != 0x1f || b[1] != 0x8b {
+		return b, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(zr)
+}
+
+// SetEngine stores the records of the collection with the engine, their
+// files going through the codec when it isn't nil. The records the
+// collection holds are moved to the engine when it changes, so it's best set
+// before using the collection, e.g. through CollectionSpec. Changing the
+// codec alone doesn't rewrite them, Decode must take files written without
+// it, as GzipCodec does. Collections nested in the collection use its engine
+// too unless set to another one.
+//
+// Engines other than EngineFiles keep their records where only this Driver
+// sees them, not other processes sharing the data directory. EngineSegment
+// needs OSStorage and EngineMemory is refused for collections already
+// holding records, as they'd be lost. Moving records is a write, refused
+// while frozen or, with a Replicator, with ErrNotReplicated, but the engines
+// of declared collections are set by New all the same
+func (d *Driver) SetEngine(collection string, engine Engine, codec Codec) error {
+	done, err := d.admitLocal(collection)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return d.setEngine(collection, engine, codec)
+}
+
+// setEngine is SetEngine without admitting it
+func (d *Driver) setEngine(collection string, engine Engine, codec Codec) error {
+	if err := ValidateCollection(collection); err != nil {
+		return err
+	}
+
+	root := filepath.Join(d.dir, collection)
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	d.engines.mutex.RLock()
+	current := d.engines.routes[root]
+	d.engines.mutex.RUnlock()
+
+	route := &engineRoute{engine: engine, codec: codec, backing: d.engines.base}
+
+	switch {
+	case engine == EngineFiles || engine == "":
+		route.engine = EngineFiles
+	case current != nil && current.engine == engine:
+		route.backing, route.close = current.backing, current.close
+	case engine == EngineMemory:
+		route.backing = newMemStorage(root, d.clock)
+	case engine == EngineSegment:
+		if !d.onDisk {
+			return fmt.Errorf("%s engine needs OSStorage", engine)
+		}
+
+		s, err := openSegment(root, filepath.Join(d.dir, segmentsDir, collection+".seg"), d.clock)
+		if err != nil {
+			return err
+		}
+
+		route.backing, route.close = s, s.close
+	default:
+		return fmt.Errorf("unknown engine %q", engine)
+	}
+
+	route.store = route.backing
+
+	if codec != nil {
+		route.store = &codecStorage{Storage: route.store, codec: codec}
+	}
+
+	if _, ok := d.engines.base.(readOnlyStorage); ok && route.backing != d.engines.base {
+		route.store = readOnlyStorage{route.store}
+	}
+
+	// the base Storage is routed to explicitly only to override the
+	// engine of a collection this one is nested in
+	if route.store == d.engines.base && !d.engines.routed(filepath.Dir(root)) {
+		route = nil
+	}
+
+	d.handles.evictDir(root)
+
+	tmp := filepath.Join(d.dir, engineDir, collection)
+	if err := d.engines.reroute(root, tmp, route); err != nil {
+		return err
+	}
+
+	pruneDirs(d.engines.base, filepath.Dir(tmp), filepath.Join(d.dir, engineDir))
+	return nil
+}
+
+// Engine returns the engine of the collection
+func (d *Driver) Engine(collection string) Engine {
+	if route := d.engines.route(filepath.Join(d.dir, collection)); route != nil {
+		return route.engine
+	}
+
+	return EngineFiles
+}
+
+// engineDir is the reserved directory records go through when they're
+// moved from an engine to another
+const engineDir = "_engine"
+
+type (
+	// engineRouter is the Storage of the Driver, handing the paths of
+	// collections set to an engine to its Storage and the others to the
+	// Storage of the Options
+	engineRouter struct {
+		base Storage
+
+		mutex  sync.RWMutex
+		routes map[string]*engineRoute
+	}
+
+	// engineRoute is where the files of a collection go: store, which is
+	// backing wrapped by a codec and read-only Drivers
+	engineRoute struct {
+		engine  Engine
+		codec   Codec
+		backing Storage
+		store   Storage
+		close   func() error
+	}
+
+	// codecStorage runs the files going through it through a codec, but
+	// for dot files which belong to the Driver
+	codecStorage struct {
+		Storage
+		codec Codec
+	}
+)
+
+func newEngineRouter(base Storage) *engineRouter {
+	return &engineRouter{base: base, routes: make(map[string]*engineRoute)}
+}
+
+// route returns the route of the path, nil when it goes to the base Storage
+func (r *engineRouter) route(path string) *engineRoute {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if len(r.routes) == 0 {
+		return nil
+	}
+
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if route, ok := r.routes[p]; ok {
+			return route
+		}
+
+		if filepath.Dir(p) == p {
+			return nil
+		}
+	}
+}
+
+// routed reports whether the path goes to an engine
+func (r *engineRouter) routed(path string) bool {
+	return r.route(path) != nil
+}
+
+func (r *engineRouter) storage(path string) Storage {
+	if route := r.route(path); route != nil {
+		return route.store
+	}
+
+	return r.base
+}
+
+// linker returns the Linker of the path, nil when it goes to an engine or
+// the Storage can't link
+func (r *engineRouter) linker(path string) Linker {
+	if r.routed(path) {
+		return nil
+	}
+
+	linker, _ := r.base.(Linker)
+	return linker
+}
+
+// syncer returns the Syncer of the base Storage for the paths it keeps,
+// routed collections flush on their own terms
+func (r *engineRouter) syncer(path string) Syncer {
+	if r.routed(path) {
+		return nil
+	}
+
+	syncer, _ := r.base.(Syncer)
+	return syncer
+}
+
+// reroute sends the paths under root to the route, nil for the base
+// Storage. When it has another backing Storage, the files are moved there
+// through tmp
+func (r *engineRouter) reroute(root, tmp string, route *engineRoute) error {
+	old := r.route(root)
+
+	from, backing := r.base, r.base
+	if old != nil {
+		from, backing = old.store, old.backing
+	}
+
+	to, target := r.base, r.base
+	if route != nil {
+		to, target = route.store, route.backing
+	}
+
+	// records are rewritten when the backing Storage changes or a codec is
+	// dropped or replaced, adding one is left to its Decode
+	moving := backing != target || old != nil && old.codec != nil
+
+	if moving {
+		files, err := from.ReadDir(root)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		moving = err == nil
+
+		if route != nil && route.engine == EngineMemory && holdsRecords(files) {
+			return fmt.Errorf("%s holds records, which the %s engine would lose", root, EngineMemory)
+		}
+	}
+
+	if moving {
+		if err := r.base.MkdirAll(filepath.Dir(tmp), 0755); err != nil {
+			return err
+		}
+
+		if err := move(from, r.base, root, tmp); err != nil {
+			return err
+		}
+	}
+
+	r.mutex.Lock()
+	replaced := r.routes[root]
+
+	if route == nil {
+		delete(r.routes, root)
+	} else {
+		r.routes[root] = route
+	}
+	r.mutex.Unlock()
+
+	if replaced != nil && replaced.close != nil && (route == nil || route.backing != replaced.backing) {
+		if err := replaced.close(); err != nil {
+			return err
+		}
+	}
+
+	if !moving {
+		return nil
+	}
+
+	if err := to.MkdirAll(filepath.Dir(root), 0755); err != nil {
+		return err
+	}
+
+	return move(r.base, to, tmp, root)
+}
+
+// holdsRecords reports whether the files of a collection directory hold
+// records or nested collections, not just the files of the Driver
+func holdsRecords(files []os.FileInfo) bool {
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *engineRouter) close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var err error
+
+	for _, route := range r.routes {
+		if route.close == nil {
+			continue
+		}
+
+		if cerr := route.close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// move renames oldpath to newpath, copying it over when they're in
+// different Storages
+func move(from, to Storage, oldpath, newpath string) error {
+	if from == to {
+		return from.Rename(oldpath, newpath)
+	}
+
+	info, err := from.Stat(oldpath)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		b, err := from.ReadFile(oldpath)
+		if err != nil {
+			return err
+		}
+
+		if err := to.WriteFile(newpath, b, info.Mode().Perm()); err != nil {
+			return err
+		}
+
+		return from.Remove(oldpath)
+	}
+
+	if err := to.MkdirAll(newpath, 0755); err != nil {
+		return err
+	}
+
+	files, err := from.ReadDir(oldpath)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := move(from, to, filepath.Join(oldpath, file.Name()), filepath.Join(newpath, file.Name())); err != nil {
+			return err
+		}
+	}
+
+	return from.Remove(oldpath)
+}
+
+func (r *engineRouter) ReadFile(name string) ([]byte, error) {
+	return r.storage(name).ReadFile(name)
+}
+
+func (r *engineRouter) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return r.storage(name).WriteFile(name, data, perm)
+}
+
+func (r *engineRouter) AppendFile(name string, data []byte, perm os.FileMode) error {
+	return r.storage(name).AppendFile(name, data, perm)
+}
+
+func (r *engineRouter) Rename(oldpath, newpath string) error {
+	return move(r.storage(oldpath), r.storage(newpath), oldpath, newpath)
+}
+
+func (r *engineRouter) Remove(name string) error {
+	return r.storage(name).Remove(name)
+}
+
+// RemoveAll removes the path from its Storage, and the collections nested
+// in it from theirs
+func (r *engineRouter) RemoveAll(path string) error {
+	for _, root := range r.nested(path) {
+		if err := r.storage(root).RemoveAll(root); err != nil {
+			return err
+		}
+	}
+
+	return r.storage(path).RemoveAll(path)
+}
+
+func (r *engineRouter) MkdirAll(path string, perm os.FileMode) error {
+	return r.storage(path).MkdirAll(path, perm)
+}
+
+func (r *engineRouter) Stat(name string) (os.FileInfo, error) {
+	return r.storage(name).Stat(name)
+}
+
+// ReadDir lists the directory in its Storage, along with the collections
+// routed elsewhere that it holds
+func (r *engineRouter) ReadDir(dirname string) ([]os.FileInfo, error) {
+	files, err := r.storage(dirname).ReadDir(dirname)
+
+	var children []os.FileInfo
+
+	for _, root := range r.nested(dirname) {
+		if filepath.Dir(root) != filepath.Clean(dirname) {
+			continue
+		}
+
+		if info, serr := r.storage(root).Stat(root); serr == nil {
+			children = append(children, info)
+		}
+	}
+
+	if len(children) == 0 {
+		return files, err
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if !r.routed(filepath.Join(dirname, file.Name())) || r.storage(filepath.Join(dirname, file.Name())) == r.storage(dirname) {
+			children = append(children, file)
+		}
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
+
+	return children, nil
+}
+
+// nested returns the roots of the routes inside the path
+func (r *engineRouter) nested(path string) []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var roots []string
+
+	for root := range r.routes {
+		if root != filepath.Clean(path) && under(root, filepath.Clean(path)) {
+			roots = append(roots, root)
+		}
+	}
+
+	sort.Strings(roots)
+	return roots
+}
+
+func (c *codecStorage) ReadFile(name string) ([]byte, error) {
+	b, err := c.Storage.ReadFile(name)
+	if err != nil || strings.HasPrefix(filepath.Base(name), ".") {
+		return b, err
+	}
+
+	return c.codec.Decode(b)
+}
+
+func (c *codecStorage) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if !strings.HasPrefix(filepath.Base(name), ".") {
+		var err error
+		if data, err = c.codec.Encode(data); err != nil {
+			return err
+		}
+	}
+
+	return c.Storage.WriteFile(name, data, perm)
+}
+
+func (c *codecStorage) AppendFile(name string, data []byte, perm os.FileMode) error {
+	if strings.HasPrefix(filepath.Base(name), ".") {
+		return c.Storage.AppendFile(name, data, perm)
+	}
+
+	b, err := c.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return c.WriteFile(name, append(b, data...), perm)
+}
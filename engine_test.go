@@ -0,0 +1,260 @@
+package jdb_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+type point struct {
+	X, Y int
+}
+
+func reopen(t *testing.T, d *jdb.Driver, dir string, options ...jdb.Option) *jdb.Driver {
+	t.Helper()
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("closing: %s", err)
+	}
+
+	return jdbtest.Open(t, dir, options...)
+}
+
+func TestEnginesPersist(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  jdb.CollectionSpec
+		kept  bool
+		files bool
+	}{
+		{name: "files", spec: jdb.CollectionSpec{Name: "points"}, kept: true, files: true},
+		{name: "gzip", spec: jdb.CollectionSpec{Name: "points", Codec: jdb.GzipCodec}, kept: true, files: true},
+		{name: "segment", spec: jdb.CollectionSpec{Name: "points", Engine: jdb.EngineSegment}, kept: true},
+		{name: "segment gzip", spec: jdb.CollectionSpec{Name: "points", Engine: jdb.EngineSegment, Codec: jdb.GzipCodec}, kept: true},
+		{name: "memory", spec: jdb.CollectionSpec{Name: "points", Engine: jdb.EngineMemory}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "db")
+			spec := jdb.WithCollections(tt.spec)
+
+			d := jdbtest.Open(t, dir, spec)
+			jdbtest.Seed(t, d, "points", 3, jdbtest.Sequence("p", point{X: 1, Y: 2}))
+
+			if err := d.Delete("points", "p-1"); err != nil {
+				t.Fatalf("deleting: %s", err)
+			}
+
+			want := jdbtest.Snapshot(t, d, "points")
+
+			d = reopen(t, d, dir, spec)
+
+			if got := d.Engine("points"); got != tt.spec.Engine && !(tt.spec.Engine == "" && got == jdb.EngineFiles) {
+				t.Errorf("engine after reopening = %q, want %q", got, tt.spec.Engine)
+			}
+
+			if !tt.kept {
+				if IDs, err := d.IDs("points"); err == nil && len(IDs) > 0 {
+					t.Errorf("records kept in memory survived reopening: %v", IDs)
+				}
+
+				return
+			}
+
+			jdbtest.AssertGolden(t, d, "points", filepath.Join("testdata", "engine.golden.json"))
+
+			if got := jdbtest.Snapshot(t, d, "points"); string(got) != string(want) {
+				t.Errorf("records after reopening:\n%s\nwant:\n%s", got, want)
+			}
+
+			_, err := os.Stat(filepath.Join(dir, "points", "p-0.json"))
+			if tt.files && err != nil {
+				t.Errorf("record file of the %s engine: %s", tt.name, err)
+			}
+
+			if !tt.files && err == nil {
+				t.Errorf("the %s engine wrote a record file", tt.name)
+			}
+		})
+	}
+}
+
+func TestEngineSwitchKeepsRecords(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+
+	d := jdbtest.Open(t, dir)
+	jdbtest.Seed(t, d, "points", 5, jdbtest.Sequence("p", point{X: 3}))
+	want := jdbtest.Snapshot(t, d, "points")
+
+	if err := d.SetEngine("points", jdb.EngineSegment, jdb.GzipCodec); err != nil {
+		t.Fatalf("moving to the segment engine: %s", err)
+	}
+
+	segment := jdb.WithCollections(jdb.CollectionSpec{Name: "points", Engine: jdb.EngineSegment, Codec: jdb.GzipCodec})
+	d = reopen(t, d, dir, segment)
+
+	if got := jdbtest.Snapshot(t, d, "points"); string(got) != string(want) {
+		t.Fatalf("records on the segment engine:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := d.SetEngine("points", jdb.EngineFiles, nil); err != nil {
+		t.Fatalf("moving back to files: %s", err)
+	}
+
+	d = reopen(t, d, dir)
+
+	if got := jdbtest.Snapshot(t, d, "points"); string(got) != string(want) {
+		t.Fatalf("records back on files:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := d.SetEngine("points", jdb.EngineMemory, nil); err == nil {
+		t.Errorf("the memory engine took a collection holding records")
+	}
+}
+
+func TestSegmentDropsTornLastWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	spec := jdb.WithCollections(jdb.CollectionSpec{Name: "points", Engine: jdb.EngineSegment})
+
+	d := jdbtest.Open(t, dir, spec)
+	jdbtest.Seed(t, d, "points", 3, jdbtest.Sequence("p", point{X: 1}))
+	want := jdbtest.Snapshot(t, d, "points")
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("closing: %s", err)
+	}
+
+	// a write cut short by a crash leaves part of an entry behind
+	f, err := os.OpenFile(filepath.Join(dir, "_segments", "points.seg"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Write([]byte{1, 9, 'p', 'o', 'i'}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	d = jdbtest.Open(t, dir, spec)
+
+	if got := jdbtest.Snapshot(t, d, "points"); string(got) != string(want) {
+		t.Fatalf("records after a torn write:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := d.Write("points", "p-9", point{X: 9}); err != nil {
+		t.Fatalf("writing after a torn write: %s", err)
+	}
+
+	d = reopen(t, d, dir, spec)
+
+	var p point
+	if err := d.ReadInto("points", "p-9", &p); err != nil || p.X != 9 {
+		t.Errorf("record written after a torn write = %+v, %v", p, err)
+	}
+}
+
+func TestSegmentIsCompacted(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	spec := jdb.WithCollections(jdb.CollectionSpec{Name: "blobs", Engine: jdb.EngineSegment})
+
+	d := jdbtest.Open(t, dir, spec)
+
+	payload := strings.Repeat("x", 64<<10)
+	for i := 0; i < 100; i++ {
+		if _, err := d.Write("blobs", "b", map[string]interface{}{"n": i, "payload": payload}); err != nil {
+			t.Fatalf("write %d: %s", i, err)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "_segments", "blobs.seg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 100 versions take over 6MB, the live one 64KB
+	if info.Size() > 2<<20 {
+		t.Errorf("segment holding one 64KB record takes %d bytes", info.Size())
+	}
+
+	d = reopen(t, d, dir, spec)
+
+	var blob struct{ N int }
+	if err := d.ReadInto("blobs", "b", &blob); err != nil || blob.N != 99 {
+		t.Errorf("record after compaction = %+v, %v, want the last version", blob, err)
+	}
+}
+
+func TestSegmentKeepsDeletesAndDrops(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	spec := jdb.WithCollections(
+		jdb.CollectionSpec{Name: "points", Engine: jdb.EngineSegment},
+		jdb.CollectionSpec{Name: "gone", Engine: jdb.EngineSegment},
+	)
+
+	d := jdbtest.Open(t, dir, spec)
+	jdbtest.Seed(t, d, "points", 4, jdbtest.Sequence("p", point{X: 1}))
+	jdbtest.Seed(t, d, "gone", 2, jdbtest.Sequence("g", point{X: 2}))
+
+	if err := d.Delete("points", "p-2"); err != nil {
+		t.Fatalf("deleting: %s", err)
+	}
+
+	if err := d.DropCollection("gone", true); err != nil {
+		t.Fatalf("dropping: %s", err)
+	}
+
+	d = reopen(t, d, dir, spec)
+
+	IDs, err := d.IDs("points")
+	if err != nil || strings.Join(IDs, ",") != "p-0,p-1,p-3" {
+		t.Errorf("points after reopening = %v, %v", IDs, err)
+	}
+
+	if IDs, _ := d.IDs("gone"); len(IDs) > 0 {
+		t.Errorf("dropped collection holds %v after reopening", IDs)
+	}
+}
+
+func TestSegmentUnderConcurrentWrites(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	spec := jdb.WithCollections(jdb.CollectionSpec{Name: "points", Engine: jdb.EngineSegment})
+
+	d := jdbtest.Open(t, dir, spec, jdb.WithLockStripes(8))
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 25; i++ {
+				if _, err := d.Write("points", fmt.Sprintf("w%d-%d", w, i), point{X: w, Y: i}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	want := jdbtest.Snapshot(t, d, "points")
+	d = reopen(t, d, dir, spec)
+
+	if got := jdbtest.Snapshot(t, d, "points"); string(got) != string(want) {
+		t.Errorf("records after reopening:\n%s\nwant:\n%s", got, want)
+	}
+
+	if IDs, err := d.IDs("points"); err != nil || len(IDs) != 100 {
+		t.Errorf("points holds %d records, %v, want 100", len(IDs), err)
+	}
+}
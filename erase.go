@@ -0,0 +1,274 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// SubjectMatcher reports whether a value of a record identifies the
+	// subject being erased, it's called for every string, number and bool
+	SubjectMatcher func(value interface{}) bool
+
+	// ErasureMode is what EraseSubject does with the records referencing
+	// the subject
+	ErasureMode int
+
+	// ErasureReport lists what EraseSubject did, for audit trails
+	ErasureReport struct {
+		At      time.Time
+		Records []ErasedRecord
+	}
+
+	// ErasedRecord is a record EraseSubject found referencing the subject
+	ErasedRecord struct {
+		Collection string
+		ID         string
+
+		// Action is "deleted", "anonymized", "purged" for copies in the
+		// trash, or "skipped" for records of append-only collections
+		Action string
+
+		// Pointers are the JSON pointers of the values that matched
+		Pointers []string
+	}
+)
+
+const (
+	// EraseDelete deletes the records referencing the subject
+	EraseDelete ErasureMode = iota
+
+	// EraseAnonymize replaces the matching values with null and keeps the
+	// rest of the records
+	EraseAnonymize
+)
+
+// SubjectValue returns a SubjectMatcher matching values equal to subject,
+// numbers are compared by their JSON text
+func SubjectValue(subject string) SubjectMatcher {
+	return func(value interface{}) bool {
+		switch v := value.(type) {
+		case string:
+			return v == subject
+		case json.Number:
+			return v.String() == subject
+		}
+
+		return false
+	}
+}
+
+// EraseSubject finds the records of every collection referencing a subject,
+// e.g. for a GDPR erasure request, and deletes or anonymizes them following
+// mode. The history, archived copy and copies in the trash of those records
+// are removed too, erased records can't be restored. Records of append-only
+// collections are reported as skipped. Older versions of records that don't
+// reference the subject anymore aren't looked at
+func (d *Driver) EraseSubject(match SubjectMatcher, mode ErasureMode) (ErasureReport, error) {
+	report := ErasureReport{At: d.clock.Now()}
+
+	if match == nil {
+		return report, fmt.Errorf("missing matcher, no subject to erase")
+	}
+
+	collections, err := d.collections()
+	if err != nil {
+		return report, err
+	}
+
+	for _, c := range collections {
+		erased, err := d.eraseCollection(c, match, mode)
+		report.Records = append(report.Records, erased...)
+		if err != nil {
+			return report, fmt.Errorf("erasing from %s: %w", c, err)
+		}
+	}
+
+	return report, nil
+}
+
+func (d *Driver) eraseCollection(collection string, match SubjectMatcher, mode ErasureMode) ([]ErasedRecord, error) {
+	done, err := d.admitLocal(collection)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.listRecords(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	IDs := make([]string, 0, len(files))
+	for _, file := range files {
+		IDs = append(IDs, file.ID)
+	}
+
+	archived, err := d.archivedIDs(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	appendOnly := d.config(collection).appendOnly
+
+	var erased []ErasedRecord
+
+	for _, ID := range append(IDs, archived...) {
+		var doc interface{}
+		var pointers []string
+
+		err := d.load(collection, ID, func(b []byte) error {
+			v, err := decodeJSON(b)
+			if err != nil {
+				return err
+			}
+
+			doc, pointers = eraseValue("", v, match)
+			return nil
+		})
+		if err != nil {
+			return erased, err
+		}
+
+		if len(pointers) == 0 {
+			continue
+		}
+
+		rec := ErasedRecord{Collection: collection, ID: ID, Pointers: pointers}
+
+		switch {
+		case appendOnly:
+			rec.Action = "skipped"
+		case mode == EraseAnonymize:
+			rec.Action = "anonymized"
+			err = d.anonymizeRecord(collection, ID, doc)
+		default:
+			rec.Action = "deleted"
+			err = d.removeRecord(collection, ID)
+		}
+
+		if err != nil {
+			return erased, err
+		}
+
+		erased = append(erased, rec)
+	}
+
+	purged, err := d.eraseTrash(collection, match)
+	return append(erased, purged...), err
+}
+
+// anonymizeRecord writes the anonymized record and drops what could still
+// hold the erased values, callers must hold the collection lock
+func (d *Driver) anonymizeRecord(collection, ID string, doc interface{}) error {
+	if _, err := d.writeLocked(collection, ID, doc); err != nil {
+		return err
+	}
+
+	if err := d.fs.RemoveAll(d.archivePath(collection, ID)); err != nil {
+		return err
+	}
+
+	return d.dropHistory(collection, ID)
+}
+
+// eraseTrash removes the copies in the trash of the collection referencing
+// the subject, callers must hold the collection lock
+func (d *Driver) eraseTrash(collection string, match SubjectMatcher) ([]ErasedRecord, error) {
+	stamps, err := d.deletedAt(collection)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []ErasedRecord
+
+	for _, stamp := range stamps {
+		dir := filepath.Join(d.dir, trashDir, collection, stamp)
+
+		files, err := d.fs.ReadDir(dir)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, file := range files {
+			if !strings.HasSuffix(file.Name(), ".json") || file.IsDir() {
+				continue
+			}
+
+			path := filepath.Join(dir, file.Name())
+
+			b, err := d.fs.ReadFile(path)
+			if err != nil {
+				return purged, err
+			}
+
+			if b, err = d.decrypt(collection, b); err != nil {
+				return purged, err
+			}
+
+			v, err := decodeJSON(b)
+			if err != nil {
+				return purged, err
+			}
+
+			_, pointers := eraseValue("", v, match)
+			if len(pointers) == 0 {
+				continue
+			}
+
+			if err := d.fs.Remove(path); err != nil {
+				return purged, err
+			}
+
+			purged = append(purged, ErasedRecord{
+				Collection: collection,
+				ID:         strings.TrimSuffix(file.Name(), ".json"),
+				Action:     "purged",
+				Pointers:   pointers,
+			})
+		}
+	}
+
+	return purged, nil
+}
+
+// eraseValue returns v with the values matching replaced with nil along with
+// their pointers
+func eraseValue(pointer string, v interface{}, match SubjectMatcher) (interface{}, []string) {
+	var pointers []string
+
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(node) {
+			var found []string
+			node[k], found = eraseValue(pointer+"/"+escapePointer(k), node[k], match)
+			pointers = append(pointers, found...)
+		}
+	case []interface{}:
+		for i := range node {
+			var found []string
+			node[i], found = eraseValue(pointer+"/"+strconv.Itoa(i), node[i], match)
+			pointers = append(pointers, found...)
+		}
+	case nil:
+	default:
+		if match(node) {
+			return nil, []string{pointer}
+		}
+	}
+
+	return v, pointers
+}
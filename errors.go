@@ -0,0 +1,78 @@
+package jdb
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	// ErrNotFound is wrapped by the errors of reads, updates and deletes of
+	// missing records. It's os.ErrNotExist so os.IsNotExist keeps working
+	ErrNotFound = os.ErrNotExist
+
+	// ErrAppendOnly is returned when updating or deleting records of an
+	// append-only collection
+	ErrAppendOnly = errors.New("collection is append-only")
+
+	// ErrChainBroken is returned by VerifyChain when records don't match the
+	// hash chain of their collection
+	ErrChainBroken = errors.New("hash chain is broken")
+
+	// ErrClosed is returned by WriteAsync once the Driver is closed
+	ErrClosed = errors.New("driver is closed")
+
+	// ErrConditionFailed is returned by WriteIf when its condition doesn't hold
+	ErrConditionFailed = errors.New("condition failed")
+
+	// ErrConflict is returned by Update when the conflict detector of the
+	// collection rejects it, see SetConflictDetector
+	ErrConflict = errors.New("conflicting update")
+
+	// ErrCorrupt is returned by New when Options.VerifyOnOpen finds bad
+	// records and is set to fail
+	ErrCorrupt = errors.New("corrupt records")
+
+	// ErrInvalidName is wrapped by the errors of operations on IDs or
+	// collections that can't be file names, see ValidateID
+	ErrInvalidName = errors.New("invalid name")
+
+	// ErrNotReplicated is returned by the writes a Replicator can't carry,
+	// like Increment or Enqueue, when Options.Replicator is set
+	ErrNotReplicated = errors.New("write is not replicated")
+
+	// ErrQueueEmpty is returned by Dequeue when no message is visible
+	ErrQueueEmpty = errors.New("queue is empty")
+
+	// ErrStaleReceipt is returned by Ack and Nack when the message was
+	// handed out again since the receipt was, or is gone
+	ErrStaleReceipt = errors.New("stale receipt")
+
+	// ErrNotConfirmed is returned by destructive operations called without
+	// their confirmation
+	ErrNotConfirmed = errors.New("operation not confirmed")
+
+	// ErrBadSignature is returned by OpenSealed when a stream's signatures
+	// don't match
+	ErrBadSignature = errors.New("bad signature")
+
+	// ErrOverloaded is returned by writes when Options.MaxPendingWrites are
+	// already waiting, and by WriteAsync when its queue is full
+	ErrOverloaded = errors.New("too many pending writes")
+
+	// ErrFrozen is returned by writes made while writes are frozen, see
+	// Driver.Freeze
+	ErrFrozen = errors.New("writes are frozen")
+
+	// ErrStop is returned by the callbacks of ForEach to stop iterating
+	// without failing
+	ErrStop = errors.New("stop")
+
+	// ErrUnsupportedValue is wrapped by the errors of calls given a value
+	// they can't work with, e.g. a nil pointer or a struct whose ID field
+	// can't be set
+	ErrUnsupportedValue = errors.New("unsupported value")
+
+	// ErrReadOnly is returned by writes to a Driver opened with
+	// Options.ReadOnly
+	ErrReadOnly = errors.New("database is read-only")
+)
@@ -0,0 +1,171 @@
+package jdb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// encryptedField prefixes the string an encrypted field is replaced with, it
+// holds the field's JSON sealed like a record encrypted at rest, in base64
+const encryptedField = "jdbenc:"
+
+// SetEncryptedFields encrypts these fields of the records of the collection
+// with Options.EncryptionKey while the rest of them stays plain, so it can
+// still be indexed and queried. Fields are dotted paths and are decrypted
+// transparently when records are read, only the fields set here are, so
+// plain values looking like encrypted ones are read as they are. Writing a
+// record with one of them fails without an encryption key
+func (d *Driver) SetEncryptedFields(collection string, fields ...string) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.encryptFields = append([]string(nil), fields...)
+	})
+}
+
+// encryptFields returns the record with the fields the collection encrypts
+// encrypted. Their values are replaced in place so the rest of the record is
+// stored byte for byte, records that aren't objects are returned as is
+func (d *Driver) encryptFields(collection string, doc []byte) ([]byte, error) {
+	fields := d.config(collection).encryptFields
+	if len(fields) == 0 {
+		return doc, nil
+	}
+
+	rk := d.keys.get()
+	if rk == nil {
+		return nil, fmt.Errorf("encrypting fields of %s needs Options.EncryptionKey", collection)
+	}
+
+	for _, field := range fields {
+		start, end, ok, err := rawField(doc, field)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok || isEncryptedField(doc[start:end]) {
+			continue
+		}
+
+		sealed, err := sealRecord(rk, doc[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(encryptedField + base64.StdEncoding.EncodeToString(sealed))
+		if err != nil {
+			return nil, err
+		}
+
+		doc = splice(doc, start, end, value)
+	}
+
+	return doc, nil
+}
+
+// decryptFields returns the record with the fields the collection encrypts
+// decrypted, putting back the values that were written
+func (d *Driver) decryptFields(collection string, doc []byte) ([]byte, error) {
+	if !hasEncryptedFields(doc) {
+		return doc, nil
+	}
+
+	for _, field := range d.config(collection).encryptFields {
+		start, end, ok, err := rawField(doc, field)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok || !isEncryptedField(doc[start:end]) {
+			continue
+		}
+
+		var s string
+		if err := json.Unmarshal(doc[start:end], &s); err != nil {
+			return nil, err
+		}
+
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encryptedField))
+		if err != nil {
+			return nil, fmt.Errorf("%s: decoding encrypted field: %w", field, err)
+		}
+
+		value, err := d.openRecord(sealed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+
+		doc = splice(doc, start, end, value)
+	}
+
+	return doc, nil
+}
+
+func hasEncryptedFields(doc []byte) bool {
+	return bytes.Contains(doc, []byte(`"`+encryptedField))
+}
+
+func isEncryptedField(value []byte) bool {
+	return bytes.HasPrefix(value, []byte(`"`+encryptedField))
+}
+
+// rawField returns where the value of the dotted field starts and ends in
+// doc, false when doc or one of the objects on the way doesn't have it
+func rawField(doc []byte, field string) (int, int, bool, error) {
+	start, end := 0, len(doc)
+
+	for _, key := range strings.Split(field, ".") {
+		s, e, ok, err := rawMember(doc[start:end], key)
+		if err != nil || !ok {
+			return 0, 0, false, err
+		}
+
+		start, end = start+s, start+e
+	}
+
+	return start, end, true, nil
+}
+
+// rawMember returns where the value of key starts and ends in the object,
+// false when it isn't an object or doesn't have key
+func rawMember(object []byte, key string) (int, int, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(object))
+
+	t, err := dec.Token()
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	if t != json.Delim('{') {
+		return 0, 0, false, nil
+	}
+
+	for dec.More() {
+		name, err := dec.Token()
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return 0, 0, false, err
+		}
+
+		if name == key {
+			end := int(dec.InputOffset())
+			return end - len(value), end, true, nil
+		}
+	}
+
+	return 0, 0, false, nil
+}
+
+// splice returns doc with its bytes from start to end replaced by value
+func splice(doc []byte, start, end int, value []byte) []byte {
+	out := make([]byte, 0, len(doc)-(end-start)+len(value))
+	out = append(out, doc[:start]...)
+	out = append(out, value...)
+
+	return append(out, doc[end:]...)
+}
@@ -0,0 +1,77 @@
+package jdb_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+var fieldKey = bytes.Repeat([]byte{7}, 32)
+
+func TestEncryptedFieldsRoundTripByteForByte(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	d := jdbtest.Open(t, dir, jdb.WithFieldEncryption(fieldKey))
+	d.SetEncryptedFields("users", "ssn", "card.number")
+
+	doc := json.RawMessage(`{"name": "Ann",  "ssn":"123-45-6789", "card": {"number": 4111, "exp": "12/30"}}`)
+	if _, err := d.Write("users", "ann", doc); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := ioutil.ReadFile(filepath.Join(dir, "users", "ann.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{"123-45-6789", "4111"} {
+		if bytes.Contains(stored, []byte(secret)) {
+			t.Errorf("%s is stored in plain: %s", secret, stored)
+		}
+	}
+
+	if !bytes.Contains(stored, []byte(`"name": "Ann",  "ssn":"jdbenc:`)) {
+		t.Errorf("plain fields weren't stored as written: %s", stored)
+	}
+
+	got, err := d.Read("users", "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != string(doc) {
+		t.Errorf("read %s, want %s", got, doc)
+	}
+}
+
+func TestPlainValuesLookingEncryptedAreRead(t *testing.T) {
+	doc := `{"text":"jdbenc:hello"}`
+
+	for name, options := range map[string][]jdb.Option{
+		"no key":      nil,
+		"field key":   {jdb.WithFieldEncryption(fieldKey)},
+		"records key": {jdb.WithEncryption(fieldKey)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := jdbtest.New(t, options...)
+			if options != nil {
+				d.SetEncryptedFields("users", "ssn")
+			}
+
+			for _, collection := range []string{"notes", "users"} {
+				if _, err := d.Write(collection, "n", json.RawMessage(doc)); err != nil {
+					t.Fatal(err)
+				}
+
+				got, err := d.Read(collection, "n")
+				if err != nil || got != doc {
+					t.Errorf("reading %s returned %s, %v, want %s", collection, got, err, doc)
+				}
+			}
+		})
+	}
+}
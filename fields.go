@@ -0,0 +1,258 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mutate decodes an object record, hands it to fn and writes it back, all
+// under the collection lock. Numbers are kept exact but keys end up sorted
+func (d *Driver) mutate(collection, ID string, fn func(doc map[string]interface{}) error) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if ID == "" {
+		return fmt.Errorf("missing identifier")
+	}
+
+	done, err := d.admitLocal(collection, ID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var doc map[string]interface{}
+
+	err = d.load(collection, ID, func(b []byte) error {
+		v, err := decodeJSON(b)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		if doc, ok = v.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s/%s is not an object", collection, ID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := fn(doc); err != nil {
+		return err
+	}
+
+	_, err = d.writeLocked(collection, ID, doc)
+	return err
+}
+
+// getField returns the value of a dotted field path
+func getField(doc map[string]interface{}, field string) (interface{}, bool) {
+	var v interface{} = doc
+
+	for _, part := range strings.Split(field, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		if v, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// setField sets a dotted field path, creating the objects on the way
+func setField(doc map[string]interface{}, field string, value interface{}) error {
+	parts := strings.Split(field, ".")
+	m := doc
+
+	for _, part := range parts[:len(parts)-1] {
+		switch next := m[part].(type) {
+		case map[string]interface{}:
+			m = next
+		case nil:
+			child := make(map[string]interface{})
+			m[part] = child
+			m = child
+		default:
+			return fmt.Errorf("%s is not an object", part)
+		}
+	}
+
+	m[parts[len(parts)-1]] = value
+	return nil
+}
+
+// Increment atomically adds delta to the integer field of a record, a missing
+// field counts as zero, and returns the new value
+func (d *Driver) Increment(collection, identifier, field string, delta int64) (int64, error) {
+	if field == "" {
+		return 0, fmt.Errorf("missing field, nothing to increment")
+	}
+
+	var n int64
+
+	err := d.mutate(collection, identifier, func(doc map[string]interface{}) error {
+		if v, ok := getField(doc, field); ok && v != nil {
+			num, ok := v.(json.Number)
+			if !ok {
+				return fmt.Errorf("%s of %s/%s is not a number", field, collection, identifier)
+			}
+
+			var err error
+			if n, err = strconv.ParseInt(num.String(), 10, 64); err != nil {
+				return fmt.Errorf("%s of %s/%s is not an integer", field, collection, identifier)
+			}
+		}
+
+		n += delta
+
+		return setField(doc, field, n)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// arrayField returns the array at the dotted field path, a missing field is an
+// empty array
+func arrayField(doc map[string]interface{}, field string) ([]interface{}, error) {
+	v, ok := getField(doc, field)
+	if !ok || v == nil {
+		return nil, nil
+	}
+
+	array, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not an array", field)
+	}
+
+	return array, nil
+}
+
+// normalize encodes values the way they will be stored so they can be
+// compared against decoded records
+func normalize(values []interface{}) ([]interface{}, []string, error) {
+	normalized := make([]interface{}, 0, len(values))
+	keys := make([]string, 0, len(values))
+
+	for _, value := range values {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		v, err := decodeJSON(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		key, ok := valueKey(v)
+		if !ok {
+			return nil, nil, fmt.Errorf("unable to encode value %v", value)
+		}
+
+		normalized = append(normalized, v)
+		keys = append(keys, key)
+	}
+
+	return normalized, keys, nil
+}
+
+// Push atomically appends the values to the array field of a record, creating
+// it when missing
+func (d *Driver) Push(collection, identifier, field string, values ...interface{}) error {
+	normalized, _, err := normalize(values)
+	if err != nil {
+		return err
+	}
+
+	return d.mutate(collection, identifier, func(doc map[string]interface{}) error {
+		array, err := arrayField(doc, field)
+		if err != nil {
+			return err
+		}
+
+		return setField(doc, field, append(array, normalized...))
+	})
+}
+
+// AddToSet atomically appends the values missing from the array field of a
+// record, creating it when missing
+func (d *Driver) AddToSet(collection, identifier, field string, values ...interface{}) error {
+	normalized, keys, err := normalize(values)
+	if err != nil {
+		return err
+	}
+
+	return d.mutate(collection, identifier, func(doc map[string]interface{}) error {
+		array, err := arrayField(doc, field)
+		if err != nil {
+			return err
+		}
+
+		present := make(map[string]bool, len(array))
+		for _, v := range array {
+			if key, ok := valueKey(v); ok {
+				present[key] = true
+			}
+		}
+
+		for i, v := range normalized {
+			if !present[keys[i]] {
+				present[keys[i]] = true
+				array = append(array, v)
+			}
+		}
+
+		if array == nil {
+			array = []interface{}{}
+		}
+
+		return setField(doc, field, array)
+	})
+}
+
+// Pull atomically removes every element equal to one of the values from the
+// array field of a record
+func (d *Driver) Pull(collection, identifier, field string, values ...interface{}) error {
+	_, keys, err := normalize(values)
+	if err != nil {
+		return err
+	}
+
+	pulled := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		pulled[key] = true
+	}
+
+	return d.mutate(collection, identifier, func(doc map[string]interface{}) error {
+		array, err := arrayField(doc, field)
+		if err != nil || array == nil {
+			return err
+		}
+
+		kept := make([]interface{}, 0, len(array))
+		for _, v := range array {
+			if key, ok := valueKey(v); !ok || !pulled[key] {
+				kept = append(kept, v)
+			}
+		}
+
+		return setField(doc, field, kept)
+	})
+}
@@ -0,0 +1,431 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a parsed filter expression selecting records by their fields,
+// e.g.
+//
+//	age >= 18 and (country = "FR" or country = "BE") and not tags exists
+//
+// Fields are dotted paths into the record, array elements being addressed by
+// their index. Comparisons are =, !=, <, <=, >, >=, ~ matching a regular
+// expression, in matching a value of a list like in ["a", "b"], and exists.
+// Values are JSON literals; numbers compare as numbers, strings in
+// lexicographic order, and comparisons with missing fields or values of
+// different types are false. and binds tighter than or
+type Filter struct {
+	src  string
+	root filterNode
+}
+
+type (
+	filterNode interface {
+		match(doc interface{}) bool
+	}
+
+	andNode struct{ left, right filterNode }
+	orNode  struct{ left, right filterNode }
+	notNode struct{ node filterNode }
+
+	compareNode struct {
+		path  []string
+		op    string
+		value interface{}
+		re    *regexp.Regexp
+	}
+
+	matchAll struct{}
+)
+
+// ParseFilter parses a filter expression, an empty one matching every record
+func ParseFilter(expr string) (*Filter, error) {
+	p := &filterParser{src: expr}
+
+	if p.skip(); p.pos == len(p.src) {
+		return &Filter{src: expr, root: matchAll{}}, nil
+	}
+
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skip(); p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+
+	return &Filter{src: expr, root: root}, nil
+}
+
+func (f *Filter) String() string {
+	return f.src
+}
+
+// Match reports whether the JSON record matches the filter
+func (f *Filter) Match(record []byte) (bool, error) {
+	doc, err := decodeJSON(record)
+	if err != nil {
+		return false, err
+	}
+
+	return f.root.match(doc), nil
+}
+
+// Find returns the records of the collection matching the filter in the
+// Driver's Order, a nil filter matching every record
+func (d *Driver) Find(collection string, filter *Filter) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	start := d.clock.Now()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.listRecords(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []string
+
+	for _, file := range files {
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			if filter != nil {
+				ok, err := filter.Match(b)
+				if err != nil || !ok {
+					return err
+				}
+			}
+
+			records = append(records, string(b))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", collection, file.ID, err)
+		}
+	}
+
+	d.logSlow("find", collection, start, recordsSize(records))
+	return records, nil
+}
+
+func (matchAll) match(interface{}) bool { return true }
+
+func (n andNode) match(doc interface{}) bool { return n.left.match(doc) && n.right.match(doc) }
+
+func (n orNode) match(doc interface{}) bool { return n.left.match(doc) || n.right.match(doc) }
+
+func (n notNode) match(doc interface{}) bool { return !n.node.match(doc) }
+
+func (n compareNode) match(doc interface{}) bool {
+	v, ok := lookupPath(doc, n.path)
+
+	switch n.op {
+	case "exists":
+		return ok
+	case "!=":
+		return !ok || !equalValues(v, n.value)
+	}
+
+	if !ok {
+		return false
+	}
+
+	switch n.op {
+	case "=":
+		return equalValues(v, n.value)
+	case "in":
+		for _, e := range n.value.([]interface{}) {
+			if equalValues(v, e) {
+				return true
+			}
+		}
+
+		return false
+	case "~":
+		s, ok := v.(string)
+		return ok && n.re.MatchString(s)
+	}
+
+	c, ok := compareValues(v, n.value)
+	if !ok {
+		return false
+	}
+
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// lookupPath returns the value at the dotted path of a decoded document
+func lookupPath(doc interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+
+			doc = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
+
+func equalValues(a, b interface{}) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+
+	switch a := a.(type) {
+	case map[string]interface{}, []interface{}:
+		x, _ := json.Marshal(a)
+		y, _ := json.Marshal(b)
+		return string(x) == string(y)
+	default:
+		return a == b
+	}
+}
+
+// compareValues orders numbers and strings, it fails for other values or
+// values of different types
+func compareValues(a, b interface{}) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	x, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+
+	y, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+
+	return strings.Compare(x, y), true
+}
+
+type filterParser struct {
+	src string
+	pos int
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid filter at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *filterParser) skip() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// keyword consumes the keyword when it's next
+func (p *filterParser) keyword(kw string) bool {
+	p.skip()
+
+	end := p.pos + len(kw)
+	if end > len(p.src) || !strings.EqualFold(p.src[p.pos:end], kw) {
+		return false
+	}
+
+	if end < len(p.src) && isPathChar(rune(p.src[end])) {
+		return false
+	}
+
+	p.pos = end
+	return true
+}
+
+func (p *filterParser) or() (filterNode, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		left = orNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) and() (filterNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = andNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) unary() (filterNode, error) {
+	if p.keyword("not") {
+		node, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{node}, nil
+	}
+
+	if p.skip(); p.pos < len(p.src) && p.src[p.pos] == '(' {
+		p.pos++
+
+		node, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.skip(); p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.errorf("expected )")
+		}
+
+		p.pos++
+		return node, nil
+	}
+
+	return p.comparison()
+}
+
+func isPathChar(r rune) bool {
+	return r == '_' || r == '-' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (p *filterParser) comparison() (filterNode, error) {
+	p.skip()
+
+	start := p.pos
+	for p.pos < len(p.src) && isPathChar(rune(p.src[p.pos])) {
+		p.pos++
+	}
+
+	if start == p.pos {
+		return nil, p.errorf("expected a field")
+	}
+
+	node := compareNode{path: strings.Split(p.src[start:p.pos], ".")}
+
+	if p.keyword("exists") {
+		node.op = "exists"
+		return node, nil
+	}
+
+	if p.keyword("in") {
+		node.op = "in"
+	} else {
+		p.skip()
+		for _, op := range []string{"<=", ">=", "!=", "==", "=", "<", ">", "~"} {
+			if strings.HasPrefix(p.src[p.pos:], op) {
+				node.op = op
+				if op == "==" {
+					node.op = "="
+				}
+
+				p.pos += len(op)
+				break
+			}
+		}
+
+		if node.op == "" {
+			return nil, p.errorf("expected a comparison after %s", strings.Join(node.path, "."))
+		}
+	}
+
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	node.value = value
+
+	switch node.op {
+	case "in":
+		if _, ok := value.([]interface{}); !ok {
+			return nil, p.errorf("in needs a list")
+		}
+	case "~":
+		s, ok := value.(string)
+		if !ok {
+			return nil, p.errorf("~ needs a string")
+		}
+
+		if node.re, err = regexp.Compile(s); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+	}
+
+	return node, nil
+}
+
+// value parses a JSON literal
+func (p *filterParser) value() (interface{}, error) {
+	p.skip()
+
+	dec := json.NewDecoder(strings.NewReader(p.src[p.pos:]))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, p.errorf("expected a JSON value")
+	}
+
+	p.pos += int(dec.InputOffset())
+	return v, nil
+}
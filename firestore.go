@@ -0,0 +1,154 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// firestoreCollections is the key Firestore JSON exports keep collections
+// and subcollections under
+const firestoreCollections = "__collections__"
+
+// importFirestore imports a Firestore JSON export: an object whose
+// __collections__ key maps collections to their documents by ID, documents
+// having their own __collections__ for subcollections. The subcollections of
+// users/alice become the nested collection users/alice/<name>. Typed values
+// are turned into plain JSON: timestamps into RFC 3339 strings, geopoints
+// into latitude and longitude objects and references into their path
+func (d *Driver) importFirestore(src string, opts ImportOptions) (int, error) {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return 0, err
+	}
+
+	root, err := decodeJSON(b)
+	if err != nil {
+		return 0, fmt.Errorf("importing %s: %w", src, err)
+	}
+
+	doc, ok := root.(map[string]interface{})
+	if !ok || doc[firestoreCollections] == nil {
+		return 0, fmt.Errorf("importing %s: no %s, not a Firestore export", src, firestoreCollections)
+	}
+
+	count, err := d.importSubcollections("", doc, opts)
+	if err != nil {
+		return count, fmt.Errorf("importing %s: %w", src, err)
+	}
+
+	return count, nil
+}
+
+// importSubcollections imports the collections held by the document, their
+// names prefixed with parent
+func (d *Driver) importSubcollections(parent string, doc map[string]interface{}, opts ImportOptions) (int, error) {
+	collections, ok := doc[firestoreCollections].(map[string]interface{})
+	if !ok {
+		return 0, nil
+	}
+
+	count := 0
+
+	for _, name := range sortedKeys(collections) {
+		docs, ok := collections[name].(map[string]interface{})
+		if !ok {
+			return count, fmt.Errorf("collection %s%s is not an object", parent, name)
+		}
+
+		n, err := d.importFirestoreDocs(parent+name, docs, opts)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+func (d *Driver) importFirestoreDocs(collection string, docs map[string]interface{}, opts ImportOptions) (int, error) {
+	count := 0
+	IDs := sortedKeys(docs)
+
+	for i, ID := range IDs {
+		doc, ok := docs[ID].(map[string]interface{})
+		if !ok {
+			return count, fmt.Errorf("document %s/%s is not an object", collection, ID)
+		}
+
+		fields := make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			if k != firestoreCollections {
+				fields[k] = firestoreValue(v)
+			}
+		}
+
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return count, fmt.Errorf("document %s/%s: %w", collection, ID, err)
+		}
+
+		ok, err = d.importRecord(collection, ID, b, opts)
+		if err != nil {
+			return count, err
+		}
+
+		if ok {
+			count++
+		}
+
+		d.progress(Progress{Op: "import", Collection: collection, Done: i + 1, Total: len(IDs)})
+
+		n, err := d.importSubcollections(collection+"/"+ID+"/", doc, opts)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// firestoreValue turns the values exported with a __datatype__ into plain
+// JSON, other values are returned as is
+func firestoreValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for i, e := range v {
+			v[i] = firestoreValue(e)
+		}
+
+		return v
+	case map[string]interface{}:
+		datatype, ok := v["__datatype__"].(string)
+		if !ok {
+			for k, e := range v {
+				v[k] = firestoreValue(e)
+			}
+
+			return v
+		}
+
+		value := v["value"]
+		fields, _ := value.(map[string]interface{})
+
+		switch datatype {
+		case "timestamp":
+			seconds, ok := toFloat(fields["_seconds"])
+			if !ok {
+				return value
+			}
+
+			nanos, _ := toFloat(fields["_nanoseconds"])
+			return time.Unix(int64(seconds), int64(nanos)).UTC().Format(time.RFC3339Nano)
+		case "geopoint":
+			return map[string]interface{}{"latitude": fields["_latitude"], "longitude": fields["_longitude"]}
+		default:
+			// documentReference holds the path of the document already
+			return firestoreValue(value)
+		}
+	default:
+		return v
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package jdb
+
+// lockFile is a no-op where advisory locks aren't available, the Driver's own
+// locks still apply within the process
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
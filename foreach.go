@@ -0,0 +1,126 @@
+package jdb
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+type (
+	// ForEachOption tunes ForEach
+	ForEachOption func(*forEachOptions)
+
+	forEachOptions struct {
+		parallel int
+	}
+)
+
+// Parallel makes ForEach call fn from n goroutines at once, records then
+// being handed out in no particular order
+func Parallel(n int) ForEachOption {
+	return func(o *forEachOptions) {
+		o.parallel = n
+	}
+}
+
+// ForEach calls fn with every record of the collection, in the Driver's
+// Order unless it's Parallel. raw is only valid until fn returns. The records
+// are listed up front and read as fn goes, without holding the collection
+// lock, so records deleted meanwhile are skipped and writes aren't blocked.
+// The first error returned by fn stops the iteration and is returned, except
+// for ErrStop which stops it without error
+func (d *Driver) ForEach(collection string, fn func(ID string, raw []byte) error, opts ...ForEachOption) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if err := ValidateCollection(collection); err != nil {
+		return err
+	}
+
+	o := forEachOptions{parallel: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	files, err := d.listRecords(collection)
+	mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	visit := func(file record) error {
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			return fn(file.ID, b)
+		})
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if o.parallel <= 1 {
+		for _, file := range files {
+			if err := visit(file); err != nil {
+				return stopped(err)
+			}
+		}
+
+		return nil
+	}
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+
+	work := make(chan record)
+	abort := make(chan struct{})
+
+	for i := 0; i < o.parallel; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for file := range work {
+				if err := visit(file); err != nil {
+					once.Do(func() {
+						first = err
+						close(abort)
+					})
+
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, file := range files {
+		select {
+		case work <- file:
+		case <-abort:
+			break feed
+		}
+	}
+
+	close(work)
+	wg.Wait()
+
+	return stopped(first)
+}
+
+// stopped returns the error ending an iteration, ErrStop meaning none
+func stopped(err error) error {
+	if err == ErrStop {
+		return nil
+	}
+
+	return err
+}
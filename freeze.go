@@ -0,0 +1,98 @@
+package jdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// Freeze pauses writes, e.g. while a backup runs, returning once the writes
+// already under way are done. Writes made until Thaw fail with ErrFrozen,
+// after waiting up to Options.FreezeTimeout for it. So do the changes the
+// Driver makes on its own, like sweeping expired records, purging the trash,
+// archiving, migrating or rotating the key, the background sweep skipping
+// its turns. Freezes nest, writes resuming at the Thaw of the last one
+func (d *Driver) Freeze() {
+	d.coalesced.flush(d, "")
+
+	d.mutex.Lock()
+
+	if d.frozen == 0 {
+		d.thawed = make(chan struct{})
+	}
+
+	d.frozen++
+
+	var idle chan struct{}
+	if d.pending > 0 {
+		if d.idle == nil {
+			d.idle = make(chan struct{})
+		}
+
+		idle = d.idle
+	}
+
+	d.mutex.Unlock()
+
+	if idle != nil {
+		<-idle
+	}
+}
+
+// Thaw ends a Freeze, doing nothing when writes aren't frozen
+func (d *Driver) Thaw() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.frozen == 0 {
+		return
+	}
+
+	d.frozen--
+	if d.frozen == 0 {
+		close(d.thawed)
+	}
+}
+
+// Frozen reports whether writes are frozen
+func (d *Driver) Frozen() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.frozen > 0
+}
+
+// awaitThaw waits for writes to be thawed as long as Options.FreezeTimeout
+// lets it, failing with ErrFrozen. what is the change waiting, for errors.
+// It's called and returns holding d.mutex
+func (d *Driver) awaitThaw(what string) error {
+	var timeout <-chan time.Time
+
+	for d.frozen > 0 {
+		if d.freezeTimeout == 0 {
+			return fmt.Errorf("%s: %w", what, ErrFrozen)
+		}
+
+		// a negative timeout leaves it nil, waiting for Thaw however long
+		if timeout == nil && d.freezeTimeout > 0 {
+			timeout = d.after(d.freezeTimeout)
+		}
+
+		thawed := d.thawed
+		d.mutex.Unlock()
+
+		select {
+		case <-thawed:
+		case <-timeout:
+			d.mutex.Lock()
+			if d.frozen > 0 {
+				return fmt.Errorf("%s, still frozen after %s: %w", what, d.freezeTimeout, ErrFrozen)
+			}
+
+			return nil
+		}
+
+		d.mutex.Lock()
+	}
+
+	return nil
+}
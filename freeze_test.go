@@ -0,0 +1,194 @@
+package jdb_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+// tree returns the content of every file below dir by path
+func tree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		b, err := ioutil.ReadFile(path)
+		files[strings.TrimPrefix(path, dir)] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func assertSameTree(t *testing.T, before, after map[string]string) {
+	t.Helper()
+
+	for path, content := range before {
+		if changed, ok := after[path]; !ok {
+			t.Errorf("%s was removed", path)
+		} else if changed != content {
+			t.Errorf("%s was changed", path)
+		}
+	}
+
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			t.Errorf("%s was added", path)
+		}
+	}
+}
+
+func TestFreezeStopsEveryWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	d := jdbtest.Open(t, dir, jdb.WithTrash(time.Hour))
+	jdbtest.Seed(t, d, "users", 3, jdbtest.Sequence("u", map[string]int{"n": 1}))
+
+	if err := d.Delete("users", "u-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.KV().Set("k", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	msgID, err := d.Enqueue("jobs", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Expire("users", "u-1", -time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	d.SetArchivePolicy("users", time.Nanosecond)
+
+	migration := jdb.Migration{Name: "double", Collection: "users", Up: func(ID string, raw []byte) (interface{}, error) {
+		return map[string]int{"n": 2}, nil
+	}}
+
+	plan, err := d.PlanMigrations(0, migration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Freeze()
+	defer d.Thaw()
+
+	before := tree(t, dir)
+
+	ops := map[string]func() error{
+		"Write": func() error { _, err := d.Write("users", "x", 1); return err },
+		"UpdateFn": func() error {
+			_, err := jdb.UpdateFn(d, "users", "u-0", func(v map[string]int) (map[string]int, error) { return v, nil })
+			return err
+		},
+		"Increment": func() error { _, err := d.Increment("users", "u-0", "n", 1); return err },
+		"Expire":    func() error { return d.Expire("users", "u-0", time.Hour) },
+		"Persist":   func() error { return d.Persist("users", "u-0") },
+		"WriteIf":   func() error { _, err := d.WriteIf("users", "u-0", 1, jdb.NotExists()); return err },
+		"Take":      func() error { _, err := d.Take("users", "u-0"); return err },
+		"Copy":      func() error { return d.CopyRecord("users", "u-0", "copies") },
+		"Undelete":  func() error { return d.Undelete("users", "u-2") },
+		"Drop":      func() error { return d.DropCollection("users", true) },
+		"KV.Del":    func() error { return d.KV().Del("k") },
+		"KV.Incr":   func() error { _, err := d.KV().Incr("k", 1); return err },
+		"Enqueue":   func() error { _, err := d.Enqueue("jobs", 1); return err },
+		"Dequeue":   func() error { _, err := d.Dequeue("jobs", time.Minute); return err },
+		"Ack":       func() error { return d.Ack("jobs", msgID, "receipt") },
+		"Restore": func() error {
+			_, err := d.Restore(strings.NewReader(`{"collection":"users","id":"r","data":1}`), jdb.RestoreOverwrite)
+			return err
+		},
+		"Import": func() error {
+			_, err := d.ImportNDJSON("users", strings.NewReader(`{"id":"i"}`), jdb.ImportOptions{})
+			return err
+		},
+		"SweepExpired":           func() error { _, err := d.SweepExpired(); return err },
+		"PurgeTrash":             func() error { _, err := d.PurgeTrash(); return err },
+		"Archive":                func() error { _, err := d.Archive("users", -time.Hour); return err },
+		"ApplyArchivePolicies":   func() error { _, err := d.ApplyArchivePolicies(); return err },
+		"RotateKey":              func() error { return d.RotateKey(nil, fieldKey, nil) },
+		"Migrate":                func() error { return d.Migrate(plan, migration) },
+		"Verify with quarantine": func() error { _, err := d.Verify(jdb.VerifyOptions{Quarantine: true}); return err },
+		"PruneBlobs":             func() error { _, err := d.PruneBlobs(); return err },
+		"SetEngine":              func() error { return d.SetEngine("users", jdb.EngineSegment, nil) },
+	}
+
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, jdb.ErrFrozen) {
+			t.Errorf("%s while frozen = %v, want ErrFrozen", name, err)
+		}
+	}
+
+	assertSameTree(t, before, tree(t, dir))
+
+	d.Thaw()
+
+	IDs, err := d.IDs("users")
+	if err != nil || len(IDs) != 2 {
+		t.Errorf("users holds %v, %v after the frozen writes, want u-0 and u-1", IDs, err)
+	}
+}
+
+func TestFrozenDriverIsNotSwept(t *testing.T) {
+	clock := jdbtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	dir := filepath.Join(t.TempDir(), "db")
+	d := jdbtest.Open(t, dir, jdb.WithClock(clock), jdb.WithTTLSweep(time.Minute), jdb.WithTrash(time.Second))
+	jdbtest.Seed(t, d, "users", 2, jdbtest.Sequence("u", 1))
+
+	if err := d.Expire("users", "u-0", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Delete("users", "u-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// turn lets the sweep run once, it's done when it waits for the next
+	turn := func() {
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		clock.Advance(time.Minute)
+
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	d.Freeze()
+
+	before := tree(t, dir)
+
+	turn()
+	turn()
+
+	assertSameTree(t, before, tree(t, dir))
+
+	d.Thaw()
+	turn()
+
+	if ok, err := d.Exists("users", "u-0"); ok || err != nil {
+		t.Errorf("u-0 exists after the sweep, %v", err)
+	}
+
+	if err := d.Undelete("users", "u-1"); err == nil {
+		t.Error("u-1 is still in the trash after the sweep")
+	}
+}
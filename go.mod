@@ -3,5 +3,9 @@ module github.com/arham09/jdb
 go 1.18
 
 require (
-	github.com/jcelliott/lumber v0.0.0-20160324203708-dd349441af25 // indirect
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/google/uuid v1.3.0
+	github.com/jcelliott/lumber v0.0.0-20160324203708-dd349441af25
 )
+
+require golang.org/x/sys v0.4.0 // indirect
@@ -0,0 +1,164 @@
+package jdb
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type (
+	// handleCache keeps the files of hot records open so reads skip the
+	// open and close syscalls. Record files are replaced by renames rather
+	// than rewritten, so an open handle keeps reading the content it was
+	// opened with: every change the Driver makes evicts the path
+	handleCache struct {
+		mutex   sync.Mutex
+		max     int
+		entries map[string]*list.Element
+		lru     *list.List
+
+		// gen is bumped by every eviction so handles opened while a path
+		// changed aren't cached
+		gen uint64
+	}
+
+	handle struct {
+		path    string
+		f       *os.File
+		size    int64
+		refs    int
+		evicted bool
+	}
+)
+
+func newHandleCache(max int) *handleCache {
+	return &handleCache{max: max, entries: map[string]*list.Element{}, lru: list.New()}
+}
+
+// acquire returns an open handle of the path, release must be called once
+// done with it
+func (c *handleCache) acquire(path string) (*handle, error) {
+	c.mutex.Lock()
+
+	if e, ok := c.entries[path]; ok {
+		h := e.Value.(*handle)
+		h.refs++
+		c.lru.MoveToFront(e)
+		c.mutex.Unlock()
+		return h, nil
+	}
+
+	gen := c.gen
+	c.mutex.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	h := &handle{path: path, f: f, size: info.Size(), refs: 1}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.entries[path]; ok || c.gen != gen {
+		// raced with another reader or a change, don't cache this one
+		h.evicted = true
+		return h, nil
+	}
+
+	c.entries[path] = c.lru.PushFront(h)
+	c.trim()
+
+	return h, nil
+}
+
+func (c *handleCache) release(h *handle) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if h.refs--; h.refs == 0 && h.evicted {
+		h.f.Close()
+	}
+}
+
+// trim closes the least recently used handles over the limit, callers must
+// hold the mutex
+func (c *handleCache) trim() {
+	for c.lru.Len() > c.max {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops the entry, closing its handle once no reader uses it.
+// Callers must hold the mutex
+func (c *handleCache) remove(e *list.Element) {
+	h := e.Value.(*handle)
+
+	c.lru.Remove(e)
+	delete(c.entries, h.path)
+
+	h.evicted = true
+	if h.refs == 0 {
+		h.f.Close()
+	}
+}
+
+// evict drops the handle of the path, to be called after it changed
+func (c *handleCache) evict(path string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.gen++
+
+	if e, ok := c.entries[path]; ok {
+		c.remove(e)
+	}
+}
+
+// evictDir drops the handles of every path under dir
+func (c *handleCache) evictDir(dir string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.gen++
+
+	prefix := dir + string(filepath.Separator)
+	for path, e := range c.entries {
+		if strings.HasPrefix(path, prefix) {
+			c.remove(e)
+		}
+	}
+}
+
+// close closes every handle not in use, the others are closed on release
+func (c *handleCache) close() {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.gen++
+
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
@@ -0,0 +1,205 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// historyDir is the reserved directory holding the deltas between versions of
+// the records of collections keeping history
+const historyDir = "_history"
+
+func (d *Driver) historyPath(collection, ID string) string {
+	return filepath.Join(d.dir, historyDir, collection, ID)
+}
+
+// SetHistory makes the collection keep every version of its records. Only the
+// changes between consecutive versions are stored, see ReadVersion
+func (d *Driver) SetHistory(collection string, keep bool) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.history = keep
+	})
+}
+
+// recordHistory stores the delta turning the new version of a record back
+// into the previous one, callers must hold the collection lock
+func (d *Driver) recordHistory(collection, ID string, doc []byte) error {
+	if !d.config(collection).history {
+		return nil
+	}
+
+	var prev []byte
+
+	err := d.load(collection, ID, func(b []byte) error {
+		prev = append([]byte(nil), b...)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	from, err := decodeJSON(doc)
+	if err != nil {
+		return err
+	}
+
+	to, err := decodeJSON(prev)
+	if err != nil {
+		return err
+	}
+
+	versions, err := d.deltaCount(collection, ID)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(diffValues("", from, to))
+	if err != nil {
+		return err
+	}
+
+	// deltas can hold any field so they're encrypted whole, even when only
+	// some fields of the records are
+	if b, err = sealRecord(d.keys.get(), b); err != nil {
+		return err
+	}
+
+	dir := d.historyPath(collection, ID)
+	if err := d.fs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// the delta is named after the version it rebuilds
+	path := filepath.Join(dir, strconv.Itoa(versions+1)+".json")
+	if err := d.fs.WriteFile(path+".tmp", b, 0644); err != nil {
+		return err
+	}
+
+	return d.fs.Rename(path+".tmp", path)
+}
+
+func (d *Driver) deltaCount(collection, ID string) (int, error) {
+	files, err := d.fs.ReadDir(d.historyPath(collection, ID))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".json") {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Versions returns the number of the current version of a record, versions
+// are numbered from 1 for the first write
+func (d *Driver) Versions(collection, identifier string) (int, error) {
+	if collection == "" {
+		return 0, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if identifier == "" {
+		return 0, fmt.Errorf("missing ID, no identifier to get data")
+	}
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := d.locate(collection, identifier); err != nil && !d.archived(collection, identifier) {
+		return 0, err
+	}
+
+	count, err := d.deltaCount(collection, identifier)
+	if err != nil {
+		return 0, err
+	}
+
+	return count + 1, nil
+}
+
+// ReadVersion rebuilds a past version of a record by applying the stored
+// deltas to the current one
+func (d *Driver) ReadVersion(collection, identifier string, version int) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if identifier == "" {
+		return "", fmt.Errorf("missing ID, no identifier to get data")
+	}
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var doc interface{}
+
+	err := d.load(collection, identifier, func(b []byte) error {
+		var err error
+		doc, err = decodeJSON(b)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	current, err := d.deltaCount(collection, identifier)
+	if err != nil {
+		return "", err
+	}
+
+	current++
+
+	if version < 1 || version > current {
+		return "", fmt.Errorf("%s/%s has versions 1 to %d, not %d", collection, identifier, current, version)
+	}
+
+	for v := current - 1; v >= version; v-- {
+		b, err := d.fs.ReadFile(filepath.Join(d.historyPath(collection, identifier), strconv.Itoa(v)+".json"))
+		if err != nil {
+			return "", err
+		}
+
+		if b, err = d.openRecord(b); err != nil {
+			return "", fmt.Errorf("decrypting delta %d of %s/%s: %w", v, collection, identifier, err)
+		}
+
+		var changes []Change
+		if err := json.Unmarshal(b, &changes); err != nil {
+			return "", fmt.Errorf("decoding delta %d of %s/%s: %w", v, collection, identifier, err)
+		}
+
+		if doc, err = applyChanges(doc, changes); err != nil {
+			return "", fmt.Errorf("rebuilding version %d of %s/%s: %w", v, collection, identifier, err)
+		}
+	}
+
+	b, err := d.encode(doc)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// dropHistory removes the deltas of a deleted record, callers must hold the
+// collection lock
+func (d *Driver) dropHistory(collection, ID string) error {
+	return d.fs.RemoveAll(d.historyPath(collection, ID))
+}
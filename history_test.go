@@ -0,0 +1,123 @@
+package jdb_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func sameJSON(t *testing.T, got, want string) bool {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal([]byte(got), &g); err != nil {
+		t.Fatalf("decoding %s: %s", got, err)
+	}
+
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("decoding %s: %s", want, err)
+	}
+
+	return reflect.DeepEqual(g, w)
+}
+
+func TestReadVersionRebuildsEveryVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []jdb.Option
+	}{
+		{name: "plain"},
+		{name: "encrypted", options: []jdb.Option{jdb.WithEncryption(fieldKey)}},
+	}
+
+	versions := []string{
+		`{"name":"ada","tags":["a"]}`,
+		`{"name":"ada","tags":["a","b"],"address":{"city":"london"}}`,
+		`{"name":"ada lovelace","address":{"city":"london","zip":"n1"}}`,
+		`{"name":"ada lovelace","address":{"zip":"n1"},"born":1815}`,
+		`[1,2,3]`,
+		`{"name":"countess"}`,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := jdbtest.New(t, tt.options...)
+			d.SetHistory("users", true)
+
+			for _, v := range versions {
+				if _, err := d.Write("users", "ada", json.RawMessage(v)); err != nil {
+					t.Fatalf("writing %s: %s", v, err)
+				}
+			}
+
+			if n, err := d.Versions("users", "ada"); err != nil || n != len(versions) {
+				t.Fatalf("Versions = %d, %v, want %d", n, err, len(versions))
+			}
+
+			for i, want := range versions {
+				got, err := d.ReadVersion("users", "ada", i+1)
+				if err != nil {
+					t.Fatalf("ReadVersion %d: %s", i+1, err)
+				}
+
+				if !sameJSON(t, got, want) {
+					t.Errorf("version %d = %s, want %s", i+1, got, want)
+				}
+			}
+
+			for _, v := range []int{0, len(versions) + 1} {
+				if _, err := d.ReadVersion("users", "ada", v); err == nil {
+					t.Errorf("ReadVersion %d succeeded", v)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryIsOnlyKeptWhenSet(t *testing.T) {
+	d := jdbtest.New(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := d.Write("users", "ada", map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := d.Versions("users", "ada"); err != nil || n != 1 {
+		t.Errorf("Versions without history = %d, %v, want 1", n, err)
+	}
+
+	if _, err := d.ReadVersion("users", "ada", 1); err != nil {
+		t.Errorf("ReadVersion of the current version: %s", err)
+	}
+}
+
+func TestDeleteDropsHistory(t *testing.T) {
+	d := jdbtest.New(t)
+	d.SetHistory("users", true)
+
+	for i := 0; i < 3; i++ {
+		if _, err := d.Write("users", "ada", map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.Delete("users", "ada"); err != nil {
+		t.Fatalf("deleting: %s", err)
+	}
+
+	if _, err := d.Versions("users", "ada"); err == nil {
+		t.Error("Versions of a deleted record succeeded")
+	}
+
+	if _, err := d.Write("users", "ada", map[string]int{"n": 9}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := d.Versions("users", "ada"); err != nil || n != 1 {
+		t.Errorf("Versions of a record written again = %d, %v, want 1", n, err)
+	}
+}
@@ -0,0 +1,96 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// idempotencyDir is the reserved directory holding the idempotency keys of
+// WriteIdempotent and the IDs they were written under
+const idempotencyDir = "_idempotency"
+
+// idempotencyRecord is what a processed idempotency key keeps
+type idempotencyRecord struct {
+	ID string    `json:"id"`
+	At time.Time `json:"at"`
+}
+
+// WriteIdempotent inserts v like Insert the first time the idempotency key is
+// seen in the collection, returning the ID it was written under on every
+// retry without writing again. It lets webhook handlers persist deliveries
+// without duplicating redelivered ones. The check and the write happen under
+// the collection lock and an advisory file lock, like WriteIf. Keys are kept
+// until the collection is deleted, failed writes don't record theirs
+func (d *Driver) WriteIdempotent(collection, idempotencyKey string, v interface{}) (string, error) {
+	if collection == "" {
+		return "", fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if idempotencyKey == "" {
+		return "", fmt.Errorf("missing idempotency key")
+	}
+
+	done, err := d.admitLocal(collection)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	unlock, err := d.lockShared(collection)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	path := d.idempotencyPath(collection, idempotencyKey)
+
+	b, err := d.fs.ReadFile(path)
+	if err == nil {
+		var seen idempotencyRecord
+		if err := json.Unmarshal(b, &seen); err != nil {
+			return "", fmt.Errorf("idempotency key %s of %s: %w", idempotencyKey, collection, err)
+		}
+
+		d.log.Debug("idempotency key %s of %s already written as %s", idempotencyKey, collection, seen.ID)
+		return seen.ID, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	ID := d.newID()
+	if err := validateRecord(collection, ID); err != nil {
+		return ID, err
+	}
+
+	if err := assignID(v, ID); err != nil {
+		return "", err
+	}
+
+	if _, err := d.writeLocked(collection, ID, v); err != nil {
+		return ID, err
+	}
+
+	if b, err = json.Marshal(idempotencyRecord{ID: ID, At: d.clock.Now()}); err != nil {
+		return ID, err
+	}
+
+	if err := d.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return ID, err
+	}
+
+	if err := d.fs.WriteFile(path+".tmp", b, 0644); err != nil {
+		return ID, err
+	}
+
+	return ID, d.fs.Rename(path+".tmp", path)
+}
+
+func (d *Driver) idempotencyPath(collection, key string) string {
+	return filepath.Join(d.dir, idempotencyDir, collection, url.QueryEscape(key)+".json")
+}
@@ -0,0 +1,71 @@
+package jdb
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// idFields caches the index of the `jdb:"id"` field of struct types, -1
+// when they have none
+var idFields sync.Map
+
+// idField returns the field of the struct type tagged `jdb:"id"`
+func idField(t reflect.Type) (reflect.StructField, bool) {
+	if i, ok := idFields.Load(t); ok {
+		if i.(int) < 0 {
+			return reflect.StructField{}, false
+		}
+
+		return t.Field(i.(int)), true
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("jdb") == "id" {
+			idFields.Store(t, i)
+			return t.Field(i), true
+		}
+	}
+
+	idFields.Store(t, -1)
+	return reflect.StructField{}, false
+}
+
+// assignID sets the string field of the struct v points to tagged `jdb:"id"`
+// to the ID, so Insert hands back the ID it made in the value itself. Values
+// without such a field are left alone, ones it can't be set on fail with
+// ErrUnsupportedValue rather than panic
+func assignID(v interface{}, ID string) error {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil
+	}
+
+	elem := t
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	if elem.Kind() != reflect.Struct {
+		return nil
+	}
+
+	field, ok := idField(elem)
+	if !ok {
+		return nil
+	}
+
+	switch {
+	case t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct:
+		return fmt.Errorf("%T has an ID field, Insert needs a pointer to it to set the ID: %w", v, ErrUnsupportedValue)
+	case reflect.ValueOf(v).IsNil():
+		return fmt.Errorf("nil %T: %w", v, ErrUnsupportedValue)
+	case field.PkgPath != "":
+		return fmt.Errorf("ID field %s of %s is unexported: %w", field.Name, elem, ErrUnsupportedValue)
+	case field.Type.Kind() != reflect.String:
+		return fmt.Errorf("ID field %s of %s has type %s, not string: %w", field.Name, elem, field.Type, ErrUnsupportedValue)
+	}
+
+	reflect.ValueOf(v).Elem().FieldByIndex(field.Index).SetString(ID)
+	return nil
+}
@@ -0,0 +1,429 @@
+package jdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Layout is how a flat-file JSON store lays its records out on disk
+	Layout int
+
+	// ImportOptions configures ImportFrom
+	ImportOptions struct {
+		Layout Layout
+
+		// IDField names the field holding the ID of records stored in JSON
+		// arrays, defaults to "id"
+		IDField string
+
+		// Overwrite replaces records that already exist instead of skipping
+		Overwrite bool
+	}
+)
+
+const (
+	// LayoutDirPerCollection is src/<collection>/<id>[.json], used by scribble
+	// and jdb itself, nested directories become nested collections
+	LayoutDirPerCollection Layout = iota
+
+	// LayoutFilePerCollection is src/<collection>.json holding either an object
+	// keyed by ID or an array of records
+	LayoutFilePerCollection
+
+	// LayoutSingleFile is a single JSON file holding an object keyed by
+	// collection, each one an object keyed by ID or an array of records
+	LayoutSingleFile
+
+	// LayoutFirestore is a single JSON file exported from Firestore by
+	// tools like node-firestore-import-export, see importFirestore
+	LayoutFirestore
+)
+
+// ImportFrom copies the records of another flat-file JSON store at src into the
+// database, normalizing them into jdb's layout. It returns how many records
+// were written
+func (d *Driver) ImportFrom(src string, opts ImportOptions) (int, error) {
+	if opts.IDField == "" {
+		opts.IDField = "id"
+	}
+
+	switch opts.Layout {
+	case LayoutDirPerCollection:
+		return d.importDirs(src, opts)
+	case LayoutFilePerCollection:
+		return d.importFiles(src, opts)
+	case LayoutSingleFile:
+		return d.importFile(src, opts)
+	case LayoutFirestore:
+		return d.importFirestore(src, opts)
+	default:
+		return 0, fmt.Errorf("unknown layout %d", opts.Layout)
+	}
+}
+
+func (d *Driver) importDirs(src string, opts ImportOptions) (int, error) {
+	count := 0
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := info.Name()
+		if path != src && strings.HasPrefix(name, ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() || strings.HasSuffix(name, ".tmp") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			d.log.Debug("skipping %s, it's not inside a collection", path)
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		collection := filepath.ToSlash(rel)
+
+		ok, err := d.importRecord(collection, strings.TrimSuffix(name, ".json"), b, opts)
+		if err != nil {
+			return fmt.Errorf("importing %s: %w", path, err)
+		}
+
+		if ok {
+			count++
+			d.progress(Progress{Op: "import", Collection: collection, Done: count, Total: -1})
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+func (d *Driver) importFiles(src string, opts ImportOptions) (int, error) {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		path := filepath.Join(src, name)
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return count, err
+		}
+
+		n, err := d.importCollection(strings.TrimSuffix(name, ".json"), b, opts)
+		count += n
+		if err != nil {
+			return count, fmt.Errorf("importing %s: %w", path, err)
+		}
+	}
+
+	return count, nil
+}
+
+func (d *Driver) importFile(src string, opts ImportOptions) (int, error) {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return 0, err
+	}
+
+	var collections map[string]json.RawMessage
+	if err := json.Unmarshal(b, &collections); err != nil {
+		return 0, fmt.Errorf("importing %s: %w", src, err)
+	}
+
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	count := 0
+
+	for _, name := range names {
+		n, err := d.importCollection(name, collections[name], opts)
+		count += n
+		if err != nil {
+			return count, fmt.Errorf("importing %s from %s: %w", name, src, err)
+		}
+	}
+
+	return count, nil
+}
+
+// importCollection imports a collection held in a single JSON value, either
+// an object keyed by ID or an array of records carrying their ID
+func (d *Driver) importCollection(collection string, b []byte, opts ImportOptions) (int, error) {
+	var byID map[string]json.RawMessage
+	if err := json.Unmarshal(b, &byID); err == nil {
+		return d.importMap(collection, byID, opts)
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(b, &list); err != nil {
+		return 0, fmt.Errorf("collection %s is neither an object nor an array", collection)
+	}
+
+	byID = make(map[string]json.RawMessage, len(list))
+
+	for i, raw := range list {
+		ID, err := importID(raw, opts.IDField)
+		if err != nil {
+			return 0, fmt.Errorf("record %d of %s: %w", i, collection, err)
+		}
+
+		byID[ID] = raw
+	}
+
+	return d.importMap(collection, byID, opts)
+}
+
+func (d *Driver) importMap(collection string, records map[string]json.RawMessage, opts ImportOptions) (int, error) {
+	IDs := make([]string, 0, len(records))
+	for ID := range records {
+		IDs = append(IDs, ID)
+	}
+	sort.Strings(IDs)
+
+	count := 0
+
+	for i, ID := range IDs {
+		ok, err := d.importRecord(collection, ID, records[ID], opts)
+		if err != nil {
+			return count, err
+		}
+
+		if ok {
+			count++
+		}
+
+		d.progress(Progress{Op: "import", Collection: collection, Done: i + 1, Total: len(IDs)})
+	}
+
+	return count, nil
+}
+
+func (d *Driver) importRecord(collection, ID string, b []byte, opts ImportOptions) (bool, error) {
+	if !json.Valid(b) {
+		return false, fmt.Errorf("record %s/%s is not valid JSON", collection, ID)
+	}
+
+	if !opts.Overwrite {
+		exists, err := d.Exists(collection, ID)
+		if err != nil {
+			return false, err
+		}
+
+		if exists {
+			d.log.Debug("skipping %s/%s, it already exists", collection, ID)
+			return false, nil
+		}
+	}
+
+	if _, err := d.Write(collection, ID, rawJSON(b)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ImportNDJSON imports newline delimited JSON records into the collection,
+// each one an object carrying its ID in ImportOptions.IDField. The import is
+// all or nothing: the collection is locked until it's done and a line failing
+// to import rolls back every record written before it. It returns how many
+// records were written
+func (d *Driver) ImportNDJSON(collection string, r io.Reader, opts ImportOptions) (int, error) {
+	return d.importNDJSON(collection, r, opts, nil)
+}
+
+// importNDJSON is ImportNDJSON turning every line into a plain JSON record
+// with convert first, when it's set
+func (d *Driver) importNDJSON(collection string, r io.Reader, opts ImportOptions, convert func([]byte) ([]byte, error)) (int, error) {
+	if collection == "" {
+		return 0, fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if err := ValidateCollection(collection); err != nil {
+		return 0, err
+	}
+
+	if opts.IDField == "" {
+		opts.IDField = "id"
+	}
+
+	done, err := d.admitLocal(collection)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var undo []func() error
+
+	count, err := d.importLines(collection, bufio.NewReader(r), opts, convert, &undo)
+	if err == nil {
+		return count, nil
+	}
+
+	for i := len(undo) - 1; i >= 0; i-- {
+		if uerr := undo[i](); uerr != nil {
+			return 0, fmt.Errorf("%w, rolling back failed too: %s", err, uerr)
+		}
+	}
+
+	return 0, err
+}
+
+// importLines writes the records read from r, appending to undo how to
+// revert each write. Callers must hold the collection lock
+func (d *Driver) importLines(collection string, r *bufio.Reader, opts ImportOptions, convert func([]byte) ([]byte, error), undo *[]func() error) (int, error) {
+	count := 0
+
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return count, err
+		}
+
+		if raw := bytes.TrimSpace(b); len(raw) > 0 {
+			var ierr error
+			if convert != nil {
+				if raw, ierr = convert(raw); ierr != nil {
+					return count, fmt.Errorf("importing line %d: %w", line, ierr)
+				}
+			}
+
+			ok, ierr := d.importLine(collection, raw, opts, undo)
+			if ierr != nil {
+				return count, fmt.Errorf("importing line %d: %w", line, ierr)
+			}
+
+			if ok {
+				count++
+			}
+
+			d.progress(Progress{Op: "import", Collection: collection, Done: line, Total: -1})
+		}
+
+		if err == io.EOF {
+			return count, nil
+		}
+	}
+}
+
+func (d *Driver) importLine(collection string, raw []byte, opts ImportOptions, undo *[]func() error) (bool, error) {
+	ID, err := importID(raw, opts.IDField)
+	if err != nil {
+		return false, err
+	}
+
+	var prev []byte
+	exists := true
+
+	err = d.load(collection, ID, func(b []byte) error {
+		prev = append([]byte(nil), b...)
+		return nil
+	})
+
+	switch {
+	case os.IsNotExist(err):
+		exists = false
+	case err != nil:
+		return false, err
+	case !opts.Overwrite:
+		d.log.Debug("skipping %s/%s, it already exists", collection, ID)
+		return false, nil
+	}
+
+	if _, err := d.writeLocked(collection, ID, rawJSON(raw)); err != nil {
+		return false, err
+	}
+
+	*undo = append(*undo, func() error {
+		if !exists {
+			return d.removeRecord(collection, ID)
+		}
+
+		_, err := d.writeLocked(collection, ID, json.RawMessage(prev))
+		return err
+	})
+
+	return true, nil
+}
+
+// importID reads the ID of an imported record from its field. Only strings
+// and integers, written out exactly, are IDs
+func importID(raw []byte, field string) (string, error) {
+	var fields map[string]interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	if err := dec.Decode(&fields); err != nil {
+		return "", fmt.Errorf("not a JSON object: %w", err)
+	}
+
+	v, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("no %q field", field)
+	}
+
+	var ID string
+
+	switch v := v.(type) {
+	case string:
+		ID = v
+	case json.Number:
+		n, err := strconv.ParseInt(v.String(), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q field %s is not an integer: %w", field, v, ErrInvalidName)
+		}
+
+		ID = strconv.FormatInt(n, 10)
+	default:
+		return "", fmt.Errorf("%q field is neither a string nor an integer: %w", field, ErrInvalidName)
+	}
+
+	if err := ValidateID(ID); err != nil {
+		return "", err
+	}
+
+	return ID, nil
+}
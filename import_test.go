@@ -0,0 +1,57 @@
+package jdb_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestImportNDJSONFormatsIntegerIDs(t *testing.T) {
+	d := jdbtest.New(t)
+
+	in := `{"id":"a","n":1}
+{"id":12345678901234567,"n":2}
+`
+	count, err := d.ImportNDJSON("users", strings.NewReader(in), jdb.ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("imported %d records, want 2", count)
+	}
+
+	for _, ID := range []string{"a", "12345678901234567"} {
+		if ok, err := d.Exists("users", ID); err != nil || !ok {
+			t.Errorf("record %s wasn't imported: %v", ID, err)
+		}
+	}
+}
+
+func TestImportNDJSONRejectsInvalidIDs(t *testing.T) {
+	for name, line := range map[string]string{
+		"escaping": `{"id":"../../escaped"}`,
+		"float":    `{"id":1.5}`,
+		"object":   `{"id":{"a":1}}`,
+		"bool":     `{"id":true}`,
+		"null":     `{"id":null}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := jdbtest.New(t)
+
+			in := `{"id":"first"}` + "\n" + line + "\n"
+
+			_, err := d.ImportNDJSON("users", strings.NewReader(in), jdb.ImportOptions{})
+			if !errors.Is(err, jdb.ErrInvalidName) {
+				t.Fatalf("importing %s = %v, want ErrInvalidName", line, err)
+			}
+
+			if ok, _ := d.Exists("users", "first"); ok {
+				t.Errorf("the records before the failing line weren't rolled back")
+			}
+		})
+	}
+}
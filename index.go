@@ -0,0 +1,495 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// index maps the JSON encoded value of a field to the IDs holding it
+	index struct {
+		field  string
+		values map[string]map[string]struct{}
+		ids    map[string]indexEntry
+		dirMod time.Time
+
+		// saved is the file the index is loaded from on first use, see
+		// SaveIndexes, and dirty tells it changed since it was saved
+		saved string
+		dirty bool
+	}
+
+	indexEntry struct {
+		key   string
+		stamp fileStamp
+	}
+
+	fileStamp struct {
+		modTime time.Time
+		size    int64
+	}
+)
+
+func stampOf(info os.FileInfo) fileStamp {
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+func newIndex(field string) *index {
+	return &index{
+		field:  field,
+		values: make(map[string]map[string]struct{}),
+		ids:    make(map[string]indexEntry),
+	}
+}
+
+func (idx *index) put(ID string, doc []byte, stamp fileStamp) {
+	idx.remove(ID)
+	idx.dirty = true
+
+	key, ok := fieldKey(doc, idx.field)
+	if !ok {
+		idx.ids[ID] = indexEntry{stamp: stamp}
+		return
+	}
+
+	if idx.values[key] == nil {
+		idx.values[key] = make(map[string]struct{})
+	}
+
+	idx.values[key][ID] = struct{}{}
+	idx.ids[ID] = indexEntry{key: key, stamp: stamp}
+}
+
+func (idx *index) remove(ID string) {
+	entry, ok := idx.ids[ID]
+	if !ok {
+		return
+	}
+
+	delete(idx.ids, ID)
+	idx.dirty = true
+
+	if ids := idx.values[entry.key]; ids != nil {
+		delete(ids, ID)
+		if len(ids) == 0 {
+			delete(idx.values, entry.key)
+		}
+	}
+}
+
+// fieldKey extracts a dotted field path from the document and encodes it so
+// equal values share a key regardless of how they were written
+func fieldKey(doc []byte, field string) (string, bool) {
+	var v interface{}
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return "", false
+	}
+
+	for _, part := range strings.Split(field, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+
+		if v, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+
+	return valueKey(v)
+}
+
+func valueKey(v interface{}) (string, bool) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+
+	// round trip so 1 and 1.0 or differently ordered maps end up equal
+	var norm interface{}
+	if err := json.Unmarshal(b, &norm); err != nil {
+		return "", false
+	}
+
+	if b, err = json.Marshal(norm); err != nil {
+		return "", false
+	}
+
+	return string(b), true
+}
+
+// EnsureIndex creates an index on the (dotted) field of the collection if it
+// doesn't exist yet, it's kept up to date by every Write and Delete. An
+// index saved by SaveIndexes is loaded on first use instead of being built,
+// unless the collection changed since
+func (d *Driver) EnsureIndex(collection, field string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to index data")
+	}
+
+	if field == "" {
+		return fmt.Errorf("missing field, nothing to index")
+	}
+
+	if d.getIndex(collection, field) != nil {
+		return nil
+	}
+
+	if d.useSavedIndex(collection, field) {
+		return nil
+	}
+
+	return d.RebuildIndex(collection, field)
+}
+
+// RebuildIndex builds the index of the field from the records on disk and
+// swaps it in, lookups keep being served by the old index until then
+func (d *Driver) RebuildIndex(collection, field string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to index data")
+	}
+
+	if field == "" {
+		return fmt.Errorf("missing field, nothing to index")
+	}
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return d.buildIndex(collection, field)
+}
+
+// buildIndex scans the collection, callers must hold the collection lock
+func (d *Driver) buildIndex(collection, field string) error {
+	idx := newIndex(field)
+
+	if err := d.scanIndex(collection, idx); err != nil {
+		return err
+	}
+
+	d.setIndex(collection, idx)
+
+	d.log.Debug("indexed %s.%s: %d records", collection, field, len(idx.ids))
+	return nil
+}
+
+// scanIndex fills the empty index from the records of the collection
+func (d *Driver) scanIndex(collection string, idx *index) error {
+	dir := filepath.Join(d.dir, collection)
+
+	if info, err := d.fs.Stat(dir); err == nil {
+		idx.dirMod = info.ModTime()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	files, err := d.listRecords(collection)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for i, file := range files {
+		file := file
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			idx.put(file.ID, b, stampOf(file.info))
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		d.progress(Progress{Op: "rebuild-index", Collection: collection, Done: i + 1, Total: len(files)})
+	}
+
+	return nil
+}
+
+func (d *Driver) setIndex(collection string, idx *index) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.indexes[collection] == nil {
+		d.indexes[collection] = make(map[string]*index)
+	}
+
+	d.indexes[collection][idx.field] = idx
+}
+
+func (d *Driver) getIndex(collection, field string) *index {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.indexes[collection][field]
+}
+
+func (d *Driver) collectionIndexes(collection string) []*index {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var indexes []*index
+	for _, idx := range d.indexes[collection] {
+		indexes = append(indexes, idx)
+	}
+
+	return indexes
+}
+
+// indexRecord refreshes every index of the collection after a write, callers
+// must hold the collection lock or its shared mutex
+func (d *Driver) indexRecord(collection, ID string, doc []byte) {
+	indexes := d.collectionIndexes(collection)
+	if len(indexes) == 0 {
+		return
+	}
+
+	dir := filepath.Join(d.dir, collection)
+
+	info, err := d.fs.Stat(filepath.Join(dir, ID+".json"))
+	if err != nil {
+		return
+	}
+
+	dirInfo, err := d.fs.Stat(dir)
+	if err != nil {
+		return
+	}
+
+	for _, idx := range indexes {
+		if err := d.loadIndex(collection, idx); err != nil {
+			d.log.Warn("unable to index %s/%s by %s: %s", collection, ID, idx.field, err)
+			continue
+		}
+
+		idx.put(ID, doc, stampOf(info))
+		idx.dirMod = dirInfo.ModTime()
+	}
+}
+
+// unindexRecord drops the ID from every index of the collection, an empty ID
+// means the whole collection is gone
+func (d *Driver) unindexRecord(collection, ID string) {
+	indexes := d.collectionIndexes(collection)
+	if len(indexes) == 0 {
+		return
+	}
+
+	if ID == "" {
+		d.mutex.Lock()
+		delete(d.indexes, collection)
+		d.mutex.Unlock()
+		return
+	}
+
+	var dirMod time.Time
+	if info, err := d.fs.Stat(filepath.Join(d.dir, collection)); err == nil {
+		dirMod = info.ModTime()
+	}
+
+	for _, idx := range indexes {
+		if err := d.loadIndex(collection, idx); err != nil {
+			d.log.Warn("unable to unindex %s/%s by %s: %s", collection, ID, idx.field, err)
+			continue
+		}
+
+		idx.remove(ID)
+		idx.dirMod = dirMod
+	}
+}
+
+// FindBy returns the records whose field equals value in ID order using the
+// index on the field, records changed behind its back are indexed again first
+func (d *Driver) FindBy(collection, field string, value interface{}) ([]string, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if d.getIndex(collection, field) == nil {
+		return nil, fmt.Errorf("no index on %s.%s", collection, field)
+	}
+
+	key, ok := valueKey(value)
+	if !ok {
+		return nil, fmt.Errorf("unable to encode value %v", value)
+	}
+
+	return d.findByKey(collection, field, key)
+}
+
+// findByKey is FindBy of an encoded value, see valueKey
+func (d *Driver) findByKey(collection, field, key string) ([]string, error) {
+	start := d.clock.Now()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	dir := filepath.Join(d.dir, collection)
+
+	records, stale, err := d.findBy(collection, field, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !stale {
+		d.logSlow("find-by", collection, start, recordsSize(records))
+		return records, nil
+	}
+
+	d.log.Debug("index %s.%s is stale, rebuilding", collection, field)
+
+	if err := d.buildIndex(collection, field); err != nil {
+		return nil, err
+	}
+
+	if records, stale, err = d.findBy(collection, field, key); stale {
+		return nil, fmt.Errorf("index %s.%s keeps changing under %s", collection, field, dir)
+	}
+
+	d.logSlow("find-by", collection, start, recordsSize(records))
+	return records, err
+}
+
+// findBy reads the candidates of the index in ID order, reporting it as
+// stale when a candidate changed while it was read
+func (d *Driver) findBy(collection, field, key string) ([]string, bool, error) {
+	idx := d.getIndex(collection, field)
+	if idx == nil {
+		return nil, false, fmt.Errorf("no index on %s.%s", collection, field)
+	}
+
+	if err := d.loadIndex(collection, idx); err != nil {
+		return nil, false, err
+	}
+
+	if err := d.refreshIndex(collection, idx); err != nil {
+		return nil, false, err
+	}
+
+	IDs := make([]string, 0, len(idx.values[key]))
+	for ID := range idx.values[key] {
+		IDs = append(IDs, ID)
+	}
+
+	sort.Strings(IDs)
+
+	records := make([]string, 0, len(IDs))
+
+	for _, ID := range IDs {
+		path, err := d.locate(collection, ID)
+		if err != nil {
+			return nil, true, nil
+		}
+
+		err = d.readFile(collection, path, func(b []byte) error {
+			records = append(records, string(b))
+			return nil
+		})
+		if os.IsNotExist(err) {
+			return nil, true, nil
+		}
+
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return records, false, nil
+}
+
+// refreshIndex indexes again the records changed behind the back of the
+// index, any of which may now hold the value looked up, and drops the ones
+// gone. Only the directory is listed when nothing changed, callers must hold
+// the collection lock
+func (d *Driver) refreshIndex(collection string, idx *index) error {
+	files, err := d.listRecords(collection)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	seen := make(map[string]bool, len(files))
+
+	for _, file := range files {
+		file := file
+		seen[file.ID] = true
+
+		if entry, ok := idx.ids[file.ID]; ok && entry.stamp == stampOf(file.info) {
+			continue
+		}
+
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			idx.put(file.ID, b, stampOf(file.info))
+			return nil
+		})
+		if os.IsNotExist(err) {
+			idx.remove(file.ID)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for ID := range idx.ids {
+		if !seen[ID] {
+			idx.remove(ID)
+		}
+	}
+
+	if info, err := d.fs.Stat(filepath.Join(d.dir, collection)); err == nil {
+		idx.dirMod = info.ModTime()
+	}
+
+	return nil
+}
+
+// VerifyIndex compares the index against every record on disk, repairing it
+// and returning the IDs whose entries were stale or missing
+func (d *Driver) VerifyIndex(collection, field string) ([]string, error) {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	idx := d.getIndex(collection, field)
+	if idx == nil {
+		return nil, fmt.Errorf("no index on %s.%s", collection, field)
+	}
+
+	if err := d.loadIndex(collection, idx); err != nil {
+		return nil, err
+	}
+
+	files, err := d.listRecords(collection)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var stale []string
+	seen := make(map[string]bool)
+
+	for _, file := range files {
+		seen[file.ID] = true
+
+		if entry, ok := idx.ids[file.ID]; !ok || entry.stamp != stampOf(file.info) {
+			stale = append(stale, file.ID)
+		}
+	}
+
+	for ID := range idx.ids {
+		if !seen[ID] {
+			stale = append(stale, ID)
+		}
+	}
+
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	return stale, d.buildIndex(collection, field)
+}
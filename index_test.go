@@ -0,0 +1,157 @@
+package jdb_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+type member struct {
+	ID   string `json:"id"`
+	Team string `json:"team"`
+}
+
+func teams(t *testing.T, d *jdb.Driver, team string) []string {
+	t.Helper()
+
+	records, err := d.FindBy("users", "team", team)
+	if err != nil {
+		t.Fatalf("FindBy %s: %s", team, err)
+	}
+
+	IDs := make([]string, 0, len(records))
+	for _, r := range records {
+		var m member
+		if err := json.Unmarshal([]byte(r), &m); err != nil {
+			t.Fatalf("decoding %s: %s", r, err)
+		}
+		IDs = append(IDs, m.ID)
+	}
+
+	return IDs
+}
+
+func TestFindByReturnsRecordsInIDOrder(t *testing.T) {
+	d := jdbtest.New(t)
+
+	for _, ID := range []string{"u-3", "u-1", "u-4", "u-0", "u-2"} {
+		if _, err := d.Write("users", ID, member{ID: ID, Team: "red"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.EnsureIndex("users", "team"); err != nil {
+		t.Fatalf("EnsureIndex: %s", err)
+	}
+
+	want := []string{"u-0", "u-1", "u-2", "u-3", "u-4"}
+
+	for i := 0; i < 10; i++ {
+		if got := teams(t, d, "red"); !reflect.DeepEqual(got, want) {
+			t.Fatalf("FindBy = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFindByFollowsWrites(t *testing.T) {
+	d := jdbtest.New(t)
+
+	if err := d.EnsureIndex("users", "team"); err != nil {
+		t.Fatalf("EnsureIndex: %s", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		ID := fmt.Sprintf("u-%d", i)
+		if _, err := d.Write("users", ID, member{ID: ID, Team: "red"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := d.Write("users", "u-1", member{ID: "u-1", Team: "blue"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Delete("users", "u-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := teams(t, d, "red"), []string{"u-0", "u-3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindBy red = %v, want %v", got, want)
+	}
+
+	if got, want := teams(t, d, "blue"), []string{"u-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindBy blue = %v, want %v", got, want)
+	}
+}
+
+func TestFindBySeesRecordsChangedBehindItsBack(t *testing.T) {
+	dir := t.TempDir()
+	d := jdbtest.Open(t, dir)
+
+	for i := 0; i < 3; i++ {
+		ID := fmt.Sprintf("u-%d", i)
+		if _, err := d.Write("users", ID, member{ID: ID, Team: "red"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.EnsureIndex("users", "team"); err != nil {
+		t.Fatalf("EnsureIndex: %s", err)
+	}
+
+	if got := teams(t, d, "blue"); len(got) != 0 {
+		t.Fatalf("FindBy blue = %v before any change", got)
+	}
+
+	// rewriting a file in place leaves the directory untouched, the record
+	// now holds a value the index doesn't list it under
+	path := filepath.Join(dir, "users", "u-1.json")
+	if err := ioutil.WriteFile(path, []byte(`{"id":"u-1","team":"blue"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := teams(t, d, "blue"), []string{"u-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindBy blue = %v, want %v", got, want)
+	}
+
+	if got, want := teams(t, d, "red"), []string{"u-0", "u-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindBy red = %v, want %v", got, want)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "users", "u-9.json"), []byte(`{"id":"u-9","team":"blue"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "users", "u-0.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := teams(t, d, "blue"), []string{"u-1", "u-9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindBy blue = %v, want %v", got, want)
+	}
+
+	if got, want := teams(t, d, "red"), []string{"u-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindBy red = %v, want %v", got, want)
+	}
+}
+
+func TestFindByWithoutIndex(t *testing.T) {
+	d := jdbtest.New(t)
+
+	if _, err := d.FindBy("users", "team", "red"); err == nil {
+		t.Error("FindBy without an index succeeded")
+	}
+}
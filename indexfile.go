@@ -0,0 +1,383 @@
+package jdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// indexesDir is the reserved directory SaveIndexes saves indexes in
+const indexesDir = "_indexes"
+
+// indexMagic starts saved indexes
+const indexMagic = "JDBIDX1\n"
+
+var errCorruptIndex = errors.New("corrupt saved index")
+
+// SaveIndexes saves the indexes changed since they were built or loaded, so
+// the next Driver opening the database loads them on first use rather than
+// reading every record again. Close saves them too, saving them before is
+// only useful to long running processes that may not get to close. Indexes
+// of collections stored in memory aren't saved, and saved indexes are
+// encrypted like records
+func (d *Driver) SaveIndexes() error {
+	if _, ok := d.engines.base.(readOnlyStorage); ok {
+		return nil
+	}
+
+	d.mutex.Lock()
+	saving := make(map[string][]*index, len(d.indexes))
+	for collection, indexes := range d.indexes {
+		for _, idx := range indexes {
+			saving[collection] = append(saving[collection], idx)
+		}
+	}
+	d.mutex.Unlock()
+
+	for _, collection := range sortedWriteKeys(saving) {
+		if d.Engine(collection) == EngineMemory {
+			continue
+		}
+
+		if err := d.saveIndexes(collection, saving[collection]); err != nil {
+			return fmt.Errorf("saving the indexes of %s: %w", collection, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) saveIndexes(collection string, indexes []*index) error {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, idx := range indexes {
+		if !idx.dirty || idx.saved != "" {
+			continue
+		}
+
+		path := d.indexPath(collection, idx.field)
+
+		b, err := sealRecord(d.keys.get(), encodeIndex(idx))
+		if err != nil {
+			return err
+		}
+
+		if err := d.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		if err := d.fs.WriteFile(path+".tmp", b, 0644); err != nil {
+			return err
+		}
+
+		if err := d.fs.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+
+		idx.dirty = false
+	}
+
+	return nil
+}
+
+func (d *Driver) indexPath(collection, field string) string {
+	return filepath.Join(d.dir, indexesDir, collection, url.QueryEscape(field)+".idx")
+}
+
+// useSavedIndex sets up the index of the field to be loaded from its saved
+// file on first use, reporting whether there is one
+func (d *Driver) useSavedIndex(collection, field string) bool {
+	path := d.indexPath(collection, field)
+	if _, err := d.fs.Stat(path); err != nil {
+		return false
+	}
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if d.getIndex(collection, field) == nil {
+		idx := newIndex(field)
+		idx.saved = path
+		d.setIndex(collection, idx)
+	}
+
+	return true
+}
+
+// loadIndex loads the index from its saved file on first use, building it
+// from the records instead when the collection changed since it was saved.
+// Callers must hold the collection lock or its shared mutex
+func (d *Driver) loadIndex(collection string, idx *index) error {
+	if idx.saved == "" {
+		return nil
+	}
+
+	path := idx.saved
+	idx.saved = ""
+
+	dirMod := time.Time{}
+	if info, err := d.fs.Stat(filepath.Join(d.dir, collection)); err == nil {
+		dirMod = info.ModTime()
+	}
+
+	err := d.readIndexFile(path, func(b []byte) error {
+		return decodeIndex(b, idx, dirMod)
+	})
+	if err == nil {
+		d.log.Debug("loaded index %s.%s: %d records", collection, idx.field, len(idx.ids))
+		return nil
+	}
+
+	d.log.Debug("rebuilding index %s.%s: %s", collection, idx.field, err)
+
+	idx.values = make(map[string]map[string]struct{})
+	idx.ids = make(map[string]indexEntry)
+
+	return d.scanIndex(collection, idx)
+}
+
+// readIndexFile hands the saved index to fn, memory mapped when it can be
+func (d *Driver) readIndexFile(path string, fn func([]byte) error) error {
+	if !d.onDisk {
+		b, err := d.fs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return d.openIndex(b, fn)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if b, err := mmap(f, info.Size()); err == nil {
+		defer munmap(b)
+		return d.openIndex(b, fn)
+	}
+
+	b, err := d.fs.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return d.openIndex(b, fn)
+}
+
+func (d *Driver) openIndex(b []byte, fn func([]byte) error) error {
+	b, err := d.openRecord(b)
+	if err != nil {
+		return err
+	}
+
+	return fn(b)
+}
+
+// encodeIndex lays the index out as its field and directory modification
+// time, then the IDs of every key with their file stamps, then the IDs
+// without the field, and a CRC of it all
+func encodeIndex(idx *index) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(indexMagic)
+	writeIndexString(&buf, idx.field)
+	writeIndexTime(&buf, idx.dirMod)
+
+	keyed := make(map[string][]string, len(idx.values))
+	var unkeyed []string
+
+	for ID, entry := range idx.ids {
+		if _, ok := idx.values[entry.key][ID]; ok {
+			keyed[entry.key] = append(keyed[entry.key], ID)
+		} else {
+			unkeyed = append(unkeyed, ID)
+		}
+	}
+
+	writeIndexUvarint(&buf, uint64(len(keyed)))
+
+	for _, key := range sortedWriteKeys(keyed) {
+		writeIndexString(&buf, key)
+		writeIndexIDs(&buf, idx, keyed[key])
+	}
+
+	writeIndexIDs(&buf, idx, unkeyed)
+
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(sum[:])
+
+	return buf.Bytes()
+}
+
+func writeIndexIDs(buf *bytes.Buffer, idx *index, IDs []string) {
+	sort.Strings(IDs)
+
+	writeIndexUvarint(buf, uint64(len(IDs)))
+
+	for _, ID := range IDs {
+		stamp := idx.ids[ID].stamp
+
+		writeIndexString(buf, ID)
+		writeIndexTime(buf, stamp.modTime)
+		writeIndexUvarint(buf, uint64(stamp.size))
+	}
+}
+
+func writeIndexUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func writeIndexString(buf *bytes.Buffer, s string) {
+	writeIndexUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+// writeIndexTime writes the time in nanoseconds, zero for the zero time
+func writeIndexTime(buf *bytes.Buffer, t time.Time) {
+	var b [binary.MaxVarintLen64]byte
+
+	nano := int64(0)
+	if !t.IsZero() {
+		nano = t.UnixNano()
+	}
+
+	buf.Write(b[:binary.PutVarint(b[:], nano)])
+}
+
+// decodeIndex fills the empty index from its encoding, failing when it was
+// saved for another directory modification time
+func decodeIndex(b []byte, idx *index, dirMod time.Time) error {
+	if len(b) < len(indexMagic)+4 || string(b[:len(indexMagic)]) != indexMagic {
+		return errCorruptIndex
+	}
+
+	body, sum := b[:len(b)-4], b[len(b)-4:]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(sum) {
+		return errCorruptIndex
+	}
+
+	r := &indexReader{b: body[len(indexMagic):]}
+
+	if field := r.string(); field != idx.field {
+		return fmt.Errorf("saved index is on %q", field)
+	}
+
+	if saved := r.time(); !saved.Equal(dirMod) {
+		return fmt.Errorf("collection changed since the index was saved")
+	}
+
+	keys := r.uvarint()
+
+	for i := uint64(0); i < keys && r.err == nil; i++ {
+		key := r.string()
+		IDs := make(map[string]struct{})
+
+		r.ids(func(ID string, stamp fileStamp) {
+			IDs[ID] = struct{}{}
+			idx.ids[ID] = indexEntry{key: key, stamp: stamp}
+		})
+
+		idx.values[key] = IDs
+	}
+
+	r.ids(func(ID string, stamp fileStamp) {
+		idx.ids[ID] = indexEntry{stamp: stamp}
+	})
+
+	if r.err != nil || len(r.b) > 0 {
+		return errCorruptIndex
+	}
+
+	idx.dirMod = dirMod
+	return nil
+}
+
+// indexReader decodes saved indexes, its first error sticking
+type indexReader struct {
+	b   []byte
+	err error
+}
+
+func (r *indexReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errCorruptIndex
+		return 0
+	}
+
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *indexReader) time() time.Time {
+	if r.err != nil {
+		return time.Time{}
+	}
+
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = errCorruptIndex
+		return time.Time{}
+	}
+
+	r.b = r.b[n:]
+
+	if v == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, v)
+}
+
+func (r *indexReader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+
+	if n > uint64(len(r.b)) {
+		r.err = errCorruptIndex
+		return ""
+	}
+
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+func (r *indexReader) ids(fn func(ID string, stamp fileStamp)) {
+	n := r.uvarint()
+
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		ID := r.string()
+		stamp := fileStamp{modTime: r.time()}
+		stamp.size = int64(r.uvarint())
+
+		if r.err == nil {
+			fn(ID, stamp)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package jdb
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// shape accumulates the values seen at a place of the sampled records
+type shape struct {
+	types    map[string]bool
+	count    int
+	props    map[string]*shape
+	objects  int
+	items    *shape
+	integral bool
+}
+
+func newShape() *shape {
+	return &shape{types: make(map[string]bool), integral: true}
+}
+
+func (s *shape) add(v interface{}) {
+	s.count++
+
+	t := typeOf(v)
+	s.types[t] = true
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		s.objects++
+		if s.props == nil {
+			s.props = make(map[string]*shape)
+		}
+
+		for k, e := range v {
+			if s.props[k] == nil {
+				s.props[k] = newShape()
+			}
+
+			s.props[k].add(e)
+		}
+	case []interface{}:
+		if s.items == nil {
+			s.items = newShape()
+		}
+
+		for _, e := range v {
+			s.items.add(e)
+		}
+	default:
+		if t == "number" && !hasType(v, "integer") {
+			s.integral = false
+		}
+	}
+}
+
+// schema returns the narrowest schema accepting every value seen
+func (s *shape) schema() *Schema {
+	types := make([]string, 0, len(s.types))
+	for t := range s.types {
+		types = append(types, t)
+	}
+
+	if len(types) != 1 {
+		return &Schema{}
+	}
+
+	schema := &Schema{Type: types[0]}
+
+	switch schema.Type {
+	case "number":
+		if s.integral {
+			schema.Type = "integer"
+		}
+	case "object":
+		schema.Properties = make(map[string]*Schema, len(s.props))
+
+		for k, p := range s.props {
+			schema.Properties[k] = p.schema()
+
+			if p.count == s.objects {
+				schema.Required = append(schema.Required, k)
+			}
+		}
+
+		sort.Strings(schema.Required)
+	case "array":
+		if s.items != nil {
+			schema.Items = s.items.schema()
+		}
+	}
+
+	return schema
+}
+
+// InferSchema returns a schema every one of the first sample records of the
+// collection matches, or every record when sample isn't positive. Fields are
+// required when every sampled record has them, and fields holding values of
+// different types accept any value
+func (d *Driver) InferSchema(collection string, sample int) (*Schema, error) {
+	root := newShape()
+	seen := 0
+
+	err := d.ForEach(collection, func(ID string, raw []byte) error {
+		v, err := decodeJSON(raw)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", collection, ID, err)
+		}
+
+		root.add(v)
+
+		if seen++; sample > 0 && seen >= sample {
+			return ErrStop
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if seen == 0 {
+		return nil, fmt.Errorf("collection %s has no records to infer a schema from", collection)
+	}
+
+	return root.schema(), nil
+}
+
+// GenerateStruct returns the gofmt'ed Go declaration of a struct type named
+// name matching the object schema, e.g. one made by InferSchema. Nested
+// objects become types named after their field, optional fields are
+// omitempty and a string id field is tagged `jdb:"id"` for Insert
+func GenerateStruct(name string, schema *Schema) ([]byte, error) {
+	if schema == nil || schema.Type != "object" {
+		return nil, fmt.Errorf("a struct needs an object schema")
+	}
+
+	g := &structGen{names: make(map[string]bool)}
+	g.declare(goName(name), schema)
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting the generated struct: %w", err)
+	}
+
+	return src, nil
+}
+
+type structGen struct {
+	buf   bytes.Buffer
+	names map[string]bool
+	queue []pendingStruct
+}
+
+type pendingStruct struct {
+	name   string
+	schema *Schema
+}
+
+func (g *structGen) declare(name string, schema *Schema) {
+	g.names[name] = true
+	g.queue = append(g.queue, pendingStruct{name, schema})
+
+	for len(g.queue) > 0 {
+		next := g.queue[0]
+		g.queue = g.queue[1:]
+
+		if g.buf.Len() > 0 {
+			g.buf.WriteString("\n")
+		}
+
+		g.writeStruct(next.name, next.schema)
+	}
+}
+
+func (g *structGen) writeStruct(name string, schema *Schema) {
+	required := make(map[string]bool, len(schema.Required))
+	for _, k := range schema.Required {
+		required[k] = true
+	}
+
+	fmt.Fprintf(&g.buf, "type %s struct {\n", name)
+
+	fields := make(map[string]bool)
+
+	for _, k := range sortedSchemaKeys(schema.Properties) {
+		prop := schema.Properties[k]
+
+		field := g.unique(goName(k), fields)
+		fields[field] = true
+
+		tag := k
+		if !required[k] {
+			tag += ",omitempty"
+		}
+
+		tags := fmt.Sprintf("json:%q", tag)
+		if strings.EqualFold(k, "id") && prop.Type == "string" {
+			tags += ` jdb:"id"`
+		}
+
+		fmt.Fprintf(&g.buf, "\t%s %s `%s`\n", field, g.goType(name+field, prop), tags)
+	}
+
+	g.buf.WriteString("}\n")
+}
+
+// goType returns the Go type of the schema, queueing the structs of nested
+// objects under a name made from the field
+func (g *structGen) goType(name string, schema *Schema) string {
+	switch schema.Type {
+	case "string":
+		return "string"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		if schema.Items == nil {
+			return "[]interface{}"
+		}
+
+		return "[]" + g.goType(name, schema.Items)
+	case "object":
+		if len(schema.Properties) == 0 {
+			return "map[string]interface{}"
+		}
+
+		name = g.unique(name, g.names)
+		g.names[name] = true
+		g.queue = append(g.queue, pendingStruct{name, schema})
+
+		return name
+	default:
+		return "interface{}"
+	}
+}
+
+// unique suffixes the name with a number when it's taken
+func (g *structGen) unique(name string, taken map[string]bool) string {
+	if !taken[name] {
+		return name
+	}
+
+	for i := 2; ; i++ {
+		if candidate := fmt.Sprintf("%s%d", name, i); !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// goName turns a JSON key into an exported Go identifier, e.g. created_at
+// into CreatedAt and id into ID
+func goName(key string) string {
+	var b strings.Builder
+
+	upper := true
+	for _, r := range key {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+
+			b.WriteRune(r)
+		default:
+			upper = true
+		}
+	}
+
+	name := b.String()
+
+	switch {
+	case name == "":
+		return "Field"
+	case unicode.IsDigit(rune(name[0])):
+		return "F" + name
+	case name == "Id":
+		return "ID"
+	case strings.HasSuffix(name, "Id"):
+		return strings.TrimSuffix(name, "Id") + "ID"
+	}
+
+	return name
+}
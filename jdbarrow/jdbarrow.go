@@ -0,0 +1,115 @@
+// Package jdbarrow exposes the records of jdb collections as Apache Arrow
+// records, and writes them as Arrow IPC streams which DuckDB, pandas or
+// polars read as they are. It's a module of its own so jdb doesn't depend on
+// Arrow:
+//
+//	err := jdbarrow.WriteStream(w, d, "users", nil, 1024)
+//
+// Records are laid out like by jdb.Driver.ScanBatches, with an id column
+// first
+package jdbarrow
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/ipc"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/arham09/jdb"
+)
+
+// TypeKey is the metadata key of the fields holding JSON values, set to
+// "json". Those fields are strings of JSON text
+const TypeKey = "jdb.type"
+
+// Schema returns the Arrow schema of the object schema: a non nullable id
+// field then a nullable field per property, see jdb.Columns
+func Schema(schema *jdb.Schema) *arrow.Schema {
+	fields := []arrow.Field{{Name: "id", Type: arrow.BinaryTypes.String}}
+
+	for _, c := range jdb.Columns(schema) {
+		f := arrow.Field{Name: c.Name, Nullable: true}
+
+		switch c.Type {
+		case "string":
+			f.Type = arrow.BinaryTypes.String
+		case "integer":
+			f.Type = arrow.PrimitiveTypes.Int64
+		case "number":
+			f.Type = arrow.PrimitiveTypes.Float64
+		case "boolean":
+			f.Type = arrow.FixedWidthTypes.Boolean
+		default:
+			f.Type = arrow.BinaryTypes.String
+			f.Metadata = arrow.NewMetadata([]string{TypeKey}, []string{"json"})
+		}
+
+		fields = append(fields, f)
+	}
+
+	return arrow.NewSchema(fields, nil)
+}
+
+// Records calls fn with the records of the collection as Arrow records of up
+// to size rows, laid out by the object schema resolved like by
+// jdb.Driver.TableSchema. It returns that schema, so empty collections still
+// have one. Records are released once fn returns, which must retain the
+// ones it keeps and can stop the scan with jdb.ErrStop
+func Records(d *jdb.Driver, collection string, schema *jdb.Schema, size int, fn func(arrow.Record) error) (*arrow.Schema, error) {
+	schema, err := d.TableSchema(collection, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	s := Schema(schema)
+
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), s)
+	defer b.Release()
+
+	err = d.ScanBatches(collection, schema, size, func(batch *jdb.RecordBatch) error {
+		b.Field(0).(*array.StringBuilder).AppendValues(batch.IDs, nil)
+
+		for i, c := range batch.Columns {
+			switch f := b.Field(i + 1).(type) {
+			case *array.StringBuilder:
+				f.AppendValues(c.Strings, c.Valid)
+			case *array.Int64Builder:
+				f.AppendValues(c.Ints, c.Valid)
+			case *array.Float64Builder:
+				f.AppendValues(c.Floats, c.Valid)
+			case *array.BooleanBuilder:
+				f.AppendValues(c.Bools, c.Valid)
+			default:
+				return fmt.Errorf("no builder for column %s of type %s", c.Name, c.Type)
+			}
+		}
+
+		rec := b.NewRecord()
+		defer rec.Release()
+
+		return fn(rec)
+	})
+
+	return s, err
+}
+
+// WriteStream writes the records of the collection to w as an Arrow IPC
+// stream of records of up to size rows, see Records
+func WriteStream(w io.Writer, d *jdb.Driver, collection string, schema *jdb.Schema, size int) error {
+	schema, err := d.TableSchema(collection, schema)
+	if err != nil {
+		return err
+	}
+
+	writer := ipc.NewWriter(w, ipc.WithSchema(Schema(schema)))
+
+	_, err = Records(d, collection, schema, size, writer.Write)
+	if err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
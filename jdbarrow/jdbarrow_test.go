@@ -0,0 +1,203 @@
+package jdbarrow_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/ipc"
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbarrow"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+var users = &jdb.Schema{
+	Type: "object",
+	Properties: map[string]*jdb.Schema{
+		"name":   {Type: "string"},
+		"age":    {Type: "integer"},
+		"score":  {Type: "number"},
+		"admin":  {Type: "boolean"},
+		"labels": {Type: "array"},
+	},
+}
+
+func seed(t *testing.T) *jdb.Driver {
+	t.Helper()
+
+	d := jdbtest.New(t)
+
+	records := map[string]string{
+		"u1": `{"name":"ada","age":36,"score":9.5,"admin":true,"labels":["a","b"]}`,
+		"u2": `{"name":"grace","age":85}`,
+		"u3": `{"name":"alan","age":41,"score":7,"admin":false,"labels":[]}`,
+	}
+
+	for ID, doc := range records {
+		if _, err := d.Write("users", ID, json.RawMessage(doc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return d
+}
+
+func TestWriteStreamIsArrowIPC(t *testing.T) {
+	d := seed(t)
+
+	var buf bytes.Buffer
+	if err := jdbarrow.WriteStream(&buf, d, "users", users, 2); err != nil {
+		t.Fatalf("WriteStream: %s", err)
+	}
+
+	r, err := ipc.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("reading the stream: %s", err)
+	}
+	defer r.Release()
+
+	want := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.BinaryTypes.String},
+		{Name: "admin", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+		{Name: "age", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		{Name: "labels", Type: arrow.BinaryTypes.String, Nullable: true, Metadata: arrow.NewMetadata([]string{jdbarrow.TypeKey}, []string{"json"})},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "score", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	}, nil)
+	if !r.Schema().Equal(want) || !r.Schema().Field(3).Metadata.Equal(want.Field(3).Metadata) {
+		t.Errorf("schema:\n%s\nwant:\n%s", r.Schema(), want)
+	}
+
+	var (
+		rows    []int64
+		IDs     []string
+		ages    []int64
+		labels  []string
+		nulls   int
+		batches int
+	)
+
+	for r.Next() {
+		rec := r.Record()
+		batches++
+		rows = append(rows, rec.NumRows())
+
+		id := rec.Column(0).(*array.String)
+		age := rec.Column(2).(*array.Int64)
+		label := rec.Column(3).(*array.String)
+		score := rec.Column(5).(*array.Float64)
+
+		for i := 0; i < int(rec.NumRows()); i++ {
+			IDs = append(IDs, id.Value(i))
+			ages = append(ages, age.Value(i))
+
+			if label.IsNull(i) {
+				labels = append(labels, "null")
+			} else {
+				labels = append(labels, label.Value(i))
+			}
+
+			if score.IsNull(i) {
+				nulls++
+			}
+		}
+	}
+
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if batches != 2 || rows[0] != 2 || rows[1] != 1 {
+		t.Errorf("%d batches of %v rows, want 2 of [2 1]", batches, rows)
+	}
+
+	if got := jsonOf(IDs); got != `["u1","u2","u3"]` {
+		t.Errorf("ids = %s", got)
+	}
+
+	if got := jsonOf(ages); got != `[36,85,41]` {
+		t.Errorf("ages = %s", got)
+	}
+
+	if got := jsonOf(labels); got != `["[\"a\",\"b\"]","null","[]"]` {
+		t.Errorf("labels = %s", got)
+	}
+
+	if nulls != 1 {
+		t.Errorf("%d null scores, want 1", nulls)
+	}
+}
+
+func TestRecordsStop(t *testing.T) {
+	d := seed(t)
+
+	calls := 0
+
+	schema, err := jdbarrow.Records(d, "users", nil, 1, func(rec arrow.Record) error {
+		calls++
+		return jdb.ErrStop
+	})
+	if err != nil {
+		t.Fatalf("Records: %s", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("fn called %d times after stopping", calls)
+	}
+
+	if len(schema.Fields()) != 6 {
+		t.Errorf("inferred schema has %d fields, want 6: %s", len(schema.Fields()), schema)
+	}
+}
+
+func TestWriteStreamFailsOnMismatchedValues(t *testing.T) {
+	d := seed(t)
+
+	if _, err := d.Write("users", "u4", json.RawMessage(`{"age":"old"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	err := jdbarrow.WriteStream(&bytes.Buffer{}, d, "users", users, 10)
+	if err == nil || errors.Is(err, jdb.ErrStop) {
+		t.Errorf("WriteStream of a string in an integer column = %v", err)
+	}
+}
+
+func jsonOf(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestWriteStreamOfAnEmptyCollection(t *testing.T) {
+	d := jdbtest.New(t)
+
+	if _, err := d.Write("users", "u1", json.RawMessage(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Delete("users", "u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := jdbarrow.WriteStream(&buf, d, "users", users, 10); err != nil {
+		t.Fatalf("WriteStream: %s", err)
+	}
+
+	r, err := ipc.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("reading the stream: %s", err)
+	}
+	defer r.Release()
+
+	if !r.Schema().Equal(jdbarrow.Schema(users)) {
+		t.Errorf("schema = %s", r.Schema())
+	}
+
+	if r.Next() {
+		t.Errorf("empty collection streamed %d rows", r.Record().NumRows())
+	}
+}
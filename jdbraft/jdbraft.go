@@ -0,0 +1,113 @@
+// Package jdbraft replicates the writes of a jdb.Driver with hashicorp/raft.
+// It's a module of its own so jdb doesn't depend on raft. A node is set up
+// with a Replicator given to the Driver and the Driver given to the FSM:
+//
+//	r := &jdbraft.Replicator{Timeout: 5 * time.Second}
+//	d, err := jdb.New(dir, jdb.WithReplicator(r))
+//	r.Raft, err = raft.NewRaft(config, jdbraft.NewFSM(d), logs, stable, snapshots, transport)
+//
+// Writes are then committed by the cluster before every node applies them,
+// see jdb.Replicator for the ones that are
+package jdbraft
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/hashicorp/raft"
+)
+
+// Replicator is a jdb.Replicator applying writes through Raft, which must be
+// set before the Driver is written to. Writes made on a node that isn't the
+// leader fail with raft.ErrNotLeader
+type Replicator struct {
+	Raft *raft.Raft
+
+	// Timeout bounds how long a write waits to be committed, zero waits
+	// however long it takes
+	Timeout time.Duration
+}
+
+func (r *Replicator) Replicate(op jdb.ReplicatedOp) error {
+	if r.Raft == nil {
+		return fmt.Errorf("raft isn't started")
+	}
+
+	b, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+
+	f := r.Raft.Apply(b, r.Timeout)
+	if err := f.Error(); err != nil {
+		return err
+	}
+
+	if err, ok := f.Response().(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// FSM applies the committed writes to the Driver of a node
+type FSM struct {
+	d *jdb.Driver
+}
+
+// NewFSM returns the raft.FSM of the Driver
+func NewFSM(d *jdb.Driver) *FSM {
+	return &FSM{d: d}
+}
+
+// Apply applies a committed write, returning the error it failed with as
+// the response of the raft.ApplyFuture
+func (f *FSM) Apply(l *raft.Log) interface{} {
+	var op jdb.ReplicatedOp
+	if err := json.Unmarshal(l.Data, &op); err != nil {
+		return fmt.Errorf("decoding log entry %d: %w", l.Index, err)
+	}
+
+	return f.d.ApplyReplicated(op)
+}
+
+// Snapshot backs the Driver up in memory, as raft applies writes while
+// snapshots are persisted
+func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	var buf bytes.Buffer
+	if _, err := f.d.Backup(&buf); err != nil {
+		return nil, err
+	}
+
+	return &snapshot{b: buf.Bytes()}, nil
+}
+
+// Restore replaces the records of the Driver with the snapshot
+func (f *FSM) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	_, err := f.d.RestoreReplicated(rc)
+	return err
+}
+
+type snapshot struct {
+	b []byte
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := sink.Write(s.b); err != nil {
+		sink.Cancel()
+		return err
+	}
+
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
+
+var _ raft.FSM = (*FSM)(nil)
+var _ jdb.Replicator = (*Replicator)(nil)
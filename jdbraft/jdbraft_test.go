@@ -0,0 +1,155 @@
+package jdbraft_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbraft"
+	"github.com/arham09/jdb/jdbtest"
+	"github.com/hashicorp/raft"
+)
+
+type node struct {
+	d    *jdb.Driver
+	r    *jdbraft.Replicator
+	fsm  *jdbraft.FSM
+	raft *raft.Raft
+}
+
+// cluster starts n nodes on in memory transports, returning them once one
+// is the leader, first
+func cluster(t *testing.T, n int) []*node {
+	t.Helper()
+
+	var servers []raft.Server
+	transports := make([]*raft.InmemTransport, n)
+
+	for i := range transports {
+		addr, transport := raft.NewInmemTransport("")
+		transports[i] = transport
+		servers = append(servers, raft.Server{ID: raft.ServerID(fmt.Sprint(i)), Address: addr})
+	}
+
+	for _, a := range transports {
+		for _, b := range transports {
+			a.Connect(b.LocalAddr(), b)
+		}
+	}
+
+	nodes := make([]*node, n)
+
+	for i := range nodes {
+		config := raft.DefaultConfig()
+		config.LocalID = servers[i].ID
+		config.HeartbeatTimeout = 50 * time.Millisecond
+		config.ElectionTimeout = 50 * time.Millisecond
+		config.LeaderLeaseTimeout = 50 * time.Millisecond
+		config.CommitTimeout = 5 * time.Millisecond
+		config.LogOutput = ioutil.Discard
+
+		r := &jdbraft.Replicator{Timeout: 5 * time.Second}
+		d := jdbtest.New(t, jdb.WithReplicator(r))
+		fsm := jdbraft.NewFSM(d)
+
+		store := raft.NewInmemStore()
+
+		var err error
+		if r.Raft, err = raft.NewRaft(config, fsm, store, store, raft.NewInmemSnapshotStore(), transports[i]); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { r.Raft.Shutdown().Error() })
+
+		nodes[i] = &node{d: d, r: r, fsm: fsm, raft: r.Raft}
+	}
+
+	if err := nodes[0].raft.BootstrapCluster(raft.Configuration{Servers: servers}).Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for i, nd := range nodes {
+			if nd.raft.State() == raft.Leader {
+				nodes[0], nodes[i] = nodes[i], nodes[0]
+				return nodes
+			}
+		}
+	}
+
+	t.Fatal("no leader elected")
+	return nil
+}
+
+func TestWritesReachEveryNode(t *testing.T) {
+	nodes := cluster(t, 3)
+	leader := nodes[0]
+
+	jdbtest.Seed(t, leader.d, "users", 3, jdbtest.Sequence("u", map[string]int{"n": 1}))
+
+	if err := leader.d.Delete("users", "u-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := jdbtest.Snapshot(t, leader.d, "users")
+
+	if err := leader.raft.Barrier(5 * time.Second).Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, nd := range nodes[1:] {
+		deadline := time.Now().Add(5 * time.Second)
+
+		for !bytes.Equal(jdbtest.Snapshot(t, nd.d, "users"), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("follower %d holds\n%s\nwant\n%s", i, jdbtest.Snapshot(t, nd.d, "users"), want)
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if _, err := nodes[1].d.Write("users", "x", 1); !errors.Is(err, raft.ErrNotLeader) {
+		t.Errorf("writing on a follower = %v, want raft.ErrNotLeader", err)
+	}
+}
+
+// sink is a raft.SnapshotSink in memory
+type sink struct {
+	bytes.Buffer
+	cancelled bool
+}
+
+func (s *sink) ID() string    { return "test" }
+func (s *sink) Close() error  { return nil }
+func (s *sink) Cancel() error { s.cancelled = true; return nil }
+
+func TestSnapshotRestore(t *testing.T) {
+	leader := cluster(t, 1)[0]
+	jdbtest.Seed(t, leader.d, "users", 2, jdbtest.Sequence("u", map[string]int{"n": 1}))
+
+	snapshot, err := leader.fsm.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Release()
+
+	var s sink
+	if err := snapshot.Persist(&s); err != nil || s.cancelled {
+		t.Fatalf("persisting the snapshot = %v, cancelled %v", err, s.cancelled)
+	}
+
+	d := jdbtest.New(t, jdb.WithReplicator(&jdbraft.Replicator{}))
+
+	if err := jdbraft.NewFSM(d).Restore(ioutil.NopCloser(&s)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := jdbtest.Snapshot(t, d, "users"), jdbtest.Snapshot(t, leader.d, "users"); !bytes.Equal(got, want) {
+		t.Errorf("restored node holds\n%s\nwant\n%s", got, want)
+	}
+}
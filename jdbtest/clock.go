@@ -0,0 +1,106 @@
+package jdbtest
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arham09/jdb"
+)
+
+// Clock is a jdb.Clock that only moves when told to. It's a jdb.Timer too,
+// so the waits of the Driver only end when the clock is advanced past them
+// and tests don't have to sleep
+type Clock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewClock returns a Clock stopped at now
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// After returns a channel receiving the time once the clock is advanced by
+// at least d
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, waiter{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Waiters returns how many After channels are still pending, letting tests
+// wait for the Driver to block before advancing the clock
+func (c *Clock) Waiters() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.waiters)
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.set(c.now.Add(d))
+}
+
+// Set moves the clock to t
+func (c *Clock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.set(t)
+}
+
+// set moves the clock and fires the waiters it went past, callers must hold
+// the mutex
+func (c *Clock) set(t time.Time) {
+	c.now = t
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(t) {
+			pending = append(pending, w)
+			continue
+		}
+
+		w.ch <- t
+	}
+
+	c.waiters = pending
+}
+
+var _ jdb.Timer = (*Clock)(nil)
+
+// SequentialIDs returns a jdb.IDGenerator making prefix-1, prefix-2...
+func SequentialIDs(prefix string) jdb.IDGenerator {
+	var n uint64
+
+	return func() string {
+		return fmt.Sprintf("%s-%d", prefix, atomic.AddUint64(&n, 1))
+	}
+}
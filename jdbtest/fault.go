@@ -0,0 +1,176 @@
+package jdbtest
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/arham09/jdb"
+)
+
+// ErrInjected is the error returned by the faults of FaultStorage by default
+var ErrInjected = errors.New("jdbtest: injected fault")
+
+type (
+	// Fault decides whether an operation on the path fails, returning nil lets
+	// it through. Ops are the names of the jdb.Storage methods
+	Fault func(op, path string) error
+
+	// FaultStorage wraps a jdb.Storage failing the operations its faults
+	// pick, so IO errors can be simulated deterministically. It's meant for
+	// tests of code built on jdb checking how it copes with a failing disk,
+	// pass it to jdb.WithStorage. It's a supported API like the rest of the
+	// package: ops keep the names of the jdb.Storage methods, and Rename
+	// is checked against its new path
+	FaultStorage struct {
+		jdb.Storage
+
+		mutex  sync.Mutex
+		faults []Fault
+		calls  map[string]int
+	}
+)
+
+// NewFaultStorage wraps the storage, jdb.OSStorage when it's nil
+func NewFaultStorage(storage jdb.Storage) *FaultStorage {
+	if storage == nil {
+		storage = jdb.OSStorage
+	}
+
+	return &FaultStorage{Storage: storage, calls: make(map[string]int)}
+}
+
+// Inject adds a fault checked before every operation
+func (s *FaultStorage) Inject(fault Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = append(s.faults, fault)
+}
+
+// Reset removes every fault and forgets the counted calls
+func (s *FaultStorage) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = nil
+	s.calls = make(map[string]int)
+}
+
+// Calls returns how many times the op was called
+func (s *FaultStorage) Calls(op string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.calls[op]
+}
+
+func (s *FaultStorage) check(op, path string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls[op]++
+
+	for _, fault := range s.faults {
+		if err := fault(op, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FailOn fails the op, or every op when it's empty, on paths containing the
+// substring with ErrInjected
+func FailOn(op, substring string) Fault {
+	return func(o, path string) error {
+		if (op == "" || op == o) && strings.Contains(path, substring) {
+			return &os.PathError{Op: o, Path: path, Err: ErrInjected}
+		}
+		return nil
+	}
+}
+
+// FailAfter lets n calls of the op succeed and fails every later one
+func FailAfter(op string, n int) Fault {
+	var mutex sync.Mutex
+	calls := 0
+
+	return func(o, path string) error {
+		if op != o {
+			return nil
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if calls++; calls > n {
+			return &os.PathError{Op: o, Path: path, Err: ErrInjected}
+		}
+		return nil
+	}
+}
+
+func (s *FaultStorage) ReadFile(name string) ([]byte, error) {
+	if err := s.check("ReadFile", name); err != nil {
+		return nil, err
+	}
+	return s.Storage.ReadFile(name)
+}
+
+func (s *FaultStorage) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := s.check("WriteFile", name); err != nil {
+		return err
+	}
+	return s.Storage.WriteFile(name, data, perm)
+}
+
+func (s *FaultStorage) AppendFile(name string, data []byte, perm os.FileMode) error {
+	if err := s.check("AppendFile", name); err != nil {
+		return err
+	}
+	return s.Storage.AppendFile(name, data, perm)
+}
+
+func (s *FaultStorage) Rename(oldpath, newpath string) error {
+	if err := s.check("Rename", newpath); err != nil {
+		return err
+	}
+	return s.Storage.Rename(oldpath, newpath)
+}
+
+func (s *FaultStorage) Remove(name string) error {
+	if err := s.check("Remove", name); err != nil {
+		return err
+	}
+	return s.Storage.Remove(name)
+}
+
+func (s *FaultStorage) RemoveAll(path string) error {
+	if err := s.check("RemoveAll", path); err != nil {
+		return err
+	}
+	return s.Storage.RemoveAll(path)
+}
+
+func (s *FaultStorage) MkdirAll(path string, perm os.FileMode) error {
+	if err := s.check("MkdirAll", path); err != nil {
+		return err
+	}
+	return s.Storage.MkdirAll(path, perm)
+}
+
+func (s *FaultStorage) Stat(name string) (os.FileInfo, error) {
+	if err := s.check("Stat", name); err != nil {
+		return nil, err
+	}
+	return s.Storage.Stat(name)
+}
+
+func (s *FaultStorage) ReadDir(dirname string) ([]os.FileInfo, error) {
+	if err := s.check("ReadDir", dirname); err != nil {
+		return nil, err
+	}
+	return s.Storage.ReadDir(dirname)
+}
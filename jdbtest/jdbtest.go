@@ -0,0 +1,176 @@
+// Package jdbtest provides helpers for tests of code built on top of jdb:
+// Drivers in temporary directories, seeding and golden files, a Clock that
+// only moves when told to and a FaultStorage failing the operations picked
+package jdbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arham09/jdb"
+)
+
+var update = flag.Bool("jdbtest.update", false, "rewrite golden files with the current collections")
+
+// logger forwards the Driver logs to the test log
+type logger struct {
+	t testing.TB
+}
+
+// Logger returns a jdb.Logger writing to the test log, so output only shows
+// up for failing or verbose tests
+func Logger(t testing.TB) jdb.Logger {
+	return logger{t: t}
+}
+
+func (l logger) log(level, format string, v ...interface{}) {
+	l.t.Helper()
+	l.t.Logf(level+" "+format, v...)
+}
+
+func (l logger) Fatal(format string, v ...interface{}) { l.log("FATAL", format, v...) }
+func (l logger) Error(format string, v ...interface{}) { l.log("ERROR", format, v...) }
+func (l logger) Warn(format string, v ...interface{})  { l.log("WARN", format, v...) }
+func (l logger) Info(format string, v ...interface{})  { l.log("INFO", format, v...) }
+func (l logger) Debug(format string, v ...interface{}) { l.log("DEBUG", format, v...) }
+func (l logger) Trace(format string, v ...interface{}) { l.log("TRACE", format, v...) }
+
+// New creates a Driver in a temporary directory removed when the test ends,
+// configured by the options applied in order. It logs to the test log unless
+// the options set a Logger
+func New(t testing.TB, options ...jdb.Option) *jdb.Driver {
+	t.Helper()
+
+	return Open(t, filepath.Join(t.TempDir(), "db"), options...)
+}
+
+// Open opens a Driver on dir like New, letting tests reopen a database to
+// check what it persisted. The Driver is closed when the test ends
+func Open(t testing.TB, dir string, options ...jdb.Option) *jdb.Driver {
+	t.Helper()
+
+	options = append([]jdb.Option{jdb.WithLogger(Logger(t))}, options...)
+
+	d, err := jdb.New(dir, options...)
+	if err != nil {
+		t.Fatalf("jdbtest: creating driver: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := d.Close(); err != nil {
+			t.Errorf("jdbtest: closing driver: %s", err)
+		}
+	})
+
+	return d
+}
+
+// Factory builds the i-th record of a collection returning its ID and value
+type Factory func(i int) (string, interface{})
+
+// Seed writes n records built by the factory into the collection, returning
+// their IDs in order
+func Seed(t testing.TB, d *jdb.Driver, collection string, n int, factory Factory) []string {
+	t.Helper()
+
+	IDs := make([]string, 0, n)
+
+	for i := 0; i < n; i++ {
+		ID, v := factory(i)
+
+		if _, err := d.Write(collection, ID, v); err != nil {
+			t.Fatalf("jdbtest: seeding %s/%s: %s", collection, ID, err)
+		}
+
+		IDs = append(IDs, ID)
+	}
+
+	return IDs
+}
+
+// Sequence is a Factory writing copies of v under the IDs prefix-0, prefix-1..
+func Sequence(prefix string, v interface{}) Factory {
+	return func(i int) (string, interface{}) {
+		return fmt.Sprintf("%s-%d", prefix, i), v
+	}
+}
+
+// Snapshot renders the collection as a JSON object of records keyed by ID
+func Snapshot(t testing.TB, d *jdb.Driver, collection string) []byte {
+	t.Helper()
+
+	records := make(map[string]json.RawMessage)
+
+	IDs, err := d.IDs(collection)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("jdbtest: listing %s: %s", collection, err)
+	}
+
+	for _, ID := range IDs {
+		data, err := d.Read(collection, ID)
+		if err != nil {
+			t.Fatalf("jdbtest: reading %s/%s: %s", collection, ID, err)
+		}
+
+		records[ID] = json.RawMessage(data)
+	}
+
+	b, err := json.MarshalIndent(records, "", "\t")
+	if err != nil {
+		t.Fatalf("jdbtest: encoding %s: %s", collection, err)
+	}
+
+	return append(b, '\n')
+}
+
+// AssertGolden compares the collection against the golden file, run the tests
+// with -jdbtest.update to write the golden file from the current collection
+func AssertGolden(t testing.TB, d *jdb.Driver, collection, golden string) {
+	t.Helper()
+
+	got := Snapshot(t, d, collection)
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatalf("jdbtest: updating %s: %s", golden, err)
+		}
+
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatalf("jdbtest: updating %s: %s", golden, err)
+		}
+
+		return
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("jdbtest: reading golden file: %s", err)
+	}
+
+	if !bytes.Equal(normalize(t, want), normalize(t, got)) {
+		t.Errorf("jdbtest: collection %s doesn't match %s\ngot:\n%s\nwant:\n%s", collection, golden, got, want)
+	}
+}
+
+// normalize re-indents JSON so golden files may be formatted by hand
+func normalize(t testing.TB, b []byte) []byte {
+	t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("jdbtest: golden file is not valid JSON: %s", err)
+	}
+
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		t.Fatalf("jdbtest: %s", err)
+	}
+
+	return b
+}
@@ -0,0 +1,119 @@
+package jdb
+
+import "fmt"
+
+type (
+	// FieldPair names the dotted fields of the left and right records Join
+	// matches on
+	FieldPair struct {
+		Left, Right string
+	}
+
+	// Projector makes a row of Join out of a left record and a right one
+	// matching it, a nil row being left out
+	Projector func(left, right []byte) (interface{}, error)
+)
+
+// Join returns the rows projected from every pair of a left record and a
+// right one whose fields are equal, in the order of the left collection.
+// Records missing the field match nothing. The index on the right field is
+// used when there's one, the right collection is loaded into a hash table
+// otherwise. Both collections must belong to the same Driver
+func Join(left, right *Collection, on FieldPair, project Projector) ([]interface{}, error) {
+	if left.d != right.d {
+		return nil, fmt.Errorf("joining %s and %s of different Drivers", left.name, right.name)
+	}
+
+	if on.Left == "" || on.Right == "" {
+		return nil, fmt.Errorf("missing field, nothing to join on")
+	}
+
+	d := left.d
+
+	matches := d.hashMatches(right.name, on.Right)
+	if d.getIndex(right.name, on.Right) != nil {
+		matches = d.indexMatches(right.name, on.Right)
+	}
+
+	var rows []interface{}
+
+	err := left.ForEach(func(ID string, raw []byte) error {
+		key, ok := fieldKey(raw, on.Left)
+		if !ok {
+			return nil
+		}
+
+		candidates, err := matches(key)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range candidates {
+			row, err := project(raw, r)
+			if err != nil {
+				return fmt.Errorf("projecting %s/%s: %w", left.name, ID, err)
+			}
+
+			if row != nil {
+				rows = append(rows, row)
+			}
+		}
+
+		return nil
+	})
+
+	return rows, err
+}
+
+// indexMatches returns a lookup of the right records through the index of
+// the field, caching the records of every key
+func (d *Driver) indexMatches(collection, field string) func(key string) ([][]byte, error) {
+	cache := make(map[string][][]byte)
+
+	return func(key string) ([][]byte, error) {
+		if records, ok := cache[key]; ok {
+			return records, nil
+		}
+
+		found, err := d.findByKey(collection, field, key)
+		if err != nil {
+			return nil, err
+		}
+
+		records := make([][]byte, len(found))
+		for i, r := range found {
+			records[i] = []byte(r)
+		}
+
+		cache[key] = records
+		return records, nil
+	}
+}
+
+// hashMatches returns a lookup of the right records through a hash table of
+// the collection built on the first call
+func (d *Driver) hashMatches(collection, field string) func(key string) ([][]byte, error) {
+	var table map[string][][]byte
+
+	return func(key string) ([][]byte, error) {
+		if table != nil {
+			return table[key], nil
+		}
+
+		table = make(map[string][][]byte)
+
+		err := d.ForEach(collection, func(ID string, raw []byte) error {
+			if k, ok := fieldKey(raw, field); ok {
+				table[k] = append(table[k], append([]byte(nil), raw...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			table = nil
+			return nil, err
+		}
+
+		return table[key], nil
+	}
+}
@@ -0,0 +1,121 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// kvCollection is the reserved collection backing the KV facade
+const kvCollection = "_kv"
+
+// KV is a key-value view of the database for flags and counters that don't
+// deserve a collection of their own
+type KV struct {
+	d *Driver
+}
+
+// KV returns the key-value facade of the Driver
+func (d *Driver) KV() *KV {
+	return &KV{d: d}
+}
+
+// Set stores value under the key
+func (kv *KV) Set(key string, value interface{}) error {
+	if key == "" {
+		return fmt.Errorf("missing key")
+	}
+
+	if err := ValidateID(key); err != nil {
+		return err
+	}
+
+	_, err := kv.d.doWrite(kvCollection, key, value)
+	return err
+}
+
+// Get decodes the value of the key into v
+func (kv *KV) Get(key string, v interface{}) error {
+	if key == "" {
+		return fmt.Errorf("missing key")
+	}
+
+	return kv.d.ReadInto(kvCollection, key, v)
+}
+
+// Del removes the key, removing a missing key is not an error
+func (kv *KV) Del(key string) error {
+	if key == "" {
+		return fmt.Errorf("missing key")
+	}
+
+	done, err := kv.d.admitStore(kvCollection, key)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if kv.d.replicator != nil {
+		if _, err := kv.d.locate(kvCollection, key); os.IsNotExist(err) {
+			return nil
+		}
+
+		return kv.d.replicate(OpDelete, kvCollection, key, nil)
+	}
+
+	mutex := kv.d.getMutex(kvCollection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := kv.d.locate(kvCollection, key); os.IsNotExist(err) {
+		return nil
+	}
+
+	return kv.d.removeRecord(kvCollection, key)
+}
+
+// Incr atomically adds delta to the integer stored under the key, a missing
+// key counts as zero, and returns the new value
+func (kv *KV) Incr(key string, delta int64) (int64, error) {
+	if key == "" {
+		return 0, fmt.Errorf("missing key")
+	}
+
+	done, err := kv.d.admitLocalStore(kvCollection, key)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	mutex := kv.d.getMutex(kvCollection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var n int64
+
+	err = kv.d.load(kvCollection, key, func(b []byte) error {
+		var num json.Number
+		if err := json.Unmarshal(b, &num); err != nil {
+			return fmt.Errorf("value of %s is not a number", key)
+		}
+
+		var err error
+		if n, err = strconv.ParseInt(num.String(), 10, 64); err != nil {
+			return fmt.Errorf("value of %s is not an integer", key)
+		}
+
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	n += delta
+
+	if _, err := kv.d.writeLocked(kvCollection, key, n); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,192 @@
+package jdb
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// record is a record file found on disk by listRecords, created is only set
+// with OrderByCreation
+type record struct {
+	ID      string
+	path    string
+	info    os.FileInfo
+	created int64
+}
+
+// roots returns the writable data directory followed by the read-only ones,
+// in the order they take precedence
+func (d *Driver) roots() []string {
+	return append([]string{d.dir}, d.layers...)
+}
+
+// whiteout is the marker left in the data directory for a record deleted while
+// it still exists in a read-only directory
+func (d *Driver) whiteout(collection, ID string) string {
+	return filepath.Join(d.dir, collection, ID+".deleted")
+}
+
+// locate returns the path of the file holding the record, looking through the
+// read-only directories when it isn't in the data directory
+func (d *Driver) locate(collection, ID string) (string, error) {
+	if err := validateStored(collection, ID); err != nil {
+		return "", err
+	}
+
+	primary := filepath.Join(d.dir, collection, ID+".json")
+
+	if len(d.layers) == 0 {
+		if _, err := d.fs.Stat(primary); err != nil {
+			return "", err
+		}
+
+		return primary, nil
+	}
+
+	if _, err := d.fs.Stat(d.whiteout(collection, ID)); err == nil {
+		return "", notExist(primary)
+	}
+
+	for _, root := range d.roots() {
+		path := filepath.Join(root, collection, ID+".json")
+
+		switch _, err := d.fs.Stat(path); {
+		case err == nil:
+			return path, nil
+		case !os.IsNotExist(err):
+			return "", err
+		}
+	}
+
+	return "", notExist(primary)
+}
+
+// loadFile hands the record to fn from the first root holding it, like
+// locate followed by readFile but opening the candidates straight away
+// instead of stating them first
+func (d *Driver) loadFile(collection, ID string, fn func([]byte) error) error {
+	primary := filepath.Join(d.dir, collection, ID+".json")
+
+	if len(d.layers) == 0 {
+		_, err := d.readCandidate(collection, primary, fn)
+		return err
+	}
+
+	if _, err := d.fs.Stat(d.whiteout(collection, ID)); err == nil {
+		return notExist(primary)
+	}
+
+	for _, root := range d.roots() {
+		found, err := d.readCandidate(collection, filepath.Join(root, collection, ID+".json"), fn)
+		if found || !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return notExist(primary)
+}
+
+// readCandidate is readFile reporting whether the file was there, so fn
+// failing with a not exist error isn't mistaken for a missing file
+func (d *Driver) readCandidate(collection, path string, fn func([]byte) error) (bool, error) {
+	found := false
+
+	err := d.readFile(collection, path, func(b []byte) error {
+		found = true
+		return fn(b)
+	})
+
+	if !found && os.IsNotExist(err) {
+		return false, notExist(path)
+	}
+
+	return found, err
+}
+
+// listRecords returns every record of the collection in the Driver's Order,
+// merging the read-only directories under the data directory
+func (d *Driver) listRecords(collection string) ([]record, error) {
+	if err := validateStoredCollection(collection); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	found := false
+
+	var records []record
+
+	for _, root := range d.roots() {
+		dir := filepath.Join(root, collection)
+
+		files, err := d.fs.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		found = true
+
+		for _, file := range files {
+			name := file.Name()
+
+			switch {
+			case strings.HasSuffix(name, ".deleted") && root == d.dir:
+				seen[strings.TrimSuffix(name, ".deleted")] = true
+			case strings.HasSuffix(name, ".json") && file.Mode().IsRegular():
+				ID := strings.TrimSuffix(name, ".json")
+				if seen[ID] {
+					continue
+				}
+
+				seen[ID] = true
+				records = append(records, record{ID: ID, path: filepath.Join(dir, name), info: file})
+			}
+		}
+	}
+
+	if !found {
+		return nil, notExist(filepath.Join(d.dir, collection))
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+
+	if d.order == OrderByCreation {
+		if err := d.sortByCreation(collection, records); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// inLayers reports whether a read-only directory still has the record
+func (d *Driver) inLayers(collection, ID string) bool {
+	for _, root := range d.layers {
+		if _, err := d.fs.Stat(filepath.Join(root, collection, ID+".json")); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clearWhiteout makes a record of a read-only directory visible again once it
+// is written to the data directory
+func (d *Driver) clearWhiteout(collection, ID string) error {
+	if len(d.layers) == 0 {
+		return nil
+	}
+
+	if err := d.fs.Remove(d.whiteout(collection, ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package jdb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// leaderFile holds the lease of the process leading the data directory
+const leaderFile = ".leader"
+
+type lease struct {
+	Holder  string `json:"holder"`
+	Expires int64  `json:"expires"`
+}
+
+// IsLeader reports whether this Driver holds the leader lease of the data
+// directory, see Options.LeaderLease. Without a lease every Driver leads
+func (d *Driver) IsLeader() bool {
+	if d.leaseTerm <= 0 {
+		return true
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.leader
+}
+
+// campaign takes the lease when it's free, expired or already held, renewing
+// it, and records whether this Driver leads
+func (d *Driver) campaign() error {
+	leading, err := d.updateLease(func(l *lease, now time.Time) bool {
+		if l.Holder != d.holder && l.Expires > now.UnixNano() {
+			return false
+		}
+
+		l.Holder = d.holder
+		l.Expires = now.Add(d.leaseTerm).UnixNano()
+		return true
+	})
+
+	d.mutex.Lock()
+	if d.leader != leading {
+		d.log.Info("leadership of %s: %t", d.dir, leading)
+	}
+	d.leader = leading
+	d.mutex.Unlock()
+
+	return err
+}
+
+// resign gives the lease up so another process can lead right away
+func (d *Driver) resign() error {
+	_, err := d.updateLease(func(l *lease, now time.Time) bool {
+		if l.Holder != d.holder {
+			return false
+		}
+
+		l.Expires = 0
+		return true
+	})
+
+	d.mutex.Lock()
+	d.leader = false
+	d.mutex.Unlock()
+
+	return err
+}
+
+// updateLease hands the current lease to fn and stores it when fn returns
+// true, under an advisory lock so processes don't race for it
+func (d *Driver) updateLease(fn func(l *lease, now time.Time) bool) (bool, error) {
+	path := filepath.Join(d.dir, leaderFile)
+
+	if d.onDisk {
+		unlock, err := lockFile(path + ".lock")
+		if err != nil {
+			return false, err
+		}
+		defer unlock()
+	}
+
+	var l lease
+
+	switch b, err := d.fs.ReadFile(path); {
+	case err == nil:
+		if err := json.Unmarshal(b, &l); err != nil {
+			d.log.Warn("ignoring corrupt lease %s: %s", path, err)
+			l = lease{}
+		}
+	case !os.IsNotExist(err):
+		return false, err
+	}
+
+	if !fn(&l, d.clock.Now()) {
+		return false, nil
+	}
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		return false, err
+	}
+
+	if err := d.fs.WriteFile(path+".tmp", b, 0644); err != nil {
+		return false, err
+	}
+
+	if err := d.fs.Rename(path+".tmp", path); err != nil {
+		return false, err
+	}
+
+	return l.Expires > 0, nil
+}
+
+// leaseLoop renews or campaigns for the lease three times per term until
+// the Driver is closed
+func (d *Driver) leaseLoop() {
+	defer d.loops.Done()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.after(d.leaseTerm / 3):
+			if err := d.campaign(); err != nil {
+				d.log.Error("campaigning for leadership: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package jdb
+
+import "fmt"
+
+type (
+	// LintRule is a soft rule records of a collection should follow, checked
+	// by Lint rather than on writes like a Schema. Its filters use the
+	// syntax of ParseFilter, e.g. a record whose When is
+	//
+	//	status = "shipped"
+	//
+	// has to match a Require of
+	//
+	//	shipped_at exists and carrier ~ "^[A-Z]{3}$"
+	LintRule struct {
+		Name string `json:"name"`
+
+		// When selects the records the rule applies to, every record when
+		// it's empty
+		When string `json:"when,omitempty"`
+
+		// Require is the filter the records must match
+		Require string `json:"require"`
+
+		// Message explains a violation, defaults to the Require filter
+		Message string `json:"message,omitempty"`
+	}
+
+	// LintViolation is a record breaking a LintRule
+	LintViolation struct {
+		ID      string `json:"id"`
+		Rule    string `json:"rule"`
+		Message string `json:"message"`
+	}
+
+	// LintReport is what Lint found
+	LintReport struct {
+		Collection string          `json:"collection"`
+		Records    int             `json:"records"`
+		Violations []LintViolation `json:"violations"`
+	}
+
+	// lintRule is a LintRule with its filters parsed
+	lintRule struct {
+		LintRule
+		when, require *Filter
+	}
+)
+
+// SetLintRules sets the rules Lint checks the records of the collection
+// against, replacing the previous ones. Rules with invalid filters are
+// refused
+func (d *Driver) SetLintRules(collection string, rules []LintRule) error {
+	parsed := make([]lintRule, 0, len(rules))
+
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("lint rule without a name")
+		}
+
+		if rule.Require == "" {
+			return fmt.Errorf("lint rule %s: missing Require, nothing to check", rule.Name)
+		}
+
+		when, err := ParseFilter(rule.When)
+		if err != nil {
+			return fmt.Errorf("lint rule %s: when: %w", rule.Name, err)
+		}
+
+		require, err := ParseFilter(rule.Require)
+		if err != nil {
+			return fmt.Errorf("lint rule %s: require: %w", rule.Name, err)
+		}
+
+		if rule.Message == "" {
+			rule.Message = "doesn't match " + rule.Require
+		}
+
+		parsed = append(parsed, lintRule{LintRule: rule, when: when, require: require})
+	}
+
+	d.configure(collection, func(c *collectionConfig) {
+		c.lintRules = parsed
+	})
+
+	return nil
+}
+
+// LintRules returns the rules set on the collection
+func (d *Driver) LintRules(collection string) []LintRule {
+	var rules []LintRule
+	for _, rule := range d.config(collection).lintRules {
+		rules = append(rules, rule.LintRule)
+	}
+
+	return rules
+}
+
+// Lint checks every record of the collection against its LintRules and
+// reports the violations, in the order of the records. Unlike a Schema the
+// rules never refuse writes, so they can be tightened over existing data
+func (d *Driver) Lint(collection string) (*LintReport, error) {
+	rules := d.config(collection).lintRules
+	report := &LintReport{Collection: collection}
+
+	err := d.ForEach(collection, func(ID string, raw []byte) error {
+		doc, err := decodeJSON(raw)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", collection, ID, err)
+		}
+
+		report.Records++
+
+		for _, rule := range rules {
+			if rule.when.root.match(doc) && !rule.require.root.match(doc) {
+				report.Violations = append(report.Violations, LintViolation{ID: ID, Rule: rule.Name, Message: rule.Message})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,64 @@
+package jdb
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// collectionLock is the lock of a collection. Everything that works on the
+// collection as a whole holds it exclusively, while writes to single records
+// only hold it shared when the Driver has Options.LockStripes, serializing on
+// the stripe of their record instead
+type collectionLock struct {
+	sync.RWMutex
+
+	// shared guards the in memory state writers holding the lock shared
+	// update: bloom filters and indexes
+	shared sync.Mutex
+}
+
+// getMutex returns the lock of the collection. Looking it up doesn't take
+// d.mutex, so collections don't contend with each other once their lock
+// exists
+func (d *Driver) getMutex(collection string) *collectionLock {
+	if m, ok := d.mutexes.Load(collection); ok {
+		return m.(*collectionLock)
+	}
+
+	m, _ := d.mutexes.LoadOrStore(collection, &collectionLock{})
+	return m.(*collectionLock)
+}
+
+// lockRecord locks a record for a write and returns the unlock function.
+// Without stripes, or for collections whose writes depend on each other, it
+// locks the whole collection
+func (d *Driver) lockRecord(collection, ID string) func() {
+	lock := d.getMutex(collection)
+
+	if len(d.stripes) == 0 || d.serialWrites(collection) {
+		lock.Lock()
+		return lock.Unlock
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(collection))
+	h.Write([]byte{'/'})
+	h.Write([]byte(ID))
+	stripe := &d.stripes[h.Sum32()%uint32(len(d.stripes))]
+
+	lock.RLock()
+	stripe.Lock()
+
+	return func() {
+		stripe.Unlock()
+		lock.RUnlock()
+	}
+}
+
+// serialWrites reports whether writes to the collection must not run in
+// parallel: hash chains link each record to the previous one, deduplicated
+// records may share a blob and the creation log is compacted by writes
+func (d *Driver) serialWrites(collection string) bool {
+	c := d.config(collection)
+	return c.hashChain || c.dedup || d.order == OrderByCreation
+}
@@ -0,0 +1,34 @@
+package jdb_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestConfigChangesWhileWriting(t *testing.T) {
+	d := jdbtest.New(t, jdb.WithLockStripes(4))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 100; i++ {
+			d.SetCoalesce("users", 0)
+			d.SetDefaultTTL("users", time.Hour)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		jdbtest.Seed(t, d, "users", 100, jdbtest.Sequence("u", 1))
+	}()
+
+	wg.Wait()
+}
@@ -0,0 +1,109 @@
+package jdb
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type (
+	// MapFunc emits any number of key-value pairs for a record, see
+	// MapReduce. raw is only valid until it returns
+	MapFunc func(ID string, raw []byte, emit func(key string, value interface{})) error
+
+	// ReduceFunc combines the values emitted under a key into the record
+	// stored for it, see MapReduce
+	ReduceFunc func(key string, values []interface{}) (interface{}, error)
+
+	// MapReduceOptions tunes MapReduce
+	MapReduceOptions struct {
+		// Output is the collection the results are written to, one record
+		// per key named after it
+		Output string
+
+		// Parallel is how many records are mapped at once, one by default
+		Parallel int
+
+		// Replace deletes the records of Output no key was emitted for, so
+		// rollups don't keep keys that are gone from the input
+		Replace bool
+	}
+)
+
+// MapReduce maps every record of the collection with mapFn, reduces the
+// values emitted under each key with reduceFn and writes the results to
+// opts.Output, returning how many were written. Values are kept in memory
+// until reduced, so keys should summarize the collection
+func (d *Driver) MapReduce(collection string, mapFn MapFunc, reduceFn ReduceFunc, opts MapReduceOptions) (int, error) {
+	if opts.Output == "" {
+		return 0, fmt.Errorf("missing output collection, no place to save results")
+	}
+
+	if opts.Output == collection {
+		return 0, fmt.Errorf("output collection %s is the input", collection)
+	}
+
+	var mutex sync.Mutex
+	emitted := make(map[string][]interface{})
+
+	err := d.ForEach(collection, func(ID string, raw []byte) error {
+		return mapFn(ID, raw, func(key string, value interface{}) {
+			mutex.Lock()
+			emitted[key] = append(emitted[key], value)
+			mutex.Unlock()
+		})
+	}, Parallel(opts.Parallel))
+	if err != nil {
+		return 0, fmt.Errorf("mapping %s: %w", collection, err)
+	}
+
+	keys := make([]string, 0, len(emitted))
+	for key := range emitted {
+		if err := ValidateID(key); err != nil {
+			return 0, fmt.Errorf("key can't name an output record: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		result, err := reduceFn(key, emitted[key])
+		if err != nil {
+			return i, fmt.Errorf("reducing %s: %w", key, err)
+		}
+
+		if _, err := d.Write(opts.Output, key, result); err != nil {
+			return i, err
+		}
+	}
+
+	if opts.Replace {
+		if err := d.dropStale(opts.Output, emitted); err != nil {
+			return len(keys), err
+		}
+	}
+
+	return len(keys), nil
+}
+
+// dropStale deletes the records of the output no key was emitted for
+func (d *Driver) dropStale(output string, emitted map[string][]interface{}) error {
+	IDs, err := d.IDs(output)
+	if err != nil {
+		return err
+	}
+
+	for _, ID := range IDs {
+		if _, ok := emitted[ID]; ok {
+			continue
+		}
+
+		if err := d.Delete(output, ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+package jdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+type (
+	// MigrateFunc returns the new value of a record, anything Write takes,
+	// or nil to leave the record as is
+	MigrateFunc func(ID string, raw []byte) (interface{}, error)
+
+	// Migration rewrites the records of a collection
+	Migration struct {
+		Name       string
+		Collection string
+		Up         MigrateFunc
+	}
+
+	// MigrationExample shows what a migration does to one record
+	MigrationExample struct {
+		ID      string          `json:"id"`
+		Before  json.RawMessage `json:"before"`
+		After   json.RawMessage `json:"after"`
+		Changes []Change        `json:"changes"`
+	}
+
+	// MigrationReport is the dry run of a migration
+	MigrationReport struct {
+		Name       string `json:"name"`
+		Collection string `json:"collection"`
+
+		// Records counts the records looked at, Affected the ones the
+		// migration changes
+		Records  int `json:"records"`
+		Affected int `json:"affected"`
+
+		// Examples are the first records changed, up to the number asked
+		// of PlanMigrations
+		Examples []MigrationExample `json:"examples,omitempty"`
+	}
+
+	// MigrationPlan is what PlanMigrations found the migrations would do,
+	// to be reviewed before handing it to Migrate
+	MigrationPlan struct {
+		Reports []MigrationReport `json:"reports"`
+
+		// Digest sums up the records the plan writes, Migrate refuses the
+		// plan when it no longer matches them
+		Digest string `json:"digest"`
+	}
+)
+
+// migrationWrite is a record a plan writes
+type migrationWrite struct {
+	revision string
+	after    []byte
+}
+
+// migrationRun applies migrations in memory, later migrations seeing the
+// records earlier ones changed
+type migrationRun struct {
+	d *Driver
+
+	// writes holds the pending records by collection and ID
+	writes map[string]map[string]*migrationWrite
+}
+
+// PlanMigrations dry runs the migrations in order without writing anything,
+// reporting for each how many records it changes with up to examples of
+// them diffed
+func (d *Driver) PlanMigrations(examples int, migrations ...Migration) (*MigrationPlan, error) {
+	plan, _, err := d.planMigrations(examples, migrations)
+	return plan, err
+}
+
+// Migrate writes the records of a plan made by PlanMigrations for the same
+// migrations. They are dry run again first and the plan is refused, with an
+// error wrapping ErrConditionFailed, when the records they'd write differ
+// from the reviewed ones. Each collection is rebuilt in a shadow directory
+// swapped in at once, so readers keep seeing the records as they were until
+// the swap and never a half migrated collection. Writes made meanwhile fail
+// the swap the same way, collections already swapped staying migrated
+func (d *Driver) Migrate(plan *MigrationPlan, migrations ...Migration) error {
+	if plan == nil {
+		return fmt.Errorf("migrating without a reviewed plan: %w", ErrNotConfirmed)
+	}
+
+	admitted, err := d.admitMaintenance("migrating")
+	if err != nil {
+		return err
+	}
+	defer admitted()
+
+	// fingerprinted before the dry run, so writes racing it fail the swap
+	fingerprints := make(map[string]string)
+
+	for _, m := range migrations {
+		if _, ok := fingerprints[m.Collection]; ok {
+			continue
+		}
+
+		fingerprint, err := d.fingerprint(m.Collection)
+		if err != nil {
+			return err
+		}
+
+		fingerprints[m.Collection] = fingerprint
+	}
+
+	current, run, err := d.planMigrations(0, migrations)
+	if err != nil {
+		return err
+	}
+
+	if current.Digest != plan.Digest {
+		return fmt.Errorf("records changed since the plan was made: %w", ErrConditionFailed)
+	}
+
+	total := 0
+	for _, writes := range run.writes {
+		total += len(writes)
+	}
+
+	done := 0
+
+	for _, collection := range sortedWriteKeys(run.writes) {
+		writes := run.writes[collection]
+		if len(writes) == 0 {
+			continue
+		}
+
+		if d.config(collection).appendOnly {
+			return fmt.Errorf("migrating %s: %w", collection, ErrAppendOnly)
+		}
+
+		docs := make(map[string][]byte, len(writes))
+		for ID, w := range writes {
+			docs[ID] = w.after
+		}
+
+		shadow, err := d.buildShadow(collection, docs, func() {
+			done++
+			d.progress(Progress{Op: "migrate", Collection: collection, Done: done, Total: total})
+		})
+		if err != nil {
+			return fmt.Errorf("rebuilding %s: %w", collection, err)
+		}
+
+		if err := d.swapShadow(collection, shadow, fingerprints[collection], docs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) planMigrations(examples int, migrations []Migration) (*MigrationPlan, *migrationRun, error) {
+	run := &migrationRun{d: d, writes: make(map[string]map[string]*migrationWrite)}
+	plan := &MigrationPlan{}
+
+	for _, m := range migrations {
+		report, err := run.apply(m, examples)
+		if err != nil {
+			return nil, nil, fmt.Errorf("migration %s: %w", m.Name, err)
+		}
+
+		plan.Reports = append(plan.Reports, report)
+	}
+
+	plan.Digest = run.digest()
+
+	return plan, run, nil
+}
+
+func (r *migrationRun) apply(m Migration, examples int) (MigrationReport, error) {
+	report := MigrationReport{Name: m.Name, Collection: m.Collection}
+
+	if m.Up == nil {
+		return report, fmt.Errorf("missing Up, nothing to migrate with")
+	}
+
+	writes := r.writes[m.Collection]
+	if writes == nil {
+		writes = make(map[string]*migrationWrite)
+		r.writes[m.Collection] = writes
+	}
+
+	err := r.d.ForEach(m.Collection, func(ID string, raw []byte) error {
+		report.Records++
+
+		w := writes[ID]
+		if w == nil {
+			w = &migrationWrite{revision: revisionOf(raw)}
+		} else {
+			raw = w.after
+		}
+
+		v, err := m.Up(ID, raw)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", m.Collection, ID, err)
+		}
+
+		if v == nil {
+			return nil
+		}
+
+		after, err := r.d.encode(v)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", m.Collection, ID, err)
+		}
+
+		changes, err := DiffJSON(raw, after)
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", m.Collection, ID, err)
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+
+		report.Affected++
+
+		if len(report.Examples) < examples {
+			report.Examples = append(report.Examples, MigrationExample{
+				ID:      ID,
+				Before:  append(json.RawMessage(nil), raw...),
+				After:   after,
+				Changes: changes,
+			})
+		}
+
+		w.after = after
+		writes[ID] = w
+
+		return nil
+	})
+
+	return report, err
+}
+
+// digest hashes the pending records with the revisions they replace
+func (r *migrationRun) digest() string {
+	h := sha256.New()
+
+	for _, collection := range sortedWriteKeys(r.writes) {
+		writes := r.writes[collection]
+
+		for _, ID := range sortedWriteKeys(writes) {
+			fmt.Fprintf(h, "%s/%s %s %d\n", collection, ID, writes[ID].revision, len(writes[ID].after))
+			h.Write(writes[ID].after)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func sortedWriteKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package jdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxNameLength is the longest ID or collection segment, leaving room
+	// for the .json.tmp suffix within the 255 bytes file names allow
+	maxNameLength = 246
+
+	// maxCollectionLength is the longest collection, nested ones included
+	maxCollectionLength = 1024
+)
+
+// forbidden are the bytes IDs and collection names can't contain: control
+// characters and path separators, which would let a name escape its
+// directory
+var forbidden = func() (t [256]bool) {
+	for b := 0; b < 0x20; b++ {
+		t[b] = true
+	}
+
+	t[0x7f] = true
+	t['/'] = true
+	t['\\'] = true
+
+	return t
+}()
+
+// ValidateID checks the ID can name a record: at most 246 bytes without
+// control characters or slashes, not starting with a dot as hidden files
+// aren't records. It doesn't allocate unless the ID is invalid
+func ValidateID(ID string) error {
+	if reason := checkName(ID); reason != "" {
+		return fmt.Errorf("invalid ID %q: %s: %w", ID, reason, ErrInvalidName)
+	}
+
+	return nil
+}
+
+// ValidateCollection checks the collection can name a directory: slash
+// separated segments following the rules of ValidateID, at most 1024 bytes
+// in all. Segments can't start with an underscore either, those names are
+// reserved for the directories of the Driver. It doesn't allocate unless the
+// collection is invalid
+func ValidateCollection(collection string) error {
+	if len(collection) > maxCollectionLength {
+		return fmt.Errorf("invalid collection %q: longer than %d bytes: %w", collection, maxCollectionLength, ErrInvalidName)
+	}
+
+	start := 0
+	for i := 0; i <= len(collection); i++ {
+		if i < len(collection) && collection[i] != '/' {
+			continue
+		}
+
+		reason := checkName(collection[start:i])
+		if reason == "" && collection[start] == '_' {
+			reason = "starts with an underscore, which is reserved"
+		}
+
+		if reason != "" {
+			return fmt.Errorf("invalid collection %q: %s: %w", collection, reason, ErrInvalidName)
+		}
+
+		start = i + 1
+	}
+
+	return nil
+}
+
+// checkName returns why the name can't be a file name, empty when it can
+func checkName(name string) string {
+	switch {
+	case name == "":
+		return "empty name"
+	case len(name) > maxNameLength:
+		return "longer than 246 bytes"
+	case name[0] == '.':
+		return "starts with a dot"
+	}
+
+	for i := 0; i < len(name); i++ {
+		if forbidden[name[i]] {
+			return "contains a control character or a slash"
+		}
+	}
+
+	return ""
+}
+
+// validateRecord checks the collection and ID of a record
+func validateRecord(collection, ID string) error {
+	if err := ValidateCollection(collection); err != nil {
+		return err
+	}
+
+	return ValidateID(ID)
+}
+
+// validateStored checks the collection and ID of a record like
+// validateRecord, also accepting the reserved collections the Driver keeps
+// records of its own in, see validateStoredCollection
+func validateStored(collection, ID string) error {
+	if err := validateStoredCollection(collection); err != nil {
+		return err
+	}
+
+	return ValidateID(ID)
+}
+
+// validateStoredCollection checks the collection like ValidateCollection,
+// also accepting the KV and the queues. It's the check of the helpers every
+// read and write of a record goes through, so no entry point can reach a
+// path outside the collection or another reserved directory
+func validateStoredCollection(collection string) error {
+	switch {
+	case collection == kvCollection:
+		return nil
+	case strings.HasPrefix(collection, queueDir+"/"):
+		return ValidateCollection(strings.TrimPrefix(collection, queueDir+"/"))
+	}
+
+	return ValidateCollection(collection)
+}
@@ -0,0 +1,111 @@
+package jdb_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestValidateCollection(t *testing.T) {
+	valid := []string{"users", "users/eu", "a.b", "user_settings"}
+	invalid := []string{"", "..", "../x", "users/../..", "_kv", "_history/users", "users/_x", ".hidden", "a\x00b", `a\b`}
+
+	for _, c := range valid {
+		if err := jdb.ValidateCollection(c); err != nil {
+			t.Errorf("ValidateCollection(%q) = %s", c, err)
+		}
+	}
+
+	for _, c := range invalid {
+		if err := jdb.ValidateCollection(c); !errors.Is(err, jdb.ErrInvalidName) {
+			t.Errorf("ValidateCollection(%q) = %v, want ErrInvalidName", c, err)
+		}
+	}
+}
+
+func TestEntryPointsRejectEscapingNames(t *testing.T) {
+	d := jdbtest.New(t)
+
+	if _, err := d.Write("users", "u", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	const escaped = "../../escaped"
+
+	ops := map[string]func() error{
+		"UpdateFn": func() error {
+			_, err := jdb.UpdateFn(d, "users", escaped, func(v map[string]interface{}) (map[string]interface{}, error) { return v, nil })
+			return err
+		},
+		"Increment": func() error {
+			_, err := d.Increment("users", escaped, "n", 1)
+			return err
+		},
+		"Push":     func() error { return d.Push("users", escaped, "tags", "a") },
+		"Expire":   func() error { return d.Expire("users", escaped, time.Hour) },
+		"Persist":  func() error { return d.Persist("users", escaped) },
+		"WriteIf":  func() error { _, err := d.WriteIf("users", escaped, 1, jdb.NotExists()); return err },
+		"Take":     func() error { _, err := d.Take("users", escaped); return err },
+		"Copy":     func() error { return d.CopyRecord("users", escaped, "copies") },
+		"CopyTo":   func() error { return d.CopyRecord("users", "u", escaped) },
+		"Undelete": func() error { return d.Undelete("users", escaped) },
+		"KV.Del":   func() error { return d.KV().Del(escaped) },
+		"KV.Set":   func() error { return d.KV().Set(escaped, 1) },
+		"KV.Incr":  func() error { _, err := d.KV().Incr(escaped, 1); return err },
+		"Enqueue":  func() error { _, err := d.Enqueue(escaped, 1); return err },
+		"Ack":      func() error { return d.Ack("jobs", escaped, "receipt") },
+		"Drop":     func() error { return d.DropCollection(escaped, true) },
+		"ReadInto": func() error { var v interface{}; return d.ReadInto("users", escaped, &v) },
+		"Replicate": func() error {
+			return d.ApplyReplicated(jdb.ReplicatedOp{Op: jdb.OpWrite, Collection: "users", ID: escaped, Data: []byte("1")})
+		},
+	}
+
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, jdb.ErrInvalidName) {
+			t.Errorf("%s with %q = %v, want ErrInvalidName", name, escaped, err)
+		}
+	}
+
+	root := filepath.Dir(filepath.Dir(filepath.Join(t.TempDir(), "db")))
+	if _, err := os.Stat(filepath.Join(root, "escaped.json")); err == nil {
+		t.Errorf("a record was written outside of the data directory")
+	}
+}
+
+func TestReservedCollectionsAreRefused(t *testing.T) {
+	d := jdbtest.New(t)
+
+	for _, c := range []string{"_kv", "_trash", "_history/users", "_queue/jobs"} {
+		if _, err := d.Write(c, "u", 1); !errors.Is(err, jdb.ErrInvalidName) {
+			t.Errorf("Write to %s = %v, want ErrInvalidName", c, err)
+		}
+
+		if _, err := d.Increment(c, "u", "n", 1); !errors.Is(err, jdb.ErrInvalidName) {
+			t.Errorf("Increment of %s = %v, want ErrInvalidName", c, err)
+		}
+	}
+
+	kv := d.KV()
+	if err := kv.Set("flag", true); err != nil {
+		t.Fatalf("KV.Set: %s", err)
+	}
+
+	var flag bool
+	if err := kv.Get("flag", &flag); err != nil || !flag {
+		t.Errorf("KV.Get = %v, %v", flag, err)
+	}
+
+	if _, err := d.Enqueue("jobs", "job"); err != nil {
+		t.Fatalf("Enqueue: %s", err)
+	}
+
+	if msg, err := d.Dequeue("jobs", time.Minute); err != nil || d.Ack("jobs", msg.ID, msg.Receipt) != nil {
+		t.Errorf("Dequeue and Ack: %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package jdb
+
+import "os"
+
+func linkCount(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,204 @@
+package jdb
+
+import "time"
+
+type (
+	// Option configures a Driver opened by New. *Options is an Option too,
+	// replacing every setting made by the Options before it, so both
+	//
+	//	jdb.New(dir, &jdb.Options{Logger: l})
+	//	jdb.New(dir, jdb.WithLogger(l))
+	//
+	// work
+	Option interface {
+		apply(*Options)
+	}
+
+	optionFunc func(*Options)
+)
+
+func (f optionFunc) apply(opts *Options) { f(opts) }
+
+func (o *Options) apply(opts *Options) {
+	if o != nil {
+		*opts = *o
+	}
+}
+
+// buildOptions applies the options in order, skipping nil ones
+func buildOptions(options []Option) Options {
+	var opts Options
+
+	for _, o := range options {
+		if o != nil {
+			o.apply(&opts)
+		}
+	}
+
+	return opts
+}
+
+// WithLogger sets Options.Logger
+func WithLogger(l Logger) Option {
+	return optionFunc(func(o *Options) { o.Logger = l })
+}
+
+// WithStorage sets Options.Storage
+func WithStorage(s Storage) Option {
+	return optionFunc(func(o *Options) { o.Storage = s })
+}
+
+// WithClock sets Options.Clock
+func WithClock(c Clock) Option {
+	return optionFunc(func(o *Options) { o.Clock = c })
+}
+
+// WithIDGenerator sets Options.IDGenerator
+func WithIDGenerator(g IDGenerator) Option {
+	return optionFunc(func(o *Options) { o.IDGenerator = g })
+}
+
+// WithJSON sets Options.JSON
+func WithJSON(j JSONOptions) Option {
+	return optionFunc(func(o *Options) { o.JSON = j })
+}
+
+// WithCodec sets Options.Codec
+func WithCodec(c Codec) Option {
+	return optionFunc(func(o *Options) { o.Codec = c })
+}
+
+// WithSync sets Options.Sync
+func WithSync(mode SyncMode) Option {
+	return optionFunc(func(o *Options) { o.Sync = mode })
+}
+
+// WithOrder sets Options.Order
+func WithOrder(order Order) Option {
+	return optionFunc(func(o *Options) { o.Order = order })
+}
+
+// WithMmapThreshold sets Options.MmapThreshold
+func WithMmapThreshold(n int64) Option {
+	return optionFunc(func(o *Options) { o.MmapThreshold = n })
+}
+
+// WithBloomFilter sets Options.BloomFilterSize
+func WithBloomFilter(size int) Option {
+	return optionFunc(func(o *Options) { o.BloomFilterSize = size })
+}
+
+// WithHandleCache sets Options.HandleCacheSize
+func WithHandleCache(size int) Option {
+	return optionFunc(func(o *Options) { o.HandleCacheSize = size })
+}
+
+// WithWatchFiles sets Options.WatchFiles
+func WithWatchFiles() Option {
+	return optionFunc(func(o *Options) { o.WatchFiles = true })
+}
+
+// WithReadOnlyDirs adds to Options.ReadOnlyDirs
+func WithReadOnlyDirs(dirs ...string) Option {
+	return optionFunc(func(o *Options) { o.ReadOnlyDirs = append(o.ReadOnlyDirs, dirs...) })
+}
+
+// WithTTLSweep sets Options.TTLSweepInterval
+func WithTTLSweep(interval time.Duration) Option {
+	return optionFunc(func(o *Options) { o.TTLSweepInterval = interval })
+}
+
+// WithTrash sets Options.TrashRetention
+func WithTrash(retention time.Duration) Option {
+	return optionFunc(func(o *Options) { o.TrashRetention = retention })
+}
+
+// WithEncryption sets Options.EncryptionKey and adds the previous keys to
+// Options.DecryptionKeys
+func WithEncryption(key []byte, previous ...[]byte) Option {
+	return optionFunc(func(o *Options) {
+		o.EncryptionKey = key
+		o.DecryptionKeys = append(o.DecryptionKeys, previous...)
+	})
+}
+
+// WithFieldEncryption sets Options.EncryptionKey and EncryptFieldsOnly
+func WithFieldEncryption(key []byte) Option {
+	return optionFunc(func(o *Options) {
+		o.EncryptionKey = key
+		o.EncryptFieldsOnly = true
+	})
+}
+
+// WithProgress sets Options.OnProgress
+func WithProgress(fn func(Progress)) Option {
+	return optionFunc(func(o *Options) { o.OnProgress = fn })
+}
+
+// WithWriteRate sets Options.WriteRate and WriteBurst
+func WithWriteRate(rate float64, burst int) Option {
+	return optionFunc(func(o *Options) {
+		o.WriteRate = rate
+		o.WriteBurst = burst
+	})
+}
+
+// WithMaxPendingWrites sets Options.MaxPendingWrites
+func WithMaxPendingWrites(n int) Option {
+	return optionFunc(func(o *Options) { o.MaxPendingWrites = n })
+}
+
+// WithFreezeTimeout sets Options.FreezeTimeout
+func WithFreezeTimeout(timeout time.Duration) Option {
+	return optionFunc(func(o *Options) { o.FreezeTimeout = timeout })
+}
+
+// WithSlowThreshold sets Options.SlowThreshold
+func WithSlowThreshold(threshold time.Duration) Option {
+	return optionFunc(func(o *Options) { o.SlowThreshold = threshold })
+}
+
+// WithStatsSample sets Options.StatsSample
+func WithStatsSample(n int) Option {
+	return optionFunc(func(o *Options) { o.StatsSample = n })
+}
+
+// WithReadOnly sets Options.ReadOnly
+func WithReadOnly() Option {
+	return optionFunc(func(o *Options) { o.ReadOnly = true })
+}
+
+// WithLeaderLease sets Options.LeaderLease
+func WithLeaderLease(term time.Duration) Option {
+	return optionFunc(func(o *Options) { o.LeaderLease = term })
+}
+
+// WithReplicator sets Options.Replicator
+func WithReplicator(r Replicator) Option {
+	return optionFunc(func(o *Options) { o.Replicator = r })
+}
+
+// WithCollections adds to Options.Collections
+func WithCollections(specs ...CollectionSpec) Option {
+	return optionFunc(func(o *Options) { o.Collections = append(o.Collections, specs...) })
+}
+
+// WithDefaultTTL sets Options.DefaultTTL
+func WithDefaultTTL(ttl time.Duration) Option {
+	return optionFunc(func(o *Options) { o.DefaultTTL = ttl })
+}
+
+// WithVerifyOnOpen sets Options.VerifyOnOpen
+func WithVerifyOnOpen(opts VerifyOptions) Option {
+	return optionFunc(func(o *Options) { o.VerifyOnOpen = &opts })
+}
+
+// WithLockStripes sets Options.LockStripes
+func WithLockStripes(n int) Option {
+	return optionFunc(func(o *Options) { o.LockStripes = n })
+}
+
+// WithAsyncWorkers sets Options.AsyncWorkers
+func WithAsyncWorkers(n int) Option {
+	return optionFunc(func(o *Options) { o.AsyncWorkers = n })
+}
@@ -0,0 +1,129 @@
+package jdb_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+// reversed stores records backwards behind a prefix, reading records
+// without it as they are
+type reversed struct{}
+
+func (reversed) Encode(b []byte) ([]byte, error) {
+	out := []byte("rev:")
+	for i := len(b) - 1; i >= 0; i-- {
+		out = append(out, b[i])
+	}
+
+	return out, nil
+}
+
+func (reversed) Decode(b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, []byte("rev:")) {
+		return b, nil
+	}
+
+	b = b[len("rev:"):]
+	out := make([]byte, 0, len(b))
+	for i := len(b) - 1; i >= 0; i-- {
+		out = append(out, b[i])
+	}
+
+	return out, nil
+}
+
+func TestCodecEncodesEveryRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	plain := jdbtest.Open(t, dir)
+	jdbtest.Seed(t, plain, "users", 2, jdbtest.Sequence("old", map[string]string{"team": "red"}))
+	if err := plain.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := jdbtest.Open(t, dir, jdb.WithCodec(reversed{}), jdb.WithJSON(jdb.JSONOptions{CompressAbove: 64}))
+	jdbtest.Seed(t, d, "users", 2, jdbtest.Sequence("new", map[string]string{"team": "red"}))
+
+	if _, err := d.Write("users", "big", map[string]string{"team": "red", "bio": strings.Repeat("x", 100)}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "users", "new-0.json"))
+	if err != nil || !bytes.HasPrefix(b, []byte("rev:")) {
+		t.Fatalf("record on disk = %q, %v, want it encoded", b, err)
+	}
+
+	if err := d.EnsureIndex("users", "team"); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := d.FindBy("users", "team", "red")
+	if err != nil || len(records) != 5 {
+		t.Errorf("FindBy = %d records, %v, want the 5 old and new ones", len(records), err)
+	}
+
+	var backup bytes.Buffer
+	if _, err := d.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := jdbtest.New(t)
+	if _, err := restored.Restore(&backup, jdb.RestoreOverwrite); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := jdbtest.Snapshot(t, restored, "users"), jdbtest.Snapshot(t, d, "users"); !bytes.Equal(got, want) {
+		t.Errorf("restored records:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// syncRecorder is OSStorage remembering what it flushed
+type syncRecorder struct {
+	jdb.Storage
+
+	mutex  sync.Mutex
+	synced []string
+}
+
+func (s *syncRecorder) Sync(name string) error {
+	s.mutex.Lock()
+	s.synced = append(s.synced, name)
+	s.mutex.Unlock()
+
+	return jdb.OSStorage.(jdb.Syncer).Sync(name)
+}
+
+func TestSyncAlwaysFlushesRecordsAndDirectories(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, mode := range []jdb.SyncMode{jdb.SyncNever, jdb.SyncAlways} {
+		fs := &syncRecorder{Storage: jdb.OSStorage}
+		d := jdbtest.Open(t, dir, jdb.WithStorage(fs), jdb.WithSync(mode))
+
+		if _, err := d.Write("users", "ada", 1); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := d.Delete("users", "ada"); err != nil {
+			t.Fatal(err)
+		}
+
+		users := filepath.Join(dir, "users")
+		want := []string{filepath.Join(users, "ada.json.tmp"), users, users}
+
+		if mode == jdb.SyncNever {
+			want = nil
+		}
+
+		if strings.Join(fs.synced, ",") != strings.Join(want, ",") {
+			t.Errorf("mode %d synced %v, want %v", mode, fs.synced, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package jdb
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Order is the order records of a collection are listed in
+type Order int
+
+const (
+	// OrderByID lists records sorted lexicographically by ID
+	OrderByID Order = iota
+
+	// OrderByCreation lists records in the order they were first written,
+	// records written before it was enabled, behind the Driver's back or
+	// living in ReadOnlyDirs come first, sorted by ID
+	OrderByCreation
+)
+
+// orderLog is the append-only file keeping the creation order of a collection
+const orderLog = ".order"
+
+// orderCompactEvery is how many creations are logged between rewrites of the
+// order log dropping the entries of deleted records
+const orderCompactEvery = 1024
+
+func (d *Driver) orderLogPath(collection string) string {
+	return filepath.Join(d.dir, collection, orderLog)
+}
+
+// recordCreation appends a new record to the order log of the collection,
+// callers must hold the collection lock. Entries are "<unix nanos> <ID>" so
+// rewriting the log keeps the positions cursors point at
+func (d *Driver) recordCreation(collection, ID string) error {
+	if d.order != OrderByCreation {
+		return nil
+	}
+
+	entry := strconv.FormatInt(d.clock.Now().UnixNano(), 10) + " " + ID + "\n"
+
+	if err := d.fs.AppendFile(d.orderLogPath(collection), []byte(entry), 0644); err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	d.creations[collection]++
+	compact := d.creations[collection]%orderCompactEvery == 0
+	d.mutex.Unlock()
+
+	if !compact {
+		return nil
+	}
+
+	files, err := d.listRecords(collection)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, file := range files {
+		if file.created > 0 {
+			buf.WriteString(strconv.FormatInt(file.created, 10) + " " + file.ID + "\n")
+		}
+	}
+
+	path := d.orderLogPath(collection)
+	if err := d.fs.WriteFile(path+".tmp", buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	return d.fs.Rename(path+".tmp", path)
+}
+
+// sortByCreation reorders records sorted by ID following the order log, ties
+// stay sorted by ID
+func (d *Driver) sortByCreation(collection string, records []record) error {
+	b, err := d.fs.ReadFile(d.orderLogPath(collection))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// a record deleted and written again counts as created the last time
+	created := make(map[string]int64)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		sep := strings.IndexByte(line, ' ')
+		if sep < 0 {
+			continue
+		}
+
+		nanos, err := strconv.ParseInt(line[:sep], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		created[line[sep+1:]] = nanos
+	}
+
+	for i := range records {
+		records[i].created = created[records[i].ID]
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].created < records[j].created
+	})
+
+	return nil
+}
@@ -0,0 +1,324 @@
+package jdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Parquet physical types, converted types and encodings, see parquet.thrift
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8 = 0
+	parquetJSON = 19
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetMagic starts and ends Parquet files
+const parquetMagic = "PAR1"
+
+// ExportParquet writes the records of the collection to w as a Parquet file,
+// with an optional column per property of the object schema laid out like by
+// ScanBatches: a nil schema means the one of the collection, or one inferred
+// from its records when it has none. Strings, integers, numbers and booleans
+// get the matching Parquet types, other properties are stored as JSON
+// strings. The file is uncompressed, with a single row group built in
+// memory. It returns how many records were written
+func (d *Driver) ExportParquet(collection string, w io.Writer, schema *Schema) (int, error) {
+	schema, err := d.TableSchema(collection, schema)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := newRecordBatch(schema)
+
+	err = d.ForEach(collection, func(ID string, raw []byte) error {
+		return batch.add(collection, ID, raw)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writeParquet(w, batch); err != nil {
+		return 0, err
+	}
+
+	return batch.Len(), nil
+}
+
+// parquetType returns the physical type of the column
+func parquetType(c *Column) int32 {
+	switch c.Type {
+	case "integer":
+		return parquetInt64
+	case "number":
+		return parquetDouble
+	case "boolean":
+		return parquetBoolean
+	default:
+		return parquetByteArray
+	}
+}
+
+// parquetPage returns the data page of the column: its definition levels,
+// as a length prefixed bit-packed run, followed by its plain encoded values
+func parquetPage(c *Column) []byte {
+	var page bytes.Buffer
+
+	levels := bitPack(c.Valid)
+
+	var header [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[:], uint64(len(levels))<<1|1)
+
+	binary.Write(&page, binary.LittleEndian, uint32(n+len(levels)))
+	page.Write(header[:n])
+	page.Write(levels)
+
+	var bools []bool
+
+	for i, valid := range c.Valid {
+		if !valid {
+			continue
+		}
+
+		switch c.Type {
+		case "integer":
+			binary.Write(&page, binary.LittleEndian, c.Ints[i])
+		case "number":
+			binary.Write(&page, binary.LittleEndian, math.Float64bits(c.Floats[i]))
+		case "boolean":
+			bools = append(bools, c.Bools[i])
+		default:
+			binary.Write(&page, binary.LittleEndian, uint32(len(c.Strings[i])))
+			page.WriteString(c.Strings[i])
+		}
+	}
+
+	if c.Type == "boolean" {
+		page.Write(bitPack(bools))
+	}
+
+	return page.Bytes()
+}
+
+// bitPack packs the bits least significant first, padding the last byte
+func bitPack(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return packed
+}
+
+// writeParquet writes the batch as a file holding a row group of a data page
+// per column, then the file metadata
+func writeParquet(w io.Writer, batch *RecordBatch) error {
+	bw := bufio.NewWriter(w)
+	columns, rows := batch.Columns, batch.Len()
+
+	bw.WriteString(parquetMagic)
+	offset := int64(len(parquetMagic))
+
+	chunks := make([]struct{ offset, size int64 }, len(columns))
+
+	for i, c := range columns {
+		data := parquetPage(c)
+
+		var header thriftWriter
+		header.begin()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.structField(5)
+		header.i32(1, int32(rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		bw.Write(header.buf.Bytes())
+		bw.Write(data)
+
+		size := int64(header.buf.Len() + len(data))
+		chunks[i].offset, chunks[i].size = offset, size
+		offset += size
+	}
+
+	var meta thriftWriter
+	meta.begin()
+	meta.i32(1, 1)
+
+	meta.list(2, thriftStruct, len(columns)+1)
+	meta.begin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.end()
+
+	for _, c := range columns {
+		meta.begin()
+		meta.i32(1, parquetType(c))
+		meta.i32(3, 1) // OPTIONAL
+		meta.binary(4, c.Name)
+
+		switch c.Type {
+		case "string":
+			meta.i32(6, parquetUTF8)
+			meta.structField(10)
+			meta.structField(1) // STRING
+			meta.end()
+			meta.end()
+		case "json":
+			meta.i32(6, parquetJSON)
+			meta.structField(10)
+			meta.structField(12) // JSON
+			meta.end()
+			meta.end()
+		}
+
+		meta.end()
+	}
+
+	meta.i64(3, int64(rows))
+
+	meta.list(4, thriftStruct, 1)
+	meta.begin()
+	meta.list(1, thriftStruct, len(columns))
+
+	total := int64(0)
+
+	for i, c := range columns {
+		meta.begin()
+		meta.i64(2, chunks[i].offset)
+		meta.structField(3)
+		meta.i32(1, parquetType(c))
+		meta.list(2, thriftI32, 2)
+		meta.varint(parquetPlain)
+		meta.varint(parquetRLE)
+		meta.list(3, thriftBinary, 1)
+		meta.str(c.Name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(rows))
+		meta.i64(6, chunks[i].size)
+		meta.i64(7, chunks[i].size)
+		meta.i64(9, chunks[i].offset)
+		meta.end()
+		meta.end()
+
+		total += chunks[i].size
+	}
+
+	meta.i64(2, total)
+	meta.i64(3, int64(rows))
+	meta.end()
+
+	meta.binary(6, "jdb")
+	meta.end()
+
+	bw.Write(meta.buf.Bytes())
+	binary.Write(bw, binary.LittleEndian, uint32(meta.buf.Len()))
+	bw.WriteString(parquetMagic)
+
+	return bw.Flush()
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol Parquet uses
+// for its metadata. Structs are written between begin and end, their fields
+// in increasing order
+type thriftWriter struct {
+	buf bytes.Buffer
+
+	// fields holds the last field written of every struct being written
+	fields []int16
+}
+
+func (t *thriftWriter) begin() {
+	t.fields = append(t.fields, 0)
+}
+
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.fields = t.fields[:len(t.fields)-1]
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.fields[len(t.fields)-1]
+
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+
+	*last = id
+}
+
+// varint writes a zigzag encoded integer
+func (t *thriftWriter) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+func (t *thriftWriter) str(s string) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], uint64(len(s)))])
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.str(s)
+}
+
+// structField starts a struct field, to be closed with end
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// list starts a list field, its n elements being written next: structs
+// between begin and end, integers with varint and strings with str
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+
+	t.buf.WriteByte(0xf0 | elem)
+
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], uint64(n))])
+}
@@ -0,0 +1,171 @@
+package jdb
+
+import (
+	"regexp"
+	"strconv"
+)
+
+type (
+	// PIIKind is a kind of personal data Scan looks for
+	PIIKind string
+
+	// PIIFinding is a value Scan found likely holding personal data
+	PIIFinding struct {
+		Collection string
+		ID         string
+
+		// Pointer is the JSON pointer of the value in the record
+		Pointer string
+
+		Kind PIIKind
+	}
+)
+
+// The kinds of personal data Scan reports
+const (
+	PIIEmail PIIKind = "email"
+	PIIPhone PIIKind = "phone"
+	PIICard  PIIKind = "card"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\(?[0-9][0-9 ().-]{5,}[0-9]`)
+	cardPattern  = regexp.MustCompile(`[0-9](?:[ -]?[0-9]){12,18}`)
+)
+
+// Scan walks the records of the collections, every collection when none is
+// given, and reports the values likely holding personal data: emails, phone
+// numbers and payment card numbers passing the Luhn check. It's a heuristic
+// meant to map where personal data lives, expect false positives
+func (d *Driver) Scan(collections ...string) ([]PIIFinding, error) {
+	if len(collections) == 0 {
+		var err error
+		if collections, err = d.collections(); err != nil {
+			return nil, err
+		}
+	}
+
+	var findings []PIIFinding
+
+	for _, c := range collections {
+		found, err := d.scanCollection(c)
+		if err != nil {
+			return nil, err
+		}
+
+		findings = append(findings, found...)
+	}
+
+	return findings, nil
+}
+
+func (d *Driver) scanCollection(collection string) ([]PIIFinding, error) {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.listRecords(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []PIIFinding
+
+	for _, file := range files {
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			v, err := decodeJSON(b)
+			if err != nil {
+				return err
+			}
+
+			scanValue("", v, func(pointer string, kind PIIKind) {
+				findings = append(findings, PIIFinding{Collection: collection, ID: file.ID, Pointer: pointer, Kind: kind})
+			})
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return findings, nil
+}
+
+func scanValue(pointer string, v interface{}, report func(string, PIIKind)) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			scanValue(pointer+"/"+escapePointer(k), v[k], report)
+		}
+	case []interface{}:
+		for i, e := range v {
+			scanValue(pointer+"/"+strconv.Itoa(i), e, report)
+		}
+	case string:
+		scanString(pointer, v, report)
+	case interface{ String() string }:
+		scanString(pointer, v.String(), report)
+	}
+}
+
+func scanString(pointer, s string, report func(string, PIIKind)) {
+	if emailPattern.MatchString(s) {
+		report(pointer, PIIEmail)
+	}
+
+	card := false
+	for _, m := range cardPattern.FindAllString(s, -1) {
+		if luhn(m) {
+			card = true
+			report(pointer, PIICard)
+			break
+		}
+	}
+
+	if card {
+		return
+	}
+
+	for _, m := range phonePattern.FindAllString(s, -1) {
+		if n := countDigits(m); n >= 7 && n <= 15 {
+			report(pointer, PIIPhone)
+			break
+		}
+	}
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum
+func luhn(s string) bool {
+	sum, double := 0, false
+
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+
+		n := int(s[i] - '0')
+		if double {
+			if n *= 2; n > 9 {
+				n -= 9
+			}
+		}
+
+		sum += n
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+
+	return n
+}
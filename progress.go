@@ -0,0 +1,56 @@
+package jdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Progress reports how far a long operation is, see Options.OnProgress
+type Progress struct {
+	// Op is the operation: "backup", "clone", "import", "migrate",
+	// "rebuild-index", "restore", "rotate-key" or "verify"
+	Op string
+
+	// Collection is the collection being processed, if any
+	Collection string
+
+	// Done counts the records processed so far by the operation
+	Done int
+
+	// Total is how many records the operation will process, -1 when it
+	// isn't known in advance, e.g. for streams
+	Total int
+}
+
+// progress reports to Options.OnProgress
+func (d *Driver) progress(p Progress) {
+	if d.onProgress != nil {
+		d.onProgress(p)
+	}
+}
+
+// countRecords estimates how many records the collections hold in the data
+// directory, without locking them
+func (d *Driver) countRecords(collections []string) (int, error) {
+	total := 0
+
+	for _, c := range collections {
+		files, err := d.fs.ReadDir(filepath.Join(d.dir, c))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		for _, file := range files {
+			if strings.HasSuffix(file.Name(), ".json") && file.Mode().IsRegular() {
+				total++
+			}
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,290 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// queueDir is the reserved collection prefix queues are stored under
+const queueDir = "_queue"
+
+// Message is a queued value handed out by Dequeue
+type Message struct {
+	ID         string          `json:"id"`
+	Body       json.RawMessage `json:"body"`
+	Attempts   int             `json:"attempts"`
+	EnqueuedAt time.Time       `json:"enqueuedAt"`
+	VisibleAt  time.Time       `json:"visibleAt"`
+
+	// Receipt is the proof of the last Dequeue, Ack and Nack take it so
+	// a consumer whose visibility timeout ran out can't settle the message
+	// for the one it was handed out to next
+	Receipt string `json:"receipt,omitempty"`
+}
+
+// Decode unmarshals the body of the message into v
+func (m *Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Body, v)
+}
+
+// queueCollection returns the collection the queue is stored in, queues
+// being named like collections
+func queueCollection(queue string) (string, error) {
+	if err := ValidateCollection(queue); err != nil {
+		return "", err
+	}
+
+	return path.Join(queueDir, queue), nil
+}
+
+// Enqueue adds v at the end of the queue, returning the ID of the message
+func (d *Driver) Enqueue(queue string, v interface{}) (string, error) {
+	if queue == "" {
+		return "", fmt.Errorf("missing queue, no place to save data")
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	now := d.clock.Now()
+
+	// IDs sort by enqueue time so the collection order is the queue order
+	msg := Message{
+		ID:         fmt.Sprintf("%020d-%s", now.UnixNano(), d.newID()),
+		Body:       body,
+		EnqueuedAt: now,
+		VisibleAt:  now,
+	}
+
+	collection, err := queueCollection(queue)
+	if err != nil {
+		return "", err
+	}
+
+	done, err := d.admitLocalStore(collection, msg.ID)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	unlock, err := d.lockShared(collection)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	return d.writeLocked(collection, msg.ID, msg)
+}
+
+// Dequeue hands out the oldest visible message of the queue and hides it for
+// the visibility timeout, after which it's handed out again unless it was
+// acknowledged with Ack. Every hand out gets a new Receipt, and messages
+// this Driver hid are skipped without being read. It returns ErrQueueEmpty
+// when no message is visible
+func (d *Driver) Dequeue(queue string, visibility time.Duration) (*Message, error) {
+	if queue == "" {
+		return nil, fmt.Errorf("missing queue, no place to get data")
+	}
+
+	collection, err := queueCollection(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := d.admitLocalStore(collection)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	unlock, err := d.lockShared(collection)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	files, err := d.listRecords(collection)
+	if os.IsNotExist(err) {
+		return nil, ErrQueueEmpty
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.clock.Now()
+	hidden := d.hiddenMessages(collection)
+
+	for _, file := range files {
+		if hidden.until(file.ID).After(now) {
+			continue
+		}
+
+		var msg Message
+
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			return json.Unmarshal(b, &msg)
+		})
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("decoding message %s of %s: %w", file.ID, queue, err)
+		}
+
+		if msg.VisibleAt.After(now) {
+			hidden.hide(msg.ID, msg.VisibleAt)
+			continue
+		}
+
+		msg.Attempts++
+		msg.VisibleAt = now.Add(visibility)
+		msg.Receipt = newUUID()
+
+		if _, err := d.writeLocked(collection, msg.ID, msg); err != nil {
+			return nil, err
+		}
+
+		hidden.hide(msg.ID, msg.VisibleAt)
+
+		return &msg, nil
+	}
+
+	return nil, ErrQueueEmpty
+}
+
+// hiddenMessages remembers until when the messages of a queue are hidden,
+// so Dequeue skips the ones handed out without reading them again. Messages
+// another process nacks stay hidden from this Driver until then
+type hiddenMessages struct {
+	mutex sync.Mutex
+	at    map[string]time.Time
+}
+
+func (d *Driver) hiddenMessages(collection string) *hiddenMessages {
+	h, _ := d.hidden.LoadOrStore(collection, &hiddenMessages{at: make(map[string]time.Time)})
+	return h.(*hiddenMessages)
+}
+
+// until returns when the message is visible again, zero when it's unknown
+func (h *hiddenMessages) until(ID string) time.Time {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.at[ID]
+}
+
+func (h *hiddenMessages) hide(ID string, until time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.at[ID] = until
+}
+
+func (h *hiddenMessages) forget(ID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.at, ID)
+}
+
+// settle loads a message handed out with the receipt, callers must hold the
+// shared lock of the queue
+func (d *Driver) settle(collection, ID, receipt string) (Message, error) {
+	var msg Message
+
+	err := d.load(collection, ID, func(b []byte) error {
+		return json.Unmarshal(b, &msg)
+	})
+	if os.IsNotExist(err) {
+		return msg, fmt.Errorf("message %s: %w", ID, ErrStaleReceipt)
+	}
+
+	if err != nil {
+		return msg, err
+	}
+
+	if receipt == "" || msg.Receipt != receipt {
+		return msg, fmt.Errorf("message %s was handed out again: %w", ID, ErrStaleReceipt)
+	}
+
+	return msg, nil
+}
+
+// Ack removes a message handed out by Dequeue from the queue for good. It
+// takes the Receipt of the message and fails with ErrStaleReceipt when it
+// was handed out again since
+func (d *Driver) Ack(queue, ID, receipt string) error {
+	collection, err := queueCollection(queue)
+	if err != nil {
+		return err
+	}
+
+	done, err := d.admitLocalStore(collection, ID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	unlock, err := d.lockShared(collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := d.settle(collection, ID, receipt); err != nil {
+		return err
+	}
+
+	if err := d.removeRecord(collection, ID); err != nil {
+		return err
+	}
+
+	d.hiddenMessages(collection).forget(ID)
+
+	return nil
+}
+
+// Nack makes a message handed out by Dequeue visible again right away, it
+// takes the Receipt of the message like Ack
+func (d *Driver) Nack(queue, ID, receipt string) error {
+	collection, err := queueCollection(queue)
+	if err != nil {
+		return err
+	}
+
+	done, err := d.admitLocalStore(collection, ID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	unlock, err := d.lockShared(collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	msg, err := d.settle(collection, ID, receipt)
+	if err != nil {
+		return err
+	}
+
+	msg.VisibleAt = d.clock.Now()
+	msg.Receipt = ""
+
+	if _, err := d.writeLocked(collection, ID, msg); err != nil {
+		return err
+	}
+
+	d.hiddenMessages(collection).forget(ID)
+
+	return nil
+}
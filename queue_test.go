@@ -0,0 +1,183 @@
+package jdb_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestDequeueInOrder(t *testing.T) {
+	clock := jdbtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := jdbtest.New(t, jdb.WithClock(clock))
+
+	for _, job := range []string{"a", "b", "c"} {
+		if _, err := d.Enqueue("jobs", job); err != nil {
+			t.Fatalf("Enqueue %s: %s", job, err)
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	for _, want := range []string{"a", "b", "c"} {
+		msg, err := d.Dequeue("jobs", time.Minute)
+		if err != nil {
+			t.Fatalf("Dequeue: %s", err)
+		}
+
+		var got string
+		if err := msg.Decode(&got); err != nil || got != want {
+			t.Errorf("Dequeue = %q, %v, want %q", got, err, want)
+		}
+
+		if msg.Attempts != 1 || msg.Receipt == "" {
+			t.Errorf("message %s has %d attempts, receipt %q", msg.ID, msg.Attempts, msg.Receipt)
+		}
+	}
+
+	if _, err := d.Dequeue("jobs", time.Minute); !errors.Is(err, jdb.ErrQueueEmpty) {
+		t.Errorf("Dequeue of hidden messages = %v, want ErrQueueEmpty", err)
+	}
+}
+
+func TestDequeueRedeliversAfterVisibility(t *testing.T) {
+	clock := jdbtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := jdbtest.New(t, jdb.WithClock(clock))
+
+	if _, err := d.Enqueue("jobs", "job"); err != nil {
+		t.Fatalf("Enqueue: %s", err)
+	}
+
+	first, err := d.Dequeue("jobs", time.Minute)
+	if err != nil {
+		t.Fatalf("Dequeue: %s", err)
+	}
+
+	clock.Advance(59 * time.Second)
+
+	if _, err := d.Dequeue("jobs", time.Minute); !errors.Is(err, jdb.ErrQueueEmpty) {
+		t.Fatalf("Dequeue before the timeout = %v, want ErrQueueEmpty", err)
+	}
+
+	clock.Advance(time.Second)
+
+	second, err := d.Dequeue("jobs", time.Minute)
+	if err != nil {
+		t.Fatalf("Dequeue after the timeout: %s", err)
+	}
+
+	if second.ID != first.ID || second.Attempts != 2 || second.Receipt == first.Receipt {
+		t.Errorf("redelivered %+v after %+v", second, first)
+	}
+}
+
+func TestLateAckKeepsRedeliveredMessage(t *testing.T) {
+	clock := jdbtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := jdbtest.New(t, jdb.WithClock(clock))
+
+	if _, err := d.Enqueue("jobs", "job"); err != nil {
+		t.Fatalf("Enqueue: %s", err)
+	}
+
+	slow, err := d.Dequeue("jobs", time.Minute)
+	if err != nil {
+		t.Fatalf("Dequeue: %s", err)
+	}
+
+	clock.Advance(time.Minute)
+
+	next, err := d.Dequeue("jobs", time.Minute)
+	if err != nil {
+		t.Fatalf("Dequeue after the timeout: %s", err)
+	}
+
+	if err := d.Ack("jobs", slow.ID, slow.Receipt); !errors.Is(err, jdb.ErrStaleReceipt) {
+		t.Fatalf("late Ack = %v, want ErrStaleReceipt", err)
+	}
+
+	if err := d.Nack("jobs", slow.ID, slow.Receipt); !errors.Is(err, jdb.ErrStaleReceipt) {
+		t.Fatalf("late Nack = %v, want ErrStaleReceipt", err)
+	}
+
+	if _, err := d.Dequeue("jobs", time.Minute); !errors.Is(err, jdb.ErrQueueEmpty) {
+		t.Fatalf("Dequeue after the late Nack = %v, want ErrQueueEmpty", err)
+	}
+
+	if err := d.Ack("jobs", next.ID, next.Receipt); err != nil {
+		t.Fatalf("Ack: %s", err)
+	}
+
+	if err := d.Ack("jobs", next.ID, next.Receipt); !errors.Is(err, jdb.ErrStaleReceipt) {
+		t.Errorf("second Ack = %v, want ErrStaleReceipt", err)
+	}
+
+	clock.Advance(time.Hour)
+
+	if _, err := d.Dequeue("jobs", time.Minute); !errors.Is(err, jdb.ErrQueueEmpty) {
+		t.Errorf("Dequeue after Ack = %v, want ErrQueueEmpty", err)
+	}
+}
+
+func TestNackMakesMessageVisible(t *testing.T) {
+	clock := jdbtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := jdbtest.New(t, jdb.WithClock(clock))
+
+	if _, err := d.Enqueue("jobs", "job"); err != nil {
+		t.Fatalf("Enqueue: %s", err)
+	}
+
+	msg, err := d.Dequeue("jobs", time.Hour)
+	if err != nil {
+		t.Fatalf("Dequeue: %s", err)
+	}
+
+	if err := d.Nack("jobs", msg.ID, ""); !errors.Is(err, jdb.ErrStaleReceipt) {
+		t.Fatalf("Nack without a receipt = %v, want ErrStaleReceipt", err)
+	}
+
+	if err := d.Nack("jobs", msg.ID, msg.Receipt); err != nil {
+		t.Fatalf("Nack: %s", err)
+	}
+
+	again, err := d.Dequeue("jobs", time.Hour)
+	if err != nil {
+		t.Fatalf("Dequeue after Nack: %s", err)
+	}
+
+	if again.ID != msg.ID || again.Attempts != 2 {
+		t.Errorf("Dequeue after Nack = %+v", again)
+	}
+}
+
+func TestDequeueSkipsHiddenMessages(t *testing.T) {
+	clock := jdbtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	dir := t.TempDir()
+	d := jdbtest.Open(t, dir, jdb.WithClock(clock))
+
+	for i := 0; i < 50; i++ {
+		if _, err := d.Enqueue("jobs", i); err != nil {
+			t.Fatalf("Enqueue: %s", err)
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	for i := 0; i < 50; i++ {
+		msg, err := d.Dequeue("jobs", time.Minute)
+		if err != nil {
+			t.Fatalf("Dequeue %d: %s", i, err)
+		}
+
+		var got int
+		if err := msg.Decode(&got); err != nil || got != i {
+			t.Fatalf("Dequeue = %d, %v, want %d", got, err, i)
+		}
+	}
+
+	// a Driver without the hidden messages in memory reads them to skip them
+	other := jdbtest.Open(t, dir, jdb.WithClock(clock))
+
+	if _, err := other.Dequeue("jobs", time.Minute); !errors.Is(err, jdb.ErrQueueEmpty) {
+		t.Errorf("Dequeue from another Driver = %v, want ErrQueueEmpty", err)
+	}
+}
@@ -0,0 +1,162 @@
+package jdb
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket letting rate writes through per second with
+// bursts of up to burst writes. Tokens are reserved ahead, going negative,
+// so each writer waits once for its turn
+type limiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate float64, burst int, now time.Time) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+
+		l.last = now
+	}
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// SetWriteRate limits writes to the collection to rate per second with
+// bursts of up to burst writes, on top of Options.WriteRate. Writers over the
+// rate wait for their turn, a zero rate removes the limit. The rate of every
+// write is Options.WriteRate, an empty collection is ignored
+func (d *Driver) SetWriteRate(collection string, rate float64, burst int) {
+	if collection == "" {
+		d.log.Warn("not limiting the write rate of an unnamed collection, see Options.WriteRate")
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if rate <= 0 {
+		delete(d.limiters, collection)
+		return
+	}
+
+	d.limiters[collection] = newLimiter(rate, burst, d.clock.Now())
+}
+
+// admit checks the names of the collection and of the records a write is
+// about, see validateRecord, flushes the writes held for the collection, see
+// SetCoalesce, then reserves the write like reserve
+func (d *Driver) admit(collection string, IDs ...string) (func(), error) {
+	if err := ValidateCollection(collection); err != nil {
+		return nil, err
+	}
+
+	return d.admitStore(collection, IDs...)
+}
+
+// admitStore is admit of a write that may be to one of the reserved
+// collections of the Driver, like the KV, see validateStored
+func (d *Driver) admitStore(collection string, IDs ...string) (func(), error) {
+	for _, ID := range IDs {
+		if err := validateStored(collection, ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateStoredCollection(collection); err != nil {
+		return nil, err
+	}
+
+	d.coalesced.flush(d, collection)
+
+	return d.reserve(collection)
+}
+
+// reserve waits until the write rate of the Driver and of the collection let
+// a write through, counting it as pending until done is called. It fails with
+// ErrOverloaded when Options.MaxPendingWrites writes are already pending, and
+// with ErrFrozen when writes are frozen, see Freeze
+func (d *Driver) reserve(collection string) (func(), error) {
+	d.mutex.Lock()
+
+	done, err := d.hold("writing to " + collection)
+	if err != nil {
+		d.mutex.Unlock()
+		return nil, err
+	}
+
+	limiters := []*limiter{d.writeLimiter, d.limiters[collection]}
+
+	d.mutex.Unlock()
+
+	var wait time.Duration
+	for _, l := range limiters {
+		if l == nil {
+			continue
+		}
+
+		if w := l.reserve(d.clock.Now()); w > wait {
+			wait = w
+		}
+	}
+
+	if wait > 0 {
+		<-d.after(wait)
+	}
+
+	return done, nil
+}
+
+// hold counts what's about to change the data directory as pending once
+// writes are thawed, until done is called, failing like reserve. It's called
+// holding d.mutex and returns holding it
+func (d *Driver) hold(what string) (func(), error) {
+	if err := d.awaitThaw(what); err != nil {
+		return nil, err
+	}
+
+	if d.maxPending > 0 && d.pending >= d.maxPending {
+		return nil, fmt.Errorf("%d writes pending: %w", d.pending, ErrOverloaded)
+	}
+
+	d.pending++
+
+	done := func() {
+		d.mutex.Lock()
+		d.pending--
+
+		if d.pending == 0 && d.idle != nil {
+			close(d.idle)
+			d.idle = nil
+		}
+
+		d.mutex.Unlock()
+	}
+
+	return done, nil
+}
@@ -0,0 +1,74 @@
+package jdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestUnnamedCollectionRateKeepsTheGlobalOne(t *testing.T) {
+	clock := jdbtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := jdbtest.New(t, jdb.WithClock(clock), jdb.WithWriteRate(20, 1))
+	d.SetWriteRate("", 1e6, 1000)
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		jdbtest.Seed(t, d, "users", 3, jdbtest.Sequence("u", 1))
+	}()
+
+	// the first write uses the burst, the next two wait 50ms each
+	for i := 0; i < 2; i++ {
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		clock.Advance(49 * time.Millisecond)
+
+		if clock.Waiters() != 1 {
+			t.Fatalf("write %d went through before 50ms at 20 per second", i+2)
+		}
+
+		clock.Advance(time.Millisecond)
+	}
+
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes still waiting once the rate let them through")
+	}
+}
+
+func TestCollectionRateLimitsItsWritesOnly(t *testing.T) {
+	clock := jdbtest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := jdbtest.New(t, jdb.WithClock(clock))
+	d.SetWriteRate("users", 1, 1)
+
+	jdbtest.Seed(t, d, "users", 1, jdbtest.Sequence("u", 1))
+
+	// other collections aren't limited, so they don't wait on the clock
+	jdbtest.Seed(t, d, "orders", 10, jdbtest.Sequence("o", 1))
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+
+		if _, err := d.Write("users", "u-9", 9); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(time.Second)
+
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("write still waiting a second later at 1 per second")
+	}
+}
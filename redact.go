@@ -0,0 +1,183 @@
+package jdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// Redactor returns what a field is shown as in redacted reads, returning
+	// false drops the field
+	Redactor func(value interface{}) (interface{}, bool)
+
+	// Redacted is a read-only view of the database with the redactions set
+	// by SetRedaction applied, for support tooling and exports that must
+	// never see sensitive values
+	Redacted struct {
+		d *Driver
+	}
+
+	redaction struct {
+		field string
+		fn    Redactor
+	}
+)
+
+// SetRedaction makes redacted reads of the collection show the field, a
+// dotted path, through fn. Setting a field again replaces its Redactor and a
+// nil one removes it
+func (d *Driver) SetRedaction(collection, field string, fn Redactor) {
+	d.configure(collection, func(c *collectionConfig) {
+		redactions := c.redactions[:0:0]
+		for _, r := range c.redactions {
+			if r.field != field {
+				redactions = append(redactions, r)
+			}
+		}
+
+		if fn != nil {
+			redactions = append(redactions, redaction{field: field, fn: fn})
+		}
+
+		c.redactions = redactions
+	})
+}
+
+// Redacted returns the redacted view of the Driver
+func (d *Driver) Redacted() *Redacted {
+	return &Redacted{d: d}
+}
+
+// Read returns a record of the collection redacted
+func (r *Redacted) Read(collection, identifier string) (string, error) {
+	data, err := r.d.Read(collection, identifier)
+	if err != nil {
+		return "", err
+	}
+
+	return r.redact(collection, data)
+}
+
+// ReadAll returns every record of the collection redacted
+func (r *Redacted) ReadAll(collection string) ([]string, error) {
+	records, err := r.d.ReadAll(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, data := range records {
+		if records[i], err = r.redact(collection, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// ReadInto decodes a record of the collection redacted into v
+func (r *Redacted) ReadInto(collection, identifier string, v interface{}) error {
+	data, err := r.Read(collection, identifier)
+	if err != nil {
+		return err
+	}
+
+	return r.d.decode(collection, identifier, []byte(data), v)
+}
+
+func (r *Redacted) redact(collection, data string) (string, error) {
+	redactions := r.d.config(collection).redactions
+	if len(redactions) == 0 {
+		return data, nil
+	}
+
+	v, err := decodeJSON([]byte(data))
+	if err != nil {
+		return "", err
+	}
+
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		return data, nil
+	}
+
+	for _, red := range redactions {
+		value, ok := getField(doc, red.field)
+		if !ok {
+			continue
+		}
+
+		if value, ok = red.fn(value); ok {
+			err = setField(doc, red.field, value)
+		} else {
+			err = dropField(doc, red.field)
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("redacting %s: %w", red.field, err)
+		}
+	}
+
+	b, err := r.d.encode(doc)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// dropField removes a dotted field path
+func dropField(doc map[string]interface{}, field string) error {
+	parts := strings.Split(field, ".")
+	m := doc
+
+	if len(parts) > 1 {
+		parent := strings.Join(parts[:len(parts)-1], ".")
+
+		v, _ := getField(doc, parent)
+		if m, _ = v.(map[string]interface{}); m == nil {
+			return fmt.Errorf("%s is not an object", parent)
+		}
+	}
+
+	delete(m, parts[len(parts)-1])
+	return nil
+}
+
+// Drop is a Redactor removing the field
+func Drop(interface{}) (interface{}, bool) {
+	return nil, false
+}
+
+// Mask returns a Redactor replacing strings with asterisks but for their last
+// keep characters, other values are dropped
+func Mask(keep int) Redactor {
+	return func(value interface{}) (interface{}, bool) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+
+		runes := []rune(s)
+		for i := 0; i < len(runes)-keep; i++ {
+			runes[i] = '*'
+		}
+
+		return string(runes), true
+	}
+}
+
+// MaskEmail is a Redactor masking the local part of email addresses but for
+// its first character, e.g. j***@example.com. Other values are masked whole
+func MaskEmail(value interface{}) (interface{}, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+
+	at := strings.LastIndex(s, "@")
+	if at < 1 {
+		return Mask(0)(s)
+	}
+
+	return s[:1] + strings.Repeat("*", at-1) + s[at:], true
+}
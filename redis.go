@@ -0,0 +1,101 @@
+package jdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// RedisOptions configures ExportRedis
+type RedisOptions struct {
+	// Prefix is put before the IDs to make the keys, defaults to the
+	// collection followed by a colon
+	Prefix string
+
+	// TTL makes the keys expire, zero keeps them. Records with a TTL of
+	// their own expire along with it instead
+	TTL time.Duration
+}
+
+// ExportRedis writes the records of the collection to w as Redis SET
+// commands in the wire protocol, for `redis-cli --pipe` to load them into a
+// cache. The values are the records as compact JSON and records whose TTL ran
+// out are left out. It returns how many commands were written
+func (d *Driver) ExportRedis(collection string, w io.Writer, opts RedisOptions) (int, error) {
+	if opts.Prefix == "" {
+		opts.Prefix = collection + ":"
+	}
+
+	found, err := d.expiries(filepath.Join(d.dir, ttlDir, collection), collection)
+	if err != nil {
+		return 0, err
+	}
+
+	expiresAt := make(map[string]time.Time, len(found))
+	for _, e := range found {
+		if e.collection == collection {
+			expiresAt[e.ID] = e.at
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	now := d.clock.Now()
+	count := 0
+
+	var value bytes.Buffer
+
+	err = d.ForEach(collection, func(ID string, raw []byte) error {
+		args := []string{"SET", opts.Prefix + ID, ""}
+
+		ttl := opts.TTL
+		if at, ok := expiresAt[ID]; ok {
+			if ttl = at.Sub(now); ttl <= 0 {
+				return nil
+			}
+		}
+
+		if ttl > 0 {
+			args = append(args, "PX", strconv.FormatInt(int64((ttl+time.Millisecond-1)/time.Millisecond), 10))
+		}
+
+		value.Reset()
+		if err := json.Compact(&value, raw); err != nil {
+			return fmt.Errorf("%s/%s: %w", collection, ID, err)
+		}
+
+		args[2] = value.String()
+
+		if err := writeRESP(bw, args); err != nil {
+			return err
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, bw.Flush()
+}
+
+// writeRESP writes a command as an array of bulk strings
+func writeRESP(w *bufio.Writer, args []string) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n", len(arg))
+		w.WriteString(arg)
+
+		if _, err := w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package jdb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Template is a template Render can execute, like the ones of text/template
+// and html/template
+type Template interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// Render executes the template with the record as data and returns the
+// output, e.g. to make a report or an email out of it. Objects are maps and
+// numbers are json.Number, so they print exactly as stored
+func (d *Driver) Render(collection, identifier string, tmpl Template) (string, error) {
+	var buf bytes.Buffer
+
+	if err := d.RenderTo(&buf, collection, identifier, tmpl); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// RenderTo is Render writing the output to w
+func (d *Driver) RenderTo(w io.Writer, collection, identifier string, tmpl Template) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if identifier == "" {
+		return fmt.Errorf("missing ID, no identifier to get data")
+	}
+
+	if err := validateRecord(collection, identifier); err != nil {
+		return err
+	}
+
+	var doc interface{}
+
+	err := d.readRecord(collection, identifier, func(b []byte) error {
+		var err error
+		doc, err = decodeJSON(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tmpl.Execute(w, doc); err != nil {
+		return fmt.Errorf("rendering %s/%s: %w", collection, identifier, err)
+	}
+
+	return nil
+}
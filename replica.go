@@ -0,0 +1,99 @@
+package jdb
+
+import (
+	"os"
+	"sync"
+)
+
+// readOnlyStorage fails every change to the wrapped Storage with ErrReadOnly
+type readOnlyStorage struct {
+	Storage
+}
+
+func (readOnlyStorage) WriteFile(name string, _ []byte, _ os.FileMode) error {
+	return &os.PathError{Op: "write", Path: name, Err: ErrReadOnly}
+}
+
+func (readOnlyStorage) AppendFile(name string, _ []byte, _ os.FileMode) error {
+	return &os.PathError{Op: "append", Path: name, Err: ErrReadOnly}
+}
+
+func (readOnlyStorage) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrReadOnly}
+}
+
+func (readOnlyStorage) Remove(name string) error {
+	return &os.PathError{Op: "remove", Path: name, Err: ErrReadOnly}
+}
+
+func (readOnlyStorage) RemoveAll(path string) error {
+	return &os.PathError{Op: "remove", Path: path, Err: ErrReadOnly}
+}
+
+func (readOnlyStorage) MkdirAll(path string, _ os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: path, Err: ErrReadOnly}
+}
+
+// Replica is a read-only Driver over a snapshot of a database, e.g. one made
+// by CloneTo on the primary, that can be swapped for a newer snapshot while
+// readers use it. It's meant to move reporting reads off the primary
+type Replica struct {
+	mutex sync.RWMutex
+	d     *Driver
+	opts  Options
+}
+
+// OpenReplica opens the snapshot at dir read-only, the options are used for
+// every snapshot the Replica opens with ReadOnly forced and no TTL sweeps
+func OpenReplica(dir string, options ...Option) (*Replica, error) {
+	r := &Replica{opts: buildOptions(options)}
+
+	r.opts.ReadOnly = true
+	r.opts.TTLSweepInterval = 0
+
+	d, err := r.open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r.d = d
+
+	return r, nil
+}
+
+func (r *Replica) open(dir string) (*Driver, error) {
+	opts := r.opts
+
+	return New(dir, &opts)
+}
+
+// Driver returns the Driver over the current snapshot, readers should get it
+// for each unit of work so they pick up swaps
+func (r *Replica) Driver() *Driver {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.d
+}
+
+// Swap atomically switches the Replica to the snapshot at dir and closes the
+// Driver of the previous one. Reads already running on it finish against the
+// previous snapshot, which must be kept until they're done
+func (r *Replica) Swap(dir string) error {
+	d, err := r.open(dir)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	prev := r.d
+	r.d = d
+	r.mutex.Unlock()
+
+	return prev.Close()
+}
+
+// Close closes the Driver of the current snapshot
+func (r *Replica) Close() error {
+	return r.Driver().Close()
+}
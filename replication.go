@@ -0,0 +1,172 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+type (
+	// Replicator is a replicated log, e.g. a raft cluster, writes go through
+	// when set in Options.Replicator. Replicate returns once the operation is
+	// committed, every node, this one included, applying committed
+	// operations to its Driver with ApplyReplicated. Package jdbraft is one
+	// on top of hashicorp/raft, its FSM applying log entries and using Backup
+	// and RestoreReplicated for snapshots.
+	//
+	// Write, Insert, Update, Delete, sessions, KV.Set and KV.Del are
+	// replicated. Every other change to the records fails with
+	// ErrNotReplicated rather than diverge from the other nodes: the writes
+	// like Increment, Expire, Take or Enqueue, and the changes the Driver
+	// makes on its own like sweeping expired records, purging the trash,
+	// archiving, migrating, rotating the key, quarantining, pruning blobs
+	// or changing engines. Options.TTLSweepInterval is ignored
+	Replicator interface {
+		Replicate(op ReplicatedOp) error
+	}
+
+	// ReplicatedOp is a write sent through a Replicator
+	ReplicatedOp struct {
+		Op         Op              `json:"op"`
+		Collection string          `json:"collection"`
+		ID         string          `json:"id"`
+		Data       json.RawMessage `json:"data,omitempty"`
+	}
+)
+
+// replicate encodes the write and sends it through the Replicator
+func (d *Driver) replicate(op Op, collection, ID string, v interface{}) error {
+	rop := ReplicatedOp{Op: op, Collection: collection, ID: ID}
+
+	if op == OpWrite {
+		b, err := d.encode(v)
+		if err != nil {
+			return err
+		}
+
+		rop.Data = b
+	}
+
+	if err := d.replicator.Replicate(rop); err != nil {
+		return fmt.Errorf("replicating %s/%s: %w", collection, ID, err)
+	}
+
+	return nil
+}
+
+// admitLocal is admit of a write applied to this node alone, refused with
+// ErrNotReplicated when writes go through a Replicator
+func (d *Driver) admitLocal(collection string, IDs ...string) (func(), error) {
+	if d.replicator != nil {
+		return nil, fmt.Errorf("writing to %s: %w", collection, ErrNotReplicated)
+	}
+
+	return d.admit(collection, IDs...)
+}
+
+// admitLocalStore is admitLocal of a write to one of the reserved
+// collections of the Driver, see admitStore
+func (d *Driver) admitLocalStore(collection string, IDs ...string) (func(), error) {
+	if d.replicator != nil {
+		return nil, fmt.Errorf("writing to %s: %w", collection, ErrNotReplicated)
+	}
+
+	return d.admitStore(collection, IDs...)
+}
+
+// admitMaintenance admits a change to the data directory that isn't a write
+// to one collection, like purging the trash, reserving it like reserve
+// without rate limiting it. It's refused with ErrNotReplicated when writes go
+// through a Replicator, like admitLocal. what names the change for errors
+func (d *Driver) admitMaintenance(what string) (func(), error) {
+	if d.replicator != nil {
+		return nil, fmt.Errorf("%s: %w", what, ErrNotReplicated)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.hold(what)
+}
+
+// ApplyReplicated applies a committed operation of the replicated log to
+// this node's records, bypassing the Replicator
+func (d *Driver) ApplyReplicated(op ReplicatedOp) error {
+	if op.Collection == "" || op.ID == "" {
+		return fmt.Errorf("replicated operation is missing its collection or ID")
+	}
+
+	if err := validateStored(op.Collection, op.ID); err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case OpWrite:
+		_, err := d.writeLocal(op.Collection, op.ID, op.Data)
+		return err
+	case OpDelete:
+		return d.deleteLocal(op.Collection, op.ID)
+	default:
+		return fmt.Errorf("unknown replicated operation %d", op.Op)
+	}
+}
+
+// RestoreReplicated restores a snapshot of the replicated log made by Backup
+// on this node, bypassing the Replicator like ApplyReplicated, e.g. in the
+// Restore of a raft FSM. The node ends up holding the snapshot alone: records
+// already stored are overwritten and the ones it doesn't hold are deleted
+func (d *Driver) RestoreReplicated(r io.Reader) (RestoreReport, error) {
+	seen := make(map[string]map[string]bool)
+
+	report, err := d.restore(r, RestoreOverwrite, seen)
+	if err != nil {
+		return report, err
+	}
+
+	collections, err := d.collections()
+	if err != nil {
+		return report, err
+	}
+
+	stores, err := d.stores()
+	if err != nil {
+		return report, err
+	}
+
+	for _, collection := range append(collections, stores...) {
+		files, err := d.listRecords(collection)
+		if err != nil && !os.IsNotExist(err) {
+			return report, err
+		}
+
+		archived, err := d.archivedIDs(collection)
+		if err != nil {
+			return report, err
+		}
+
+		IDs := archived
+		for _, file := range files {
+			IDs = append(IDs, file.ID)
+		}
+
+		for _, ID := range IDs {
+			if seen[collection][ID] {
+				continue
+			}
+
+			err := d.deleteLocal(collection, ID)
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			if err != nil {
+				return report, fmt.Errorf("deleting %s/%s missing from the snapshot: %w", collection, ID, err)
+			}
+
+			report.Deleted++
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,155 @@
+package jdb_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+// cluster is a Replicator applying every operation to its nodes at once
+type cluster struct {
+	nodes []*jdb.Driver
+}
+
+func (c *cluster) Replicate(op jdb.ReplicatedOp) error {
+	for _, d := range c.nodes {
+		if err := d.ApplyReplicated(op); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestReplicatedWrites(t *testing.T) {
+	c := &cluster{}
+	a := jdbtest.New(t, jdb.WithReplicator(c))
+	b := jdbtest.New(t, jdb.WithReplicator(c))
+	c.nodes = []*jdb.Driver{a, b}
+
+	jdbtest.Seed(t, a, "users", 2, jdbtest.Sequence("u", map[string]int{"n": 1}))
+
+	if err := a.Delete("users", "u-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.KV().Set("k", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.KV().Set("gone", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.KV().Del("gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := jdbtest.Snapshot(t, b, "users"), jdbtest.Snapshot(t, a, "users"); !bytes.Equal(got, want) {
+		t.Errorf("node b holds\n%s\nwant\n%s", got, want)
+	}
+
+	var k int
+	if err := b.KV().Get("k", &k); err != nil || k != 1 {
+		t.Errorf("node b KV k = %d, %v", k, err)
+	}
+
+	if err := b.KV().Get("gone", &k); !errors.Is(err, jdb.ErrNotFound) {
+		t.Errorf("node b still holds the deleted key: %v", err)
+	}
+}
+
+func TestLocalWritesRefusedWhenReplicated(t *testing.T) {
+	c := &cluster{}
+	d := jdbtest.New(t, jdb.WithReplicator(c))
+	c.nodes = []*jdb.Driver{d}
+
+	if _, err := d.Write("users", "u", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	ops := map[string]func() error{
+		"Increment": func() error { _, err := d.Increment("users", "u", "n", 1); return err },
+		"UpdateFn": func() error {
+			_, err := jdb.UpdateFn(d, "users", "u", func(v map[string]int) (map[string]int, error) { return v, nil })
+			return err
+		},
+		"Expire":   func() error { return d.Expire("users", "u", time.Hour) },
+		"Take":     func() error { _, err := d.Take("users", "u"); return err },
+		"Move":     func() error { return d.MoveRecord("users", "u", "archive") },
+		"Drop":     func() error { return d.DropCollection("users", true) },
+		"KV.Incr":  func() error { _, err := d.KV().Incr("n", 1); return err },
+		"Enqueue":  func() error { _, err := d.Enqueue("jobs", 1); return err },
+		"Restore":  func() error { _, err := d.Restore(&bytes.Buffer{}, jdb.RestoreOverwrite); return err },
+		"Undelete": func() error { return d.Undelete("users", "u") },
+
+		"SweepExpired":           func() error { _, err := d.SweepExpired(); return err },
+		"PurgeTrash":             func() error { _, err := d.PurgeTrash(); return err },
+		"Archive":                func() error { _, err := d.Archive("users", -time.Hour); return err },
+		"RotateKey":              func() error { return d.RotateKey(nil, fieldKey, nil) },
+		"Verify with quarantine": func() error { _, err := d.Verify(jdb.VerifyOptions{Quarantine: true}); return err },
+		"PruneBlobs":             func() error { _, err := d.PruneBlobs(); return err },
+		"SetEngine":              func() error { return d.SetEngine("users", jdb.EngineSegment, nil) },
+		"Migrate": func() error {
+			return d.Migrate(&jdb.MigrationPlan{}, jdb.Migration{Name: "m", Collection: "users", Up: func(string, []byte) (interface{}, error) { return nil, nil }})
+		},
+	}
+
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, jdb.ErrNotReplicated) {
+			t.Errorf("%s = %v, want ErrNotReplicated", name, err)
+		}
+	}
+
+	var backup bytes.Buffer
+	if _, err := d.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	node := jdbtest.New(t, jdb.WithReplicator(c))
+
+	if report, err := node.RestoreReplicated(&backup); err != nil || report.Written != 1 {
+		t.Errorf("restoring the snapshot = %+v, %v", report, err)
+	}
+}
+
+func TestRestoreReplicatedReplacesTheNode(t *testing.T) {
+	c := &cluster{}
+	leader := jdbtest.New(t, jdb.WithReplicator(c))
+	c.nodes = []*jdb.Driver{leader}
+
+	jdbtest.Seed(t, leader, "users", 2, jdbtest.Sequence("u", map[string]int{"n": 1}))
+
+	var snapshot bytes.Buffer
+	if _, err := leader.Backup(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	node := jdbtest.New(t, jdb.WithReplicator(c))
+	c.nodes = []*jdb.Driver{node}
+
+	if _, err := node.Write("users", "stale", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.KV().Set("stale", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := node.RestoreReplicated(&snapshot)
+	if err != nil || report.Written != 2 || report.Deleted != 2 {
+		t.Fatalf("restoring the snapshot = %+v, %v, want 2 written and 2 deleted", report, err)
+	}
+
+	if got, want := jdbtest.Snapshot(t, node, "users"), jdbtest.Snapshot(t, leader, "users"); !bytes.Equal(got, want) {
+		t.Errorf("node holds\n%s\nwant\n%s", got, want)
+	}
+
+	if err := node.KV().Get("stale", new(int)); !errors.Is(err, jdb.ErrNotFound) {
+		t.Errorf("node still holds the stale key: %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package jdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+type (
+	// RestoreStrategy is what Restore does with records that already exist
+	RestoreStrategy int
+
+	// RestoreReport sums up what Restore did
+	RestoreReport struct {
+		// Written counts records created or overwritten
+		Written int
+
+		// Skipped counts existing records left untouched
+		Skipped int
+
+		// Merged counts existing records the backup was merged into
+		Merged int
+
+		// Deleted counts the records RestoreReplicated removed as the
+		// snapshot doesn't hold them
+		Deleted int
+
+		// Failures are the entries of the backup that weren't restored
+		// because they're invalid, see Restore
+		Failures []RestoreFailure
+	}
+
+	// RestoreFailure is an entry of a backup Restore refused
+	RestoreFailure struct {
+		// Line is the position of the entry in the backup, from 1
+		Line       int
+		Collection string
+		ID         string
+		Err        error
+	}
+)
+
+func (f RestoreFailure) Error() string {
+	return fmt.Sprintf("backup entry %d, %s/%s: %s", f.Line, f.Collection, f.ID, f.Err)
+}
+
+const (
+	// RestoreOverwrite replaces existing records with the backed up ones
+	RestoreOverwrite RestoreStrategy = iota
+
+	// RestoreSkipExisting leaves existing records untouched
+	RestoreSkipExisting
+
+	// RestoreMerge merges the fields of the backed up record into the
+	// existing one, nested objects field by field, the backup winning on
+	// conflicts. Records that aren't both objects are overwritten
+	RestoreMerge
+)
+
+// Restore writes the records of a backup made by Backup or BackupSince back
+// into the database, resolving records that already exist following the
+// strategy. Backups aren't trusted: entries with invalid names or data, or
+// records their collection's schema refuses, are skipped and listed in the
+// report's Failures. Restoring stops at any other error, the report counts
+// what was restored until then. It fails with ErrNotReplicated when writes
+// go through a Replicator, see RestoreReplicated
+func (d *Driver) Restore(r io.Reader, strategy RestoreStrategy) (RestoreReport, error) {
+	if d.replicator != nil {
+		return RestoreReport{}, fmt.Errorf("restoring: %w", ErrNotReplicated)
+	}
+
+	return d.restore(r, strategy, nil)
+}
+
+// restore restores the backup, adding the records of its entries to seen
+// when it isn't nil
+func (d *Driver) restore(r io.Reader, strategy RestoreStrategy, seen map[string]map[string]bool) (RestoreReport, error) {
+	var report RestoreReport
+
+	if strategy < RestoreOverwrite || strategy > RestoreMerge {
+		return report, fmt.Errorf("unknown restore strategy %d", strategy)
+	}
+
+	dec := json.NewDecoder(r)
+
+	for line := 1; ; line++ {
+		var entry BackupEntry
+
+		switch err := dec.Decode(&entry); {
+		case err == io.EOF:
+			return report, nil
+		case err != nil:
+			return report, fmt.Errorf("backup entry %d: %w", line, err)
+		}
+
+		err := checkEntry(entry)
+		if err == nil {
+			err = d.restoreEntry(entry, strategy, &report)
+		}
+
+		if seen != nil {
+			if seen[entry.Collection] == nil {
+				seen[entry.Collection] = make(map[string]bool)
+			}
+
+			seen[entry.Collection][entry.ID] = true
+		}
+
+		var invalid *ValidationError
+
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrInvalidName), errors.Is(err, errInvalidEntry), errors.As(err, &invalid):
+			d.log.Warn("skipping backup entry %d: %s", line, err)
+			report.Failures = append(report.Failures, RestoreFailure{Line: line, Collection: entry.Collection, ID: entry.ID, Err: err})
+		default:
+			return report, fmt.Errorf("restoring %s/%s: %w", entry.Collection, entry.ID, err)
+		}
+
+		d.progress(Progress{Op: "restore", Collection: entry.Collection, Done: line, Total: -1})
+	}
+}
+
+// errInvalidEntry is a backup entry that can't be restored as it is
+var errInvalidEntry = errors.New("invalid backup entry")
+
+// checkEntry checks an entry of a backup before anything is written, its
+// names may be the ones of the reserved stores backed up with the records,
+// see validateStored
+func checkEntry(entry BackupEntry) error {
+	if entry.Collection == "" || entry.ID == "" || entry.Data == nil {
+		return fmt.Errorf("missing collection, id or data: %w", errInvalidEntry)
+	}
+
+	if err := validateStored(entry.Collection, entry.ID); err != nil {
+		return err
+	}
+
+	if !json.Valid(entry.Data) {
+		return fmt.Errorf("data is not valid JSON: %w", errInvalidEntry)
+	}
+
+	return nil
+}
+
+func (d *Driver) restoreEntry(entry BackupEntry, strategy RestoreStrategy, report *RestoreReport) error {
+	done, err := d.admitStore(entry.Collection, entry.ID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	mutex := d.getMutex(entry.Collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var existing []byte
+
+	err = d.load(entry.Collection, entry.ID, func(b []byte) error {
+		existing = append([]byte(nil), b...)
+		return nil
+	})
+
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	case strategy == RestoreSkipExisting:
+		report.Skipped++
+		return nil
+	case strategy == RestoreMerge:
+		merged, ok, err := mergeJSON(existing, entry.Data)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			if _, err := d.writeLocked(entry.Collection, entry.ID, merged); err != nil {
+				return err
+			}
+
+			report.Merged++
+			return nil
+		}
+	}
+
+	if _, err := d.writeLocked(entry.Collection, entry.ID, rawJSON(entry.Data)); err != nil {
+		return err
+	}
+
+	report.Written++
+	return nil
+}
+
+// mergeJSON merges the object src into the object dst, returning false when
+// either isn't an object
+func mergeJSON(dst, src []byte) (map[string]interface{}, bool, error) {
+	a, err := decodeJSON(dst)
+	if err != nil {
+		return nil, false, err
+	}
+
+	b, err := decodeJSON(src)
+	if err != nil {
+		return nil, false, err
+	}
+
+	am, ok := a.(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+
+	bm, ok := b.(map[string]interface{})
+	if !ok {
+		return nil, false, nil
+	}
+
+	mergeFields(am, bm)
+
+	return am, true, nil
+}
+
+func mergeFields(dst, src map[string]interface{}) {
+	for k, v := range src {
+		sub, ok := v.(map[string]interface{})
+		if cur, isMap := dst[k].(map[string]interface{}); ok && isMap {
+			mergeFields(cur, sub)
+			continue
+		}
+
+		dst[k] = v
+	}
+}
@@ -0,0 +1,208 @@
+package jdb_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	src := jdbtest.New(t)
+	jdbtest.Seed(t, src, "users", 3, jdbtest.Sequence("u", map[string]int{"n": 1}))
+	jdbtest.Seed(t, src, "orders/eu", 2, jdbtest.Sequence("o", map[string]string{"sku": "x"}))
+
+	var backup bytes.Buffer
+	if _, err := src.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := jdbtest.New(t)
+
+	report, err := dst.Restore(&backup, jdb.RestoreOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Written != 5 || len(report.Failures) != 0 {
+		t.Errorf("report = %+v, want 5 records written", report)
+	}
+
+	for _, c := range []string{"users", "orders/eu"} {
+		if got, want := jdbtest.Snapshot(t, dst, c), jdbtest.Snapshot(t, src, c); !bytes.Equal(got, want) {
+			t.Errorf("restored %s:\n%s\nwant:\n%s", c, got, want)
+		}
+	}
+}
+
+func TestRestoreReportsInvalidEntries(t *testing.T) {
+	d := jdbtest.New(t)
+
+	backup := strings.Join([]string{
+		`{"collection":"users","id":"ok","data":{"n":1}}`,
+		`{"collection":"users","id":"../../escaped","data":{"n":2}}`,
+		`{"collection":"_trash","id":"x","data":{"n":3}}`,
+		`{"collection":"users","id":"","data":{"n":4}}`,
+		`{"collection":"users","id":"last","data":{"n":5}}`,
+	}, "\n")
+
+	report, err := d.Restore(strings.NewReader(backup), jdb.RestoreOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Written != 2 {
+		t.Errorf("wrote %d records, want 2", report.Written)
+	}
+
+	var lines []int
+	for _, f := range report.Failures {
+		lines = append(lines, f.Line)
+	}
+
+	if len(lines) != 3 || lines[0] != 2 || lines[1] != 3 || lines[2] != 4 {
+		t.Fatalf("failures on lines %v, want [2 3 4]", lines)
+	}
+
+	if !errors.Is(report.Failures[0].Err, jdb.ErrInvalidName) {
+		t.Errorf("escaping ID failed with %v, want ErrInvalidName", report.Failures[0].Err)
+	}
+
+	IDs, err := d.IDs("users")
+	if err != nil || len(IDs) != 2 {
+		t.Errorf("users holds %v, %v, want ok and last", IDs, err)
+	}
+}
+
+func TestBackupRestoresReservedStores(t *testing.T) {
+	src := jdbtest.New(t)
+	jdbtest.Seed(t, src, "users", 2, jdbtest.Sequence("u", map[string]int{"n": 1}))
+
+	if err := src.KV().Set("flag", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := src.Enqueue("jobs", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if n, err := src.Archive("users", time.Millisecond); err != nil || n != 2 {
+		t.Fatalf("archived %d records: %v", n, err)
+	}
+
+	var backup bytes.Buffer
+	if _, err := src.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := jdbtest.New(t)
+
+	report, err := dst.Restore(&backup, jdb.RestoreOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Written != 4 || len(report.Failures) != 0 {
+		t.Errorf("report = %+v, want 4 records written", report)
+	}
+
+	if got, want := jdbtest.Snapshot(t, dst, "users"), []byte(`{"u-0":{"n":1},"u-1":{"n":1}}`); !bytes.Equal(normalizeJSON(t, got), normalizeJSON(t, want)) {
+		t.Errorf("restored users:\n%s", got)
+	}
+
+	var flag bool
+	if err := dst.KV().Get("flag", &flag); err != nil || !flag {
+		t.Errorf("restored KV flag = %v, %v", flag, err)
+	}
+
+	if _, err := dst.Dequeue("jobs", time.Minute); err != nil {
+		t.Errorf("dequeuing the restored message: %s", err)
+	}
+}
+
+func TestBackupSinceKeepsWritesMadeDuringTheBackup(t *testing.T) {
+	d := jdbtest.New(t)
+	jdbtest.Seed(t, d, "users", 2, jdbtest.Sequence("u", 1))
+
+	var full bytes.Buffer
+
+	seq, err := d.Backup(&full)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if seq == 0 || time.Unix(0, int64(seq)).After(time.Now()) {
+		t.Fatalf("seq %d isn't taken from the start of the backup", seq)
+	}
+
+	if _, err := d.Write("users", "late", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	var diff bytes.Buffer
+	if _, err := d.BackupSince(seq, &diff); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(diff.String(), `"id":"late"`) {
+		t.Errorf("the differential backup misses the record written after the full one:\n%s", diff.String())
+	}
+}
+
+func TestBackupSinceFollowsTheClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := jdbtest.NewClock(start)
+
+	// the memory engine stamps its files with the clock of the Driver
+	d := jdbtest.New(t, jdb.WithClock(clock), jdb.WithCollections(jdb.CollectionSpec{Name: "users", Engine: jdb.EngineMemory}))
+	jdbtest.Seed(t, d, "users", 2, jdbtest.Sequence("u", 1))
+
+	clock.Advance(time.Hour)
+
+	seq, err := d.Backup(&bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := start.Add(time.Hour - time.Second); seq != uint64(want.UnixNano()) {
+		t.Fatalf("seq = %s, want %s", time.Unix(0, int64(seq)).UTC(), want)
+	}
+
+	clock.Advance(time.Hour)
+
+	if _, err := d.Write("users", "late", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	var diff bytes.Buffer
+	if _, err := d.BackupSince(seq, &diff); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Count(diff.String(), "\n"); got != 1 || !strings.Contains(diff.String(), `"id":"late"`) {
+		t.Errorf("the differential backup holds more than the late record:\n%s", diff.String())
+	}
+}
+
+func normalizeJSON(t *testing.T, b []byte) []byte {
+	t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
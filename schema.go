@@ -0,0 +1,283 @@
+package jdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// Schema is the subset of JSON Schema records of a collection are
+	// validated against, see SetSchema. A nil Schema accepts anything
+	Schema struct {
+		// Type is one of object, array, string, number, integer, boolean
+		// and null, empty accepts any type
+		Type string `json:"type,omitempty"`
+
+		Properties           map[string]*Schema `json:"properties,omitempty"`
+		Required             []string           `json:"required,omitempty"`
+		AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+
+		Items *Schema `json:"items,omitempty"`
+
+		Enum []interface{} `json:"enum,omitempty"`
+
+		Minimum *float64 `json:"minimum,omitempty"`
+		Maximum *float64 `json:"maximum,omitempty"`
+
+		MinLength *int   `json:"minLength,omitempty"`
+		MaxLength *int   `json:"maxLength,omitempty"`
+		Pattern   string `json:"pattern,omitempty"`
+	}
+
+	// Violation is a constraint of a Schema a value doesn't hold
+	Violation struct {
+		// Pointer is the JSON pointer of the value, empty for the record
+		Pointer string `json:"pointer"`
+
+		// Constraint is the schema keyword violated, e.g. "required"
+		Constraint string `json:"constraint"`
+
+		Message string `json:"message"`
+	}
+
+	// ValidationError is returned by writes of records not matching the
+	// schema of their collection, listing every violation
+	ValidationError struct {
+		Collection string      `json:"collection"`
+		ID         string      `json:"id"`
+		Violations []Violation `json:"violations"`
+	}
+)
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", pointerOrRoot(v.Pointer), v.Message))
+	}
+
+	return fmt.Sprintf("%s/%s is invalid: %s", e.Collection, e.ID, strings.Join(msgs, "; "))
+}
+
+func pointerOrRoot(pointer string) string {
+	if pointer == "" {
+		return "/"
+	}
+
+	return pointer
+}
+
+// SetSchema validates the records written to the collection against the
+// schema, a nil schema turns validation off. Records already stored aren't
+// checked
+func (d *Driver) SetSchema(collection string, schema *Schema) error {
+	if err := schema.compile(""); err != nil {
+		return err
+	}
+
+	d.configure(collection, func(c *collectionConfig) {
+		c.schema = schema
+	})
+
+	return nil
+}
+
+// Schema returns the schema of the collection, nil when it has none
+func (d *Driver) Schema(collection string) *Schema {
+	return d.config(collection).schema
+}
+
+// compile checks the patterns of the schema are valid regular expressions
+func (s *Schema) compile(pointer string) error {
+	if s == nil {
+		return nil
+	}
+
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("schema %s: %w", pointerOrRoot(pointer), err)
+		}
+	}
+
+	for _, k := range sortedSchemaKeys(s.Properties) {
+		if err := s.Properties[k].compile(pointer + "/properties/" + escapePointer(k)); err != nil {
+			return err
+		}
+	}
+
+	return s.Items.compile(pointer + "/items")
+}
+
+// validate checks the encoded record against the schema of its collection
+func (d *Driver) validate(collection, ID string, doc []byte) error {
+	schema := d.config(collection).schema
+	if schema == nil {
+		return nil
+	}
+
+	v, err := decodeJSON(doc)
+	if err != nil {
+		return err
+	}
+
+	if violations := schema.Validate(v); len(violations) > 0 {
+		return &ValidationError{Collection: collection, ID: ID, Violations: violations}
+	}
+
+	return nil
+}
+
+// Validate returns the violations of the schema by v, a value decoded from
+// JSON with numbers as float64 or json.Number
+func (s *Schema) Validate(v interface{}) []Violation {
+	var violations []Violation
+
+	s.validate("", v, func(pointer, constraint, format string, args ...interface{}) {
+		violations = append(violations, Violation{
+			Pointer:    pointer,
+			Constraint: constraint,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	})
+
+	return violations
+}
+
+func (s *Schema) validate(pointer string, v interface{}, report func(pointer, constraint, format string, args ...interface{})) {
+	if s == nil {
+		return
+	}
+
+	if s.Type != "" && !hasType(v, s.Type) {
+		report(pointer, "type", "must be %s, got %s", s.Type, typeOf(v))
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		report(pointer, "enum", "must be one of %s", enumText(s.Enum))
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range s.Required {
+			if _, ok := v[k]; !ok {
+				report(pointer+"/"+escapePointer(k), "required", "is required")
+			}
+		}
+
+		for _, k := range sortedKeys(v) {
+			child, ok := s.Properties[k]
+			if !ok && s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				report(pointer+"/"+escapePointer(k), "additionalProperties", "is not allowed")
+				continue
+			}
+
+			child.validate(pointer+"/"+escapePointer(k), v[k], report)
+		}
+	case []interface{}:
+		for i, e := range v {
+			s.Items.validate(pointer+"/"+strconv.Itoa(i), e, report)
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			report(pointer, "minLength", "must be at least %d characters", *s.MinLength)
+		}
+
+		if s.MaxLength != nil && n > *s.MaxLength {
+			report(pointer, "maxLength", "must be at most %d characters", *s.MaxLength)
+		}
+
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+				report(pointer, "pattern", "must match %s", s.Pattern)
+			}
+		}
+	case json.Number, float64:
+		f, _ := toFloat(v)
+		if s.Minimum != nil && f < *s.Minimum {
+			report(pointer, "minimum", "must be at least %v", *s.Minimum)
+		}
+
+		if s.Maximum != nil && f > *s.Maximum {
+			report(pointer, "maximum", "must be at most %v", *s.Maximum)
+		}
+	}
+}
+
+func hasType(v interface{}, t string) bool {
+	switch t {
+	case "integer":
+		f, ok := toFloat(v)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := toFloat(v)
+		return ok
+	default:
+		return typeOf(v) == t
+	}
+}
+
+// typeOf returns the JSON Schema type of a decoded value
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number, float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		a, aok := toFloat(v)
+		b, bok := toFloat(e)
+		if aok && bok && a == b || reflect.DeepEqual(v, e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func enumText(enum []interface{}) string {
+	b, _ := json.Marshal(enum)
+	return string(b)
+}
+
+func sortedSchemaKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
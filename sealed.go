@@ -0,0 +1,448 @@
+package jdb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// SealKeys are the keys sealing a stream, e.g. a backup, with Seal and
+// opening it with OpenSealed. Every key is optional, a stream sealed with
+// none of them is only framed
+type SealKeys struct {
+	// Encryption is an AES-128, AES-192 or AES-256 key, the stream is
+	// encrypted with AES-GCM under a key derived from it per stream
+	Encryption []byte
+
+	// HMAC signs the stream with HMAC-SHA256
+	HMAC []byte
+
+	// Sign signs the stream with ed25519 in Seal
+	Sign ed25519.PrivateKey
+
+	// Verify checks the ed25519 signature in OpenSealed
+	Verify ed25519.PublicKey
+}
+
+const (
+	sealMagic = "JDBSEAL1"
+	sealChunk = 64 << 10
+	sealFinal = 1 << 31
+	sealSalt  = 32
+)
+
+const (
+	sealEncrypted byte = 1 << iota
+	sealHMAC
+	sealEd25519
+)
+
+type sealWriter struct {
+	w      io.Writer
+	keys   SealKeys
+	aead   cipher.AEAD
+	digest hash.Hash
+	mac    hash.Hash
+	buf    []byte
+	frame  uint64
+	closed bool
+}
+
+// Seal returns a writer sealing what's written to it into w, following keys.
+// The stream is written in authenticated chunks followed by its signatures
+// when Close is called, which doesn't close w. A backup is sealed with:
+//
+//	sw, err := jdb.Seal(f, keys)
+//	_, err = d.Backup(sw)
+//	err = sw.Close()
+func Seal(w io.Writer, keys SealKeys) (io.WriteCloser, error) {
+	s := &sealWriter{w: w, keys: keys, digest: sha256.New()}
+
+	var flags byte
+	var salt []byte
+
+	if keys.Encryption != nil {
+		flags |= sealEncrypted
+
+		salt = make([]byte, sealSalt)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+
+		aead, err := sealCipher(keys.Encryption, salt)
+		if err != nil {
+			return nil, err
+		}
+
+		s.aead = aead
+	}
+
+	if keys.HMAC != nil {
+		flags |= sealHMAC
+		s.mac = hmac.New(sha256.New, keys.HMAC)
+	}
+
+	if keys.Sign != nil {
+		flags |= sealEd25519
+	}
+
+	header := append([]byte(sealMagic), flags)
+	if err := s.write(append(header, salt...)); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *sealWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, fmt.Errorf("write to a closed sealed stream")
+	}
+
+	s.buf = append(s.buf, p...)
+
+	for len(s.buf) > sealChunk {
+		if err := s.writeFrame(s.buf[:sealChunk], false); err != nil {
+			return 0, err
+		}
+
+		s.buf = s.buf[sealChunk:]
+	}
+
+	return len(p), nil
+}
+
+// Close writes the last chunk and the signatures
+func (s *sealWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+
+	if err := s.writeFrame(s.buf, true); err != nil {
+		return err
+	}
+
+	var trailer []byte
+
+	if s.mac != nil {
+		trailer = s.mac.Sum(trailer)
+	}
+
+	if s.keys.Sign != nil {
+		trailer = append(trailer, ed25519.Sign(s.keys.Sign, s.digest.Sum(nil))...)
+	}
+
+	_, err := s.w.Write(trailer)
+	return err
+}
+
+func (s *sealWriter) writeFrame(p []byte, final bool) error {
+	if s.aead != nil {
+		p = s.aead.Seal(nil, sealNonce(s.aead, s.frame), p, sealAAD(final))
+	}
+
+	s.frame++
+
+	size := uint32(len(p))
+	if final {
+		size |= sealFinal
+	}
+
+	var head [4]byte
+	binary.BigEndian.PutUint32(head[:], size)
+
+	if err := s.write(head[:]); err != nil {
+		return err
+	}
+
+	return s.write(p)
+}
+
+// write writes p to the stream, adding it to what gets signed
+func (s *sealWriter) write(p []byte) error {
+	s.digest.Write(p)
+	if s.mac != nil {
+		s.mac.Write(p)
+	}
+
+	_, err := s.w.Write(p)
+	return err
+}
+
+// OpenSealed checks the signatures of a stream sealed by Seal and returns a
+// reader of its content. The whole stream is verified before anything is
+// returned, which is why it needs to seek back to its start, so a tampered
+// backup is never partially restored. The reader checks every frame against
+// the hash it had when verified, failing with ErrBadSignature when the stream
+// changed in between. Signatures the keys can check must be present, a
+// stream signed with a key that isn't given can't be opened, and neither can
+// a plain stream when an encryption key is given
+func OpenSealed(r io.ReadSeeker, keys SealKeys) (io.Reader, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	flags, salt, err := readSealHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSealKeys(flags, keys); err != nil {
+		return nil, err
+	}
+
+	frames, err := verifySealed(r, start, flags, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	reread, resalt, err := readSealHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if reread != flags || !bytes.Equal(resalt, salt) {
+		return nil, fmt.Errorf("sealed header changed after verification: %w", ErrBadSignature)
+	}
+
+	o := &sealReader{r: r, frames: frames}
+
+	if flags&sealEncrypted != 0 {
+		if o.aead, err = sealCipher(keys.Encryption, salt); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+func readSealHeader(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, len(sealMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, fmt.Errorf("reading sealed header: %w", err)
+	}
+
+	if string(header[:len(sealMagic)]) != sealMagic {
+		return 0, nil, fmt.Errorf("not a sealed stream")
+	}
+
+	flags := header[len(sealMagic)]
+	if flags&sealEncrypted == 0 {
+		return flags, nil, nil
+	}
+
+	salt := make([]byte, sealSalt)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return 0, nil, fmt.Errorf("reading sealed header: %w", err)
+	}
+
+	return flags, salt, nil
+}
+
+func checkSealKeys(flags byte, keys SealKeys) error {
+	switch {
+	case flags&sealEncrypted != 0 && keys.Encryption == nil:
+		return fmt.Errorf("stream is encrypted, missing the encryption key")
+	case flags&sealEncrypted == 0 && keys.Encryption != nil:
+		return fmt.Errorf("stream isn't encrypted: %w", ErrBadSignature)
+	case flags&sealHMAC == 0 && keys.HMAC != nil:
+		return fmt.Errorf("stream has no HMAC: %w", ErrBadSignature)
+	case flags&sealHMAC != 0 && keys.HMAC == nil:
+		return fmt.Errorf("stream has an HMAC, missing the HMAC key")
+	case flags&sealEd25519 == 0 && keys.Verify != nil:
+		return fmt.Errorf("stream has no ed25519 signature: %w", ErrBadSignature)
+	case flags&sealEd25519 != 0 && keys.Verify == nil:
+		return fmt.Errorf("stream has an ed25519 signature, missing the public key")
+	}
+
+	return nil
+}
+
+// verifySealed reads the stream from start to its signatures and checks
+// them, returning the hash of every frame so they can be checked again when
+// the content is read
+func verifySealed(r io.ReadSeeker, start int64, flags byte, keys SealKeys) ([][sha256.Size]byte, error) {
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	digest := sha256.New()
+	signed := io.Writer(digest)
+
+	var mac hash.Hash
+	if flags&sealHMAC != 0 {
+		mac = hmac.New(sha256.New, keys.HMAC)
+		signed = io.MultiWriter(digest, mac)
+	}
+
+	body := io.TeeReader(r, signed)
+
+	if _, _, err := readSealHeader(body); err != nil {
+		return nil, err
+	}
+
+	var frames [][sha256.Size]byte
+
+	for final := false; !final; {
+		frame := sha256.New()
+		framed := io.TeeReader(body, frame)
+
+		var head [4]byte
+		if _, err := io.ReadFull(framed, head[:]); err != nil {
+			return nil, fmt.Errorf("reading sealed frame: %w", err)
+		}
+
+		size := binary.BigEndian.Uint32(head[:])
+		final = size&sealFinal != 0
+
+		if _, err := io.CopyN(io.Discard, framed, int64(size&^sealFinal)); err != nil {
+			return nil, fmt.Errorf("reading sealed frame: %w", err)
+		}
+
+		var sum [sha256.Size]byte
+		copy(sum[:], frame.Sum(nil))
+		frames = append(frames, sum)
+	}
+
+	if mac != nil {
+		sum := make([]byte, sha256.Size)
+		if _, err := io.ReadFull(r, sum); err != nil {
+			return nil, fmt.Errorf("reading HMAC: %w", err)
+		}
+
+		if !hmac.Equal(sum, mac.Sum(nil)) {
+			return nil, fmt.Errorf("HMAC mismatch: %w", ErrBadSignature)
+		}
+	}
+
+	if flags&sealEd25519 != 0 {
+		sig := make([]byte, ed25519.SignatureSize)
+		if _, err := io.ReadFull(r, sig); err != nil {
+			return nil, fmt.Errorf("reading ed25519 signature: %w", err)
+		}
+
+		if !ed25519.Verify(keys.Verify, digest.Sum(nil), sig) {
+			return nil, fmt.Errorf("ed25519 signature mismatch: %w", ErrBadSignature)
+		}
+	}
+
+	if n, _ := io.CopyN(io.Discard, r, 1); n != 0 {
+		return nil, fmt.Errorf("data after the signatures: %w", ErrBadSignature)
+	}
+
+	return frames, nil
+}
+
+type sealReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	frames [][sha256.Size]byte
+	buf    bytes.Reader
+	frame  uint64
+	done   bool
+}
+
+func (o *sealReader) Read(p []byte) (int, error) {
+	for o.buf.Len() == 0 {
+		if o.done {
+			return 0, io.EOF
+		}
+
+		if err := o.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	return o.buf.Read(p)
+}
+
+func (o *sealReader) readFrame() error {
+	var head [4]byte
+	if _, err := io.ReadFull(o.r, head[:]); err != nil {
+		return fmt.Errorf("reading sealed frame: %w", err)
+	}
+
+	size := binary.BigEndian.Uint32(head[:])
+	o.done = size&sealFinal != 0
+
+	p := make([]byte, size&^sealFinal)
+	if _, err := io.ReadFull(o.r, p); err != nil {
+		return fmt.Errorf("reading sealed frame: %w", err)
+	}
+
+	if o.frame >= uint64(len(o.frames)) {
+		return fmt.Errorf("frame %d wasn't verified: %w", o.frame, ErrBadSignature)
+	}
+
+	frame := sha256.New()
+	frame.Write(head[:])
+	frame.Write(p)
+
+	if !bytes.Equal(frame.Sum(nil), o.frames[o.frame][:]) {
+		return fmt.Errorf("frame %d changed after verification: %w", o.frame, ErrBadSignature)
+	}
+
+	if o.aead != nil {
+		var err error
+		if p, err = o.aead.Open(p[:0], sealNonce(o.aead, o.frame), p, sealAAD(o.done)); err != nil {
+			return fmt.Errorf("decrypting frame %d: %w", o.frame, err)
+		}
+	}
+
+	o.frame++
+	o.buf.Reset(p)
+
+	return nil
+}
+
+// sealCipher is AES-GCM under a key derived from key and the stream's salt,
+// so nonces can be counters without ever repeating under the same key
+func sealCipher(key, salt []byte) (cipher.AEAD, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(salt)
+
+	block, err := aes.NewCipher(mac.Sum(nil)[:len(key)])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func sealNonce(aead cipher.AEAD, frame uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], frame)
+
+	return nonce
+}
+
+// sealAAD binds whether a frame is the last one, so a stream can't be
+// truncated at a frame boundary
+func sealAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+
+	return []byte{0}
+}
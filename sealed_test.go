@@ -0,0 +1,163 @@
+package jdb_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/arham09/jdb"
+)
+
+func seal(t *testing.T, content []byte, keys jdb.SealKeys) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	sw, err := jdb.Seal(&buf, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func sealKeys(t *testing.T) jdb.SealKeys {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return jdb.SealKeys{
+		Encryption: bytes.Repeat([]byte{1}, 32),
+		HMAC:       []byte("hmac key"),
+		Sign:       private,
+		Verify:     public,
+	}
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	all := sealKeys(t)
+
+	// spans several frames so frame order and the final flag are exercised
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+
+	for name, keys := range map[string]jdb.SealKeys{
+		"framed":    {},
+		"encrypted": {Encryption: all.Encryption},
+		"hmac":      {HMAC: all.HMAC},
+		"ed25519":   {Sign: all.Sign, Verify: all.Verify},
+		"all":       all,
+	} {
+		t.Run(name, func(t *testing.T) {
+			sealed := seal(t, content, keys)
+
+			if keys.Encryption != nil && bytes.Contains(sealed, content[:64]) {
+				t.Error("encrypted stream holds the plaintext")
+			}
+
+			r, err := jdb.OpenSealed(bytes.NewReader(sealed), keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, content) {
+				t.Errorf("opened %d bytes, want the %d sealed", len(got), len(content))
+			}
+		})
+	}
+}
+
+func TestOpenSealedRefusesTampering(t *testing.T) {
+	keys := sealKeys(t)
+	keys.Encryption = nil
+
+	sealed := seal(t, []byte(`{"balance":100}`), keys)
+
+	for i := range sealed {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 0xff
+
+		if _, err := jdb.OpenSealed(bytes.NewReader(tampered), keys); err == nil {
+			t.Fatalf("opened a stream with byte %d flipped", i)
+		}
+	}
+
+	if _, err := jdb.OpenSealed(bytes.NewReader(sealed[:len(sealed)-1]), keys); err == nil {
+		t.Error("opened a truncated stream")
+	}
+
+	if _, err := jdb.OpenSealed(bytes.NewReader(append(sealed, 0)), keys); !errors.Is(err, jdb.ErrBadSignature) {
+		t.Errorf("opening a stream with trailing data returned %v, want ErrBadSignature", err)
+	}
+}
+
+func TestOpenSealedRefusesDowngrade(t *testing.T) {
+	keys := sealKeys(t)
+	plain := seal(t, []byte(`{"balance":100}`), jdb.SealKeys{})
+
+	for name, expected := range map[string]jdb.SealKeys{
+		"encryption": {Encryption: keys.Encryption},
+		"hmac":       {HMAC: keys.HMAC},
+		"ed25519":    {Verify: keys.Verify},
+	} {
+		if _, err := jdb.OpenSealed(bytes.NewReader(plain), expected); !errors.Is(err, jdb.ErrBadSignature) {
+			t.Errorf("opening a plain stream expecting %s returned %v, want ErrBadSignature", name, err)
+		}
+	}
+}
+
+// swapping is a ReadSeeker serving other once the content was read through
+// once, like a file replaced between verification and restore
+type swapping struct {
+	*bytes.Reader
+	other []byte
+	read  bool
+}
+
+func (s *swapping) Seek(offset int64, whence int) (int64, error) {
+	if s.Reader.Len() == 0 && !s.read {
+		s.read = true
+		s.Reader = bytes.NewReader(s.other)
+	}
+
+	return s.Reader.Seek(offset, whence)
+}
+
+func TestOpenSealedRefusesStreamChangedAfterVerification(t *testing.T) {
+	keys := sealKeys(t)
+	keys.Encryption = nil
+
+	sealed := seal(t, []byte(`{"balance":100}`), keys)
+	forged := bytes.Replace(sealed, []byte("100"), []byte("999"), 1)
+
+	r, err := jdb.OpenSealed(&swapping{Reader: bytes.NewReader(sealed), other: forged}, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadAll(r)
+	if !errors.Is(err, jdb.ErrBadSignature) {
+		t.Errorf("reading a stream changed after verification returned %q, %v, want ErrBadSignature", got, err)
+	}
+
+	if bytes.Contains(got, []byte("999")) {
+		t.Error("returned content that wasn't verified")
+	}
+}
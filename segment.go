@@ -0,0 +1,723 @@
+package jdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentsDir is the reserved directory holding the segment files of the
+// collections using EngineSegment
+const segmentsDir = "_segments"
+
+// compactSegmentSize is how many bytes of replaced records a segment holds
+// before being compacted, on top of as many as its live records take
+const compactSegmentSize = 1 << 20
+
+// Entries of segment files
+const (
+	segmentPut    = 1
+	segmentRemove = 2
+	segmentRename = 3
+	segmentMkdir  = 4
+)
+
+var errNotEmpty = errors.New("directory not empty")
+
+type (
+	// memStorage is a Storage holding the tree of files under root in
+	// memory, for EngineMemory. With a segment, file contents are appended
+	// to it instead and only their offsets are kept, for EngineSegment
+	memStorage struct {
+		mutex sync.RWMutex
+		root  string
+		files map[string]*memFile
+		dirs  map[string]time.Time
+
+		// clock stamps the modification times of the files, the one of
+		// the Driver
+		clock Clock
+
+		segment *segment
+	}
+
+	memFile struct {
+		data      []byte
+		off, size int64
+		mod       time.Time
+	}
+
+	// segment is the append-only file a memStorage logs its changes to,
+	// each entry followed by its CRC so a torn last write is dropped on load
+	segment struct {
+		path string
+		f    *os.File
+		size int64
+
+		// live is how many bytes the current files take
+		live int64
+	}
+
+	memInfo struct {
+		name string
+		size int64
+		mod  time.Time
+		dir  bool
+	}
+)
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return i.mod }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() interface{}   { return nil }
+
+func (i memInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+
+	return 0644
+}
+
+func newMemStorage(root string, clock Clock) *memStorage {
+	return &memStorage{root: root, files: make(map[string]*memFile), dirs: make(map[string]time.Time), clock: clock}
+}
+
+// openSegment returns the memStorage of root logged to the segment file at
+// path, loading the files it holds
+func openSegment(root, path string, clock Clock) (*memStorage, error) {
+	s := newMemStorage(root, clock)
+	s.segment = &segment{path: path}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.segment.f = f
+
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// load replays the entries of the segment, truncating it after the last
+// whole one
+func (s *memStorage) load() error {
+	r := bufio.NewReader(s.segment.f)
+	off := int64(0)
+
+	for {
+		n, err := s.replay(r, off)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			if err := s.segment.f.Truncate(off); err != nil {
+				return err
+			}
+
+			break
+		}
+
+		off += n
+	}
+
+	s.segment.size = off
+	return nil
+}
+
+// replay applies the entry of the segment at off read from r, returning
+// its length
+func (s *memStorage) replay(r *bufio.Reader, off int64) (int64, error) {
+	crc := crc32.NewIEEE()
+	tr := &countingReader{r: io.TeeReader(r, crc)}
+
+	op, err := tr.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	name, err := tr.readString()
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	var (
+		to   string
+		mod  time.Time
+		data int64
+		size uint64
+	)
+
+	switch op {
+	case segmentRename:
+		to, err = tr.readString()
+	case segmentPut, segmentMkdir, segmentRemove:
+	default:
+		return 0, errors.New("corrupt segment entry")
+	}
+
+	var nano int64
+	if err == nil {
+		err = binary.Read(tr, binary.LittleEndian, &nano)
+		mod = time.Unix(0, nano)
+	}
+
+	if err == nil && op == segmentPut {
+		if size, err = binary.ReadUvarint(tr); err == nil {
+			data = off + tr.n
+			_, err = io.CopyN(io.Discard, tr, int64(size))
+		}
+	}
+
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil || binary.LittleEndian.Uint32(sum[:]) != crc.Sum32() {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	name = filepath.Join(s.root, name)
+
+	if _, exists := s.files[name]; !exists || op != segmentPut {
+		s.touch(name, mod)
+	}
+
+	switch op {
+	case segmentPut:
+		s.setFile(name, &memFile{off: data, size: int64(size), mod: mod})
+	case segmentMkdir:
+		s.dirs[name] = mod
+	case segmentRemove:
+		s.removeAll(name)
+	case segmentRename:
+		to = filepath.Join(s.root, to)
+		s.touch(to, mod)
+		s.rename(name, to)
+	}
+
+	return tr.n + int64(len(sum)), nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(c, b[:]); err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
+
+func (c *countingReader) readString() (string, error) {
+	n, err := binary.ReadUvarint(c)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c, b); err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// log appends an entry to the segment, creating it on the first one, and
+// returns the offset of its data
+func (s *memStorage) log(op byte, name, to string, mod time.Time, data []byte) (int64, error) {
+	seg := s.segment
+
+	if seg.f == nil {
+		if err := os.MkdirAll(filepath.Dir(seg.path), 0755); err != nil {
+			return 0, err
+		}
+
+		f, err := os.OpenFile(seg.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return 0, err
+		}
+
+		seg.f = f
+	}
+
+	entry := []byte{op}
+	entry = appendString(entry, s.rel(name))
+
+	if op == segmentRename {
+		entry = appendString(entry, s.rel(to))
+	}
+
+	var nano [8]byte
+	binary.LittleEndian.PutUint64(nano[:], uint64(mod.UnixNano()))
+	entry = append(entry, nano[:]...)
+
+	dataOff := int64(0)
+	if op == segmentPut {
+		entry = appendUvarint(entry, uint64(len(data)))
+		dataOff = seg.size + int64(len(entry))
+		entry = append(entry, data...)
+	}
+
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(entry))
+	entry = append(entry, sum[:]...)
+
+	if _, err := seg.f.Write(entry); err != nil {
+		// dropping the torn entry, or later ones would be lost with it
+		seg.f.Truncate(seg.size)
+		return 0, err
+	}
+
+	seg.size += int64(len(entry))
+
+	return dataOff, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUvarint(b, uint64(len(s))), s...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func (s *memStorage) rel(name string) string {
+	rel, err := filepath.Rel(s.root, name)
+	if err != nil {
+		return name
+	}
+
+	return rel
+}
+
+// compact rewrites the segment with only the current files and directories
+// once replaced records take more room than the live ones
+func (s *memStorage) compact() error {
+	seg := s.segment
+	if seg == nil || seg.size <= 2*seg.live+compactSegmentSize {
+		return nil
+	}
+
+	tmp := &memStorage{root: s.root, files: make(map[string]*memFile), dirs: s.dirs, clock: s.clock, segment: &segment{path: seg.path + ".tmp"}}
+	os.Remove(tmp.segment.path)
+
+	fail := func(err error) error {
+		if tmp.segment.f != nil {
+			tmp.segment.f.Close()
+		}
+
+		os.Remove(tmp.segment.path)
+		return err
+	}
+
+	for _, name := range sortedWriteKeys(s.files) {
+		file := s.files[name]
+
+		data, err := s.contents(file)
+		if err != nil {
+			return fail(err)
+		}
+
+		off, err := tmp.log(segmentPut, name, "", file.mod, data)
+		if err != nil {
+			return fail(err)
+		}
+
+		tmp.files[name] = &memFile{off: off, size: file.size, mod: file.mod}
+	}
+
+	// directories last, parents after their children, so replaying the
+	// entries of their files doesn't change their modification times
+	dirs := sortedWriteKeys(s.dirs)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if _, err := tmp.log(segmentMkdir, dirs[i], "", s.dirs[dirs[i]], nil); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := os.Rename(tmp.segment.path, seg.path); err != nil {
+		return fail(err)
+	}
+
+	seg.f.Close()
+	seg.f, seg.size = tmp.segment.f, tmp.segment.size
+	s.files = tmp.files
+
+	return nil
+}
+
+func (s *memStorage) close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.segment == nil || s.segment.f == nil {
+		return nil
+	}
+
+	err := s.segment.f.Close()
+	s.segment.f = nil
+	return err
+}
+
+// contents returns the data of the file, which must not be changed
+func (s *memStorage) contents(file *memFile) ([]byte, error) {
+	if s.segment == nil {
+		return file.data, nil
+	}
+
+	b := make([]byte, file.size)
+	if _, err := s.segment.f.ReadAt(b, file.off); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (s *memStorage) setFile(name string, file *memFile) {
+	if s.segment != nil {
+		if old, ok := s.files[name]; ok {
+			s.segment.live -= old.size
+		}
+
+		s.segment.live += file.size
+	}
+
+	s.files[name] = file
+}
+
+func (s *memStorage) deleteFile(name string) {
+	if old, ok := s.files[name]; ok && s.segment != nil {
+		s.segment.live -= old.size
+	}
+
+	delete(s.files, name)
+}
+
+// touch sets the modification time of the directory holding name, changed
+// like on disk by the files and directories added, removed or renamed in it
+func (s *memStorage) touch(name string, mod time.Time) {
+	if _, ok := s.dirs[filepath.Dir(name)]; ok {
+		s.dirs[filepath.Dir(name)] = mod
+	}
+}
+
+// under reports whether name is dir or inside it
+func under(name, dir string) bool {
+	return name == dir || strings.HasPrefix(name, dir+string(filepath.Separator))
+}
+
+func (s *memStorage) removeAll(path string) {
+	for name := range s.files {
+		if under(name, path) {
+			s.deleteFile(name)
+		}
+	}
+
+	for name := range s.dirs {
+		if under(name, path) {
+			delete(s.dirs, name)
+		}
+	}
+}
+
+func (s *memStorage) rename(oldpath, newpath string) {
+	if file, ok := s.files[oldpath]; ok {
+		s.deleteFile(oldpath)
+		s.setFile(newpath, file)
+		return
+	}
+
+	for name, file := range s.files {
+		if under(name, oldpath) {
+			s.deleteFile(name)
+			s.setFile(newpath+strings.TrimPrefix(name, oldpath), file)
+		}
+	}
+
+	for name, mod := range s.dirs {
+		if under(name, oldpath) {
+			delete(s.dirs, name)
+			s.dirs[newpath+strings.TrimPrefix(name, oldpath)] = mod
+		}
+	}
+}
+
+// dirExists reports whether the directory exists, the parents of root
+// always do
+func (s *memStorage) dirExists(path string) bool {
+	if _, ok := s.dirs[path]; ok {
+		return true
+	}
+
+	return !under(path, s.root)
+}
+
+func (s *memStorage) write(op, name string, data []byte) error {
+	name = filepath.Clean(name)
+
+	if !s.dirExists(filepath.Dir(name)) {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+
+	if _, ok := s.dirs[name]; ok {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrExist}
+	}
+
+	_, exists := s.files[name]
+	file := &memFile{size: int64(len(data)), mod: s.clock.Now()}
+
+	if s.segment == nil {
+		file.data = append([]byte(nil), data...)
+	} else {
+		off, err := s.log(segmentPut, name, "", file.mod, data)
+		if err != nil {
+			return err
+		}
+
+		file.off = off
+	}
+
+	s.setFile(name, file)
+
+	if !exists {
+		s.touch(name, file.mod)
+	}
+
+	return s.compact()
+}
+
+func (s *memStorage) ReadFile(name string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	file, ok := s.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	b, err := s.contents(file)
+	if err != nil || s.segment != nil {
+		return b, err
+	}
+
+	return append([]byte(nil), b...), nil
+}
+
+func (s *memStorage) WriteFile(name string, data []byte, _ os.FileMode) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.write("open", name, data)
+}
+
+func (s *memStorage) AppendFile(name string, data []byte, _ os.FileMode) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if file, ok := s.files[filepath.Clean(name)]; ok {
+		b, err := s.contents(file)
+		if err != nil {
+			return err
+		}
+
+		data = append(append([]byte(nil), b...), data...)
+	}
+
+	return s.write("open", name, data)
+}
+
+func (s *memStorage) Rename(oldpath, newpath string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+
+	_, isFile := s.files[oldpath]
+	_, isDir := s.dirs[oldpath]
+
+	switch {
+	case !isFile && !isDir:
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	case !s.dirExists(filepath.Dir(newpath)):
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	case isDir && s.hasChildren(newpath):
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errNotEmpty}
+	}
+
+	if _, ok := s.dirs[newpath]; ok && isFile {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrExist}
+	}
+
+	now := s.clock.Now()
+
+	if s.segment != nil {
+		if _, err := s.log(segmentRename, oldpath, newpath, now, nil); err != nil {
+			return err
+		}
+	}
+
+	s.touch(oldpath, now)
+	s.touch(newpath, now)
+	s.rename(oldpath, newpath)
+	return nil
+}
+
+func (s *memStorage) hasChildren(dir string) bool {
+	for name := range s.files {
+		if under(name, dir) && name != dir {
+			return true
+		}
+	}
+
+	for name := range s.dirs {
+		if under(name, dir) && name != dir {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *memStorage) Remove(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	name = filepath.Clean(name)
+
+	_, isFile := s.files[name]
+	_, isDir := s.dirs[name]
+
+	switch {
+	case !isFile && !isDir:
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	case isDir && s.hasChildren(name):
+		return &os.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+
+	return s.remove(name)
+}
+
+func (s *memStorage) RemoveAll(path string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.remove(filepath.Clean(path))
+}
+
+func (s *memStorage) remove(path string) error {
+	now := s.clock.Now()
+
+	if s.segment != nil {
+		if _, err := s.log(segmentRemove, path, "", now, nil); err != nil {
+			return err
+		}
+	}
+
+	s.touch(path, now)
+	s.removeAll(path)
+	return s.compact()
+}
+
+func (s *memStorage) MkdirAll(path string, _ os.FileMode) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+
+	for dir := filepath.Clean(path); under(dir, s.root) && !s.dirExists(dir); dir = filepath.Dir(dir) {
+		if _, ok := s.files[dir]; ok {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: os.ErrExist}
+		}
+
+		if s.segment != nil {
+			if _, err := s.log(segmentMkdir, dir, "", now, nil); err != nil {
+				return err
+			}
+		}
+
+		s.touch(dir, now)
+		s.dirs[dir] = now
+	}
+
+	return nil
+}
+
+func (s *memStorage) Stat(name string) (os.FileInfo, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	name = filepath.Clean(name)
+
+	if file, ok := s.files[name]; ok {
+		return memInfo{name: filepath.Base(name), size: file.size, mod: file.mod}, nil
+	}
+
+	if mod, ok := s.dirs[name]; ok {
+		return memInfo{name: filepath.Base(name), mod: mod, dir: true}, nil
+	}
+
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (s *memStorage) ReadDir(dirname string) ([]os.FileInfo, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	dirname = filepath.Clean(dirname)
+
+	if _, ok := s.dirs[dirname]; !ok {
+		return nil, &os.PathError{Op: "open", Path: dirname, Err: os.ErrNotExist}
+	}
+
+	var infos []os.FileInfo
+
+	for name, file := range s.files {
+		if filepath.Dir(name) == dirname {
+			infos = append(infos, memInfo{name: filepath.Base(name), size: file.size, mod: file.mod})
+		}
+	}
+
+	for name, mod := range s.dirs {
+		if filepath.Dir(name) == dirname && name != dirname {
+			infos = append(infos, memInfo{name: filepath.Base(name), mod: mod, dir: true})
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	return infos, nil
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+type (
+	// Auth holds the credentials accepted by the server, requests without
+	// one of them are refused with 401
+	Auth struct {
+		// Tokens are sent as "Authorization: Bearer <token>"
+		Tokens map[string]Scope
+
+		// APIKeys are sent as "X-API-Key: <key>"
+		APIKeys map[string]Scope
+
+		// Users are sent with HTTP basic authentication
+		Users map[string]User
+	}
+
+	// User is a basic authentication user
+	User struct {
+		Password string
+		Scope    Scope
+	}
+
+	// Scope lists the collections a credential gives access to along with
+	// their nested collections, an empty Scope giving access to all of them
+	Scope []string
+
+	scopeKey struct{}
+)
+
+// Handler returns next behind authentication, e.g. to protect a GraphQL
+// handler. Handlers of this package refuse collections out of the scope of
+// the credential with 403
+func (a *Auth) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope, ok := a.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="jdb", charset="UTF-8"`)
+			writeError(w, http.StatusUnauthorized, &Error{Code: CodeUnauthorized, Message: "missing or invalid credentials"})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scopeKey{}, scope)))
+	})
+}
+
+// authenticate returns the scope of the credential of the request
+func (a *Auth) authenticate(r *http.Request) (Scope, bool) {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return lookup(a.Tokens, strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return lookup(a.APIKeys, key)
+	}
+
+	if name, password, ok := r.BasicAuth(); ok {
+		var found *User
+		for n, u := range a.Users {
+			u := u
+			if subtle.ConstantTimeCompare([]byte(n), []byte(name)) == 1 {
+				found = &u
+			}
+		}
+
+		if found != nil && subtle.ConstantTimeCompare([]byte(found.Password), []byte(password)) == 1 {
+			return scopeOrAll(found.Scope), true
+		}
+	}
+
+	return nil, false
+}
+
+// lookup finds the secret comparing every one in constant time
+func lookup(secrets map[string]Scope, secret string) (Scope, bool) {
+	var scope Scope
+	found := false
+
+	for s, sc := range secrets {
+		if subtle.ConstantTimeCompare([]byte(s), []byte(secret)) == 1 {
+			scope, found = sc, true
+		}
+	}
+
+	return scopeOrAll(scope), found
+}
+
+// scopeOrAll keeps empty scopes distinct from requests without credentials
+func scopeOrAll(scope Scope) Scope {
+	if scope == nil {
+		return Scope{}
+	}
+
+	return scope
+}
+
+// allows reports whether the scope gives access to the collection, an empty
+// collection standing for every collection
+func (s Scope) allows(collection string) bool {
+	if len(s) == 0 {
+		return true
+	}
+
+	if collection == "" {
+		return false
+	}
+
+	for _, c := range s {
+		if collection == c || strings.HasPrefix(collection, c+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowed reports whether the credential of the request gives access to the
+// collection, requests not going through Auth are allowed everything
+func allowed(r *http.Request, collection string) bool {
+	scope, _ := r.Context().Value(scopeKey{}).(Scope)
+	return scope.allows(collection)
+}
+
+func forbidden(w http.ResponseWriter, collection string) {
+	writeError(w, http.StatusForbidden, &Error{Code: CodeForbidden, Message: "no access to " + collection})
+}
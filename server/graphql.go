@@ -0,0 +1,749 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/arham09/jdb"
+)
+
+// GraphQL serves queries over collections, each collection being a root
+// field of the query type returning a list of its records:
+//
+//	{ users(filter: {country: "FR"}, first: 10, after: "u42") { id name address { city } } }
+//
+// The fields of a collection come from the schema registered with
+// SetSchema, id being the record ID; collections without a schema expose
+// whatever their records hold. filter matches fields equal to the given
+// values, first limits the number of records and after starts after the
+// record with the given ID. Nested collections are named with their slashes
+// replaced by underscores.
+//
+// Only queries are supported, without fragments or directives; variables are
+// substituted without being type checked. Wrap the handler with Auth.Handler
+// to require authentication, collections out of scope can't be queried.
+type GraphQL struct {
+	d      *jdb.Driver
+	fields map[string]string
+}
+
+// NewGraphQL returns a GraphQL handler exposing the collections
+func NewGraphQL(d *jdb.Driver, collections ...string) *GraphQL {
+	fields := make(map[string]string, len(collections))
+	for _, c := range collections {
+		fields[strings.ReplaceAll(c, "/", "_")] = c
+	}
+
+	return &GraphQL{d: d, fields: fields}
+}
+
+type (
+	gqlRequest struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+
+	gqlResponse struct {
+		Data   interface{}   `json:"data"`
+		Errors []interface{} `json:"errors,omitempty"`
+	}
+
+	// gqlField is a field of a selection set
+	gqlField struct {
+		alias     string
+		name      string
+		args      map[string]interface{}
+		selection []gqlField
+	}
+
+	// gqlVariable is a reference to a variable in arguments
+	gqlVariable string
+)
+
+func (g *GraphQL) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req gqlRequest
+
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			dec := json.NewDecoder(strings.NewReader(vars))
+			dec.UseNumber()
+			if err := dec.Decode(&req.Variables); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+	case http.MethodPost:
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeGraphQLError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	selection, err := parseGraphQL(req.Query)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	data, err := g.execute(r, selection, req.Variables)
+	if err != nil {
+		writeGraphQLError(w, http.StatusOK, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gqlResponse{Data: data})
+}
+
+func writeGraphQLError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(gqlResponse{Errors: []interface{}{map[string]string{"message": err.Error()}}})
+}
+
+func (g *GraphQL) execute(r *http.Request, selection []gqlField, vars map[string]interface{}) (map[string]interface{}, error) {
+	data := make(map[string]interface{}, len(selection))
+
+	for _, f := range selection {
+		if f.name == "__typename" {
+			data[f.alias] = "Query"
+			continue
+		}
+
+		collection, ok := g.fields[f.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q on Query", f.name)
+		}
+
+		if !allowed(r, collection) {
+			return nil, fmt.Errorf("no access to %s", collection)
+		}
+
+		args, err := resolveArgs(f.args, vars)
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := g.query(collection, f, args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.alias, err)
+		}
+
+		data[f.alias] = records
+	}
+
+	return data, nil
+}
+
+// query returns the records of the collection matching the arguments,
+// shaped after the selection
+func (g *GraphQL) query(collection string, f gqlField, args map[string]interface{}) ([]interface{}, error) {
+	schema := g.d.Schema(collection)
+	typeName := typeName(collection)
+
+	if err := checkSelection(typeName, schema, f.selection, true); err != nil {
+		return nil, err
+	}
+
+	filter, _ := args["filter"].(map[string]interface{})
+	if v, ok := args["filter"]; ok && filter == nil && v != nil {
+		return nil, fmt.Errorf("filter must be an object")
+	}
+
+	first := -1
+	if v, ok := args["first"]; ok {
+		n, err := strconv.Atoi(fmt.Sprint(v))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("first must be a non negative integer, got %v", v)
+		}
+
+		first = n
+	}
+
+	for name := range args {
+		if name != "filter" && name != "first" && name != "after" {
+			return nil, fmt.Errorf("unknown argument %q", name)
+		}
+	}
+
+	IDs, err := g.d.IDs(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	if after, ok := args["after"].(string); ok {
+		i := 0
+		for i < len(IDs) && IDs[i] != after {
+			i++
+		}
+
+		if i == len(IDs) {
+			return nil, fmt.Errorf("no record %q to start after", after)
+		}
+
+		IDs = IDs[i+1:]
+	}
+
+	records := []interface{}{}
+
+	for _, ID := range IDs {
+		if first >= 0 && len(records) == first {
+			break
+		}
+
+		raw, err := g.d.Read(collection, ID)
+		if err != nil {
+			return nil, err
+		}
+
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.UseNumber()
+
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+
+		obj, _ := doc.(map[string]interface{})
+		if obj == nil {
+			obj = map[string]interface{}{}
+		}
+
+		if _, ok := obj["id"]; !ok {
+			obj["id"] = ID
+		}
+
+		if !matches(obj, filter) {
+			continue
+		}
+
+		records = append(records, project(obj, f.selection, typeName))
+	}
+
+	return records, nil
+}
+
+// checkSelection checks the fields selected exist in the schema
+func checkSelection(typeName string, schema *jdb.Schema, selection []gqlField, root bool) error {
+	if len(selection) == 0 {
+		return fmt.Errorf("field of type %s must have a selection of subfields", typeName)
+	}
+
+	for _, f := range selection {
+		if f.name == "__typename" || root && f.name == "id" {
+			if len(f.selection) > 0 {
+				return fmt.Errorf("field %q of type %s can't have a selection", f.name, typeName)
+			}
+			continue
+		}
+
+		if schema == nil || schema.Properties == nil {
+			continue
+		}
+
+		prop, ok := schema.Properties[f.name]
+		if !ok {
+			return fmt.Errorf("unknown field %q on type %s", f.name, typeName)
+		}
+
+		for prop != nil && prop.Type == "array" {
+			prop = prop.Items
+		}
+
+		if prop != nil && prop.Type == "object" {
+			if err := checkSelection(typeName+"_"+f.name, prop, f.selection, false); err != nil {
+				return err
+			}
+		} else if prop != nil && prop.Type != "" && len(f.selection) > 0 {
+			return fmt.Errorf("field %q of type %s can't have a selection", f.name, typeName)
+		}
+	}
+
+	return nil
+}
+
+// project returns the selected fields of v
+func project(v interface{}, selection []gqlField, typeName string) interface{} {
+	if len(selection) == 0 {
+		return v
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(selection))
+		for _, f := range selection {
+			if f.name == "__typename" {
+				out[f.alias] = typeName
+				continue
+			}
+
+			out[f.alias] = project(v[f.name], f.selection, typeName+"_"+f.name)
+		}
+
+		return orderedObject{keys: aliases(selection), values: out}
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = project(e, selection, typeName)
+		}
+
+		return out
+	default:
+		return v
+	}
+}
+
+// orderedObject marshals its keys in selection order as GraphQL expects
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func aliases(selection []gqlField) []string {
+	keys := make([]string, 0, len(selection))
+	seen := make(map[string]bool, len(selection))
+
+	for _, f := range selection {
+		if !seen[f.alias] {
+			seen[f.alias] = true
+			keys = append(keys, f.alias)
+		}
+	}
+
+	return keys
+}
+
+// matches reports whether the fields of the filter equal those of obj
+func matches(obj, filter map[string]interface{}) bool {
+	for k, want := range filter {
+		if !equal(obj[k], want) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equal(a, b interface{}) bool {
+	switch b := b.(type) {
+	case map[string]interface{}:
+		a, ok := a.(map[string]interface{})
+		return ok && matches(a, b)
+	case []interface{}:
+		a, ok := a.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+
+		return true
+	case json.Number:
+		a, ok := a.(json.Number)
+		if !ok {
+			return false
+		}
+
+		af, aerr := a.Float64()
+		bf, berr := b.Float64()
+		return aerr == nil && berr == nil && af == bf
+	default:
+		return a == b
+	}
+}
+
+// resolveArgs substitutes the variables referenced by the arguments
+func resolveArgs(args map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	resolved, err := resolveValue(args, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolved.(map[string]interface{}), nil
+}
+
+func resolveValue(v interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case gqlVariable:
+		value, ok := vars[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", v)
+		}
+
+		return value, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			var err error
+			if out[k], err = resolveValue(e, vars); err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if out[i], err = resolveValue(e, vars); err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// typeName returns the GraphQL type of the records of the collection
+func typeName(collection string) string {
+	parts := strings.FieldsFunc(collection, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, p := range parts {
+		parts[i] = strings.ToUpper(p[:1]) + p[1:]
+	}
+
+	return strings.Join(parts, "")
+}
+
+// gqlParser parses the query subset of GraphQL
+type gqlParser struct {
+	src string
+	pos int
+}
+
+// parseGraphQL returns the selection set of the query in the document
+func parseGraphQL(src string) ([]gqlField, error) {
+	p := &gqlParser{src: src}
+
+	p.skip()
+	if p.peekName() {
+		switch op := p.name(); op {
+		case "query":
+		case "mutation", "subscription":
+			return nil, fmt.Errorf("%s operations are not supported", op)
+		case "fragment":
+			return nil, fmt.Errorf("fragments are not supported")
+		default:
+			return nil, p.errorf("unexpected %q", op)
+		}
+
+		p.skip()
+		if p.peekName() {
+			p.name()
+		}
+
+		if err := p.skipVariableDefinitions(); err != nil {
+			return nil, err
+		}
+	}
+
+	selection, err := p.selection()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skip(); p.pos < len(p.src) {
+		return nil, p.errorf("only one operation is supported")
+	}
+
+	return selection, nil
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skip skips whitespace, commas and comments
+func (p *gqlParser) skip() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *gqlParser) peek(c byte) bool {
+	p.skip()
+	return p.pos < len(p.src) && p.src[p.pos] == c
+}
+
+func (p *gqlParser) expect(c byte) error {
+	if !p.peek(c) {
+		return p.errorf("expected %q", c)
+	}
+
+	p.pos++
+	return nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *gqlParser) peekName() bool {
+	p.skip()
+	return p.pos < len(p.src) && isNameStart(p.src[p.pos])
+}
+
+func (p *gqlParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) && (isNameStart(p.src[p.pos]) || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+		p.pos++
+	}
+
+	return p.src[start:p.pos]
+}
+
+// skipVariableDefinitions skips ($a: Type = default, ...)
+func (p *gqlParser) skipVariableDefinitions() error {
+	if !p.peek('(') {
+		return nil
+	}
+
+	depth := 0
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				p.pos++
+				return nil
+			}
+		}
+	}
+
+	return p.errorf("unterminated variable definitions")
+}
+
+func (p *gqlParser) selection() ([]gqlField, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+
+	var fields []gqlField
+
+	for !p.peek('}') {
+		if p.peek('.') {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+
+		if !p.peekName() {
+			return nil, p.errorf("expected a field")
+		}
+
+		f := gqlField{name: p.name()}
+		f.alias = f.name
+
+		if p.peek(':') {
+			p.pos++
+			if !p.peekName() {
+				return nil, p.errorf("expected a field after alias %q", f.alias)
+			}
+
+			f.name = p.name()
+		}
+
+		if p.peek('(') {
+			p.pos++
+
+			f.args = map[string]interface{}{}
+			for !p.peek(')') {
+				if !p.peekName() {
+					return nil, p.errorf("expected an argument")
+				}
+
+				name := p.name()
+				if err := p.expect(':'); err != nil {
+					return nil, err
+				}
+
+				v, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+
+				f.args[name] = v
+			}
+			p.pos++
+		}
+
+		if p.peek('@') {
+			return nil, fmt.Errorf("directives are not supported")
+		}
+
+		if p.peek('{') {
+			var err error
+			if f.selection, err = p.selection(); err != nil {
+				return nil, err
+			}
+		}
+
+		fields = append(fields, f)
+	}
+	p.pos++
+
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection")
+	}
+
+	return fields, nil
+}
+
+func (p *gqlParser) value() (interface{}, error) {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("expected a value")
+	}
+
+	switch c := p.src[p.pos]; {
+	case c == '$':
+		p.pos++
+		if !p.peekName() {
+			return nil, p.errorf("expected a variable name")
+		}
+
+		return gqlVariable(p.name()), nil
+	case c == '"':
+		return p.string()
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos++; p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0; p.pos++ {
+		}
+
+		n := json.Number(p.src[start:p.pos])
+		if _, err := n.Float64(); err != nil {
+			return nil, p.errorf("invalid number %q", n)
+		}
+
+		return n, nil
+	case c == '[':
+		p.pos++
+
+		list := []interface{}{}
+		for !p.peek(']') {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, v)
+		}
+		p.pos++
+
+		return list, nil
+	case c == '{':
+		p.pos++
+
+		obj := map[string]interface{}{}
+		for !p.peek('}') {
+			if !p.peekName() {
+				return nil, p.errorf("expected a field name")
+			}
+
+			name := p.name()
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+
+			obj[name] = v
+		}
+		p.pos++
+
+		return obj, nil
+	case isNameStart(c):
+		switch name := p.name(); name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			// enum values are matched as strings
+			return name, nil
+		}
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+func (p *gqlParser) string() (string, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '"':
+			p.pos++
+
+			var s string
+			if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+				return "", p.errorf("invalid string %s", p.src[start:p.pos])
+			}
+
+			return s, nil
+		case '\n':
+			return "", p.errorf("unterminated string")
+		}
+	}
+
+	return "", p.errorf("unterminated string")
+}
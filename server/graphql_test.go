@@ -0,0 +1,183 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func graphQLStore(t *testing.T) *jdb.Driver {
+	t.Helper()
+
+	d := jdbtest.New(t)
+
+	users := map[string]string{
+		"u1": `{"name":"ada","country":"UK","address":{"city":"london","zip":"n1"}}`,
+		"u2": `{"name":"grace","country":"US","address":{"city":"arlington"}}`,
+		"u3": `{"name":"alan","country":"UK","address":{"city":"wilmslow"}}`,
+		"u4": `{"name":"edsger","country":"NL"}`,
+	}
+
+	for ID, doc := range users {
+		if _, err := d.Write("users", ID, json.RawMessage(doc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := d.Write("teams/core", "t1", json.RawMessage(`{"name":"core"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func query(t *testing.T, h http.Handler, q string, vars string) (int, string) {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]interface{}{"query": q, "variables": json.RawMessage(vars)})
+	w := do(h, http.MethodPost, "/graphql", string(body))
+
+	return w.Code, strings.TrimSpace(w.Body.String())
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	h := NewGraphQL(graphQLStore(t), "users", "teams/core")
+
+	tests := []struct {
+		name, query, vars, want string
+	}{
+		{
+			name:  "every record",
+			query: `{ users { id name } }`,
+			want:  `{"data":{"users":[{"id":"u1","name":"ada"},{"id":"u2","name":"grace"},{"id":"u3","name":"alan"},{"id":"u4","name":"edsger"}]}}`,
+		},
+		{
+			name:  "filter and nested selection in selection order",
+			query: `{ users(filter: {country: "UK"}) { address { zip city } name } }`,
+			want:  `{"data":{"users":[{"address":{"zip":"n1","city":"london"},"name":"ada"},{"address":{"zip":null,"city":"wilmslow"},"name":"alan"}]}}`,
+		},
+		{
+			name:  "first and after",
+			query: `{ users(first: 2, after: "u1") { id } }`,
+			want:  `{"data":{"users":[{"id":"u2"},{"id":"u3"}]}}`,
+		},
+		{
+			name:  "aliases, variables and typename",
+			query: `query Q($c: String) { uk: users(filter: {country: $c}, first: 1) { id __typename } __typename }`,
+			vars:  `{"c":"UK"}`,
+			want:  `{"data":{"uk":[{"id":"u1","__typename":"Users"}],"__typename":"Query"}}`,
+		},
+		{
+			name:  "nested collection",
+			query: `{ teams_core { id name } }`,
+			want:  `{"data":{"teams_core":[{"id":"t1","name":"core"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := tt.vars
+			if vars == "" {
+				vars = "null"
+			}
+
+			code, got := query(t, h, tt.query, vars)
+			if code != http.StatusOK || !sameJSON(got, tt.want) {
+				t.Errorf("%s = %d %s, want %s", tt.query, code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	d := graphQLStore(t)
+
+	err := d.SetSchema("users", &jdb.Schema{
+		Type: "object",
+		Properties: map[string]*jdb.Schema{
+			"name":    {Type: "string"},
+			"country": {Type: "string"},
+			"address": {Type: "object", Properties: map[string]*jdb.Schema{"city": {Type: "string"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewGraphQL(d, "users")
+
+	tests := []struct {
+		query string
+		code  int
+		error string
+	}{
+		{query: `{ users { id `, code: http.StatusBadRequest},
+		{query: `{ orders { id } }`, code: http.StatusOK, error: `unknown field "orders"`},
+		{query: `{ users { email } }`, code: http.StatusOK, error: `unknown field "email"`},
+		{query: `{ users { address } }`, code: http.StatusOK, error: "must have a selection"},
+		{query: `{ users { name { first } } }`, code: http.StatusOK, error: "can't have a selection"},
+		{query: `{ users(first: -1) { id } }`, code: http.StatusOK, error: "non negative"},
+		{query: `{ users(after: "nobody") { id } }`, code: http.StatusOK, error: "nobody"},
+		{query: `{ users(sort: "name") { id } }`, code: http.StatusOK, error: `unknown argument "sort"`},
+	}
+
+	for _, tt := range tests {
+		code, got := query(t, h, tt.query, "null")
+
+		var resp struct {
+			Data   interface{}
+			Errors []struct{ Message string }
+		}
+		if err := json.Unmarshal([]byte(got), &resp); err != nil {
+			t.Fatalf("%s: decoding %s: %s", tt.query, got, err)
+		}
+
+		if code != tt.code || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, tt.error) || resp.Data != nil {
+			t.Errorf("%s = %d %s, want %d and an error about %s", tt.query, code, got, tt.code, tt.error)
+		}
+	}
+}
+
+func TestGraphQLOverGET(t *testing.T) {
+	h := NewGraphQL(graphQLStore(t), "users")
+
+	target := "/graphql?" + url.Values{
+		"query":     {`query($n: Int) { users(first: $n) { name } }`},
+		"variables": {`{"n":1}`},
+	}.Encode()
+
+	w := do(h, http.MethodGet, target, "")
+	if w.Code != http.StatusOK || !sameJSON(w.Body.String(), `{"data":{"users":[{"name":"ada"}]}}`) {
+		t.Errorf("GET = %d %s", w.Code, w.Body)
+	}
+
+	if w := do(h, http.MethodPut, "/graphql", "{}"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestGraphQLChecksScope(t *testing.T) {
+	auth := &Auth{Tokens: map[string]Scope{"t": {"teams/core"}}}
+	h := auth.Handler(NewGraphQL(graphQLStore(t), "users", "teams/core"))
+
+	body := `{"query":"{ users { id } }"}`
+
+	w := do(h, http.MethodPost, "/graphql", body, "Authorization", "Bearer t")
+	if !strings.Contains(w.Body.String(), "no access to users") {
+		t.Errorf("query out of scope = %d %s", w.Code, w.Body)
+	}
+
+	if w := do(h, http.MethodPost, "/graphql", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("query without credentials = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w = do(h, http.MethodPost, "/graphql", `{"query":"{ teams_core { id } }"}`, "Authorization", "Bearer t")
+	if !sameJSON(w.Body.String(), `{"data":{"teams_core":[{"id":"t1"}]}}`) {
+		t.Errorf("query in scope = %d %s", w.Code, w.Body)
+	}
+}
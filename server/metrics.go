@@ -0,0 +1,209 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arham09/jdb"
+)
+
+// latencyBuckets are the upper bounds in seconds of the request latency
+// histogram buckets
+var latencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// StatsSource is implemented by stores able to report their size cheaply,
+// like the Driver
+type StatsSource interface {
+	Sizes() (jdb.Stats, error)
+}
+
+type (
+	// metrics counts the requests served by a Handler
+	metrics struct {
+		mutex     sync.Mutex
+		requests  map[requestKey]uint64
+		latencies map[string]*histogram
+	}
+
+	requestKey struct {
+		op   string
+		code int
+	}
+
+	histogram struct {
+		counts []uint64
+		sum    float64
+		count  uint64
+	}
+
+	// statusRecorder remembers the status code of a response
+	statusRecorder struct {
+		http.ResponseWriter
+		code int
+	}
+)
+
+func newMetrics() *metrics {
+	return &metrics{requests: map[requestKey]uint64{}, latencies: map[string]*histogram{}}
+}
+
+func (m *metrics) observe(op string, code int, elapsed time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.requests[requestKey{op, code}]++
+
+	// change streams last as long as the client listens
+	if op == "watch" {
+		return
+	}
+
+	h := m.latencies[op]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latencies[op] = h
+	}
+
+	s := elapsed.Seconds()
+	for i, bound := range latencyBuckets {
+		if s <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += s
+	h.count++
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// operation names the operation of a request for metrics
+func operation(method string, parts []string) string {
+	switch {
+	case len(parts) == 1 && parts[0] == "metrics":
+		return "metrics"
+	case len(parts) == 2 && parts[0] == "records" && method == http.MethodGet:
+		return "read_all"
+	case len(parts) == 2 && parts[0] == "records" && method == http.MethodPost:
+		return "insert"
+	case len(parts) == 3 && parts[0] == "records":
+		switch method {
+		case http.MethodGet:
+			return "read"
+		case http.MethodHead:
+			return "exists"
+		case http.MethodPut:
+			return "write"
+		case http.MethodDelete:
+			return "delete"
+		}
+	case len(parts) == 3 && parts[0] == "collections" && parts[2] == "watch":
+		return "watch"
+	case len(parts) == 2 && parts[0] == "ids":
+		return "ids"
+	}
+
+	return "other"
+}
+
+// serveMetrics writes the metrics in the Prometheus text format, along with
+// the size of every collection when the store reports it
+func (h *Handler) serveMetrics(w http.ResponseWriter) {
+	var stats *jdb.Stats
+
+	if src, ok := h.store.(StatsSource); ok {
+		s, err := src.Sizes()
+		if err != nil {
+			respond(w, nil, err)
+			return
+		}
+
+		stats = &s
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	h.metrics.write(w)
+
+	if stats == nil {
+		return
+	}
+
+	fmt.Fprintln(w, "# HELP jdb_collection_records Records of the collection.")
+	fmt.Fprintln(w, "# TYPE jdb_collection_records gauge")
+	for _, c := range stats.Collections {
+		fmt.Fprintf(w, "jdb_collection_records{collection=%s} %d\n", quote(c.Collection), c.Records)
+	}
+
+	fmt.Fprintln(w, "# HELP jdb_collection_bytes Size of the record files of the collection.")
+	fmt.Fprintln(w, "# TYPE jdb_collection_bytes gauge")
+	for _, c := range stats.Collections {
+		fmt.Fprintf(w, "jdb_collection_bytes{collection=%s} %d\n", quote(c.Collection), c.Bytes)
+	}
+
+	fmt.Fprintln(w, "# HELP jdb_disk_usage_bytes Size of every file of the data directory.")
+	fmt.Fprintln(w, "# TYPE jdb_disk_usage_bytes gauge")
+	fmt.Fprintf(w, "jdb_disk_usage_bytes %d\n", stats.DiskUsage)
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].op != keys[j].op {
+			return keys[i].op < keys[j].op
+		}
+
+		return keys[i].code < keys[j].code
+	})
+
+	fmt.Fprintln(w, "# HELP jdb_requests_total Requests served, by operation and status code.")
+	fmt.Fprintln(w, "# TYPE jdb_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "jdb_requests_total{op=%s,code=\"%d\"} %d\n", quote(k.op), k.code, m.requests[k])
+	}
+
+	ops := make([]string, 0, len(m.latencies))
+	for op := range m.latencies {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	fmt.Fprintln(w, "# HELP jdb_request_duration_seconds Latency of requests, by operation.")
+	fmt.Fprintln(w, "# TYPE jdb_request_duration_seconds histogram")
+	for _, op := range ops {
+		h := m.latencies[op]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "jdb_request_duration_seconds_bucket{op=%s,le=\"%s\"} %d\n", quote(op), strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+
+		fmt.Fprintf(w, "jdb_request_duration_seconds_bucket{op=%s,le=\"+Inf\"} %d\n", quote(op), h.count)
+		fmt.Fprintf(w, "jdb_request_duration_seconds_sum{op=%s} %g\n", quote(op), h.sum)
+		fmt.Fprintf(w, "jdb_request_duration_seconds_count{op=%s} %d\n", quote(op), h.count)
+	}
+}
+
+// quote quotes a label value
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
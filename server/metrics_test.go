@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestMetricsExportOnlyCheapCounters(t *testing.T) {
+	d := jdbtest.New(t)
+	jdbtest.Seed(t, d, "users", 3, jdbtest.Sequence("u", map[string]string{"email": "a@b.c"}))
+
+	w := httptest.NewRecorder()
+	New(d, nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := w.Body.String()
+
+	if !strings.Contains(body, `jdb_collection_records{collection="users"} 3`) {
+		t.Errorf("metrics miss the records of users:\n%s", body)
+	}
+
+	if strings.Contains(body, "jdb_field_") {
+		t.Errorf("metrics export per field series:\n%s", body)
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/arham09/jdb"
+)
+
+// SchemaSource is implemented by stores knowing the schemas of their
+// collections, like the Driver
+type SchemaSource interface {
+	Schema(collection string) *jdb.Schema
+}
+
+// schema returns the schema of the collection, nil when the store has none
+func (h *Handler) schema(collection string) *jdb.Schema {
+	src, ok := h.store.(SchemaSource)
+	if !ok {
+		return nil
+	}
+
+	return src.Schema(collection)
+}
+
+// validateBody checks a request body against the schema of the collection,
+// so stores without schemas of their own get the same validation
+func (h *Handler) validateBody(collection, ID string, body json.RawMessage) error {
+	schema := h.schema(collection)
+	if schema == nil {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return err
+	}
+
+	if violations := schema.Validate(v); len(violations) > 0 {
+		return &jdb.ValidationError{Collection: collection, ID: ID, Violations: violations}
+	}
+
+	return nil
+}
+
+// serveOpenAPI writes the OpenAPI document of the collections of
+// Options.Collections
+func (h *Handler) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	respond(w, h.openAPI(r), nil)
+}
+
+type object = map[string]interface{}
+
+// openAPI returns an OpenAPI 3.1 document describing the endpoints of every
+// collection, with the records described by their schema
+func (h *Handler) openAPI(r *http.Request) object {
+	schemas := object{
+		"Error": object{
+			"type":     "object",
+			"required": []string{"type", "title", "status", "code", "error"},
+			"properties": object{
+				"type":       object{"type": "string"},
+				"title":      object{"type": "string"},
+				"status":     object{"type": "integer"},
+				"detail":     object{"type": "string"},
+				"code":       object{"type": "string"},
+				"error":      object{"type": "string"},
+				"collection": object{"type": "string"},
+				"id":         object{"type": "string"},
+				"violations": object{"type": "array", "items": object{
+					"type": "object",
+					"properties": object{
+						"pointer":    object{"type": "string"},
+						"constraint": object{"type": "string"},
+						"message":    object{"type": "string"},
+					},
+				}},
+			},
+		},
+		"ID": object{"type": "object", "properties": object{"id": object{"type": "string"}}},
+	}
+
+	paths := object{}
+
+	for _, c := range h.opts.Collections {
+		if !allowed(r, c) {
+			continue
+		}
+
+		name := typeName(c)
+
+		var schema interface{} = object{}
+		if s := h.schema(c); s != nil {
+			schema = s
+		}
+
+		schemas[name] = schema
+
+		ref := object{"$ref": "#/components/schemas/" + name}
+		record := content(ref)
+		id := object{"name": "id", "in": "path", "required": true, "schema": object{"type": "string"}}
+
+		path := "/records/" + url.PathEscape(c)
+
+		paths[path] = object{
+			"get": object{
+				"summary":   "Read every record of " + c,
+				"responses": responses("200", "The records", content(object{"type": "array", "items": ref})),
+			},
+			"post": object{
+				"summary":     "Insert a record into " + c,
+				"requestBody": object{"required": true, "content": record["content"]},
+				"responses":   responses("200", "The ID of the record", content(object{"$ref": "#/components/schemas/ID"})),
+			},
+		}
+
+		paths[path+"/{id}"] = object{
+			"parameters": []interface{}{id},
+			"get": object{
+				"summary":   "Read a record of " + c,
+				"responses": responses("200", "The record", record),
+			},
+			"head": object{
+				"summary":   "Check a record of " + c + " exists",
+				"responses": object{"200": object{"description": "The record exists"}, "404": object{"description": "The record doesn't exist"}},
+			},
+			"put": object{
+				"summary": "Write a record of " + c,
+				"parameters": []interface{}{object{
+					"name": "If-Match", "in": "header", "schema": object{"type": "string", "enum": []string{"*"}},
+					"description": "Only update an existing record",
+				}},
+				"requestBody": object{"required": true, "content": record["content"]},
+				"responses":   responses("200", "The ID of the record", content(object{"$ref": "#/components/schemas/ID"})),
+			},
+			"delete": object{
+				"summary":   "Delete a record of " + c,
+				"responses": responses("204", "The record was deleted", nil),
+			},
+		}
+
+		paths["/ids/"+url.PathEscape(c)] = object{
+			"get": object{
+				"summary":   "List the IDs of " + c,
+				"responses": responses("200", "The IDs", content(object{"type": "array", "items": object{"type": "string"}})),
+			},
+		}
+	}
+
+	doc := object{
+		"openapi":    "3.1.0",
+		"info":       object{"title": "jdb", "version": "1"},
+		"paths":      paths,
+		"components": object{"schemas": schemas},
+	}
+
+	if h.opts.Auth != nil {
+		doc["components"].(object)["securitySchemes"] = object{
+			"bearer": object{"type": "http", "scheme": "bearer"},
+			"apiKey": object{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			"basic":  object{"type": "http", "scheme": "basic"},
+		}
+		doc["security"] = []interface{}{object{"bearer": []string{}}, object{"apiKey": []string{}}, object{"basic": []string{}}}
+	}
+
+	return doc
+}
+
+func content(schema object) object {
+	return object{"content": object{"application/json": object{"schema": schema}}}
+}
+
+// responses returns the success response along with the error ones
+func responses(code, description string, body object) object {
+	ok := object{"description": description}
+	for k, v := range body {
+		ok[k] = v
+	}
+
+	errResponse := func(description string) object {
+		schema := object{"schema": object{"$ref": "#/components/schemas/Error"}}
+		return object{"description": description, "content": object{"application/problem+json": schema}}
+	}
+
+	return object{
+		code:      ok,
+		"400":     errResponse("The request is malformed"),
+		"404":     errResponse("No such record"),
+		"409":     errResponse("The change conflicts with the record or its collection"),
+		"412":     errResponse("The condition of the write doesn't hold"),
+		"413":     errResponse("The body is too large"),
+		"422":     errResponse("The record is invalid"),
+		"507":     errResponse("The server is out of storage"),
+		"default": errResponse("An error occurred"),
+	}
+}
@@ -0,0 +1,391 @@
+// Package server serves a jdb.Store over HTTP as a small REST API, see
+// package client for the Go client.
+//
+// Records are addressed as /records/{collection}/{id}, nested collections
+// having their slashes escaped as %2F:
+//
+//	GET    /records/{collection}       every record, as a JSON array
+//	POST   /records/{collection}       insert, responds {"id": ...}
+//	GET    /records/{collection}/{id}  a record
+//	HEAD   /records/{collection}/{id}  whether a record exists
+//	PUT    /records/{collection}/{id}  write, If-Match: * only updates
+//	DELETE /records/{collection}/{id}  delete
+//	GET    /ids/{collection}           the IDs, as a JSON array
+//	GET    /collections/{collection}/watch
+//	                                   changes, as server-sent events
+//	GET    /metrics                    metrics in the Prometheus format
+//	GET    /openapi.json               the OpenAPI document of the API
+//
+// Errors are served as application/problem+json with the status matching the
+// error of the Driver, see Error.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/arham09/jdb"
+)
+
+// Error codes of error responses, the body of which is an Error
+const (
+	CodeNotFound        = "not_found"
+	CodeInvalid         = "invalid"
+	CodeAppendOnly      = "append_only"
+	CodeReadOnly        = "read_only"
+	CodeConditionFailed = "condition_failed"
+	CodeConflict        = "conflict"
+	CodeOverloaded      = "overloaded"
+	CodeFrozen          = "frozen"
+	CodeStorageFull     = "storage_full"
+	CodeTooLarge        = "too_large"
+	CodeBadRequest      = "bad_request"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeInternal        = "internal"
+)
+
+// errorType prefixes the code of an Error into its problem type
+const errorType = "urn:jdb:error:"
+
+// Error is the body of error responses, an RFC 7807 problem served as
+// application/problem+json. Its type is the code prefixed by urn:jdb:error:
+// and its detail the message, which is also kept under error for older
+// clients
+type Error struct {
+	Type       string          `json:"type"`
+	Title      string          `json:"title"`
+	Status     int             `json:"status"`
+	Detail     string          `json:"detail"`
+	Code       string          `json:"code"`
+	Message    string          `json:"error"`
+	Collection string          `json:"collection,omitempty"`
+	ID         string          `json:"id,omitempty"`
+	Violations []jdb.Violation `json:"violations,omitempty"`
+}
+
+type (
+	// Handler serves a jdb.Store
+	Handler struct {
+		store   jdb.Store
+		opts    Options
+		metrics *metrics
+		routes  http.Handler
+	}
+
+	// Options configures a Handler
+	Options struct {
+		// Auth requires requests to authenticate, the server is open to
+		// anyone when it's nil
+		Auth *Auth
+
+		// CertFile and KeyFile are the PEM files of the certificate
+		// ListenAndServe serves TLS with, it serves plaintext without them
+		CertFile, KeyFile string
+
+		// ClientCAFile is a PEM file of the CAs client certificates are
+		// verified against, turning on mutual TLS
+		ClientCAFile string
+
+		// RequireClientCert refuses clients without a certificate, they're
+		// only verified when they present one otherwise
+		RequireClientCert bool
+
+		// Collections are the collections described by the OpenAPI
+		// document, with the schemas the store has for them
+		Collections []string
+
+		// MaxBodySize is the largest record body accepted in bytes,
+		// defaulting to 8 MiB
+		MaxBodySize int64
+	}
+)
+
+// New returns a Handler serving the store, opts may be nil
+func New(store jdb.Store, opts *Options) *Handler {
+	h := &Handler{store: store, metrics: newMetrics()}
+	if opts != nil {
+		h.opts = *opts
+	}
+
+	h.routes = http.HandlerFunc(h.route)
+	if h.opts.Auth != nil {
+		h.routes = h.opts.Auth.Handler(h.routes)
+	}
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts, _ := splitPath(r.URL.EscapedPath())
+
+	rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+	start := time.Now()
+
+	h.routes.ServeHTTP(rec, r)
+
+	h.metrics.observe(operation(r.Method, parts), rec.code, time.Since(start))
+}
+
+func (h *Handler) route(w http.ResponseWriter, r *http.Request) {
+	parts, err := splitPath(r.URL.EscapedPath())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, &Error{Code: CodeBadRequest, Message: err.Error()})
+		return
+	}
+
+	// watching "*" checks the access to every collection, see watch
+	watchAll := len(parts) == 3 && parts[0] == "collections" && parts[1] == "*" && parts[2] == "watch"
+
+	if len(parts) >= 2 && !watchAll && !allowed(r, parts[1]) {
+		forbidden(w, parts[1])
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "metrics" && r.Method == http.MethodGet:
+		if !allowed(r, "") {
+			forbidden(w, "metrics")
+			return
+		}
+
+		h.serveMetrics(w)
+	case len(parts) == 1 && parts[0] == "openapi.json" && r.Method == http.MethodGet:
+		h.serveOpenAPI(w, r)
+	case len(parts) == 2 && parts[0] == "records":
+		h.collection(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "records":
+		h.record(w, r, parts[1], parts[2])
+	case len(parts) == 3 && parts[0] == "collections" && parts[2] == "watch" && r.Method == http.MethodGet:
+		h.watch(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "ids" && r.Method == http.MethodGet:
+		IDs, err := h.store.IDs(parts[1])
+		respond(w, IDs, err)
+	default:
+		writeError(w, http.StatusNotFound, &Error{Code: CodeNotFound, Message: "no such endpoint"})
+	}
+}
+
+func (h *Handler) collection(w http.ResponseWriter, r *http.Request, collection string) {
+	switch r.Method {
+	case http.MethodGet:
+		records, err := h.store.ReadAll(collection)
+		if err != nil {
+			respond(w, nil, err)
+			return
+		}
+
+		raw := make([]json.RawMessage, len(records))
+		for i, record := range records {
+			raw[i] = json.RawMessage(record)
+		}
+
+		respond(w, raw, nil)
+	case http.MethodPost:
+		body, err := h.readBody(w, r)
+		if err == nil {
+			err = h.validateBody(collection, "", body)
+		}
+
+		if err != nil {
+			respond(w, nil, err)
+			return
+		}
+
+		ID, err := h.store.Insert(collection, body)
+		respond(w, map[string]string{"id": ID}, err)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, &Error{Code: CodeBadRequest, Message: "method not allowed"})
+	}
+}
+
+func (h *Handler) record(w http.ResponseWriter, r *http.Request, collection, ID string) {
+	switch r.Method {
+	case http.MethodGet:
+		record, err := h.store.Read(collection, ID)
+		if err != nil {
+			respond(w, nil, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, record)
+	case http.MethodHead:
+		exists, err := h.store.Exists(collection, ID)
+		switch {
+		case err != nil:
+			w.WriteHeader(status(err))
+		case !exists:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	case http.MethodPut:
+		body, err := h.readBody(w, r)
+		if err == nil {
+			err = h.validateBody(collection, ID, body)
+		}
+
+		if err != nil {
+			respond(w, nil, err)
+			return
+		}
+
+		if r.Header.Get("If-Match") == "*" {
+			_, err = h.store.Update(collection, ID, body)
+		} else {
+			_, err = h.store.Write(collection, ID, body)
+		}
+
+		respond(w, map[string]string{"id": ID}, err)
+	case http.MethodDelete:
+		if err := h.store.Delete(collection, ID); err != nil {
+			respond(w, nil, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, &Error{Code: CodeBadRequest, Message: "method not allowed"})
+	}
+}
+
+// splitPath splits an escaped path into its unescaped segments
+func splitPath(path string) ([]string, error) {
+	var parts []string
+
+	for _, p := range strings.Split(strings.Trim(path, "/"), "/") {
+		part, err := url.PathUnescape(p)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+// defaultMaxBodySize is the default of Options.MaxBodySize
+const defaultMaxBodySize = 8 << 20
+
+// readBody reads the JSON body of the request, up to Options.MaxBodySize
+func (h *Handler) readBody(w http.ResponseWriter, r *http.Request) (json.RawMessage, error) {
+	limit := h.opts.MaxBodySize
+	if limit <= 0 {
+		limit = defaultMaxBodySize
+	}
+
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil && int64(len(b)) >= limit {
+		return nil, fmt.Errorf("body is larger than %d bytes: %w", limit, errTooLarge)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(b) {
+		return nil, errBadJSON
+	}
+
+	return json.RawMessage(b), nil
+}
+
+var (
+	errBadJSON  = errors.New("body is not valid JSON")
+	errTooLarge = errors.New("body too large")
+)
+
+// respond writes v as JSON, or the error
+func respond(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		writeError(w, status(err), toError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, e *Error) {
+	e.Type = errorType + e.Code
+	e.Title = http.StatusText(code)
+	e.Status = code
+	e.Detail = e.Message
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(e)
+}
+
+func status(err error) int {
+	switch code := toError(err).Code; code {
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeInvalid:
+		return http.StatusUnprocessableEntity
+	case CodeBadRequest:
+		return http.StatusBadRequest
+	case CodeReadOnly:
+		return http.StatusForbidden
+	case CodeAppendOnly, CodeConflict:
+		return http.StatusConflict
+	case CodeConditionFailed:
+		return http.StatusPreconditionFailed
+	case CodeStorageFull:
+		return http.StatusInsufficientStorage
+	case CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CodeOverloaded, CodeFrozen:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func toError(err error) *Error {
+	e := &Error{Code: CodeInternal, Message: err.Error()}
+
+	var invalid *jdb.ValidationError
+
+	switch {
+	case errors.As(err, &invalid):
+		e.Code = CodeInvalid
+		e.Collection, e.ID = invalid.Collection, invalid.ID
+		e.Violations = invalid.Violations
+	case errors.Is(err, errBadJSON), errors.Is(err, jdb.ErrInvalidName):
+		e.Code = CodeBadRequest
+	case errors.Is(err, errTooLarge):
+		e.Code = CodeTooLarge
+	case errors.Is(err, os.ErrNotExist):
+		e.Code = CodeNotFound
+	case errors.Is(err, jdb.ErrAppendOnly):
+		e.Code = CodeAppendOnly
+	case errors.Is(err, jdb.ErrReadOnly):
+		e.Code = CodeReadOnly
+	case errors.Is(err, jdb.ErrConditionFailed):
+		e.Code = CodeConditionFailed
+	case errors.Is(err, jdb.ErrConflict):
+		e.Code = CodeConflict
+	case errors.Is(err, jdb.ErrOverloaded):
+		e.Code = CodeOverloaded
+	case errors.Is(err, jdb.ErrFrozen):
+		e.Code = CodeFrozen
+	case errors.Is(err, syscall.ENOSPC):
+		e.Code = CodeStorageFull
+	}
+
+	return e
+}
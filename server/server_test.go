@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestScopeIsCheckedOnEveryRoute(t *testing.T) {
+	d := jdbtest.New(t)
+	if _, err := d.Write("*", "secret", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(d, &Options{Auth: &Auth{Tokens: map[string]Scope{"t": {"users"}}}})
+
+	for _, target := range []string{"/records/*", "/ids/*", "/records/*/secret", "/records/orders", "/collections/*/watch"} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Authorization", "Bearer t")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("GET %s = %d, want %d", target, w.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestBodySizeIsLimited(t *testing.T) {
+	h := New(jdbtest.New(t), &Options{MaxBodySize: 16})
+
+	for body, want := range map[string]int{
+		`{"n":1}`:                 http.StatusOK,
+		`{"name":"far too long"}`: http.StatusRequestEntityTooLarge,
+	} {
+		r := httptest.NewRequest(http.MethodPut, "/records/users/u", strings.NewReader(body))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != want {
+			t.Errorf("PUT %s = %d, want %d: %s", body, w.Code, want, w.Body)
+		}
+	}
+}
+
+// do serves a request, returning the recorded response
+func do(h http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestRecordsRoundTrip(t *testing.T) {
+	h := New(jdbtest.New(t), nil)
+
+	steps := []struct {
+		method, target, body string
+		header               []string
+		code                 int
+		want                 string
+	}{
+		{method: http.MethodGet, target: "/records/users/ada", code: http.StatusNotFound},
+		{method: http.MethodHead, target: "/records/users/ada", code: http.StatusNotFound},
+		{method: http.MethodPut, target: "/records/users/ada", body: `{"name":"ada"}`, header: []string{"If-Match", "*"}, code: http.StatusNotFound},
+		{method: http.MethodPut, target: "/records/users/ada", body: `{"name":"ada"}`, code: http.StatusOK, want: `{"id":"ada"}`},
+		{method: http.MethodGet, target: "/records/users/ada", code: http.StatusOK, want: `{"name":"ada"}`},
+		{method: http.MethodHead, target: "/records/users/ada", code: http.StatusOK},
+		{method: http.MethodPut, target: "/records/users/ada", body: `{"name":"lovelace"}`, header: []string{"If-Match", "*"}, code: http.StatusOK},
+		{method: http.MethodPut, target: "/records/users%2Fadmins/root", body: `{"name":"root"}`, code: http.StatusOK},
+		{method: http.MethodGet, target: "/records/users%2Fadmins/root", code: http.StatusOK, want: `{"name":"root"}`},
+		{method: http.MethodGet, target: "/records/users", code: http.StatusOK, want: `[{"name":"lovelace"}]`},
+		{method: http.MethodGet, target: "/ids/users", code: http.StatusOK, want: `["ada"]`},
+		{method: http.MethodDelete, target: "/records/users/ada", code: http.StatusNoContent},
+		{method: http.MethodGet, target: "/ids/users", code: http.StatusOK, want: `[]`},
+		{method: http.MethodPut, target: "/records/users/bad", body: `{"name":`, code: http.StatusBadRequest},
+		{method: http.MethodPatch, target: "/records/users/ada", code: http.StatusMethodNotAllowed},
+		{method: http.MethodPatch, target: "/records/users", code: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, target: "/nowhere", code: http.StatusNotFound},
+	}
+
+	for _, s := range steps {
+		w := do(h, s.method, s.target, s.body, s.header...)
+
+		if w.Code != s.code {
+			t.Fatalf("%s %s = %d, want %d: %s", s.method, s.target, w.Code, s.code, w.Body)
+		}
+
+		if s.want != "" && !sameJSON(w.Body.String(), s.want) {
+			t.Errorf("%s %s = %s, want %s", s.method, s.target, w.Body, s.want)
+		}
+	}
+}
+
+func TestInsertReturnsTheID(t *testing.T) {
+	d := jdbtest.New(t)
+	h := New(d, nil)
+
+	w := do(h, http.MethodPost, "/records/users", `{"name":"ada"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST = %d: %s", w.Code, w.Body)
+	}
+
+	var inserted struct{ ID string }
+	if err := json.Unmarshal(w.Body.Bytes(), &inserted); err != nil || inserted.ID == "" {
+		t.Fatalf("POST = %s, %v", w.Body, err)
+	}
+
+	if record, err := d.Read("users", inserted.ID); err != nil || !sameJSON(record, `{"name":"ada"}`) {
+		t.Errorf("inserted record = %s, %v", record, err)
+	}
+}
+
+func TestErrorsAreProblems(t *testing.T) {
+	d := jdbtest.New(t)
+
+	err := d.SetSchema("users", &jdb.Schema{
+		Type:       "object",
+		Required:   []string{"name"},
+		Properties: map[string]*jdb.Schema{"name": {Type: "string"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(d, nil)
+
+	w := do(h, http.MethodPut, "/records/users/ada", `{"name":1}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("PUT of an invalid record = %d: %s", w.Code, w.Body)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var e Error
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Code != CodeInvalid || e.Type != "urn:jdb:error:invalid" || e.Status != w.Code || e.ID != "ada" || len(e.Violations) == 0 {
+		t.Errorf("problem = %+v", e)
+	}
+
+	d.Freeze()
+	defer d.Thaw()
+
+	if w := do(h, http.MethodPut, "/records/orders/o", `{}`); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), CodeFrozen) {
+		t.Errorf("PUT while frozen = %d: %s", w.Code, w.Body)
+	}
+}
+
+func sameJSON(got, want string) bool {
+	var g, w interface{}
+	if json.Unmarshal([]byte(got), &g) != nil || json.Unmarshal([]byte(want), &w) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(g, w)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// ListenAndServe serves the Handler on addr, over TLS when Options.CertFile
+// is set
+func (h *Handler) ListenAndServe(addr string) error {
+	config, err := h.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: addr, Handler: h, TLSConfig: config}
+	if config == nil {
+		return srv.ListenAndServe()
+	}
+
+	return srv.ListenAndServeTLS("", "")
+}
+
+// TLSConfig returns the TLS configuration of the Options, nil when the
+// Handler is served in plaintext
+func (h *Handler) TLSConfig() (*tls.Config, error) {
+	if h.opts.CertFile == "" {
+		if h.opts.ClientCAFile != "" {
+			return nil, fmt.Errorf("missing certificate, client certificates need TLS")
+		}
+
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(h.opts.CertFile, h.opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if h.opts.ClientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(h.opts.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading client CAs: %w", err)
+	}
+
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in %s", h.opts.ClientCAFile)
+	}
+
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if h.opts.RequireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/arham09/jdb"
+)
+
+// keepAlive is how often an idle change stream sends a comment so proxies
+// don't close it
+const keepAlive = 15 * time.Second
+
+// Watcher is implemented by stores able to stream their changes, like the
+// Driver
+type Watcher interface {
+	Watch(collection string) (<-chan jdb.Event, func())
+}
+
+// ChangeEvent is the data of the server-sent events of a change stream
+type ChangeEvent struct {
+	Collection string    `json:"collection"`
+	ID         string    `json:"id"`
+	Op         string    `json:"op"`
+	External   bool      `json:"external,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// watch streams the changes of the collection as server-sent events named
+// after their Op, "*" watching every collection. Events are dropped for
+// clients lagging behind, as with Driver.Watch
+func (h *Handler) watch(w http.ResponseWriter, r *http.Request, collection string) {
+	watcher, ok := h.store.(Watcher)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, &Error{Code: CodeBadRequest, Message: "store can't be watched"})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, &Error{Code: CodeInternal, Message: "streaming unsupported"})
+		return
+	}
+
+	if collection == "*" {
+		if !allowed(r, "") {
+			forbidden(w, "every collection")
+			return
+		}
+
+		collection = ""
+	}
+
+	events, cancel := watcher.Watch(collection)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, _ := json.Marshal(ChangeEvent{
+				Collection: e.Collection,
+				ID:         e.ID,
+				Op:         e.Op.String(),
+				External:   e.External,
+				Time:       e.Time,
+			})
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Op, data)
+		}
+
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,251 @@
+package jdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+type (
+	// Session gathers the reads and writes of one logical request, like an
+	// HTTP handler touching many records. Reads are cached and see the
+	// writes of the Session, writes and deletes are held until Flush, which
+	// admits and locks each collection once for all of them. Sessions are
+	// safe for concurrent use but meant to live as long as the request
+	Session struct {
+		d *Driver
+
+		mutex   sync.Mutex
+		cache   map[sessionKey][]byte
+		changes map[sessionKey]sessionChange
+		order   []sessionKey
+	}
+
+	sessionKey struct {
+		collection, ID string
+	}
+
+	// sessionChange is a held write of v, or a delete
+	sessionChange struct {
+		v       interface{}
+		deleted bool
+	}
+)
+
+// Session starts a Session, its writes are lost unless it's flushed
+func (d *Driver) Session() *Session {
+	return &Session{
+		d:       d,
+		cache:   make(map[sessionKey][]byte),
+		changes: make(map[sessionKey]sessionChange),
+	}
+}
+
+// Read returns the record as the Session sees it, reading it from disk only
+// the first time
+func (s *Session) Read(collection, identifier string) (string, error) {
+	var data string
+
+	err := s.read(collection, identifier, func(b []byte) error {
+		data = string(b)
+		return nil
+	})
+
+	return data, err
+}
+
+// ReadInto decodes the record as the Session sees it into v, see
+// Driver.ReadInto
+func (s *Session) ReadInto(collection, identifier string, v interface{}) error {
+	return s.read(collection, identifier, func(b []byte) error {
+		return s.d.decode(collection, identifier, b, v)
+	})
+}
+
+func (s *Session) read(collection, identifier string, fn func([]byte) error) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to get data")
+	}
+
+	if identifier == "" {
+		return fmt.Errorf("missing ID, no identifier to get data")
+	}
+
+	if err := validateRecord(collection, identifier); err != nil {
+		return err
+	}
+
+	key := sessionKey{collection, identifier}
+
+	s.mutex.Lock()
+	change, changed := s.changes[key]
+	b, cached := s.cache[key]
+	s.mutex.Unlock()
+
+	switch {
+	case changed && change.deleted:
+		return notExist(filepath.Join(s.d.dir, collection, identifier+".json"))
+	case changed:
+		buf := getBuffer()
+		defer putBuffer(buf)
+
+		if err := s.d.encodeTo(buf, change.v); err != nil {
+			return err
+		}
+
+		return fn(buf.Bytes())
+	case cached:
+		return fn(b)
+	}
+
+	return s.d.readRecord(collection, identifier, func(b []byte) error {
+		s.mutex.Lock()
+		s.cache[key] = append([]byte(nil), b...)
+		s.mutex.Unlock()
+
+		return fn(b)
+	})
+}
+
+// Write holds the write of v under the ID until Flush, v must not be changed
+// until then. Later writes and deletes of the record replace it
+func (s *Session) Write(collection, identifier string, v interface{}) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if identifier == "" {
+		return fmt.Errorf("missing identifier")
+	}
+
+	if err := validateRecord(collection, identifier); err != nil {
+		return err
+	}
+
+	s.change(sessionKey{collection, identifier}, sessionChange{v: v})
+	return nil
+}
+
+// Delete holds the delete of the record until Flush, which fails with
+// ErrNotFound when the record is missing by then
+func (s *Session) Delete(collection, identifier string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to delete data")
+	}
+
+	if identifier == "" {
+		return fmt.Errorf("missing ID, no identifier to delete data")
+	}
+
+	if err := validateRecord(collection, identifier); err != nil {
+		return err
+	}
+
+	s.change(sessionKey{collection, identifier}, sessionChange{deleted: true})
+	return nil
+}
+
+func (s *Session) change(key sessionKey, change sessionChange) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.changes[key]; !ok {
+		s.order = append(s.order, key)
+	}
+
+	s.changes[key] = change
+}
+
+// Pending returns the number of writes and deletes held
+func (s *Session) Pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.changes)
+}
+
+// Flush writes and deletes the held records a collection at a time, in the
+// order they were first changed. It stops at the first failing change, which
+// stays held with the ones after it so Flush can be retried
+func (s *Session) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	defer func() {
+		held := s.order[:0]
+		for _, key := range s.order {
+			if _, ok := s.changes[key]; ok {
+				held = append(held, key)
+			}
+		}
+
+		s.order = held
+	}()
+
+	byCollection := make(map[string][]sessionKey)
+	for _, key := range s.order {
+		byCollection[key.collection] = append(byCollection[key.collection], key)
+	}
+
+	for _, collection := range sortedWriteKeys(byCollection) {
+		if err := s.flush(collection, byCollection[collection]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flush applies the changes of a collection, callers must hold the Session
+// mutex
+func (s *Session) flush(collection string, keys []sessionKey) error {
+	d := s.d
+
+	done, err := d.admit(collection)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	apply := func(key sessionKey, change sessionChange) error {
+		switch {
+		case d.replicator != nil && change.deleted:
+			return d.replicate(OpDelete, collection, key.ID, nil)
+		case d.replicator != nil:
+			return d.replicate(OpWrite, collection, key.ID, change.v)
+		case change.deleted:
+			return d.deleteLocked(collection, key.ID)
+		default:
+			_, err := d.writeLocked(collection, key.ID, change.v)
+			return err
+		}
+	}
+
+	if d.replicator == nil {
+		mutex := d.getMutex(collection)
+		mutex.Lock()
+		defer mutex.Unlock()
+	}
+
+	for _, key := range keys {
+		if err := apply(key, s.changes[key]); err != nil {
+			return fmt.Errorf("%s/%s: %w", collection, key.ID, err)
+		}
+
+		delete(s.changes, key)
+		delete(s.cache, key)
+	}
+
+	d.log.Debug("flushed %d session changes to %s", len(keys), collection)
+	return nil
+}
+
+// Discard drops the held writes and deletes and the cached reads
+func (s *Session) Discard() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.cache = make(map[sessionKey][]byte)
+	s.changes = make(map[sessionKey]sessionChange)
+	s.order = nil
+}
@@ -0,0 +1,284 @@
+package jdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// shadowDir is the reserved directory collections are rebuilt in before
+// being swapped with the live ones
+const shadowDir = "_shadow"
+
+// fingerprint sums up the entries of the directory of the collection, so a
+// change made while its shadow was built can be noticed. A missing directory
+// has an empty fingerprint
+func (d *Driver) fingerprint(collection string) (string, error) {
+	files, err := d.fs.ReadDir(filepath.Join(d.dir, collection))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	h := sha256.New()
+	for _, file := range files {
+		// dot files are the Driver's own, like the lock file
+		if file.Mode().IsRegular() && !strings.HasPrefix(file.Name(), ".") {
+			fmt.Fprintf(h, "%s %d %d\n", file.Name(), file.Size(), file.ModTime().UnixNano())
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildShadow makes a copy of the directory of the collection with the
+// records of docs replaced, and returns its path. Files are hard linked into
+// it when the Storage can, they're never changed in place. Subdirectories,
+// nested collections, are left for swapShadow to move
+func (d *Driver) buildShadow(collection string, docs map[string][]byte, progress func()) (_ string, err error) {
+	live := filepath.Join(d.dir, collection)
+	shadow := filepath.Join(d.dir, shadowDir, collection)
+
+	if err := d.fs.RemoveAll(shadow); err != nil {
+		return "", err
+	}
+
+	defer func() {
+		if err != nil {
+			d.fs.RemoveAll(shadow)
+		}
+	}()
+
+	if err := d.fs.MkdirAll(shadow, 0755); err != nil {
+		return "", err
+	}
+
+	files, err := d.fs.ReadDir(live)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	linker := d.engines.linker(live)
+
+	for _, file := range files {
+		name := file.Name()
+		if !file.Mode().IsRegular() || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+
+		if _, ok := docs[strings.TrimSuffix(name, ".json")]; ok && strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		if err := d.copyFile(linker, filepath.Join(live, name), filepath.Join(shadow, name)); err != nil {
+			return "", err
+		}
+	}
+
+	for _, ID := range sortedWriteKeys(docs) {
+		if err := d.validate(collection, ID, docs[ID]); err != nil {
+			return "", err
+		}
+
+		stored, err := d.encryptFields(collection, docs[ID])
+		if err != nil {
+			return "", err
+		}
+
+		if err := d.writeTemp(collection, filepath.Join(shadow, ID+".json"), stored); err != nil {
+			return "", err
+		}
+
+		progress()
+	}
+
+	return shadow, nil
+}
+
+func (d *Driver) copyFile(linker Linker, src, dst string) error {
+	if linker != nil {
+		return linker.Link(src, dst)
+	}
+
+	b, err := d.fs.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return d.fs.WriteFile(dst, b, 0644)
+}
+
+// swapShadow replaces the directory of the collection with its shadow when
+// it still has the fingerprint the shadow was built from, failing with
+// ErrConditionFailed otherwise. The records of docs get their history
+// recorded, their indexes updated and their write events emitted. Readers of
+// the Driver missing a record while the directories are swapped wait for
+// the swap and read again, other processes may miss it for that instant
+func (d *Driver) swapShadow(collection, shadow, fingerprint string, docs map[string][]byte) error {
+	unlock, err := d.lockShared(collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := d.fingerprint(collection)
+	if err != nil {
+		return err
+	}
+
+	if current != fingerprint {
+		d.fs.RemoveAll(shadow)
+		return fmt.Errorf("%s changed while it was rebuilt: %w", collection, ErrConditionFailed)
+	}
+
+	for _, ID := range sortedWriteKeys(docs) {
+		if err := d.recordHistory(collection, ID, docs[ID]); err != nil {
+			return err
+		}
+	}
+
+	done := d.startSwap(collection)
+	defer done()
+
+	if err := d.exchange(filepath.Join(d.dir, collection), shadow); err != nil {
+		return err
+	}
+
+	d.handles.evictDir(filepath.Join(d.dir, collection))
+	pruneDirs(d.fs, filepath.Dir(shadow), filepath.Join(d.dir, shadowDir))
+
+	lock := d.getMutex(collection)
+	lock.shared.Lock()
+	for ID, doc := range docs {
+		d.indexRecord(collection, ID, doc)
+	}
+	lock.shared.Unlock()
+
+	for _, ID := range sortedWriteKeys(docs) {
+		d.emit(Event{Collection: collection, ID: ID, Op: OpWrite})
+	}
+
+	return nil
+}
+
+// exchange moves the subdirectories of live into shadow, puts shadow in
+// place of live and removes the previous live directory. Failures put
+// everything back where it was, as far as they can
+func (d *Driver) exchange(live, shadow string) error {
+	old := shadow + ".old"
+
+	files, err := d.fs.ReadDir(live)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	exists := err == nil
+
+	var moved []string
+
+	restore := func(err error) error {
+		for _, name := range moved {
+			if rerr := d.fs.Rename(filepath.Join(shadow, name), filepath.Join(live, name)); rerr != nil {
+				return fmt.Errorf("%w, moving %s back failed too: %s", err, name, rerr)
+			}
+		}
+
+		d.fs.RemoveAll(shadow)
+		return err
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+
+		if err := d.fs.Rename(filepath.Join(live, file.Name()), filepath.Join(shadow, file.Name())); err != nil {
+			return restore(err)
+		}
+
+		moved = append(moved, file.Name())
+	}
+
+	if exists {
+		if err := d.fs.Rename(live, old); err != nil {
+			return restore(err)
+		}
+	}
+
+	if err := d.fs.Rename(shadow, live); err != nil {
+		if exists {
+			if rerr := d.fs.Rename(old, live); rerr != nil {
+				return fmt.Errorf("%w, moving %s back failed too: %s", err, old, rerr)
+			}
+		}
+
+		return restore(err)
+	}
+
+	if err := d.fs.RemoveAll(old); err != nil {
+		d.log.Warn("unable to remove the previous records of %s: %s", live, err)
+	}
+
+	return nil
+}
+
+// pruneDirs removes dir and its parents up to root as long as they're empty
+func pruneDirs(fs Storage, dir, root string) {
+	for ; under(dir, root); dir = filepath.Dir(dir) {
+		if fs.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// startSwap marks the collection as being swapped until the returned
+// function is called
+func (d *Driver) startSwap(collection string) func() {
+	done := make(chan struct{})
+
+	d.mutex.Lock()
+	d.swaps[collection] = done
+	d.mutex.Unlock()
+
+	return func() {
+		d.mutex.Lock()
+		delete(d.swaps, collection)
+		d.mutex.Unlock()
+
+		close(done)
+	}
+}
+
+// awaitSwap waits for the swap of the collection, or of a collection it's
+// nested in, and reports whether there was one
+func (d *Driver) awaitSwap(collection string) bool {
+	d.mutex.Lock()
+
+	var done chan struct{}
+	for c, ch := range d.swaps {
+		if collection == c || strings.HasPrefix(collection, c+"/") {
+			done = ch
+			break
+		}
+	}
+
+	d.mutex.Unlock()
+
+	if done == nil {
+		return false
+	}
+
+	<-done
+	return true
+}
@@ -0,0 +1,43 @@
+package jdb
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// logSlow logs the operation when it took longer than
+// Options.SlowThreshold, along with the bytes it read or wrote
+func (d *Driver) logSlow(op, collection string, start time.Time, bytes int64) {
+	if d.slowThreshold <= 0 {
+		return
+	}
+
+	if took := d.clock.Now().Sub(start); took > d.slowThreshold {
+		d.log.Warn("slow %s of %s: took %s, %d bytes", op, collection, took, bytes)
+	}
+}
+
+// logSlowWrite is logSlow of a write, the size of the record only being
+// looked up when the write was slow
+func (d *Driver) logSlowWrite(collection, ID string, start time.Time) {
+	if d.slowThreshold <= 0 || d.clock.Now().Sub(start) <= d.slowThreshold {
+		return
+	}
+
+	var size int64
+	if info, err := d.fs.Stat(filepath.Join(d.dir, collection, ID+".json")); err == nil {
+		size = info.Size()
+	}
+
+	d.logSlow("write", collection, start, size)
+}
+
+// recordsSize is the size of the records read by an operation
+func recordsSize(records []string) int64 {
+	var size int64
+	for _, r := range records {
+		size += int64(len(r))
+	}
+
+	return size
+}
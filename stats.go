@@ -0,0 +1,279 @@
+package jdb
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// defaultStatsSample is how many records of each collection Stats
+	// samples when Options.StatsSample isn't set
+	defaultStatsSample = 1000
+
+	// histogramBuckets is how many of the most common values a field
+	// histogram keeps
+	histogramBuckets = 10
+)
+
+type (
+	// Stats describes the size of the database
+	Stats struct {
+		Collections []CollectionStats
+
+		// DiskUsage is the size in bytes of every file of the data
+		// directory, history, archives, trash and blobs included
+		DiskUsage int64
+	}
+
+	// CollectionStats describes the size of a collection, Bytes being the
+	// size of its record files. Fields describes the values of the fields
+	// found in the Sampled records, see FieldStats
+	CollectionStats struct {
+		Collection string
+		Records    int
+		Bytes      int64
+
+		Sampled int
+		Fields  []FieldStats
+	}
+
+	// FieldStats describes the values of a dotted field in a sample of the
+	// records, telling how selective an index on it would be. Count is how
+	// many sampled records have the field and Cardinality the estimated
+	// number of distinct values in the whole collection. Histogram holds the
+	// most common values, most common first
+	FieldStats struct {
+		Field       string
+		Count       int
+		Cardinality int
+		Histogram   []HistogramBucket
+	}
+
+	// HistogramBucket is a JSON encoded value and how many sampled records
+	// hold it
+	HistogramBucket struct {
+		Value string
+		Count int
+	}
+)
+
+// Stats returns the number of records and size of every collection along
+// with the disk usage of the data directory. The fields of each collection
+// are described from Options.StatsSample of its records
+func (d *Driver) Stats() (Stats, error) {
+	return d.stats(d.statsSample)
+}
+
+// Sizes is Stats without the fields of the collections, only listing files so
+// it's cheap enough for every scrape of a metrics endpoint
+func (d *Driver) Sizes() (Stats, error) {
+	return d.stats(0)
+}
+
+// stats describes the fields of each collection from up to sample records
+func (d *Driver) stats(sample int) (Stats, error) {
+	var stats Stats
+
+	collections, err := d.collections()
+	if err != nil {
+		return stats, err
+	}
+
+	for _, c := range collections {
+		records, err := d.listRecords(c)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return stats, err
+		}
+
+		cs := CollectionStats{Collection: c, Records: len(records)}
+		for _, r := range records {
+			cs.Bytes += r.info.Size()
+		}
+
+		if sample > 0 {
+			if cs.Sampled, cs.Fields, err = d.fieldStats(c, records, sample); err != nil {
+				return stats, err
+			}
+		}
+
+		stats.Collections = append(stats.Collections, cs)
+	}
+
+	stats.DiskUsage, err = d.diskUsage(d.dir)
+	return stats, err
+}
+
+// diskUsage returns the size of the files under dir
+func (d *Driver) diskUsage(dir string) (int64, error) {
+	files, err := d.fs.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+
+	for _, file := range files {
+		if !file.IsDir() {
+			size += file.Size()
+			continue
+		}
+
+		n, err := d.diskUsage(filepath.Join(dir, file.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return size, err
+		}
+
+		size += n
+	}
+
+	return size, nil
+}
+
+// FieldStats describes the fields of the collection from sample of its
+// records spread over the whole collection, every record when sample isn't
+// positive. Fields are sorted by name
+func (d *Driver) FieldStats(collection string, sample int) ([]FieldStats, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("missing collection, no place to get data")
+	}
+
+	records, err := d.listRecords(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	_, fields, err := d.fieldStats(collection, records, sample)
+	return fields, err
+}
+
+// fieldStats reads up to sample records picked at random, the same ones for
+// the same records so stats are stable, returning how many were read
+func (d *Driver) fieldStats(collection string, records []record, sample int) (int, []FieldStats, error) {
+	picked := records
+	if sample > 0 && sample < len(records) {
+		picked = make([]record, sample)
+		for i, j := range rand.New(rand.NewSource(1)).Perm(len(records))[:sample] {
+			picked[i] = records[j]
+		}
+	}
+
+	values := make(map[string]map[string]int)
+	sampled := 0
+
+	for _, file := range picked {
+
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			doc, err := decodeJSON(b)
+			if err != nil {
+				return err
+			}
+
+			countFields(values, "", doc)
+			return nil
+		})
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return sampled, nil, fmt.Errorf("%s/%s: %w", collection, file.ID, err)
+		}
+
+		sampled++
+	}
+
+	fields := make([]FieldStats, 0, len(values))
+
+	for _, field := range sortedWriteKeys(values) {
+		fs := FieldStats{Field: field}
+
+		for value, n := range values[field] {
+			fs.Count += n
+			fs.Histogram = append(fs.Histogram, HistogramBucket{Value: value, Count: n})
+		}
+
+		fs.Cardinality = estimateCardinality(fs.Histogram, sampled, len(records))
+
+		sort.Slice(fs.Histogram, func(i, j int) bool {
+			a, b := fs.Histogram[i], fs.Histogram[j]
+			return a.Count > b.Count || a.Count == b.Count && a.Value < b.Value
+		})
+
+		if len(fs.Histogram) > histogramBuckets {
+			fs.Histogram = fs.Histogram[:histogramBuckets]
+		}
+
+		fields = append(fields, fs)
+	}
+
+	return sampled, fields, nil
+}
+
+// countFields counts the values of every dotted field of the document,
+// descending into objects. Arrays count as a single value
+func countFields(values map[string]map[string]int, prefix string, v interface{}) {
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, child := range m {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+
+			countFields(values, k, child)
+		}
+
+		return
+	}
+
+	if prefix == "" {
+		return
+	}
+
+	key, ok := valueKey(v)
+	if !ok {
+		return
+	}
+
+	if values[prefix] == nil {
+		values[prefix] = make(map[string]int)
+	}
+
+	values[prefix][key]++
+}
+
+// estimateCardinality scales the distinct values of a sample to the whole
+// collection with the GEE estimator: values seen once in the sample stand
+// for sqrt(total/sampled) values each, the others for themselves. Fields
+// without a repeated value are taken as unique
+func estimateCardinality(counts []HistogramBucket, sampled, total int) int {
+	if sampled == 0 || sampled >= total {
+		return len(counts)
+	}
+
+	once, more := 0, 0
+	for _, c := range counts {
+		if c.Count == 1 {
+			once++
+		} else {
+			more++
+		}
+	}
+
+	if more == 0 {
+		return once * total / sampled
+	}
+
+	estimate := int(math.Round(math.Sqrt(float64(total)/float64(sampled))*float64(once))) + more
+	if estimate > total {
+		estimate = total
+	}
+
+	return estimate
+}
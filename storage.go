@@ -0,0 +1,159 @@
+package jdb
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// Storage is the filesystem the Driver keeps its records in, it can be
+	// swapped to inject faults or to keep records somewhere else than disk.
+	// WriteFile and AppendFile must not keep data once they return, the
+	// Driver reuses its buffers
+	Storage interface {
+		ReadFile(name string) ([]byte, error)
+		WriteFile(name string, data []byte, perm os.FileMode) error
+		AppendFile(name string, data []byte, perm os.FileMode) error
+		Rename(oldpath, newpath string) error
+		Remove(name string) error
+		RemoveAll(path string) error
+		MkdirAll(path string, perm os.FileMode) error
+		Stat(name string) (os.FileInfo, error)
+		ReadDir(dirname string) ([]os.FileInfo, error)
+	}
+
+	// Clock tells the Driver the time, it's used for every timestamp it takes
+	Clock interface {
+		Now() time.Time
+	}
+
+	// Timer is implemented by Clocks also controlling how long the Driver
+	// waits: rate limited writes, lease renewals and sweeps. Other Clocks
+	// wait in real time
+	Timer interface {
+		After(d time.Duration) <-chan time.Time
+	}
+
+	// IDGenerator returns a new unique identifier for Insert
+	IDGenerator func() string
+
+	// Syncer is implemented by Storages able to flush a file or a
+	// directory to disk, which Options.Sync needs
+	Syncer interface {
+		Sync(name string) error
+	}
+
+	// SyncMode is how durable writes are, see Options.Sync
+	SyncMode int
+
+	osStorage struct{}
+
+	systemClock struct{}
+)
+
+const (
+	// SyncNever leaves flushing records to disk to the OS, a crash may
+	// lose the last writes but never tears a record
+	SyncNever SyncMode = iota
+
+	// SyncAlways flushes every record written to disk, and its directory
+	// once it's renamed in place or deleted, before the write returns.
+	// Storages that aren't a Syncer are left to flush them on their own
+	SyncAlways
+)
+
+// OSStorage is the Storage backed by the local filesystem used by default
+var OSStorage Storage = osStorage{}
+
+func (osStorage) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osStorage) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(name, data, perm)
+}
+
+func (osStorage) AppendFile(name string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (osStorage) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (osStorage) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osStorage) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (osStorage) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osStorage) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osStorage) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(dirname)
+}
+
+func (osStorage) Sync(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// syncFile flushes the file or directory to disk with SyncAlways
+func (d *Driver) syncFile(name string) error {
+	if d.sync != SyncAlways {
+		return nil
+	}
+
+	if s := d.engines.syncer(name); s != nil {
+		return s.Sync(name)
+	}
+
+	return nil
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// after returns a channel receiving the time once d elapsed on the clock
+func (d *Driver) after(dur time.Duration) <-chan time.Time {
+	if t, ok := d.clock.(Timer); ok {
+		return t.After(dur)
+	}
+
+	return time.After(dur)
+}
+
+func newUUID() string {
+	return uuid.NewString()
+}
@@ -0,0 +1,62 @@
+package jdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestFailedWriteKeepsThePreviousVersion(t *testing.T) {
+	fs := jdbtest.NewFaultStorage(nil)
+	d := jdbtest.New(t, jdb.WithStorage(fs))
+
+	if _, err := d.Write("users", "ada", map[string]int{"v": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	fs.Inject(jdbtest.FailOn("Rename", "ada.json"))
+
+	if _, err := d.Write("users", "ada", map[string]int{"v": 2}); !errors.Is(err, jdbtest.ErrInjected) {
+		t.Fatalf("Write with a failing rename = %v, want ErrInjected", err)
+	}
+
+	var got struct{ V int }
+	if err := d.ReadInto("users", "ada", &got); err != nil || got.V != 1 {
+		t.Errorf("record after a failed write = %+v, %v, want version 1", got, err)
+	}
+
+	if fs.Calls("Rename") == 0 {
+		t.Error("no Rename counted")
+	}
+
+	fs.Reset()
+
+	if _, err := d.Write("users", "ada", map[string]int{"v": 3}); err != nil {
+		t.Fatalf("Write once the fault is gone: %s", err)
+	}
+
+	if err := d.ReadInto("users", "ada", &got); err != nil || got.V != 3 {
+		t.Errorf("record = %+v, %v, want version 3", got, err)
+	}
+}
+
+func TestFailedDeleteKeepsTheRecord(t *testing.T) {
+	fs := jdbtest.NewFaultStorage(nil)
+	d := jdbtest.New(t, jdb.WithStorage(fs))
+
+	jdbtest.Seed(t, d, "users", 3, jdbtest.Sequence("u", 1))
+
+	fs.Inject(jdbtest.FailOn("", "u-1.json"))
+
+	if err := d.Delete("users", "u-1"); !errors.Is(err, jdbtest.ErrInjected) {
+		t.Fatalf("Delete with a failing disk = %v, want ErrInjected", err)
+	}
+
+	fs.Reset()
+
+	if ok, err := d.Exists("users", "u-1"); err != nil || !ok {
+		t.Errorf("record after a failed delete exists = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,18 @@
+package jdb
+
+// Store is what the Driver offers for plain record access, implemented by
+// both the Driver and the HTTP client of package client so code can switch
+// between an embedded and a remote database
+type Store interface {
+	Write(collection, identifier string, v interface{}) (string, error)
+	Insert(collection string, v interface{}) (string, error)
+	Update(collection, ID string, v interface{}) (string, error)
+	Read(collection, identifier string) (string, error)
+	ReadInto(collection, identifier string, v interface{}) error
+	ReadAll(collection string) ([]string, error)
+	IDs(collection string) ([]string, error)
+	Exists(collection, identifier string) (bool, error)
+	Delete(collection, ID string) error
+}
+
+var _ Store = (*Driver)(nil)
@@ -0,0 +1,59 @@
+package jdb
+
+import "fmt"
+
+// Take deletes a record and returns what it held, reading and removing it
+// under the same lock so no other writer can slip in between
+func (d *Driver) Take(collection, identifier string) (string, error) {
+	var data string
+
+	err := d.take(collection, identifier, func(b []byte) error {
+		data = string(b)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return data, nil
+}
+
+// TakeInto deletes a record like Take, decoding what it held into v following
+// Options.JSON
+func (d *Driver) TakeInto(collection, identifier string, v interface{}) error {
+	return d.take(collection, identifier, func(b []byte) error {
+		return d.decode(collection, identifier, b, v)
+	})
+}
+
+// take hands the record to fn and deletes it when fn succeeds, so a record
+// that can't be decoded is left in place
+func (d *Driver) take(collection, ID string, fn func([]byte) error) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to delete data")
+	}
+
+	if ID == "" {
+		return fmt.Errorf("missing ID, no identifier to delete data")
+	}
+
+	done, err := d.admitLocal(collection, ID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if d.config(collection).appendOnly {
+		return fmt.Errorf("%s/%s: %w", collection, ID, ErrAppendOnly)
+	}
+
+	if err := d.load(collection, ID, fn); err != nil {
+		return err
+	}
+
+	return d.deleteRecord(collection, ID)
+}
@@ -0,0 +1,221 @@
+package jdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// trashDir is the reserved directory deleted records are moved to while
+// Options.TrashRetention is set
+const trashDir = "_trash"
+
+// trashRoot is where the records of the collection deleted at the given
+// time are kept
+func (d *Driver) trashRoot(collection string, at time.Time) string {
+	return filepath.Join(d.dir, trashDir, collection, strconv.FormatInt(at.UnixNano(), 10))
+}
+
+// trashRecord copies a record about to be deleted into the trash, callers
+// must hold the collection lock
+func (d *Driver) trashRecord(collection, ID string) error {
+	if d.trashRetention <= 0 {
+		return nil
+	}
+
+	var doc []byte
+
+	err := d.load(collection, ID, func(b []byte) error {
+		doc = append([]byte(nil), b...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if doc, err = d.seal(collection, doc); err != nil {
+		return err
+	}
+
+	path := filepath.Join(d.trashRoot(collection, d.clock.Now()), ID+".json")
+	if err := d.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return d.fs.WriteFile(path, doc, 0644)
+}
+
+// trashTree moves the content of a directory about to be deleted into the
+// trash and removes it, callers must hold the collection lock
+func (d *Driver) trashTree(collection, ID, dir string) error {
+	target := filepath.Join(d.trashRoot(collection, d.clock.Now()), ID)
+	if err := d.fs.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+
+	files, err := d.fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := d.fs.Rename(filepath.Join(dir, file.Name()), filepath.Join(target, file.Name())); err != nil {
+			return err
+		}
+	}
+
+	return d.fs.RemoveAll(dir)
+}
+
+// deletedAt lists the times records of the collection were moved to the
+// trash, latest first
+func (d *Driver) deletedAt(collection string) ([]string, error) {
+	files, err := d.fs.ReadDir(filepath.Join(d.dir, trashDir, collection))
+	if err != nil {
+		return nil, err
+	}
+
+	var stamps []string
+	for _, file := range files {
+		if _, err := strconv.ParseInt(file.Name(), 10, 64); err == nil && file.IsDir() {
+			stamps = append(stamps, file.Name())
+		}
+	}
+
+	sort.Slice(stamps, func(i, j int) bool {
+		return len(stamps[i]) > len(stamps[j]) || len(stamps[i]) == len(stamps[j]) && stamps[i] > stamps[j]
+	})
+
+	return stamps, nil
+}
+
+// Undelete restores the latest deleted version of a record from the trash,
+// it fails when the record exists again
+func (d *Driver) Undelete(collection, identifier string) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if identifier == "" {
+		return fmt.Errorf("missing identifier")
+	}
+
+	done, err := d.admitLocal(collection, identifier)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := d.locate(collection, identifier); err == nil {
+		return fmt.Errorf("%s/%s exists, not restoring it", collection, identifier)
+	}
+
+	stamps, err := d.deletedAt(collection)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	root := filepath.Join(d.dir, trashDir, collection)
+
+	for _, stamp := range stamps {
+		path := filepath.Join(root, stamp, identifier+".json")
+
+		b, err := d.fs.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if b, err = d.decrypt(collection, b); err != nil {
+			return err
+		}
+
+		if _, err := d.writeLocked(collection, identifier, rawJSON(b)); err != nil {
+			return err
+		}
+
+		return d.fs.Remove(path)
+	}
+
+	return fmt.Errorf("%s/%s is not in the trash: %w", collection, identifier, os.ErrNotExist)
+}
+
+// PurgeTrash removes what was deleted longer than Options.TrashRetention ago
+// from the trash, returning how many deletions were purged
+func (d *Driver) PurgeTrash() (int, error) {
+	done, err := d.admitMaintenance("purging the trash")
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	cutoff := d.clock.Now().Add(-d.trashRetention).UnixNano()
+	root := filepath.Join(d.dir, trashDir)
+
+	count := 0
+
+	err = d.walkDirs(root, func(dir string, info os.FileInfo) (bool, error) {
+		stamp, err := strconv.ParseInt(info.Name(), 10, 64)
+		if err != nil || dir == root {
+			return true, nil
+		}
+
+		if stamp >= cutoff {
+			return false, nil
+		}
+
+		if err := d.fs.RemoveAll(dir); err != nil {
+			return false, err
+		}
+
+		count++
+		return false, nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	return count, err
+}
+
+// walkDirs calls fn for every directory below root, descending into it when
+// fn returns true
+func (d *Driver) walkDirs(root string, fn func(dir string, info os.FileInfo) (bool, error)) error {
+	files, err := d.fs.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(root, file.Name())
+
+		descend, err := fn(dir, file)
+		if err != nil {
+			return err
+		}
+
+		if !descend {
+			continue
+		}
+
+		if err := d.walkDirs(dir, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package jdb
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type (
+	// RecordRef identifies a record
+	RecordRef struct {
+		Collection string `json:"collection"`
+		ID         string `json:"id"`
+	}
+
+	// Edge tells Traverse that the dotted Field of the records of From holds
+	// the ID, or a list of IDs, of records of To
+	Edge struct {
+		From, Field, To string
+	}
+
+	// Link is an edge followed by Traverse
+	Link struct {
+		From  RecordRef `json:"from"`
+		Field string    `json:"field"`
+		To    RecordRef `json:"to"`
+	}
+
+	// GraphNode is a record visited by Traverse
+	GraphNode struct {
+		Ref    RecordRef `json:"ref"`
+		Depth  int       `json:"depth"`
+		Record string    `json:"record"`
+	}
+
+	// Graph is the subgraph visited by Traverse
+	Graph struct {
+		// Nodes are the records visited breadth first, the start first
+		Nodes []GraphNode `json:"nodes"`
+
+		// Links are the edges followed between nodes, including the ones
+		// closing cycles
+		Links []Link `json:"links"`
+	}
+)
+
+// Traverse walks the records linked from start along the edges breadth
+// first, up to depth links away, and returns the subgraph visited. Records
+// are visited once so cycles end the walk, and references to missing records
+// or invalid IDs are skipped
+func (d *Driver) Traverse(start RecordRef, edges []Edge, depth int) (*Graph, error) {
+	record, err := d.Read(start.Collection, start.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Graph{Nodes: []GraphNode{{Ref: start, Record: record}}}
+	visited := map[RecordRef]bool{start: true}
+
+	for i := 0; i < len(g.Nodes); i++ {
+		node := g.Nodes[i]
+		if node.Depth >= depth {
+			continue
+		}
+
+		doc, err := decodeJSON([]byte(node.Record))
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", node.Ref.Collection, node.Ref.ID, err)
+		}
+
+		for _, edge := range edges {
+			if edge.From != node.Ref.Collection {
+				continue
+			}
+
+			for _, ID := range referencedIDs(doc, edge.Field) {
+				if ValidateID(ID) != nil {
+					continue
+				}
+
+				to := RecordRef{Collection: edge.To, ID: ID}
+
+				if !visited[to] {
+					record, err := d.Read(to.Collection, to.ID)
+					if os.IsNotExist(err) {
+						continue
+					}
+
+					if err != nil {
+						return nil, err
+					}
+
+					visited[to] = true
+					g.Nodes = append(g.Nodes, GraphNode{Ref: to, Depth: node.Depth + 1, Record: record})
+				}
+
+				g.Links = append(g.Links, Link{From: node.Ref, Field: edge.Field, To: to})
+			}
+		}
+	}
+
+	return g, nil
+}
+
+// referencedIDs returns the IDs held by the field, a string or a list of
+// strings
+func referencedIDs(doc interface{}, field string) []string {
+	v, ok := lookupPath(doc, strings.Split(field, "."))
+	if !ok {
+		return nil
+	}
+
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var IDs []string
+		for _, e := range v {
+			if ID, ok := e.(string); ok {
+				IDs = append(IDs, ID)
+			}
+		}
+
+		return IDs
+	}
+
+	return nil
+}
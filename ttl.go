@@ -0,0 +1,296 @@
+package jdb
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ttlDir is the reserved directory holding the expiry time of records
+const ttlDir = "_ttl"
+
+// ExpireFunc is called with the content of a record removed because its TTL
+// ran out
+type ExpireFunc func(collection, ID string, doc []byte)
+
+func (d *Driver) ttlPath(collection, ID string) string {
+	return filepath.Join(d.dir, ttlDir, collection, ID+".ttl")
+}
+
+// Expire makes the record expire after ttl, it's removed by the next sweep
+// after that, see SweepExpired and Options.TTLSweepInterval
+func (d *Driver) Expire(collection, identifier string, ttl time.Duration) error {
+	if collection == "" {
+		return fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if identifier == "" {
+		return fmt.Errorf("missing identifier")
+	}
+
+	done, err := d.admitLocal(collection, identifier)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, err := d.locate(collection, identifier); err != nil {
+		return err
+	}
+
+	return d.setExpiry(collection, identifier, ttl)
+}
+
+// setExpiry makes the record expire after ttl, callers must hold the
+// collection lock
+func (d *Driver) setExpiry(collection, ID string, ttl time.Duration) error {
+	if err := validateStored(collection, ID); err != nil {
+		return err
+	}
+
+	path := d.ttlPath(collection, ID)
+	if err := d.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	at := d.clock.Now().Add(ttl).UTC().Format(time.RFC3339Nano)
+
+	return d.fs.WriteFile(path, []byte(at), 0644)
+}
+
+// SetDefaultTTL makes records written to the collection without a TTL
+// expire after ttl, zero turns it off. Records already stored keep their TTL
+func (d *Driver) SetDefaultTTL(collection string, ttl time.Duration) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.defaultTTL = ttl
+	})
+}
+
+// applyDefaultTTL gives a record just written the default TTL of its
+// collection unless it has one, callers must hold the collection lock
+func (d *Driver) applyDefaultTTL(collection, ID string) error {
+	ttl := d.config(collection).defaultTTL
+	if ttl <= 0 {
+		return nil
+	}
+
+	if _, err := d.fs.Stat(d.ttlPath(collection, ID)); !os.IsNotExist(err) {
+		return err
+	}
+
+	return d.setExpiry(collection, ID, ttl)
+}
+
+// Persist removes the TTL of the record
+func (d *Driver) Persist(collection, identifier string) error {
+	done, err := d.admitLocal(collection, identifier)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return d.dropTTL(collection, identifier)
+}
+
+// dropTTL forgets the expiry of a record, callers must hold the collection
+// lock
+func (d *Driver) dropTTL(collection, ID string) error {
+	if err := validateStored(collection, ID); err != nil {
+		return err
+	}
+
+	if err := d.fs.Remove(d.ttlPath(collection, ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// OnExpire registers fn to be called for every record of the collection
+// removed by a sweep, so related resources can be cleaned up
+func (d *Driver) OnExpire(collection string, fn ExpireFunc) {
+	d.configure(collection, func(c *collectionConfig) {
+		c.onExpire = append(c.onExpire, fn)
+	})
+}
+
+type expiry struct {
+	collection string
+	ID         string
+	at         time.Time
+}
+
+// expiries lists the TTLs stored under dir, collection being the relative
+// path of dir below the TTL directory
+func (d *Driver) expiries(dir, collection string) ([]expiry, error) {
+	files, err := d.fs.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var found []expiry
+
+	for _, file := range files {
+		name := file.Name()
+
+		if file.IsDir() {
+			nested, err := d.expiries(filepath.Join(dir, name), path.Join(collection, name))
+			if err != nil {
+				return nil, err
+			}
+
+			found = append(found, nested...)
+			continue
+		}
+
+		if collection == "" || !strings.HasSuffix(name, ".ttl") {
+			continue
+		}
+
+		b, err := d.fs.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		at, err := time.Parse(time.RFC3339Nano, string(b))
+		if err != nil {
+			d.log.Warn("ignoring malformed TTL of %s/%s", collection, name)
+			continue
+		}
+
+		found = append(found, expiry{collection: collection, ID: strings.TrimSuffix(name, ".ttl"), at: at})
+	}
+
+	return found, nil
+}
+
+// SweepExpired removes every record whose TTL ran out, calling the OnExpire
+// callbacks of their collection, and returns how many were removed
+func (d *Driver) SweepExpired() (int, error) {
+	if d.replicator != nil {
+		return 0, fmt.Errorf("sweeping expired records: %w", ErrNotReplicated)
+	}
+
+	found, err := d.expiries(filepath.Join(d.dir, ttlDir), "")
+	if err != nil {
+		return 0, err
+	}
+
+	now := d.clock.Now()
+	count := 0
+
+	for _, e := range found {
+		if e.at.After(now) {
+			continue
+		}
+
+		doc, ok, err := d.expire(e)
+		if err != nil {
+			return count, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		count++
+
+		for _, fn := range d.config(e.collection).onExpire {
+			fn(e.collection, e.ID, doc)
+		}
+	}
+
+	return count, nil
+}
+
+// expire removes an expired record, reporting false when it was already gone
+// or its TTL changed since it was listed
+func (d *Driver) expire(e expiry) ([]byte, bool, error) {
+	done, err := d.admitLocalStore(e.collection, e.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer done()
+
+	mutex := d.getMutex(e.collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	b, err := d.fs.ReadFile(d.ttlPath(e.collection, e.ID))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if at, err := time.Parse(time.RFC3339Nano, string(b)); err != nil || at.After(d.clock.Now()) {
+		return nil, false, nil
+	}
+
+	var doc []byte
+
+	err = d.load(e.collection, e.ID, func(b []byte) error {
+		doc = append([]byte(nil), b...)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, false, d.dropTTL(e.collection, e.ID)
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := d.removeRecord(e.collection, e.ID); err != nil {
+		return nil, false, err
+	}
+
+	d.log.Debug("expired %s/%s", e.collection, e.ID)
+
+	return doc, true, nil
+}
+
+// sweepLoop sweeps expired records and purges the trash every interval until
+// the Driver is closed
+func (d *Driver) sweepLoop(interval time.Duration) {
+	defer d.loops.Done()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.after(interval):
+			// a frozen Driver is left as it is until the next turn
+			if !d.IsLeader() || d.Frozen() {
+				continue
+			}
+
+			if _, err := d.SweepExpired(); err != nil {
+				d.log.Error("sweeping expired records: %s", err)
+			}
+
+			if d.trashRetention > 0 {
+				if _, err := d.PurgeTrash(); err != nil {
+					d.log.Error("purging trash: %s", err)
+				}
+			}
+		}
+	}
+}
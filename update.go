@@ -0,0 +1,46 @@
+package jdb
+
+import "fmt"
+
+// UpdateFn reads a record into a T, hands it to fn and writes back what fn
+// returns, all under the collection lock so concurrent read-modify-write
+// cycles can't lose each other's changes. Nothing is written when fn fails
+func UpdateFn[T any](d *Driver, collection, identifier string, fn func(current T) (T, error)) (T, error) {
+	var current T
+
+	if collection == "" {
+		return current, fmt.Errorf("missing collection, no place to save data")
+	}
+
+	if identifier == "" {
+		return current, fmt.Errorf("missing identifier")
+	}
+
+	done, err := d.admitLocal(collection, identifier)
+	if err != nil {
+		return current, err
+	}
+	defer done()
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	err = d.load(collection, identifier, func(b []byte) error {
+		return d.decode(collection, identifier, b, &current)
+	})
+	if err != nil {
+		return current, err
+	}
+
+	next, err := fn(current)
+	if err != nil {
+		return current, err
+	}
+
+	if _, err := d.writeLocked(collection, identifier, next); err != nil {
+		return current, err
+	}
+
+	return next, nil
+}
@@ -0,0 +1,184 @@
+package jdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// quarantineDir is the reserved directory bad records are moved to by Verify
+const quarantineDir = "_quarantine"
+
+type (
+	// VerifyOptions tunes Verify and Options.VerifyOnOpen
+	VerifyOptions struct {
+		// Schemas also checks the records against the schema of their
+		// collection, see SetSchema
+		Schemas bool
+
+		// Quarantine moves the bad records of the data directory to the
+		// reserved _quarantine directory so they stop being served, records
+		// of the read-only directories are only reported
+		Quarantine bool
+
+		// Fail makes New fail with ErrCorrupt when bad records are found,
+		// they're only logged otherwise
+		Fail bool
+	}
+
+	// BadRecord is a record Verify couldn't read, decode or validate
+	BadRecord struct {
+		Collection string
+		ID         string
+		Path       string
+		Err        error
+
+		// Quarantined is where the record was moved, empty when it wasn't
+		Quarantined string
+	}
+)
+
+func (b BadRecord) Error() string {
+	var invalid *ValidationError
+	if errors.As(b.Err, &invalid) {
+		return b.Err.Error()
+	}
+
+	return fmt.Sprintf("%s/%s: %s", b.Collection, b.ID, b.Err)
+}
+
+// Verify reads every record of every collection, returning the ones that
+// can't be decrypted, aren't JSON or, with opts.Schemas, don't match their
+// schema. Quarantining them is a write, refused while frozen or, with a
+// Replicator, with ErrNotReplicated, though Options.VerifyOnOpen still does
+func (d *Driver) Verify(opts VerifyOptions) ([]BadRecord, error) {
+	if opts.Quarantine {
+		done, err := d.admitMaintenance("quarantining bad records")
+		if err != nil {
+			return nil, err
+		}
+		defer done()
+	}
+
+	return d.verify(opts)
+}
+
+func (d *Driver) verify(opts VerifyOptions) ([]BadRecord, error) {
+	collections, err := d.collections()
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := d.countRecords(collections)
+	if err != nil {
+		return nil, err
+	}
+
+	var bad []BadRecord
+	done := 0
+
+	for _, collection := range collections {
+		found, err := d.verifyCollection(collection, opts, &done, total)
+		if err != nil {
+			return bad, err
+		}
+
+		bad = append(bad, found...)
+	}
+
+	return bad, nil
+}
+
+func (d *Driver) verifyCollection(collection string, opts VerifyOptions, done *int, total int) ([]BadRecord, error) {
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	files, err := d.listRecords(collection)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var bad []BadRecord
+
+	for _, file := range files {
+		*done++
+		d.progress(Progress{Op: "verify", Collection: collection, Done: *done, Total: total})
+
+		err := d.readFile(collection, file.path, func(b []byte) error {
+			if !json.Valid(b) {
+				return errors.New("not valid JSON")
+			}
+
+			if opts.Schemas {
+				return d.validate(collection, file.ID, b)
+			}
+
+			return nil
+		})
+		if err == nil {
+			continue
+		}
+
+		r := BadRecord{Collection: collection, ID: file.ID, Path: file.path, Err: err}
+
+		if opts.Quarantine && strings.HasPrefix(file.path, d.dir+string(filepath.Separator)) {
+			if r.Quarantined, err = d.quarantine(collection, file.ID, file.path); err != nil {
+				return bad, err
+			}
+		}
+
+		bad = append(bad, r)
+	}
+
+	return bad, nil
+}
+
+// quarantine moves a record out of its collection, callers must hold the
+// collection lock
+func (d *Driver) quarantine(collection, ID, path string) (string, error) {
+	target := filepath.Join(d.dir, quarantineDir, collection, ID+".json")
+	if err := d.fs.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+
+	d.expectChange(path)
+	if err := d.fs.Rename(path, target); err != nil {
+		d.expectedChange(path)
+		return "", err
+	}
+
+	d.handles.evict(path)
+
+	return target, nil
+}
+
+// verifyOnOpen runs Verify for New, logging every bad record
+func (d *Driver) verifyOnOpen(opts VerifyOptions) error {
+	bad, err := d.verify(opts)
+	if err != nil {
+		return fmt.Errorf("verifying records: %w", err)
+	}
+
+	for _, r := range bad {
+		if r.Quarantined != "" {
+			d.log.Warn("quarantined %s/%s to %s: %s", r.Collection, r.ID, r.Quarantined, r.Err)
+			continue
+		}
+
+		d.log.Warn("bad record %s", r)
+	}
+
+	if len(bad) > 0 && opts.Fail {
+		return fmt.Errorf("%d bad records, first %s: %w", len(bad), bad[0], ErrCorrupt)
+	}
+
+	return nil
+}
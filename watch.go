@@ -0,0 +1,305 @@
+package jdb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchBuffer is how many events a subscriber can lag behind before events
+// get dropped for it
+const watchBuffer = 64
+
+type (
+	// Op is the kind of change an Event describes
+	Op int
+
+	// Event describes a change to a record, External is set when the change
+	// was made on disk outside of the Driver
+	Event struct {
+		Collection string
+		ID         string
+		Op         Op
+		External   bool
+		Time       time.Time
+	}
+
+	subscriber struct {
+		collection string
+		ch         chan Event
+	}
+)
+
+const (
+	OpWrite Op = iota + 1
+	OpDelete
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpWrite:
+		return "write"
+	case OpDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Watch subscribes to the changes of a collection, or of every collection
+// when it's empty. Events that can't be delivered because the subscriber is
+// lagging are dropped, call the returned func to unsubscribe
+func (d *Driver) Watch(collection string) (<-chan Event, func()) {
+	sub := &subscriber{collection: collection, ch: make(chan Event, watchBuffer)}
+
+	d.mutex.Lock()
+	d.subscribers[sub] = struct{}{}
+	d.mutex.Unlock()
+
+	cancel := func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+
+		if _, ok := d.subscribers[sub]; ok {
+			delete(d.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+
+	return sub.ch, cancel
+}
+
+func (d *Driver) emit(e Event) {
+	e.Time = d.clock.Now()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for sub := range d.subscribers {
+		if sub.collection != "" && sub.collection != e.Collection {
+			continue
+		}
+
+		select {
+		case sub.ch <- e:
+		default:
+			d.log.Warn("dropping %s event of %s/%s, watcher is lagging", e.Op, e.Collection, e.ID)
+		}
+	}
+}
+
+// expectChange marks a path the Driver is about to change so the file
+// watcher doesn't report it as an external edit
+func (d *Driver) expectChange(path string) {
+	if d.watcher == nil {
+		return
+	}
+
+	d.mutex.Lock()
+	d.expected[path]++
+	d.mutex.Unlock()
+}
+
+func (d *Driver) expectedChange(path string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.expected[path] == 0 {
+		return false
+	}
+
+	if d.expected[path]--; d.expected[path] == 0 {
+		delete(d.expected, path)
+	}
+
+	return true
+}
+
+// watchFiles starts watching the data directory for changes made outside of
+// the Driver, refreshing bloom filters and indexes and emitting events
+func (d *Driver) watchFiles() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	d.watcher = w
+
+	if err := d.watchTree(d.dir); err != nil {
+		w.Close()
+		d.watcher = nil
+		return err
+	}
+
+	d.loops.Add(1)
+	go d.watchLoop(w)
+
+	return nil
+}
+
+func (d *Driver) watchTree(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		return d.watcher.Add(path)
+	})
+}
+
+func (d *Driver) watchLoop(w *fsnotify.Watcher) {
+	defer d.loops.Done()
+
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+
+			d.handleFileEvent(event)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+
+			d.log.Error("watching %s: %s", d.dir, err)
+		}
+	}
+}
+
+func (d *Driver) handleFileEvent(event fsnotify.Event) {
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := d.watchTree(event.Name); err != nil {
+				d.log.Error("watching %s: %s", event.Name, err)
+			}
+			return
+		}
+	}
+
+	if !strings.HasSuffix(event.Name, ".json") || d.expectedChange(event.Name) {
+		return
+	}
+
+	d.handles.evict(event.Name)
+
+	rel, err := filepath.Rel(d.dir, event.Name)
+	if err != nil {
+		return
+	}
+
+	collection := filepath.ToSlash(filepath.Dir(rel))
+	ID := strings.TrimSuffix(filepath.Base(rel), ".json")
+
+	// reserved directories like _history hold copies, not records, and
+	// hidden ones aren't collections, see validateStoredCollection
+	if collection == "." || validateStoredCollection(collection) != nil {
+		return
+	}
+
+	mutex := d.getMutex(collection)
+	mutex.Lock()
+
+	op := OpWrite
+	err = d.readFile(collection, event.Name, func(b []byte) error {
+		d.addBloom(collection, ID)
+		d.indexRecord(collection, ID, b)
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		op = OpDelete
+		d.unindexRecord(collection, ID)
+	}
+
+	mutex.Unlock()
+
+	if err != nil && op != OpDelete {
+		d.log.Error("reading externally changed %s: %s", event.Name, err)
+		return
+	}
+
+	d.log.Debug("external %s of %s/%s", op, collection, ID)
+	d.emit(Event{Collection: collection, ID: ID, Op: op, External: true})
+}
+
+// stop ends the goroutines of the Driver and waits for them, it doesn't
+// resign the leadership so a Driver failing to open keeps it until its lease
+// expires
+func (d *Driver) stop() error {
+	d.closeOnce.Do(func() {
+		close(d.done)
+	})
+
+	var err error
+	if d.watcher != nil {
+		err = d.watcher.Close()
+	}
+
+	d.loops.Wait()
+
+	return err
+}
+
+// abort tears down what New started before failing with err
+func (d *Driver) abort(err error) error {
+	d.async.close()
+
+	if serr := d.stop(); serr != nil {
+		d.log.Warn("stopping after failing to open: %s", serr)
+	}
+
+	d.handles.close()
+
+	if eerr := d.engines.close(); eerr != nil {
+		d.log.Warn("closing engines after failing to open: %s", eerr)
+	}
+
+	return err
+}
+
+// Close finishes the writes queued by WriteAsync, saves the indexes, stops
+// the background work of the Driver and ends every Watch subscription
+func (d *Driver) Close() error {
+	d.async.close()
+	d.coalesced.flush(d, "")
+
+	err := d.SaveIndexes()
+
+	if serr := d.stop(); err == nil {
+		err = serr
+	}
+
+	if d.leaseTerm > 0 {
+		if rerr := d.resign(); err == nil {
+			err = rerr
+		}
+	}
+
+	d.handles.close()
+
+	if eerr := d.engines.close(); err == nil {
+		err = eerr
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for sub := range d.subscribers {
+		delete(d.subscribers, sub)
+		close(sub.ch)
+	}
+
+	return err
+}
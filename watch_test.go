@@ -0,0 +1,94 @@
+package jdb_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arham09/jdb"
+	"github.com/arham09/jdb/jdbtest"
+)
+
+func TestExternalChangesOfReservedDirsAreIgnored(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	d := jdbtest.Open(t, dir, jdb.WithWatchFiles())
+
+	for _, c := range []string{"users", "_history/users"} {
+		if err := os.MkdirAll(filepath.Join(dir, c), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// let the watcher pick the new directories up
+	time.Sleep(50 * time.Millisecond)
+
+	events, cancel := d.Watch("")
+	defer cancel()
+
+	for _, c := range []string{"_history/users", "users"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, c, "x.json"), []byte(`{}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeout := time.After(2 * time.Second)
+
+	for {
+		select {
+		case e := <-events:
+			if e.Collection != "users" || !e.External {
+				t.Fatalf("got %+v, want only external events of users", e)
+			}
+
+			if e.Op == jdb.OpWrite {
+				return
+			}
+		case <-timeout:
+			t.Fatal("no event for the external write to users")
+		}
+	}
+}
+
+// failingRemove is the OS storage failing to remove records
+type failingRemove struct {
+	jdb.Storage
+}
+
+var errRemove = errors.New("remove failed")
+
+func (s failingRemove) RemoveAll(path string) error {
+	if strings.HasSuffix(path, ".json") {
+		return errRemove
+	}
+
+	return s.Storage.RemoveAll(path)
+}
+
+func TestFailedRemoveKeepsTheRecord(t *testing.T) {
+	d := jdbtest.New(t, jdb.WithStorage(failingRemove{jdb.OSStorage}))
+
+	if _, err := d.Write("users", "u", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	events, cancel := d.Watch("users")
+	defer cancel()
+
+	if err := d.Delete("users", "u"); !errors.Is(err, errRemove) {
+		t.Fatalf("Delete = %v, want the remove error", err)
+	}
+
+	select {
+	case e := <-events:
+		t.Errorf("the failed delete emitted %+v", e)
+	default:
+	}
+
+	if ok, err := d.Exists("users", "u"); err != nil || !ok {
+		t.Errorf("the record is gone after the failed delete: %v", err)
+	}
+}